@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/subtle"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * 管理者用エンドポイントの認証
+ * ADMIN_TOKENと一致するトークンを持つリクエストだけを通す
+ */
+
+/**
+ * リクエストから管理者トークンを取り出す
+ * X-Admin-Tokenヘッダー、またはAuthorization: Bearer のどちらでも受け付ける
+ */
+func extractAdminToken(c *gin.Context) string {
+	if token := c.GetHeader("X-Admin-Token"); token != "" {
+		return token
+	}
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
+
+/**
+ * 管理者トークンが正しいか判定
+ * ADMIN_TOKENが未設定の場合は常にfalse（管理機能は無効）
+ */
+func isAdminRequest(c *gin.Context) bool {
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(extractAdminToken(c)), []byte(adminToken)) == 1
+}
+
+/**
+ * 管理者用エンドポイントのミドルウェア
+ */
+func requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if os.Getenv("ADMIN_TOKEN") == "" {
+			c.AbortWithStatusJSON(403, gin.H{"error": "Admin API is disabled"})
+			return
+		}
+		if !isAdminRequest(c) {
+			c.AbortWithStatusJSON(401, gin.H{"error": "Invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * AI APIの呼び出しモジュール
+ * どのプロバイダーも同じHTTPクライアントを使い、次をまとめて行う
+ *  - タイムアウト（1回の呼び出しごとにcontextで区切る）
+ *  - 一時的なエラー（429・5xx・通信エラー・タイムアウト）の再試行。Retry-Afterがあればそれに従う
+ *  - サーキットブレーカー（失敗が続いたら、しばらくAPIを呼ばずにすぐエラーを返す）
+ *  - エラーの種類の判別（利用上限・レート制限・タイムアウト・停止中・不正な応答）
+ */
+
+// AI APIのエラーの種類
+const (
+	AIErrorQuota       = "ai_quota_exceeded" // 利用上限・残高不足（待っても回復しない）
+	AIErrorRateLimited = "ai_rate_limited"   // 短時間に呼びすぎ（待てば回復する）
+	AIErrorTimeout     = "ai_timeout"        // 応答が時間内に返らなかった
+	AIErrorUnavailable = "ai_unavailable"    // 5xx・通信エラー・サーキットブレーカーが開いている
+	AIErrorBadResponse = "ai_bad_response"   // 応答を読み取れない・リクエストが受け付けられない
+)
+
+// AI APIの呼び出しエラー
+type AIError struct {
+	Kind       string        // エラーの種類（AIError*）
+	StatusCode int           // APIのHTTPステータス（通信エラーなどの場合は0）
+	RetryAfter time.Duration // 再試行できるまでの時間（わかる場合のみ）
+	Err        error         // 元のエラー
+}
+
+func (e *AIError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AIError) Unwrap() error {
+	return e.Err
+}
+
+// AI APIの呼び出し設定
+type AIClientConfig struct {
+	Timeout          time.Duration // 1回の呼び出しのタイムアウト
+	StreamTimeout    time.Duration // ストリーミングの呼び出しのタイムアウト（読み終わるまで）
+	MaxRetries       int           // 再試行の最大回数
+	RetryBaseDelay   time.Duration // 1回目の再試行までの待ち時間（以降は倍々）
+	RetryMaxDelay    time.Duration // 1回の待ち時間の上限（Retry-Afterがこれより長ければ再試行しない）
+	BreakerThreshold int           // 続けて失敗したらブレーカーを開く回数
+	BreakerCooldown  time.Duration // ブレーカーを開いておく時間
+}
+
+// 接続先ごとのサーキットブレーカー
+type circuitBreaker struct {
+	failures  int       // 続けて失敗した回数
+	openUntil time.Time // この時刻まではAPIを呼ばない
+	probing   bool      // 開いている時間が過ぎたあと、試しに1回だけ呼んでいる最中
+}
+
+var (
+	// すべてのプロバイダーで使うHTTPクライアント
+	// 全体のタイムアウトは呼び出しごとのcontextで決めるので、ここでは接続までのタイムアウトだけ設定する
+	aiHTTPClient = &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 60 * time.Second,
+			MaxIdleConnsPerHost:   10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+	}
+
+	aiBreakersMu sync.Mutex
+	aiBreakers   = map[string]*circuitBreaker{}
+)
+
+/**
+ * 環境変数からAI APIの呼び出し設定を読み込む
+ */
+func LoadAIClientConfig() AIClientConfig {
+	return AIClientConfig{
+		Timeout:          envDuration("AI_TIMEOUT", 30*time.Second),
+		StreamTimeout:    envDuration("AI_STREAM_TIMEOUT", 2*time.Minute),
+		MaxRetries:       envNonNegativeInt("AI_MAX_RETRIES", 2),
+		RetryBaseDelay:   envDuration("AI_RETRY_BASE_DELAY", 500*time.Millisecond),
+		RetryMaxDelay:    envDuration("AI_RETRY_MAX_DELAY", 10*time.Second),
+		BreakerThreshold: envInt("AI_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:  envDuration("AI_BREAKER_COOLDOWN", 30*time.Second),
+	}
+}
+
+/**
+ * 環境変数をGoのduration形式で読む（読めなければデフォルト）
+ */
+func envDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnvWithDefault(key, defaultValue.String()))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+/**
+ * 環境変数を0以上の整数として読む（読めなければデフォルト）
+ */
+func envNonNegativeInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnvWithDefault(key, strconv.Itoa(defaultValue)))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+/**
+ * AI APIを呼び出し、200のレスポンスを返す
+ * newRequestは再試行のたびに呼ばれる（ボディを読み直せるように毎回作る）
+ * 返したレスポンスのボディを読み終えたら、cancelを呼ぶこと
+ * @param breakerKey サーキットブレーカーの単位（接続先のURL）
+ * @param timeout 1回の呼び出しのタイムアウト（ボディを読み終えるまで）
+ */
+func doAIRequest(ctx context.Context, breakerKey string, timeout time.Duration, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, context.CancelFunc, error) {
+	config := LoadAIClientConfig()
+	if err := allowAIRequest(breakerKey, config); err != nil {
+		return nil, nil, err
+	}
+
+	var lastErr *AIError
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		resp, err := sendAIRequest(attemptCtx, newRequest)
+		if err == nil {
+			recordAIResult(breakerKey, config, nil, true)
+			return resp, cancel, nil
+		}
+		cancel()
+		lastErr = err
+
+		// 呼び出し元がキャンセルした・時間切れになった場合はそれ以上待たない
+		if ctx.Err() != nil || !isRetryableAIError(err) || attempt >= config.MaxRetries {
+			break
+		}
+		wait := retryDelay(config, attempt, err.RetryAfter)
+		if wait < 0 {
+			break
+		}
+		log.Printf("AI API call failed, retrying in %v (%d/%d): %v", wait, attempt+1, config.MaxRetries, err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	// 呼び出し元の都合で打ち切った場合は、APIの失敗として数えない
+	if ctx.Err() != nil {
+		recordAIResult(breakerKey, config, nil, false)
+		return nil, nil, lastErr
+	}
+	recordAIResult(breakerKey, config, lastErr, true)
+	return nil, nil, lastErr
+}
+
+/**
+ * 1回分の呼び出しを行い、200以外はエラーの種類を判別して返す
+ */
+func sendAIRequest(ctx context.Context, newRequest func(ctx context.Context) (*http.Request, error)) (*http.Response, *AIError) {
+	req, err := newRequest(ctx)
+	if err != nil {
+		return nil, &AIError{Kind: AIErrorBadResponse, Err: err}
+	}
+
+	resp, err := aiHTTPClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || isNetTimeout(err) {
+			return nil, &AIError{Kind: AIErrorTimeout, Err: fmt.Errorf("AI API call timed out: %v", err)}
+		}
+		return nil, &AIError{Kind: AIErrorUnavailable, Err: fmt.Errorf("AI API call failed: %v", err)}
+	}
+	if resp.StatusCode == 200 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return nil, classifyAIStatus(resp.StatusCode, resp.Header.Get("Retry-After"), string(body))
+}
+
+/**
+ * 200以外のステータスをエラーの種類に振り分ける
+ */
+func classifyAIStatus(status int, retryAfter, body string) *AIError {
+	aiErr := &AIError{
+		StatusCode: status,
+		RetryAfter: parseRetryAfter(retryAfter, time.Now()),
+		Err:        fmt.Errorf("AI API error: status %d, body: %s", status, body),
+	}
+	lower := strings.ToLower(body)
+	switch {
+	case status == 402 || (status == 429 && strings.Contains(lower, "quota") && !strings.Contains(lower, "per minute") && !strings.Contains(lower, "perminute")):
+		// OpenRouterの残高不足は402、OpenAIの枠切れは429のinsufficient_quota
+		// Geminiは1分あたりの上限でもquotaと返すので、それはレート制限として扱う
+		aiErr.Kind = AIErrorQuota
+	case status == 429:
+		aiErr.Kind = AIErrorRateLimited
+	case status == 408 || status == 504:
+		aiErr.Kind = AIErrorTimeout
+	case status >= 500:
+		aiErr.Kind = AIErrorUnavailable
+	default:
+		aiErr.Kind = AIErrorBadResponse
+	}
+	return aiErr
+}
+
+/**
+ * Retry-Afterヘッダーを読む（秒数とHTTP日付の両方に対応、読めなければ0）
+ */
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+/**
+ * 再試行して回復する見込みのあるエラーかどうか
+ */
+func isRetryableAIError(err *AIError) bool {
+	switch err.Kind {
+	case AIErrorRateLimited, AIErrorTimeout, AIErrorUnavailable:
+		return true
+	default:
+		return false
+	}
+}
+
+/**
+ * 次の再試行までの待ち時間（指数バックオフ、±20%のゆらぎ付き）
+ * Retry-Afterがあればそれより短くはしない。上限を超える場合は-1（再試行しない）
+ */
+func retryDelay(config AIClientConfig, attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > config.RetryMaxDelay {
+		return -1
+	}
+	delay := config.RetryBaseDelay << attempt
+	delay += time.Duration((rand.Float64()*0.4 - 0.2) * float64(delay))
+	if delay > config.RetryMaxDelay {
+		delay = config.RetryMaxDelay
+	}
+	if delay < retryAfter {
+		delay = retryAfter
+	}
+	return delay
+}
+
+/**
+ * 通信エラーがタイムアウトによるものかどうか
+ */
+func isNetTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+/**
+ * サーキットブレーカーが開いていればエラーを返す
+ * 開いている時間が過ぎたら、試しに1回だけ通す（その結果で閉じるか開き直すかを決める）
+ */
+func allowAIRequest(key string, config AIClientConfig) *AIError {
+	aiBreakersMu.Lock()
+	defer aiBreakersMu.Unlock()
+
+	b := aiBreakers[key]
+	if b == nil || b.openUntil.IsZero() {
+		return nil
+	}
+	now := time.Now()
+	if now.Before(b.openUntil) || b.probing {
+		retryAfter := b.openUntil.Sub(now)
+		if retryAfter < time.Second {
+			retryAfter = time.Second
+		}
+		return &AIError{
+			Kind:       AIErrorUnavailable,
+			RetryAfter: retryAfter,
+			Err:        fmt.Errorf("AI API circuit breaker is open after %d consecutive failures", b.failures),
+		}
+	}
+	b.probing = true
+	return nil
+}
+
+/**
+ * 呼び出し結果をサーキットブレーカーに反映する
+ * APIが止まっていることを示すエラー（5xx・通信エラー・タイムアウト）だけを失敗として数える
+ * @param completed falseなら結果を数えず、試しの呼び出し中の印だけ外す
+ */
+func recordAIResult(key string, config AIClientConfig, err *AIError, completed bool) {
+	aiBreakersMu.Lock()
+	defer aiBreakersMu.Unlock()
+
+	b := aiBreakers[key]
+	if b == nil {
+		b = &circuitBreaker{}
+		aiBreakers[key] = b
+	}
+	b.probing = false
+	if !completed {
+		return
+	}
+
+	if err == nil || (err.Kind != AIErrorUnavailable && err.Kind != AIErrorTimeout) {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+
+	b.failures++
+	if b.failures >= config.BreakerThreshold {
+		b.openUntil = time.Now().Add(config.BreakerCooldown)
+		log.Printf("AI API circuit breaker opened for %v (%s, %d consecutive failures)", config.BreakerCooldown, key, b.failures)
+	}
+}
+
+/**
+ * AIのエラーをHTTPステータスに変換する
+ * 利用上限・レート制限は429、停止中は503、タイムアウトは504、不正な応答は502、それ以外は500
+ */
+func aiErrorStatus(err error) int {
+	var aiErr *AIError
+	if !errors.As(err, &aiErr) {
+		return 500
+	}
+	switch aiErr.Kind {
+	case AIErrorQuota, AIErrorRateLimited:
+		return 429
+	case AIErrorUnavailable:
+		return 503
+	case AIErrorTimeout:
+		return 504
+	case AIErrorBadResponse:
+		return 502
+	default:
+		return 500
+	}
+}
+
+/**
+ * AIのエラーをレスポンスとして書き込む
+ * 待てば回復するエラーにはRetry-Afterヘッダーを付ける
+ */
+func writeAIError(c *gin.Context, err error) {
+	status := aiErrorStatus(err)
+	body := gin.H{"error": describeAIError(err), "detail": err.Error()}
+
+	var aiErr *AIError
+	if errors.As(err, &aiErr) {
+		body["code"] = aiErr.Kind
+		if aiErr.RetryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(aiErr.RetryAfter)))
+		}
+	}
+	c.JSON(status, body)
+}
+
+/**
+ * Retry-Afterに入れる秒数（切り上げ）
+ */
+func retryAfterSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -9,6 +10,7 @@ import (
 /**
  * 全案件一覧表示機能
  * データベース内の全案件を取得するAPIを提供
+ * クエリパラメーター（min_price・max_price・work_style・period・source・exclude_source・posted_since・exclude_skill・exclude_keyword）で絞り込める
  */
 
 // 全案件取得のレスポンス構造体
@@ -23,14 +25,26 @@ type AllProjectsResponse struct {
  * データベースから全案件を取得して返す
  */
 func getAllProjects(c *gin.Context) {
-	// データベースから全案件を取得
+	filter, err := filterFromQuery(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	// データベースから全案件を取得（絞り込み条件はすべてSQLで。単価はproject_price_matchesで比べる）
+	where := ""
+	conditions, args := filter.sqlConditions(nil)
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
 	query := `
 		SELECT prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt
 		FROM tbl_project
+		` + where + `
 		ORDER BY procrt DESC
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		log.Printf("Database query failed: %v", err)
 		c.JSON(500, gin.H{"error": "Database query failed"})
@@ -67,6 +81,11 @@ func getAllProjects(c *gin.Context) {
 			p.Period = ""
 		}
 
+		// SQLと同じ判定をGoでも行う（SQLの正規表現と読み方がずれても範囲外の案件を返さないため。読み取れない単価の案件は残す）
+		if r, ok := parseSalaryRange(p.Price); ok && !filter.matchesPrice(Project{PriceRange: &r}) {
+			continue
+		}
+
 		projects = append(projects, p)
 	}
 
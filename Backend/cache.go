@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * AI分析結果のキャッシュモジュール
+ * 同じスキルシートを何度も送ったときにOpenRouterの枠を消費しないよう、
+ * 分析結果をtbl_aicacheに保存して使い回す
+ */
+
+// キャッシュ設定の構造体
+type CacheConfig struct {
+	Enabled bool          // キャッシュを使うかどうか
+	TTL     time.Duration // キャッシュの有効期間
+}
+
+/**
+ * 環境変数からキャッシュ設定を読み込む
+ * @return CacheConfig キャッシュ設定
+ */
+func LoadCacheConfig() CacheConfig {
+	enabled, err := strconv.ParseBool(getEnvWithDefault("AI_CACHE_ENABLED", "true"))
+	if err != nil {
+		enabled = true
+	}
+
+	ttl, err := time.ParseDuration(getEnvWithDefault("AI_CACHE_TTL", "24h"))
+	if err != nil || ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	return CacheConfig{Enabled: enabled, TTL: ttl}
+}
+
+/**
+ * キャッシュキー用にメッセージを正規化
+ * 全角英数字・全角スペースを半角に寄せ、大文字小文字と空白の揺れを吸収する
+ */
+func normalizeMessage(message string) string {
+	var sb strings.Builder
+	for _, r := range message {
+		switch {
+		case r == '　':
+			r = ' '
+		case r >= '！' && r <= '～':
+			r = r - '！' + '!'
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+/**
+ * キャッシュキーを生成
+ * 正規化メッセージ・モデル・プロンプトバージョンのいずれかが変われば別キーになる
+ */
+func buildCacheKey(message, model, promptVersion string) string {
+	sum := sha256.Sum256([]byte(normalizeMessage(message) + "\x00" + model + "\x00" + promptVersion))
+	return hex.EncodeToString(sum[:])
+}
+
+/**
+ * キャッシュから分析結果を取得
+ * @return AIAnalysis キャッシュされた分析結果
+ * @return bool キャッシュヒットしたかどうか
+ * @return error エラー情報
+ */
+func getCachedAnalysis(key string) (AIAnalysis, bool, error) {
+	var raw []byte
+	err := db.QueryRow(
+		"SELECT aicanl FROM tbl_aicache WHERE aickey = $1 AND aicexp > NOW()",
+		key,
+	).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return AIAnalysis{}, false, nil
+	}
+	if err != nil {
+		return AIAnalysis{}, false, fmt.Errorf("cache lookup failed: %v", err)
+	}
+
+	var analysis AIAnalysis
+	if err := json.Unmarshal(raw, &analysis); err != nil {
+		return AIAnalysis{}, false, fmt.Errorf("failed to decode cached analysis: %v", err)
+	}
+
+	return analysis, true, nil
+}
+
+/**
+ * 分析結果をキャッシュに保存（同じキーがあれば上書き）
+ */
+func saveCachedAnalysis(key, model, promptVersion string, analysis AIAnalysis, ttl time.Duration) error {
+	raw, err := json.Marshal(analysis)
+	if err != nil {
+		return fmt.Errorf("failed to encode analysis: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO tbl_aicache (aickey, aicmdl, aicprv, aicanl, aiccrt, aicexp)
+		VALUES ($1, $2, $3, $4, NOW(), $5)
+		ON CONFLICT (aickey) DO UPDATE
+		SET aicmdl = EXCLUDED.aicmdl, aicprv = EXCLUDED.aicprv, aicanl = EXCLUDED.aicanl,
+			aiccrt = EXCLUDED.aiccrt, aicexp = EXCLUDED.aicexp
+	`, key, model, promptVersion, raw, time.Now().Add(ttl))
+	if err != nil {
+		return fmt.Errorf("cache save failed: %v", err)
+	}
+
+	return nil
+}
+
+// 20261016 同じスキルシートを何回も投げるとすぐ枠が尽きるのでキャッシュを挟んだ。
+/**
+ * キャッシュを考慮してスキル解析を行う
+ * キャッシュにあればAIを呼ばずに返す。キャッシュの障害時はAIを直接呼ぶ
+ * @return AIAnalysis 分析結果
+ * @return bool キャッシュヒットしたかどうか
+ * @return error エラー情報
+ */
+func analyzeSkillsCached(message string) (AIAnalysis, bool, error) {
+	return analyzeSkillsCachedStream(context.Background(), message, nil)
+}
+
+/**
+ * キャッシュを考慮してスキル解析を行う（ストリーミング対応版）
+ * キャッシュヒット時もonFieldには全フィールドを通知する
+ */
+func analyzeSkillsCachedStream(ctx context.Context, message string, onField analysisFieldHandler) (AIAnalysis, bool, error) {
+	config := LoadCacheConfig()
+	if !config.Enabled || db == nil {
+		analysis, err := analyzeSkillsStream(ctx, message, onField)
+		return analysis, false, err
+	}
+
+	model := currentLLMModel()
+	promptVersion := activePromptVersion(PromptAnalysis)
+	key := buildCacheKey(message, model, promptVersion)
+
+	analysis, hit, err := getCachedAnalysis(key)
+	if err != nil {
+		log.Printf("AI cache error: %v", err)
+	} else if hit {
+		emitAnalysisFields(analysis, onField)
+		return analysis, true, nil
+	}
+
+	analysis, err = analyzeSkillsStream(ctx, message, onField)
+	if err != nil {
+		return AIAnalysis{}, false, err
+	}
+
+	if err := saveCachedAnalysis(key, model, promptVersion, analysis, config.TTL); err != nil {
+		log.Printf("AI cache error: %v", err)
+	}
+
+	return analysis, false, nil
+}
+
+/**
+ * キャッシュ削除の管理者用ハンドラー
+ * ?key= 指定でそのキーのみ、?expired=true で期限切れのみ、指定なしで全件削除
+ */
+func handlePurgeCache(c *gin.Context) {
+	query := "DELETE FROM tbl_aicache"
+	var args []interface{}
+
+	if key := c.Query("key"); key != "" {
+		query += " WHERE aickey = $1"
+		args = append(args, key)
+	} else if c.Query("expired") == "true" {
+		query += " WHERE aicexp <= NOW()"
+	}
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		log.Printf("Cache purge failed: %v", err)
+		c.JSON(500, gin.H{"error": "Cache purge failed"})
+		return
+	}
+
+	deleted, _ := result.RowsAffected()
+	c.JSON(200, gin.H{"deleted": deleted})
+}
@@ -1,14 +1,14 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"math"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/gin-contrib/cors"
@@ -19,9 +19,15 @@ import (
 
 // AI API用の構造体定義
 type AIChatRequest struct {
-	Model       string          `json:"model"`
-	Messages    []AIChatMessage `json:"messages"`
-	Temperature float64         `json:"temperature"`
+	Model         string               `json:"model"`
+	Messages      []AIChatMessage      `json:"messages"`
+	Temperature   float64              `json:"temperature"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *AIChatStreamOptions `json:"stream_options,omitempty"`
+}
+
+type AIChatStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type AIChatMessage struct {
@@ -30,9 +36,11 @@ type AIChatMessage struct {
 }
 
 type AIChatResponse struct {
+	Model   string `json:"model"`
 	Choices []struct {
 		Message AIChatMessage `json:"message"`
 	} `json:"choices"`
+	Usage *LLMUsage `json:"usage"`
 }
 
 /**
@@ -57,236 +65,428 @@ func handleChat(c *gin.Context) {
 		return
 	}
 
-	// AIでスキル解析
-	aiAnalysis, err := analyzeSkills(req.Message)
-	if err != nil {
-		log.Printf("AI API error: %v", err)
-		errorMsg := err.Error()
-		// クォータエラーの場合、より分かりやすいメッセージに変換
-		if strings.Contains(errorMsg, "quota") || strings.Contains(errorMsg, "429") || strings.Contains(errorMsg, "insufficient_quota") {
-			c.JSON(500, gin.H{"error": "AI APIの利用上限に達しました。しばらく時間をおいてから再度お試しください。", "detail": errorMsg})
-		} else {
-			c.JSON(500, gin.H{"error": "AI分析に失敗しました。もう一度お試しください。", "detail": errorMsg})
-		}
-		return
+	if response, ok := processChatRequest(c, req); ok {
+		c.JSON(200, response)
+	}
+}
+
+/**
+ * チャットリクエストを解析・検索してレスポンスを組み立てる（/api/chat と /api/resume の共通処理）
+ * 失敗した場合はエラーレスポンスを書き込んでfalseを返す
+ */
+func processChatRequest(c *gin.Context, req ChatRequest) (ChatResponse, bool) {
+	pipeline, ok := prepareChatPipeline(c, req)
+	if !ok {
+		return ChatResponse{}, false
 	}
 
-	// データベースから関連案件を検索（key_skillsを優先）
-	projects, err := searchProjectsWithPriority(aiAnalysis.KeySkills, aiAnalysis.StructuredSkills)
+	response, err := pipeline.run(c.Request.Context(), nil)
 	if err != nil {
-		log.Printf("Database search error: %v", err)
-		c.JSON(500, gin.H{"error": "Database search failed: " + err.Error()})
-		return
+		var searchErr *chatSearchError
+		if errors.As(err, &searchErr) {
+			log.Printf("Database search error: %v", searchErr.err)
+			c.JSON(500, gin.H{"error": "Database search failed: " + searchErr.err.Error()})
+			return ChatResponse{}, false
+		}
+		log.Printf("AI API error: %v", err)
+		writeAIError(c, err)
+		return ChatResponse{}, false
 	}
+	return response, true
+}
 
-	// レスポンスを返す
-	c.JSON(200, ChatResponse{
-		AIAnalysis: aiAnalysis,
-		Projects:   projects,
-	})
+// 20261017 /api/chatとストリーミング版で同じ前処理を2か所に書いていたので、1つにまとめた。
+// チャットの解析・検索の流れ（/api/chat・/api/resume・/api/chat/streamの共通処理）
+type chatPipeline struct {
+	req           ChatRequest
+	analyzer      string
+	searchMode    string
+	ranking       RankingProfile
+	inputWarnings []string
+	session       *Session
 }
 
-// 20251227 AI呼び出し処理を実装した。プロンプト設計が意外と時間かかった。JSON強制するのが肝だった。
-/**
- * AIを使ってスキル情報を解析
- * OpenAI互換のAPIを使用
- */
-func analyzeSkills(message string) (AIAnalysis, error) {
-	apiKey := os.Getenv("OPENROUTER_API_KEY")
-	if apiKey == "" {
-		return AIAnalysis{}, fmt.Errorf("OPENROUTER_API_KEY not set")
-	}
-
-	// プロンプトの作成
-	systemPrompt := `あなたはIT案件マッチングの専門家です。ユーザーのスキルシート情報を深く分析し、案件検索に最適なJSON形式で回答してください。
-
-以下の形式でJSONを返してください（他の説明文は含めないでください）:
-{
-  "estimated_salary": "月額XX万円〜XX万円",
-  "strengths": "具体的な強みの説明",
-  "suggestions": "今後のキャリアアップの提案",
-  "structured_skills": [
-    {
-      "skill_name": "スキル名",
-      "experience_years": 年数
-    }
-  ],
-  "search_prompt": "案件検索用の最適化されたプロンプト",
-  "key_skills": ["最も重要なスキル1", "最も重要なスキル2", "最も重要なスキル3"],
-  "preferred_role": "最適な役割（例：フロントエンドエンジニア、フルスタック開発者、など）",
-  "experience_level": "初級/中級/上級/エキスパート のいずれか"
+// 案件検索で失敗したことを表すエラー（AI分析の失敗と返し方を分けるため）
+type chatSearchError struct {
+	err error
 }
 
-重要:
-- 必ず有効なJSONのみを返してください。Markdownのコードブロック（` + "```" + `json など）は含めないでください。
-- すべてのフィールドを必ず含めてください。
-`
+func (e *chatSearchError) Error() string {
+	return "database search failed: " + e.err.Error()
+}
 
-	reqBody := AIChatRequest{
-		Model: "openai/gpt-3.5-turbo",
-		Messages: []AIChatMessage{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: message},
-		},
-		Temperature: 0.7,
-	}
+func (e *chatSearchError) Unwrap() error {
+	return e.err
+}
 
-	jsonBody, err := json.Marshal(reqBody)
+/**
+ * リクエストの値を確認し、入力チェックとセッションの読み込みまで行う
+ * 失敗した場合はエラーレスポンスを書き込んでfalseを返す（ストリーミングを始める前に呼ぶ）
+ */
+func prepareChatPipeline(c *gin.Context, req ChatRequest) (*chatPipeline, bool) {
+	analyzer, err := resolveAnalyzer(req.Analyzer)
 	if err != nil {
-		return AIAnalysis{}, fmt.Errorf("failed to marshal request: %v", err)
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return nil, false
+	}
+	searchMode, err := resolveSearchMode(req.SearchMode)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return nil, false
+	}
+	ranking, status, err := resolveRankingProfile(c, req.RankingProfile)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err := req.Filters.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: invalid filters: " + err.Error()})
+		return nil, false
 	}
 
-	req, err := http.NewRequest("POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewBuffer(jsonBody))
+	// AIに渡す前にメッセージをチェック（バイナリ・指示文・長すぎる入力）
+	inputWarnings, ok := guardChatRequest(c, &req)
+	if !ok {
+		return nil, false
+	}
+
+	// 会話の続きなら前回の状態を読み込む
+	session, status, err := resolveSession(req)
 	if err != nil {
-		return AIAnalysis{}, fmt.Errorf("failed to create request: %v", err)
+		log.Printf("Session error: %v", err)
+		c.JSON(status, gin.H{"error": err.Error()})
+		return nil, false
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	return &chatPipeline{
+		req:           req,
+		analyzer:      analyzer,
+		searchMode:    searchMode,
+		ranking:       ranking,
+		inputWarnings: inputWarnings,
+		session:       session,
+	}, true
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+/**
+ * スキルを解析して案件を検索し、セッションに記録してレスポンスを組み立てる
+ * onFieldを渡すと、AIAnalysisのフィールドが確定するたびに呼び出される
+ * @return error AI分析のエラー、または案件検索のエラー（*chatSearchError）
+ */
+func (p *chatPipeline) run(ctx context.Context, onField analysisFieldHandler) (ChatResponse, error) {
+	// AIでスキル解析（会話の続きなら前回の結果を絞り込み、新規ならキャッシュを確認）
+	// 利用上限に達している場合はルールベースの簡易解析になる
+	outcome, err := analyzeChatRequest(ctx, p.session, p.req.Message, p.analyzer, onField)
 	if err != nil {
-		return AIAnalysis{}, fmt.Errorf("AI API call failed: %v", err)
+		return ChatResponse{}, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	aiAnalysis := outcome.Analysis
+
+	// データベースから関連案件を検索（key_skillsを優先、希望単価も加味）
+	searchParams := buildSearchParams(p.session, p.req.Message, aiAnalysis)
+	searchParams.SearchMode = p.searchMode
+	searchParams.Translate = p.req.Translate
+	searchParams.Ranking = &p.ranking
+	if p.req.ClearFilters {
+		// 前回までの条件は捨て、今回のメッセージの条件だけにする
+		searchParams.Filter = aiAnalysis.Filters
+	}
+	searchParams.Filter = mergeProjectFilters(p.req.Filters, searchParams.Filter)
+	projects, searched, err := searchProjectsForAnalysis(ctx, searchParams, outcome)
 	if err != nil {
-		return AIAnalysis{}, fmt.Errorf("failed to read response body: %v", err)
+		return ChatResponse{}, &chatSearchError{err: err}
 	}
 
-	if resp.StatusCode != 200 {
-		return AIAnalysis{}, fmt.Errorf("AI API error: status %d, body: %s", resp.StatusCode, string(body))
+	// 今回のやり取りをセッションに記録
+	sessionID := recordSessionTurn(p.session, p.req.Message, aiAnalysis, searchParams)
+
+	return ChatResponse{
+		AIAnalysis:    aiAnalysis,
+		Projects:      projects,
+		CacheHit:      outcome.CacheHit,
+		SessionID:     sessionID,
+		Analyzer:      outcome.Analyzer,
+		Degraded:      outcome.Analyzer == AnalyzerRule,
+		DesiredSalary: searchParams.DesiredSalary,
+		Reranked:      searched.Reranked,
+		PromptVersion: outcome.PromptVersion,
+		InputWarnings: p.inputWarnings,
+		SearchMode:    searched.SearchMode,
+		InputLanguage: detectLanguage(p.req.Message),
+		SearchTerms:   searchParams.SearchTerms,
+		Translated:    searched.Translated,
+		NextCursor:    searched.NextCursor,
+		Filters:       searchParams.Filter,
+
+		RankingProfile: p.ranking,
+	}, nil
+}
+
+/**
+ * AI分析のエラーをユーザー向けのメッセージに変換
+ * エラーの種類（aiclient.go）ごとに、待てばよいのかどうかがわかるメッセージにする
+ */
+func describeAIError(err error) string {
+	var aiErr *AIError
+	if !errors.As(err, &aiErr) {
+		return "AI分析に失敗しました。もう一度お試しください。"
+	}
+	switch aiErr.Kind {
+	case AIErrorQuota:
+		return "AI APIの利用上限に達しました。しばらく時間をおいてから再度お試しください。"
+	case AIErrorRateLimited:
+		return "AI APIへのリクエストが集中しています。少し待ってから再度お試しください。"
+	case AIErrorTimeout:
+		return "AI分析が時間内に終わりませんでした。もう一度お試しください。"
+	case AIErrorUnavailable:
+		return "AI APIに接続できません。しばらく時間をおいてから再度お試しください。"
+	default:
+		return "AI分析に失敗しました。もう一度お試しください。"
 	}
+}
+
+// 20251227 AI呼び出し処理を実装した。プロンプト設計が意外と時間かかった。JSON強制するのが肝だった。
+/**
+ * AIを使ってスキル情報を解析
+ * 実際の呼び出し先はLLM_PROVIDERで選択したプロバイダー（llm.go）
+ */
+func analyzeSkills(message string) (AIAnalysis, error) {
+	return analyzeSkillsStream(context.Background(), message, nil)
+}
 
-	var chatResp AIChatResponse
-	if err := json.Unmarshal(body, &chatResp); err != nil {
-		return AIAnalysis{}, fmt.Errorf("failed to parse AI response: %v", err)
+/**
+ * AIを使ってスキル情報を解析（ストリーミング対応版）
+ * onFieldを渡すと、返答のJSONからフィールドが確定するたびに呼び出される
+ * 修正ループで再試行した場合は、同じフィールドが再度通知されることがある
+ */
+func analyzeSkillsStream(ctx context.Context, message string, onField analysisFieldHandler) (AIAnalysis, error) {
+	systemPrompt, version, err := renderPrompt(PromptAnalysis, nil)
+	if err != nil {
+		return AIAnalysis{}, err
 	}
 
-	if len(chatResp.Choices) == 0 {
-		return AIAnalysis{}, fmt.Errorf("empty choices from AI")
+	ctx = withPromptVersion(withAIPurpose(ctx, AIPurposeAnalysis), version)
+	return runAnalysis(ctx, []AIChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: message},
+	}, onField)
+}
+
+/**
+ * 組み立て済みの会話でAIを呼び出し、スキーマ検証済みの分析結果を返す
+ * 初回の分析と、セッションでの絞り込み（session.go）の共通処理
+ */
+func runAnalysis(ctx context.Context, messages []AIChatMessage, onField analysisFieldHandler) (AIAnalysis, error) {
+	provider, err := getLLMProvider()
+	if err != nil {
+		return AIAnalysis{}, err
 	}
 
-	responseText := chatResp.Choices[0].Message.Content
+	// スキーマ違反の返答は検証エラーを添えてAIに差し戻し、決められた回数まで修正させる
+	repairAttempts := LoadLLMConfig().RepairAttempts
+	var validationErrors []string
+	for attempt := 0; attempt <= repairAttempts; attempt++ {
+		result, err := chatWithFields(ctx, provider, messages, onField)
+		if err != nil {
+			return AIAnalysis{}, err
+		}
 
-	// JSONのパース
-	var analysis AIAnalysis
-	// コードブロックを除去（念のため）
-	cleanedText := strings.TrimSpace(responseText)
-	cleanedText = strings.TrimPrefix(cleanedText, "```json")
-	cleanedText = strings.TrimPrefix(cleanedText, "```")
-	cleanedText = strings.TrimSuffix(cleanedText, "```")
-	cleanedText = strings.TrimSpace(cleanedText)
+		analysis, errs := parseAIAnalysis(result.Content)
+		if len(errs) == 0 {
+			return analysis, nil
+		}
 
-	if err := json.Unmarshal([]byte(cleanedText), &analysis); err != nil {
-		log.Printf("Failed to parse AI response. Raw: %s", responseText)
-		return AIAnalysis{}, fmt.Errorf("failed to parse AI JSON: %v", err)
+		validationErrors = errs
+		log.Printf("AI response failed schema validation (attempt %d/%d): %v. Raw: %s", attempt+1, repairAttempts+1, errs, result.Content)
+		messages = append(messages,
+			AIChatMessage{Role: "assistant", Content: result.Content},
+			AIChatMessage{Role: "user", Content: buildRepairMessage(errs)},
+		)
 	}
 
-	return analysis, nil
+	return AIAnalysis{}, fmt.Errorf("failed to parse AI JSON after %d repair attempts: %s", repairAttempts, strings.Join(validationErrors, "; "))
 }
 
 // 20260103 スコアリング方式の検索を実装した。key_skillsを優先する設計にした。年明けから本腰入れた。
 /**
  * データベースから案件を検索（key_skillsを優先、スコアリング方式）
  * 重点スキルにマッチする案件を優先的に検索し、サイトごとに均等に取得
+ * allSkillsのスキルも経験年数で重み付けして照合する
  */
 func searchProjectsWithPriority(keySkills []string, allSkills []Skill) ([]Project, error) {
-	if len(keySkills) == 0 && len(allSkills) == 0 {
-		return []Project{}, nil
+	return searchProjectsWithParams(SearchParams{KeySkills: keySkills, Skills: allSkills})
+}
+
+/**
+ * 検索条件を指定して案件を検索
+ * 候補を並べ替え、サイトごとの件数を制限して返す
+ */
+func searchProjectsWithParams(params SearchParams) ([]Project, error) {
+	params = withRankingProfile(params)
+	candidates, err := searchCandidates(params)
+	if err != nil {
+		return nil, err
+	}
+	return selectProjects(candidates, params.rankingProfile()), nil
+}
+
+/**
+ * 検索条件に合う候補を取り出す
+ * SQLでスキルのスコアが高い候補を取り出し、希望単価などを加味した並べ替えはGo側（ranking.go）で行う
+ */
+func searchCandidates(params SearchParams) ([]rankedProject, error) {
+	candidates, _, err := searchCandidateWindow(params)
+	return candidates, err
+}
+
+/**
+ * 検索条件に合う候補を取り出す（params.Afterがあればページ送りのカーソルより後ろだけ）
+ * @return bool SQLで取り出した候補がsearchCandidateLimit件、または1ページ目でいずれかのサイトが
+ *              candidatesPerSource件に達したか（まだ続きがあるかもしれない）
+ */
+func searchCandidateWindow(params SearchParams) ([]rankedProject, bool, error) {
+	primarySkills := weightedSearchSkills(params)
+	profile := params.rankingProfile()
+
+	// プライマリスキル（英語の入力なら日本語の検索語も）がない場合は検索しない
+	if len(primarySkills) == 0 {
+		return nil, false, nil
 	}
 
-	// key_skillsを優先的に使用（最大3個まで）
+	// 候補の取り出しはSEARCH_BACKENDのバックエンドで行う（採点・結果はどれも同じ）
+	backend, err := resolveSearchBackend()
+	if err != nil {
+		return nil, false, err
+	}
+	query := candidateQuery{Skills: primarySkills, Profile: profile, Filter: params.Filter, After: params.After}
+	projects, elapsed, err := queryCandidates(backend, query)
+	if err != nil {
+		return nil, false, err
+	}
+	log.Printf("Search candidates: backend=%s profile=%s skills=%v %d件 %v", backend, profile.Name, primarySkills, len(projects), elapsed)
+	compareSearchBackends(backend, query, projects, elapsed)
+
+	full := len(projects) >= searchCandidateLimit
+	if params.After == nil {
+		perSite := map[string]int{}
+		for _, p := range projects {
+			perSite[p.Source]++
+			full = full || perSite[p.Source] >= profile.candidatesPerSource()
+		}
+	}
+	return scoreCandidates(projects, primarySkills, params), full, nil
+}
+
+/**
+ * key_skillsを辞書の正式名にそろえ、検索に使う重点スキル（最大limit個）を選ぶ
+ * "Golang"と"Go"のような表記ゆれで枠を無駄にしないよう、重複は除いてから数える
+ */
+func primarySearchSkills(keySkills []string, limit int) []string {
 	var primarySkills []string
-	for i, skill := range keySkills {
-		if i >= 3 {
+	for i, skill := range getSkillDictionary().NormalizeSkills(keySkills) {
+		if i >= limit {
 			break
 		}
 		primarySkills = append(primarySkills, skill)
 	}
+	return primarySkills
+}
 
-	// プライマリスキルがない場合は検索しない
-	if len(primarySkills) == 0 {
-		return []Project{}, nil
-	}
-
-	// スコアリングクエリ：重点スキルにマッチする案件を優先
-	// 各スキルの出現回数とマッチしたスキル数をカウント
-	var scoreConditions []string
-	var matchCountConditions []string
-	var args []interface{}
-	argIndex := 1
-
-	for _, skill := range primarySkills {
-		// 各スキルに対して、タイトル/詳細/スキル欄での出現をスコア化
-		// タイトル: 5点、スキル欄: 3点、詳細: 1点
-		scoreCondition := fmt.Sprintf(`
-			(CASE WHEN prottl ILIKE $%d THEN 5 ELSE 0 END) +
-			(CASE WHEN proot1 ILIKE $%d THEN 3 ELSE 0 END) +
-			(CASE WHEN prodtl ILIKE $%d THEN 1 ELSE 0 END)
-		`, argIndex, argIndex+1, argIndex+2)
-		scoreConditions = append(scoreConditions, scoreCondition)
-
-		// マッチしたスキルの数をカウント（ボーナスポイント用）
-		matchCountCondition := fmt.Sprintf(`
-			(CASE WHEN prottl ILIKE $%d OR proot1 ILIKE $%d OR prodtl ILIKE $%d THEN 1 ELSE 0 END)
-		`, argIndex, argIndex+1, argIndex+2)
-		matchCountConditions = append(matchCountConditions, matchCountCondition)
-
-		args = append(args, "%"+skill+"%", "%"+skill+"%", "%"+skill+"%")
-		argIndex += 3
-	}
-
-	scoreSum := strings.Join(scoreConditions, " + ")
-	matchCountSum := strings.Join(matchCountConditions, " + ")
-
-	// 少なくとも1つのプライマリスキルにマッチする案件のみ取得
-	var whereConditions []string
-	for i := range primarySkills {
-		baseIndex := i * 3
-		whereCondition := fmt.Sprintf("(prottl ILIKE $%d OR proot1 ILIKE $%d OR prodtl ILIKE $%d)",
-			baseIndex+1, baseIndex+2, baseIndex+3)
-		whereConditions = append(whereConditions, whereCondition)
-	}
-	whereClause := strings.Join(whereConditions, " OR ")
-
-	// スコアリング＋サイト分散クエリ
-	// 改善点：
-	// 1. スコアが4以上の案件のみ（タイトルマッチまたは複数箇所マッチ）
-	// 2. 複数スキルマッチにボーナス（match_count * 2）
-	// 3. 各サイトから最大3件
-	// 4. 合計8件まで
-	query := fmt.Sprintf(`
-		WITH scored_projects AS (
-			SELECT
-				prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt,
-				(%s) + ((%s) * 2) as match_score,
-				(%s) as match_count
-			FROM tbl_project
-			WHERE %s
-		),
-		ranked_projects AS (
-			SELECT
-				prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt, match_score, match_count,
-				ROW_NUMBER() OVER (PARTITION BY prostn ORDER BY match_score DESC, match_count DESC, procrt DESC) as rn
-			FROM scored_projects
-			WHERE match_score >= 4
-		)
-		SELECT prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt
-		FROM ranked_projects
-		WHERE rn <= 3
-		ORDER BY match_score DESC, match_count DESC, procrt DESC
-		LIMIT 8
-	`, scoreSum, matchCountSum, matchCountSum, whereClause)
-
-	rows, err := db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("database query failed: %v", err)
+// 検索で照合するスキル1つ分
+type searchSkill struct {
+	Name   string  // スキル（辞書の正式名）または検索語
+	Weight float64 // 配点に掛ける重み（1以上）
+}
+
+/**
+ * 検索で照合するスキル（重点スキルの上位max_key_skills個 + 構造化スキル + 英語の入力から作った日本語の検索語）
+ */
+func searchSkillsForParams(params SearchParams) []string {
+	var names []string
+	for _, s := range weightedSearchSkills(params) {
+		names = append(names, s.Name)
 	}
-	defer rows.Close()
+	return names
+}
 
+/**
+ * 検索で照合するスキルに重みを付けて選ぶ
+ * 重点スキル → 構造化スキル（重みの高い順、重点スキルと合わせてmax_search_skills個まで）→ 検索語の順に並べる
+ * 重みは経験年数（max_yearsまで）と重点スキルかどうかで決まる（ランキングプロファイル）
+ * 構造化スキルがない（経験年数がわからない）場合は、これまでどおりすべて同じ重み（1）で照合する
+ * 構造化スキルは重点スキルの補完なので、重点スキルも検索語もない場合は検索しない
+ * 絞り込み条件で除外するスキルは照合しない
+ */
+func weightedSearchSkills(params SearchParams) []searchSkill {
+	profile := params.rankingProfile()
+	dict := getSkillDictionary()
+	excluded := params.Filter.excludedSkillSet()
+
+	var wanted []string
+	for _, skill := range params.KeySkills {
+		if name, _ := dict.Canonical(skill); !excluded[strings.ToLower(name)] {
+			wanted = append(wanted, skill)
+		}
+	}
+	keySkills := primarySearchSkills(wanted, profile.MaxKeySkills)
+	if len(keySkills) == 0 && len(params.SearchTerms) == 0 {
+		return nil
+	}
+
+	years := map[string]float64{}
+	var structured []string
+	for _, s := range params.Skills {
+		name, _ := dict.Canonical(s.SkillName)
+		if name == "" || excluded[strings.ToLower(name)] {
+			continue
+		}
+		key := strings.ToLower(name)
+		if _, ok := years[key]; !ok {
+			structured = append(structured, name)
+		}
+		years[key] = math.Max(years[key], s.ExperienceYears)
+	}
+
+	var skills []searchSkill
+	seen := map[string]bool{}
+	add := func(name string, weight float64) {
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			skills = append(skills, searchSkill{Name: name, Weight: weight})
+		}
+	}
+
+	for _, name := range keySkills {
+		weight := 1.0
+		if len(structured) > 0 {
+			weight = profile.skillWeight(years[strings.ToLower(name)], true)
+		}
+		add(name, weight)
+	}
+	var others []searchSkill
+	for _, name := range structured {
+		if !seen[strings.ToLower(name)] {
+			others = append(others, searchSkill{Name: name, Weight: profile.skillWeight(years[strings.ToLower(name)], false)})
+		}
+	}
+	sort.SliceStable(others, func(i, j int) bool { return others[i].Weight > others[j].Weight })
+	for _, s := range others {
+		if len(skills) >= profile.MaxSearchSkills {
+			break
+		}
+		add(s.Name, s.Weight)
+	}
+	for _, term := range params.SearchTerms {
+		add(term, 1)
+	}
+	return skills
+}
+
+/**
+ * 案件の行（prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrtの順）を読み取る
+ */
+func scanProjects(rows *sql.Rows) ([]Project, error) {
 	var projects []Project
 	for rows.Next() {
 		var p Project
@@ -362,6 +562,29 @@ func main() {
 	}
 	log.Println("Database connected successfully")
 
+	// 追加テーブルのマイグレーション
+	if err := RunMigrations(db); err != nil {
+		log.Fatal("Database migration failed:", err)
+	}
+
+	// 埋め込みのテンプレートをtbl_promptにも置く（Vercel版が読む）
+	if err := seedPromptTable(db); err != nil {
+		log.Printf("[WARN] Prompt table seed failed: %v", err)
+	}
+
+	// 使用するプロンプトのバージョンが揃っているか確認
+	if err := checkActivePrompts(); err != nil {
+		log.Fatal("Prompt template check failed:", err)
+	}
+
+	// 検索バックエンドの設定と、trgmならインデックスがあるかを確認
+	if err := checkSearchBackend(db); err != nil {
+		log.Fatal("Search backend check failed:", err)
+	}
+
+	// 意味検索を使う設定なら案件ベクトルを定期的に同期
+	startEmbeddingSync()
+
 	// Ginルーターの初期化
 	router := gin.Default()
 
@@ -369,7 +592,7 @@ func main() {
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:3000", "http://localhost:3001", "http://localhost:80"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Admin-Token"}
 	router.Use(cors.New(config))
 
 	// ルーティング
@@ -377,7 +600,30 @@ func main() {
 	{
 		api.GET("/health", healthCheck)
 		api.POST("/chat", handleChat)
+		api.POST("/resume", handleResumeUpload)
+		api.POST("/skillsheet/analyze", handleAnalyzeSkillSheet)
+		api.POST("/skill-gap", handleSkillGap)
+		api.GET("/chat/stream", handleChatStream)
+		api.POST("/chat/stream", handleChatStream)
 		api.GET("/projects", getAllProjects)
+		api.GET("/sessions/:id", handleGetSession)
+		api.GET("/sessions/:id/projects", handleSessionProjects)
+		api.DELETE("/sessions/:id", handleDeleteSession)
+	}
+
+	// 管理者用ルーティング（ADMIN_TOKENが必要）
+	admin := router.Group("/api/admin", requireAdmin())
+	{
+		admin.DELETE("/cache", handlePurgeCache)
+		admin.GET("/sessions", handleListSessions)
+		admin.GET("/skills", handleGetSkillDictionary)
+		admin.POST("/skills/reload", handleReloadSkillDictionary)
+		admin.GET("/ai-usage", handleGetAIUsage)
+		admin.GET("/prompts", handleGetPrompts)
+		admin.POST("/prompts/reload", handleReloadPrompts)
+		admin.POST("/embeddings/sync", handleSyncEmbeddings)
+		admin.GET("/ranking-profile", handleGetRankingProfile)
+		admin.POST("/ranking-profile/reload", handleReloadRankingProfile)
 	}
 
 	// サーバー起動
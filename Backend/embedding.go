@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+/**
+ * 埋め込み（ベクトル化）モジュール
+ * 案件と検索条件を同じ空間のベクトルにして、文字列が一致しなくても意味の近い案件を探せるようにする
+ *  - openai: OpenAI互換の/embeddingsエンドポイント（OpenAI・Ollama・vLLMなど）
+ *  - hash: APIを使わないローカルの埋め込み。単語・2文字の組・辞書のスキル・分野の概念をハッシュして数える
+ *    （「サーバーサイド保守」と「バックエンド」、「EA開発」と「MQL」のように、辞書と概念の表で近づける）
+ */
+
+// 埋め込みの種類
+const (
+	EmbedderHash   = "hash"   // ローカルのハッシュ埋め込み（APIキー不要、テスト・オフライン用）
+	EmbedderOpenAI = "openai" // OpenAI互換の/embeddings
+)
+
+const (
+	defaultEmbeddingBaseURL   = "https://api.openai.com/v1"
+	defaultOpenAIEmbedModel   = "text-embedding-3-small"
+	defaultHashEmbeddingDims  = 256
+	defaultEmbeddingBatchSize = 32
+)
+
+// 埋め込みの設定
+type EmbeddingConfig struct {
+	Provider   string // 埋め込みの種類（hash / openai）
+	Model      string // モデル名（hashのときは次元数から決まる）
+	BaseURL    string // APIのベースURL（openaiのみ）
+	APIKey     string // APIキー（openaiのみ）
+	Dimensions int    // 次元数（hashは必須、openaiは0ならモデルの既定）
+	BatchSize  int    // 1回のAPI呼び出しでまとめる件数
+}
+
+// 文章をベクトルにするインターフェース
+// 同じModelのベクトル同士だけを比べる（モデルが変わったら案件のベクトルも作り直す）
+type Embedder interface {
+	Name() string
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// 使用中の埋め込み（nilの場合は呼び出しごとに設定から生成）
+// テストではhashやモックに差し替えて使う
+var embedder Embedder
+
+/**
+ * 環境変数から埋め込みの設定を読み込む
+ */
+func LoadEmbeddingConfig() EmbeddingConfig {
+	config := EmbeddingConfig{
+		Provider:   strings.ToLower(getEnvWithDefault("EMBEDDING_PROVIDER", EmbedderHash)),
+		Model:      os.Getenv("EMBEDDING_MODEL"),
+		BaseURL:    getEnvWithDefault("EMBEDDING_BASE_URL", defaultEmbeddingBaseURL),
+		APIKey:     os.Getenv("EMBEDDING_API_KEY"),
+		Dimensions: envNonNegativeInt("EMBEDDING_DIMENSIONS", 0),
+		BatchSize:  envInt("EMBEDDING_BATCH_SIZE", defaultEmbeddingBatchSize),
+	}
+
+	switch config.Provider {
+	case EmbedderHash:
+		if config.Dimensions == 0 {
+			config.Dimensions = defaultHashEmbeddingDims
+		}
+		config.Model = fmt.Sprintf("hash-%d", config.Dimensions)
+	case EmbedderOpenAI:
+		if config.Model == "" {
+			config.Model = defaultOpenAIEmbedModel
+		}
+		if config.APIKey == "" {
+			config.APIKey = os.Getenv("LLM_API_KEY")
+		}
+	}
+
+	return config
+}
+
+/**
+ * 設定から埋め込みを生成
+ */
+func NewEmbedder(config EmbeddingConfig) (Embedder, error) {
+	switch config.Provider {
+	case EmbedderHash:
+		return &hashEmbedder{dims: config.Dimensions}, nil
+	case EmbedderOpenAI:
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("EMBEDDING_BASE_URL not set")
+		}
+		return &openAIEmbedder{config: config}, nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider: %s", config.Provider)
+	}
+}
+
+/**
+ * 使用する埋め込みを取得（差し替え済みのものがあればそれを優先）
+ */
+func getEmbedder() (Embedder, error) {
+	if embedder != nil {
+		return embedder, nil
+	}
+	return NewEmbedder(LoadEmbeddingConfig())
+}
+
+/**
+ * コサイン類似度（どちらかが0ベクトル・次元が違う場合は0）
+ */
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// ============================================================
+// OpenAI互換の埋め込み
+// ============================================================
+
+type openAIEmbedder struct {
+	config EmbeddingConfig
+}
+
+type openAIEmbeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type openAIEmbeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage *LLMUsage `json:"usage"`
+}
+
+func (e *openAIEmbedder) Name() string  { return EmbedderOpenAI }
+func (e *openAIEmbedder) Model() string { return e.config.Model }
+
+/**
+ * BatchSize件ずつ/embeddingsを呼び出す
+ * 呼び出しごとの使用量はtbl_aiusageに用途embeddingとして記録する
+ */
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	headers := map[string]string{}
+	if e.config.APIKey != "" {
+		headers["Authorization"] = "Bearer " + e.config.APIKey
+	}
+	url := strings.TrimSuffix(e.config.BaseURL, "/") + "/embeddings"
+
+	batchSize := e.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultEmbeddingBatchSize
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		end := start + batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		began := time.Now()
+		body, err := postJSON(ctx, url, headers, openAIEmbeddingRequest{
+			Model:      e.config.Model,
+			Input:      texts[start:end],
+			Dimensions: e.config.Dimensions,
+		})
+		batch, usage, err := parseEmbeddingResponse(body, err, end-start)
+		e.recordUsage(usage, err, time.Since(began))
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+/**
+ * /embeddingsの応答を入力の順番に並べて返す
+ */
+func parseEmbeddingResponse(body []byte, err error, want int) ([][]float32, LLMUsage, error) {
+	if err != nil {
+		return nil, LLMUsage{}, err
+	}
+
+	var resp openAIEmbeddingResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, LLMUsage{}, badAIResponse("failed to parse embedding response: %v", err)
+	}
+	var usage LLMUsage
+	if resp.Usage != nil {
+		usage = *resp.Usage
+	}
+	if len(resp.Data) != want {
+		return nil, usage, badAIResponse("embedding count mismatch: want %d, got %d", want, len(resp.Data))
+	}
+
+	sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+	vectors := make([][]float32, len(resp.Data))
+	for i, d := range resp.Data {
+		if len(d.Embedding) == 0 {
+			return nil, usage, badAIResponse("empty embedding at index %d", d.Index)
+		}
+		vectors[i] = d.Embedding
+	}
+	return vectors, usage, nil
+}
+
+func (e *openAIEmbedder) recordUsage(usage LLMUsage, err error, latency time.Duration) {
+	record := aiUsageRecord{
+		Provider:  EmbedderOpenAI,
+		Model:     e.config.Model,
+		Purpose:   AIPurposeEmbedding,
+		Usage:     usage,
+		LatencyMs: latency.Milliseconds(),
+		Status:    aiUsageStatusOK,
+	}
+	if record.Usage.TotalTokens == 0 {
+		record.Usage.TotalTokens = record.Usage.PromptTokens
+	}
+	if err != nil {
+		record.Status = aiUsageStatusError
+		record.Error = truncateRunes(err.Error(), aiUsageErrorMaxRunes)
+	}
+	recordAIUsage(record)
+}
+
+// ============================================================
+// ローカルのハッシュ埋め込み
+// ============================================================
+
+// 分野の概念と、その分野を表す言い回し（正規化済み＝小文字・半角）
+// 文字列としては一致しない「サーバーサイド」と「バックエンド」を同じ概念として数える
+var embeddingConcepts = map[string][]string{
+	"backend":     {"バックエンド", "サーバーサイド", "サーバサイド", "backend", "server side", "server-side", "api開発", "web api", "バッチ開発"},
+	"frontend":    {"フロントエンド", "frontend", "front-end", "フロント開発", "画面開発", "ui実装", "コーディング"},
+	"infra":       {"インフラ", "infrastructure", "サーバー構築", "サーバ構築", "ネットワーク構築", "クラウド", "cloud", "sre", "devops"},
+	"mobile":      {"スマホアプリ", "スマートフォンアプリ", "モバイル", "mobile", "ios", "android"},
+	"data":        {"機械学習", "machine learning", "深層学習", "データ分析", "データサイエンス", "データ基盤", "生成ai", "llm", "ai開発"},
+	"fx":          {"ea", "mql", "mql4", "mql5", "mt4", "mt5", "metatrader", "自動売買", "fx", "トレードシステム", "インジケーター"},
+	"management":  {"pm", "pmo", "pl", "プロジェクトマネージャ", "プロジェクト管理", "マネジメント", "進捗管理", "リーダー"},
+	"qa":          {"テスト", "qa", "品質保証", "検証", "テスター"},
+	"maintenance": {"保守", "運用", "改修", "maintenance", "追加開発"},
+	"game":        {"ゲーム", "game", "unity", "unreal"},
+}
+
+// トークンの重み（概念・辞書のスキルは、単語・2文字の組より強く数える）
+const (
+	hashWeightConcept = 3
+	hashWeightSkill   = 3
+	hashWeightToken   = 1
+)
+
+type hashEmbedder struct {
+	dims int
+}
+
+func (e *hashEmbedder) Name() string  { return EmbedderHash }
+func (e *hashEmbedder) Model() string { return fmt.Sprintf("hash-%d", e.dims) }
+
+func (e *hashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = hashEmbed(text, e.dims)
+	}
+	return vectors, nil
+}
+
+/**
+ * 文章をハッシュ埋め込みにする（長さ1に正規化）
+ * トークンごとにFNVハッシュで次元と符号を決めて重みを足す
+ */
+func hashEmbed(text string, dims int) []float32 {
+	vector := make([]float32, dims)
+	add := func(token string, weight float32) {
+		h := fnv.New32a()
+		h.Write([]byte(token))
+		sum := h.Sum32()
+		if sum&(1<<31) != 0 {
+			weight = -weight
+		}
+		vector[int(sum%uint32(dims))] += weight
+	}
+
+	normalized := normalizeMessage(text)
+	for concept, phrases := range embeddingConcepts {
+		for _, phrase := range phrases {
+			if containsPhrase(normalized, phrase) {
+				add("concept:"+concept, hashWeightConcept)
+				break
+			}
+		}
+	}
+	for _, skill := range extractOfflineSkills(normalized) {
+		add("skill:"+skill.SkillName, hashWeightSkill)
+	}
+	for _, token := range textTokens(normalized) {
+		add(token, hashWeightToken)
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
+
+/**
+ * 言い回しが単語境界つきで含まれているか（辞書のエイリアスと同じ規則）
+ */
+func containsPhrase(text, phrase string) bool {
+	for offset := 0; offset < len(text); {
+		idx := strings.Index(text[offset:], phrase)
+		if idx < 0 {
+			return false
+		}
+		start := offset + idx
+		if isAliasBoundary(text, start, start+len(phrase)) {
+			return true
+		}
+		offset = start + len(phrase)
+	}
+	return false
+}
+
+/**
+ * 正規化済みの文章をトークンに分ける
+ * 英数字は2文字以上の単語、日本語は連続した文字の2文字の組（1文字だけならその文字）
+ */
+func textTokens(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	var run []rune
+
+	flushWord := func() {
+		if utf8.RuneCountInString(word.String()) >= 2 {
+			tokens = append(tokens, "w:"+word.String())
+		}
+		word.Reset()
+	}
+	flushRun := func() {
+		if len(run) == 1 {
+			tokens = append(tokens, "c:"+string(run))
+		}
+		for i := 0; i+1 < len(run); i++ {
+			tokens = append(tokens, "c:"+string(run[i:i+2]))
+		}
+		run = run[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isASCIIWordRune(r):
+			flushRun()
+			word.WriteRune(r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushWord()
+			run = append(run, r)
+		default:
+			flushWord()
+			flushRun()
+		}
+	}
+	flushWord()
+	flushRun()
+	return tokens
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * ルールベースのスキル抽出モジュール
+ * LLMを使わずに、スキル辞書（skilldict.go）と正規表現だけでAIAnalysisを組み立てる
+ * AIの利用上限に達したときの代替と、大量に処理したいときの安価な解析に使う
+ */
+
+// 解析方法
+const (
+	AnalyzerAI   = "ai"   // LLMで解析
+	AnalyzerRule = "rule" // ルールベースで解析（簡易）
+)
+
+// 解析結果と、どの方法で解析したかの情報
+type analysisOutcome struct {
+	Analysis      AIAnalysis
+	CacheHit      bool   // キャッシュから返したかどうか
+	Analyzer      string // 実際に使った解析方法
+	PromptVersion string // AIで解析したときのプロンプトのバージョン
+}
+
+// 希望する役割のキーワード（上から順に判定）
+var offlineRoleKeywords = []struct {
+	Keyword string
+	Role    string
+}{
+	{"pm", "プロジェクトマネージャー"},
+	{"pmo", "PMO"},
+	{"フルスタック", "フルスタック開発者"},
+	{"フロントエンド", "フロントエンドエンジニア"},
+	{"バックエンド", "バックエンドエンジニア"},
+	{"サーバーサイド", "バックエンドエンジニア"},
+	{"インフラ", "インフラエンジニア"},
+	{"sre", "SREエンジニア"},
+	{"データエンジニア", "データエンジニア"},
+	{"データ基盤", "データエンジニア"},
+	{"データ分析基盤", "データエンジニア"},
+	{"アプリエンジニア", "アプリエンジニア"},
+	{"スマホアプリ", "アプリエンジニア"},
+	{"モバイルアプリ", "アプリエンジニア"},
+	{"ネイティブアプリ", "アプリエンジニア"},
+	{"full stack", "フルスタック開発者"},
+	{"fullstack", "フルスタック開発者"},
+	{"frontend", "フロントエンドエンジニア"},
+	{"front-end", "フロントエンドエンジニア"},
+	{"backend", "バックエンドエンジニア"},
+	{"back-end", "バックエンドエンジニア"},
+	{"server-side", "バックエンドエンジニア"},
+	{"infrastructure", "インフラエンジニア"},
+	{"devops", "インフラエンジニア"},
+	{"data engineer", "データエンジニア"},
+	{"mobile", "アプリエンジニア"},
+}
+
+var (
+	// スキル名の直後の経験年数（例: "Java3年", "TypeScriptを2年", "Go歴1.5年", "React 6ヶ月", "Go for 3 years", "AWS (2 yrs)"）
+	offlineYearsPattern = regexp.MustCompile(`^\s*(?:を|で|は|が|歴|の経験|経験|:|、|,|-|\(|for|with)?\s*(?:約|計|about|over)?\s*(\d+(?:\.\d+)?)\s*(年|ヶ月|か月|カ月|ヵ月|ケ月|years?|yrs?|months?)`)
+	// スキル名の直後の半年
+	offlineHalfYearPattern = regexp.MustCompile(`^\s*(?:を|で|は|が|歴|の経験|経験|:|、)?\s*半年`)
+	// 単価の範囲（例: "月80〜100万円", "80-100万"）
+	offlinePriceRangePattern = regexp.MustCompile(`(\d+)\s*(?:万円?)?\s*[〜~\-ー]\s*(\d+)\s*万`)
+	// 単価の単独指定（例: "月額90万円"）
+	offlinePricePattern = regexp.MustCompile(`(\d+)\s*万円?`)
+)
+
+/**
+ * 解析方法を決める
+ * リクエストで指定があればそれを、なければSKILL_ANALYZER（デフォルトai）を使う
+ */
+func resolveAnalyzer(requested string) (string, error) {
+	analyzer := requested
+	if analyzer == "" {
+		analyzer = getEnvWithDefault("SKILL_ANALYZER", AnalyzerAI)
+	}
+	switch analyzer {
+	case AnalyzerAI, AnalyzerRule:
+		return analyzer, nil
+	default:
+		return "", fmt.Errorf("unknown analyzer: %s", analyzer)
+	}
+}
+
+/**
+ * AIの利用上限（insufficient_quota / 残高不足）によるエラーかどうか
+ * 一時的なレート制限は再試行で待つので含めない（aiclient.go）
+ */
+func isQuotaError(err error) bool {
+	var aiErr *AIError
+	return errors.As(err, &aiErr) && aiErr.Kind == AIErrorQuota
+}
+
+// 20261016 枠が尽きると検索すらできなくなるので、ルールベースの簡易解析に逃がすようにした。
+/**
+ * チャットリクエストのスキル解析
+ * AIで解析し、利用上限に達していた場合はルールベースの解析に切り替える
+ * analyzerにruleを指定した場合は最初からルールベースで解析する
+ */
+func analyzeChatRequest(ctx context.Context, session *Session, message, analyzer string, onField analysisFieldHandler) (analysisOutcome, error) {
+	if analyzer == AnalyzerAI {
+		analysis, cacheHit, err := analyzeForSession(ctx, session, message, onField)
+		if err == nil {
+			analysis.SalaryRange = parseEstimatedSalary(analysis.EstimatedSalary)
+			analysis.Filters = analysisFilters(analysis, message)
+			return analysisOutcome{Analysis: analysis, CacheHit: cacheHit, Analyzer: AnalyzerAI, PromptVersion: activePromptVersion(PromptAnalysis)}, nil
+		}
+		if !isQuotaError(err) {
+			return analysisOutcome{}, err
+		}
+		log.Printf("AI quota exceeded, falling back to rule-based analyzer: %v", err)
+	}
+
+	var previous *AIAnalysis
+	if session != nil {
+		previous = session.LastAnalysis
+	}
+	analysis := extractSkillsOffline(message, previous)
+	emitAnalysisFields(analysis, onField)
+	analysis.SalaryRange = parseEstimatedSalary(analysis.EstimatedSalary)
+	analysis.Filters = analysisFilters(analysis, message)
+	return analysisOutcome{Analysis: analysis, Analyzer: AnalyzerRule}, nil
+}
+
+/**
+ * メッセージに書かれた絞り込み条件
+ * AIが取り出した条件（除外するスキル・キーワードを含む）を優先し、足りない項目をルールで補う（AIの条件が不正なら使わない）
+ */
+func analysisFilters(analysis AIAnalysis, message string) *ProjectFilter {
+	extracted := mergeProjectFilters(analysis.Filters, &ProjectFilter{
+		ExcludedSkills:   analysis.ExcludedSkills,
+		ExcludedKeywords: analysis.ExcludedKeywords,
+	})
+	if err := extracted.Validate(); err != nil {
+		log.Printf("Ignoring invalid filters from analysis: %v", err)
+		extracted = nil
+	}
+	return mergeProjectFilters(extracted, parseMessageFilters(message, time.Now()))
+}
+
+/**
+ * LLMを使わずにメッセージからスキル・経験年数・希望単価を抽出する
+ * previousを渡すと、メッセージに無かった項目は前回の分析結果を引き継ぐ（セッション用）
+ */
+func extractSkillsOffline(message string, previous *AIAnalysis) AIAnalysis {
+	text := normalizeMessage(message)
+	excludedSkills, excludedKeywords := parseMessageExclusions(message)
+	skills := withoutExcludedSkills(extractOfflineSkills(text), excludedSkills)
+	salary := extractOfflineSalary(text)
+
+	if previous != nil {
+		if len(skills) == 0 {
+			skills = withoutExcludedSkills(previous.StructuredSkills, excludedSkills)
+		}
+		if salary == "" {
+			salary = previous.EstimatedSalary
+		}
+	}
+	if salary == "" {
+		salary = "簡易分析のため推定なし"
+	}
+
+	// 経験年数の長い順（同じなら出現順）に上位3つを重点スキルにする
+	ranked := make([]Skill, len(skills))
+	copy(ranked, skills)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].ExperienceYears > ranked[j].ExperienceYears
+	})
+	keySkills := []string{}
+	maxYears := 0.0
+	for i, s := range ranked {
+		if i < 3 {
+			keySkills = append(keySkills, s.SkillName)
+		}
+		if s.ExperienceYears > maxYears {
+			maxYears = s.ExperienceYears
+		}
+	}
+
+	var described []string
+	for _, s := range skills {
+		if s.ExperienceYears > 0 {
+			described = append(described, fmt.Sprintf("%s（%s年）", s.SkillName, strconv.FormatFloat(s.ExperienceYears, 'f', -1, 64)))
+		} else {
+			described = append(described, s.SkillName)
+		}
+	}
+	strengths := "スキルを読み取れませんでした。"
+	if len(described) > 0 {
+		strengths = strings.Join(described, "、") + "の経験があります。"
+	}
+
+	return AIAnalysis{
+		EstimatedSalary:  salary,
+		Strengths:        strengths,
+		Suggestions:      "AIを使わない簡易分析の結果です。詳しい分析は時間をおいて再度お試しください。",
+		StructuredSkills: skills,
+		SearchPrompt:     strings.Join(keySkills, " "),
+		KeySkills:        keySkills,
+		PreferredRole:    extractOfflineRole(text, previous),
+		ExperienceLevel:  experienceLevelForYears(maxYears),
+		ExcludedSkills:   excludedSkills,
+		ExcludedKeywords: excludedKeywords,
+	}
+}
+
+/**
+ * 除外するスキル（「PHPはNG」のPHPなど）を抽出したスキルから除く
+ */
+func withoutExcludedSkills(skills []Skill, excluded []string) []Skill {
+	if len(excluded) == 0 {
+		return skills
+	}
+	excludedSet := (&ProjectFilter{ExcludedSkills: excluded}).excludedSkillSet()
+	kept := []Skill{}
+	for _, s := range skills {
+		if !excludedSet[strings.ToLower(s.SkillName)] {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+/**
+ * 正規化済みのテキストから辞書にあるスキルと経験年数を抽出する
+ * 長い表記を優先し（"spring boot"と"spring"など）、同じスキルは最大の年数を採用する
+ */
+func extractOfflineSkills(text string) []Skill {
+	type hit struct {
+		start, end int
+		name       string
+	}
+
+	var hits []hit
+	for _, entry := range getSkillDictionary().Entries {
+		for _, alias := range entry.Aliases {
+			for offset := 0; offset < len(text); {
+				idx := strings.Index(text[offset:], alias)
+				if idx < 0 {
+					break
+				}
+				start := offset + idx
+				end := start + len(alias)
+				if isAliasBoundary(text, start, end) {
+					hits = append(hits, hit{start: start, end: end, name: entry.Name})
+				}
+				offset = end
+			}
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].start != hits[j].start {
+			return hits[i].start < hits[j].start
+		}
+		return hits[i].end > hits[j].end
+	})
+
+	skills := []Skill{}
+	index := map[string]int{}
+	covered := 0
+	for _, h := range hits {
+		if h.start < covered {
+			continue
+		}
+		covered = h.end
+
+		years := extractOfflineYears(text[h.end:])
+		if i, ok := index[h.name]; ok {
+			if years > skills[i].ExperienceYears {
+				skills[i].ExperienceYears = years
+			}
+			continue
+		}
+		index[h.name] = len(skills)
+		skills = append(skills, Skill{SkillName: h.name, ExperienceYears: years})
+	}
+
+	return skills
+}
+
+/**
+ * スキル名の直後から経験年数を読み取る（月数は年に換算）
+ */
+func extractOfflineYears(rest string) float64 {
+	if offlineHalfYearPattern.MatchString(rest) {
+		return 0.5
+	}
+	m := offlineYearsPattern.FindStringSubmatch(rest)
+	if m == nil {
+		return 0
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0
+	}
+	if m[2] != "年" && !strings.HasPrefix(m[2], "y") {
+		value = float64(int(value/12*10+0.5)) / 10
+	}
+	return value
+}
+
+/**
+ * 希望単価を読み取って "月額XX万円〜XX万円" の形にする
+ * 年収・年俸で書かれていたら "年収XX万円" のまま返す（月額に読み替えるのはparseSalaryRange）
+ */
+func extractOfflineSalary(text string) string {
+	text = stripNonSalaryAmounts(text)
+	unit := "月額"
+	if m := offlinePriceRangePattern.FindStringSubmatchIndex(text); m != nil {
+		if isAnnualSalary(text[:m[0]]) {
+			unit = "年収"
+		}
+		return fmt.Sprintf("%s%s万円〜%s万円", unit, text[m[2]:m[3]], text[m[4]:m[5]])
+	}
+	if m := offlinePricePattern.FindStringSubmatchIndex(text); m != nil {
+		if isAnnualSalary(text[:m[0]]) {
+			unit = "年収"
+		}
+		return fmt.Sprintf("%s%s万円", unit, text[m[2]:m[3]])
+	}
+	return ""
+}
+
+/**
+ * 金額の直前（10文字以内）に年収・年俸・年額と書かれているか
+ */
+func isAnnualSalary(before string) bool {
+	runes := []rune(before)
+	if len(runes) > 10 {
+		runes = runes[len(runes)-10:]
+	}
+	near := string(runes)
+	return strings.Contains(near, "年収") || strings.Contains(near, "年俸") || strings.Contains(near, "年額")
+}
+
+/**
+ * 役割のキーワードから希望する役割を決める
+ * 英字のキーワード（pm, sre）だけ単語境界を見る。カタカナは"バックエンドエンジニア"のように続けて書かれるため
+ * 20261017 "pmo経験あり、pm希望"のように最初の出現が境界外でも後ろの出現を見るようcontainsRoleKeywordを使う
+ */
+func extractOfflineRole(text string, previous *AIAnalysis) string {
+	for _, r := range offlineRoleKeywords {
+		if containsRoleKeyword(text, r.Keyword) {
+			return r.Role
+		}
+	}
+	if previous != nil && previous.PreferredRole != "" {
+		return previous.PreferredRole
+	}
+	return "エンジニア"
+}
+
+/**
+ * 最長の経験年数から経験レベルを決める
+ */
+func experienceLevelForYears(years float64) string {
+	switch {
+	case years >= 10:
+		return "エキスパート"
+	case years >= 5:
+		return "上級"
+	case years >= 2:
+		return "中級"
+	default:
+		return "初級"
+	}
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+/**
+ * 案件の絞り込み条件モジュール
+ * 単価・リモート・契約期間・掲載サイト（prostn）・掲載日・除外するスキルとキーワードで案件を絞り込む
+ * （並べ替えではなく、合わない案件は返さない）
+ * /api/chatではリクエストのfiltersに、メッセージ（「リモートのみ」「月60万以上」「lancers以外」「直近3日」「PHPはNG」など）から
+ * 取り出した条件を合わせて使う。/api/projectsではクエリパラメーターで指定する
+ *
+ * どの条件もSQLで絞り込む（件数を絞る前に除くため）。単価は文字列なので、parseSalaryRangeと同じ読み方をする
+ * SQLの関数（project_price_matches、マイグレーション9）で比べる（読み取れない案件は除かない）
+ */
+
+// 働き方
+const (
+	WorkStyleRemote = "remote" // リモートの記載がある案件（「リモート不可」などは除く）
+	WorkStyleOnsite = "onsite" // リモートの記載がない案件
+)
+
+// 契約期間
+const (
+	PeriodLong  = "long"  // 長期・継続
+	PeriodShort = "short" // 短期・単発・スポット
+)
+
+// 案件の絞り込み条件（指定のない項目では絞り込まない）
+type ProjectFilter struct {
+	MinPrice       int      `json:"min_price,omitempty"`       // 単価の下限（円/月）
+	MaxPrice       int      `json:"max_price,omitempty"`       // 単価の上限（円/月）
+	WorkStyle      string   `json:"work_style,omitempty"`      // 働き方（remote / onsite）
+	Period         string   `json:"period,omitempty"`          // 契約期間（long / short）
+	Sources        []string `json:"sources,omitempty"`         // このサイトの案件のみ（prostnの部分一致）
+	ExcludeSources []string `json:"exclude_sources,omitempty"` // このサイトの案件を除く（prostnの部分一致）
+	PostedSince    string   `json:"posted_since,omitempty"`    // この日以降に掲載された案件のみ（YYYY-MM-DD）
+
+	ExcludedSkills   []string `json:"excluded_skills,omitempty"`   // タイトル・スキル欄・詳細にこのスキル（辞書のエイリアスも）がある案件を除く
+	ExcludedKeywords []string `json:"excluded_keywords,omitempty"` // タイトル・スキル欄・詳細にこの語（部分一致）がある案件を除く
+}
+
+// 除外するスキル・キーワードの上限（1つごとに全件の正規表現・部分一致になるので）
+const maxExcludedTerms = 20
+
+// 案件の本文から働き方・契約期間を判定するパターン（SQLの ~* で使う）
+const (
+	filterRemotePattern   = `リモート|在宅|テレワーク|remote`
+	filterNoRemotePattern = `リモート(不可|なし|無し|ng)|フル出社|常駐のみ`
+)
+
+var filterPeriodPatterns = map[string]string{
+	PeriodLong:  `長期|継続`,
+	PeriodShort: `短期|単発|スポット`,
+}
+
+// メッセージに書かれたサイト名とprostn（日次のスクレイピング対象のホスト名）に含まれる名前
+var messageSourceNames = map[string]string{
+	"lancers":         "lancers",
+	"ランサーズ":           "lancers",
+	"crowdworks":      "crowdworks",
+	"クラウドワークス":        "crowdworks",
+	"freelance-start": "freelance-start",
+	"フリーランススタート":      "freelance-start",
+}
+
+// 除外の指定に見えても、働き方・掲載サイトの条件として扱う語
+var exclusionIgnoredTerms = map[string]bool{
+	"リモート": true, "フルリモート": true, "在宅": true, "テレワーク": true, "remote": true,
+}
+
+// 除外する語1つ分（助詞・区切りの前まで。空白は英数字の語が続く場合だけ含める（"excel vba"））
+const exclusionTermPattern = `[^\s、。,.!?:;/・「」()（）をがのはもでにと]+(?: [a-z0-9]+)*`
+
+// メッセージから絞り込み条件を取り出すパターン（normalizeMessage済みのテキストに使う）
+var (
+	// 働き方は「のみ」「限定」「希望」と書かれたときだけ（"客先常駐で金融系の開発" "リモートで開発した経験" は経歴の説明）
+	messageRemotePattern   = regexp.MustCompile(`フルリモート|(リモート|在宅|テレワーク)(のみ|限定|を?希望)`)
+	messageOnsitePattern   = regexp.MustCompile(`(常駐|出社|オンサイト)(のみ|限定|を?希望)`)
+	messageLongPattern     = regexp.MustCompile(`長期(のみ|限定|を?希望|案件)`)
+	messageShortPattern    = regexp.MustCompile(`(短期|単発|スポット)(のみ|限定|を?希望|案件)`)
+	messageMinPricePattern = regexp.MustCompile(`\d+(?:\.\d+)?\s*万円?\s*以上`)
+	messageMaxPricePattern = regexp.MustCompile(`\d+(?:\.\d+)?\s*万円?\s*(?:以下|まで|以内)`)
+	// サイト名（"lancers以外" "クラウドワークスのみ"）
+	messageSourcePattern = regexp.MustCompile(`(lancers|ランサーズ|crowdworks|クラウドワークス|freelance-start|フリーランススタート)(?:\.[a-z.]+)?\s*(以外|除外|を除く|のみ|だけ|限定)`)
+	// 掲載日（"直近3日" "3日以内" "ここ1週間"）
+	messagePostedPattern = regexp.MustCompile(`(?:直近|ここ)\s*(\d+)\s*(日|週間)|(\d+)\s*(日|週間)\s*以内`)
+	// 除外（"PHPはNG" "SES常駐・Excel VBAは避けたい" "保守以外で"）。「・」「/」「と」で並べた語をまとめて取り出す
+	// 「以外」は案件の希望として書かれたとき（"以外の案件" "以外で" "以外がいい"）だけ。"要件定義以外、設計を担当" は経歴の説明
+	messageExclusionPattern = regexp.MustCompile(`(` + exclusionTermPattern + `(?:\s*[・/と]\s*` + exclusionTermPattern + `)*)\s*(?:の案件)?\s*` +
+		`(?:(?:は|も)\s*ng|(?:は|も)?\s*(?:除外|以外(?:の案件|で(?:[^もはの]|$)|が(?:いい|希望))|を除く|除いて|お断り|避けたい|やりたくない|したくない))`)
+	messageExclusionSplitter = regexp.MustCompile(`\s*[・/と]\s*`)
+	// 英字の語と日本語の語の間の空白（"SES 常駐" は "SES常駐" と同じ1つの語にする）
+	exclusionMixedSpace = regexp.MustCompile(`([a-z0-9]) ([^\x00-\x7f])`)
+	// 前回までの絞り込み条件を使わない（"絞り込みを解除" "条件をリセットして"）
+	messageClearFilterPattern = regexp.MustCompile(`(?:絞り込み|絞込み?|条件|フィルター?)\s*(?:を|は)?\s*(?:リセット|解除|クリア|取り消|なしに)`)
+)
+
+/**
+ * 値が使える範囲か確認する（nilは絞り込みなし）
+ */
+func (f *ProjectFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	switch {
+	case f.MinPrice < 0 || f.MaxPrice < 0:
+		return errors.New("min_price and max_price must not be negative")
+	case f.MinPrice > 0 && f.MaxPrice > 0 && f.MinPrice > f.MaxPrice:
+		return errors.New("min_price must not be greater than max_price")
+	case f.WorkStyle != "" && f.WorkStyle != WorkStyleRemote && f.WorkStyle != WorkStyleOnsite:
+		return fmt.Errorf("unknown work_style: %s (use %s or %s)", f.WorkStyle, WorkStyleRemote, WorkStyleOnsite)
+	case f.Period != "" && filterPeriodPatterns[f.Period] == "":
+		return fmt.Errorf("unknown period: %s (use %s or %s)", f.Period, PeriodLong, PeriodShort)
+	case len(f.ExcludedSkills) > maxExcludedTerms || len(f.ExcludedKeywords) > maxExcludedTerms:
+		return fmt.Errorf("excluded_skills and excluded_keywords must have at most %d items", maxExcludedTerms)
+	}
+	for _, keyword := range f.ExcludedKeywords {
+		if utf8.RuneCountInString(strings.TrimSpace(keyword)) < 2 {
+			return fmt.Errorf("excluded_keywords must be at least 2 characters: %q", keyword)
+		}
+	}
+	if f.PostedSince != "" {
+		if _, err := time.Parse("2006-01-02", f.PostedSince); err != nil {
+			return fmt.Errorf("posted_since must be YYYY-MM-DD: %s", f.PostedSince)
+		}
+	}
+	return nil
+}
+
+/**
+ * 絞り込み条件が1つもないか
+ */
+func (f *ProjectFilter) IsEmpty() bool {
+	return f == nil || (f.MinPrice == 0 && f.MaxPrice == 0 && f.WorkStyle == "" && f.Period == "" &&
+		len(f.Sources) == 0 && len(f.ExcludeSources) == 0 && f.PostedSince == "" &&
+		len(f.ExcludedSkills) == 0 && len(f.ExcludedKeywords) == 0)
+}
+
+/**
+ * 絞り込み条件を合わせる
+ * 単価・働き方・契約期間・掲載日・掲載サイト（のみ）は、その項目を指定している最初の条件を使う
+ * 新しい条件を前に渡せば、前回の条件のうち指定し直した項目だけが変わる
+ * 除外する掲載サイト・スキル・キーワードはすべての条件を合わせる（一度除外した案件は、条件をリセットするまで出さない）
+ * @return *ProjectFilter 条件が1つもなければnil
+ */
+func mergeProjectFilters(filters ...*ProjectFilter) *ProjectFilter {
+	var merged ProjectFilter
+	for _, f := range filters {
+		if f == nil {
+			continue
+		}
+		if merged.MinPrice == 0 {
+			merged.MinPrice = f.MinPrice
+		}
+		if merged.MaxPrice == 0 {
+			merged.MaxPrice = f.MaxPrice
+		}
+		if merged.WorkStyle == "" {
+			merged.WorkStyle = f.WorkStyle
+		}
+		if merged.Period == "" {
+			merged.Period = f.Period
+		}
+		if merged.PostedSince == "" {
+			merged.PostedSince = f.PostedSince
+		}
+		if len(merged.Sources) == 0 {
+			merged.Sources = appendUniqueFold(nil, f.Sources...)
+		}
+		merged.ExcludeSources = appendUniqueFold(merged.ExcludeSources, f.ExcludeSources...)
+		merged.ExcludedSkills = appendUniqueFold(merged.ExcludedSkills, f.ExcludedSkills...)
+		merged.ExcludedKeywords = appendUniqueFold(merged.ExcludedKeywords, f.ExcludedKeywords...)
+	}
+	// 上限を超えたら前にある（新しい）条件の語を残す
+	if len(merged.ExcludedSkills) > maxExcludedTerms {
+		merged.ExcludedSkills = merged.ExcludedSkills[:maxExcludedTerms]
+	}
+	if len(merged.ExcludedKeywords) > maxExcludedTerms {
+		merged.ExcludedKeywords = merged.ExcludedKeywords[:maxExcludedTerms]
+	}
+	if merged.IsEmpty() {
+		return nil
+	}
+	return &merged
+}
+
+/**
+ * 大文字小文字を区別せず、まだない値だけを追加する
+ */
+func appendUniqueFold(values []string, additions ...string) []string {
+	for _, a := range additions {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		found := false
+		for _, v := range values {
+			if strings.EqualFold(v, a) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, a)
+		}
+	}
+	return values
+}
+
+/**
+ * メッセージが前回までの絞り込み条件の解除を求めているか
+ */
+func clearsFilters(message string) bool {
+	return messageClearFilterPattern.MatchString(normalizeMessage(message))
+}
+
+/**
+ * メッセージから絞り込み条件を取り出す（AIを使わない。どちらの解析方法でも使う）
+ * 「リモート」「長期」などの単語だけでは絞り込まず、「のみ」「希望」などが続く場合だけ条件にする
+ * @param now 「直近3日」などの基準日
+ */
+func parseMessageFilters(message string, now time.Time) *ProjectFilter {
+	text := normalizeMessage(message)
+	var f ProjectFilter
+
+	switch {
+	case messageRemotePattern.MatchString(text):
+		f.WorkStyle = WorkStyleRemote
+	case messageOnsitePattern.MatchString(text):
+		f.WorkStyle = WorkStyleOnsite
+	}
+	switch {
+	case messageLongPattern.MatchString(text):
+		f.Period = PeriodLong
+	case messageShortPattern.MatchString(text):
+		f.Period = PeriodShort
+	}
+
+	// "月間100万PV以上" のような単価ではない数量は除いてから読む
+	priceText := stripNonSalaryAmounts(text)
+	if m := messageMinPricePattern.FindString(priceText); m != "" {
+		if r, ok := parseSalaryRange(m); ok {
+			f.MinPrice = r.Min
+		}
+	}
+	if m := messageMaxPricePattern.FindString(priceText); m != "" {
+		if r, ok := parseSalaryRange(m); ok {
+			f.MaxPrice = r.Max
+		}
+	}
+
+	for _, m := range messageSourcePattern.FindAllStringSubmatch(text, -1) {
+		switch source := messageSourceNames[m[1]]; m[2] {
+		case "以外", "除外", "を除く":
+			f.ExcludeSources = appendUniqueFold(f.ExcludeSources, source)
+		default:
+			f.Sources = appendUniqueFold(f.Sources, source)
+		}
+	}
+
+	if m := messagePostedPattern.FindStringSubmatch(text); m != nil {
+		number, unit := m[1], m[2]
+		if number == "" {
+			number, unit = m[3], m[4]
+		}
+		days, _ := strconv.Atoi(number)
+		if unit == "週間" {
+			days *= 7
+		}
+		f.PostedSince = now.AddDate(0, 0, -days).Format("2006-01-02")
+	}
+
+	f.ExcludedSkills, f.ExcludedKeywords = parseMessageExclusions(message)
+
+	if f.Validate() != nil || f.IsEmpty() {
+		return nil
+	}
+	return &f
+}
+
+/**
+ * メッセージから除外するスキルとキーワードを取り出す（AIを使わない）
+ * 辞書にあるスキルは正式名で除外するスキルに、それ以外の語は除外するキーワードにする
+ * 「保守案件」「金融系」は「保守」「金融」にする（案件の本文には「保守」「金融」と書かれるので）
+ * 「ランサーズ以外」「リモート不可」のような掲載サイト・働き方の条件は除く
+ */
+func parseMessageExclusions(message string) (skills, keywords []string) {
+	dict := getSkillDictionary()
+	text := exclusionMixedSpace.ReplaceAllString(normalizeMessage(message), "$1$2")
+	for _, m := range messageExclusionPattern.FindAllStringSubmatch(text, -1) {
+		for _, term := range messageExclusionSplitter.Split(m[1], -1) {
+			term = strings.TrimSuffix(strings.TrimSuffix(term, "案件"), "系")
+			// 「リモート以外はNG」は除外ではなく、それだけを希望するという意味
+			if _, isSource := messageSourceNames[term]; isSource || exclusionIgnoredTerms[term] || strings.HasSuffix(term, "以外") {
+				continue
+			}
+			if name, ok := dict.Canonical(term); ok {
+				skills = appendUniqueFold(skills, name)
+			} else if utf8.RuneCountInString(term) >= 2 && len(keywords) < maxExcludedTerms {
+				keywords = appendUniqueFold(keywords, term)
+			}
+		}
+	}
+	if len(skills) > maxExcludedTerms {
+		skills = skills[:maxExcludedTerms]
+	}
+	return skills, keywords
+}
+
+/**
+ * 除外するスキルの正式名（小文字）の集合
+ */
+func (f *ProjectFilter) excludedSkillSet() map[string]bool {
+	excluded := map[string]bool{}
+	if f == nil {
+		return excluded
+	}
+	dict := getSkillDictionary()
+	for _, skill := range f.ExcludedSkills {
+		name, _ := dict.Canonical(skill)
+		excluded[strings.ToLower(name)] = true
+	}
+	return excluded
+}
+
+/**
+ * JSONの文字列で渡された絞り込み条件を読み取る（GETのクエリ・フォーム用。空ならnil）
+ */
+func parseFilterParam(raw string) (*ProjectFilter, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var f ProjectFilter
+	if err := json.Unmarshal([]byte(raw), &f); err != nil {
+		return nil, fmt.Errorf("invalid filters: %v", err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid filters: %v", err)
+	}
+	return &f, nil
+}
+
+/**
+ * /api/projectsのクエリパラメーターから絞り込み条件を読み取る
+ * source・exclude_source・exclude_skill・exclude_keywordはカンマ区切りでも、複数回指定してもよい
+ */
+func filterFromQuery(c *gin.Context) (*ProjectFilter, error) {
+	var f ProjectFilter
+	for name, target := range map[string]*int{"min_price": &f.MinPrice, "max_price": &f.MaxPrice} {
+		if raw := c.Query(name); raw != "" {
+			value, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an integer (yen per month): %s", name, raw)
+			}
+			*target = value
+		}
+	}
+	f.WorkStyle = c.Query("work_style")
+	f.Period = c.Query("period")
+	f.PostedSince = c.Query("posted_since")
+	for _, raw := range c.QueryArray("source") {
+		f.Sources = appendUniqueFold(f.Sources, strings.Split(raw, ",")...)
+	}
+	for _, raw := range c.QueryArray("exclude_source") {
+		f.ExcludeSources = appendUniqueFold(f.ExcludeSources, strings.Split(raw, ",")...)
+	}
+	for _, raw := range c.QueryArray("exclude_skill") {
+		f.ExcludedSkills = appendUniqueFold(f.ExcludedSkills, strings.Split(raw, ",")...)
+	}
+	for _, raw := range c.QueryArray("exclude_keyword") {
+		f.ExcludedKeywords = appendUniqueFold(f.ExcludedKeywords, strings.Split(raw, ",")...)
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if f.IsEmpty() {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+/**
+ * SQLで絞り込む条件を組み立てる
+ * プレースホルダーはargsの続きの番号を使う
+ */
+func (f *ProjectFilter) sqlConditions(args []interface{}) ([]string, []interface{}) {
+	if f == nil {
+		return nil, args
+	}
+
+	var conditions []string
+	add := func(format string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(format, placeholders...))
+	}
+
+	const workText = "concat_ws(' ', prottl, prodtl, proprd, proot2)"
+	switch f.WorkStyle {
+	case WorkStyleRemote:
+		add("("+workText+" ~* $%d AND "+workText+" !~* $%d)", filterRemotePattern, filterNoRemotePattern)
+	case WorkStyleOnsite:
+		add("NOT ("+workText+" ~* $%d AND "+workText+" !~* $%d)", filterRemotePattern, filterNoRemotePattern)
+	}
+	if pattern := filterPeriodPatterns[f.Period]; pattern != "" {
+		add("concat_ws(' ', prottl, prodtl, proprd) ~* $%d", pattern)
+	}
+	if len(f.Sources) > 0 {
+		add("prostn ILIKE ANY($%d)", pq.Array(containsLikePatterns(f.Sources)))
+	}
+	if len(f.ExcludeSources) > 0 {
+		add("NOT (coalesce(prostn, '') ILIKE ANY($%d))", pq.Array(containsLikePatterns(f.ExcludeSources)))
+	}
+	if f.PostedSince != "" {
+		add("procrt >= $%d", f.PostedSince)
+	}
+	// 単価は文字列なので、parseSalaryRangeと同じ読み方で月額にして比べる（読み取れない単価の案件は残す）
+	if f.MinPrice > 0 || f.MaxPrice > 0 {
+		add("project_price_matches(proprc, $%d, $%d)", f.MinPrice, f.MaxPrice)
+	}
+
+	// 除外するスキル・キーワードはタイトル・スキル欄・詳細のどこにあっても除く（NULLの項目は空として扱う）
+	const matchText = "concat_ws(' ', prottl, proot1, prodtl)"
+	if len(f.ExcludedSkills) > 0 {
+		dict := getSkillDictionary()
+		var patterns []string
+		for _, skill := range f.ExcludedSkills {
+			patterns = append(patterns, dict.Pattern(skill))
+		}
+		add("NOT ("+matchText+" ~* $%d)", strings.Join(patterns, "|"))
+	}
+	if len(f.ExcludedKeywords) > 0 {
+		add("NOT ("+matchText+" ILIKE ANY($%d))", pq.Array(containsLikePatterns(f.ExcludedKeywords)))
+	}
+	return conditions, args
+}
+
+/**
+ * サイト名・キーワードを部分一致のLIKEパターンにする
+ */
+func containsLikePatterns(values []string) []string {
+	patterns := make([]string, len(values))
+	for i, s := range values {
+		patterns[i] = "%" + escapeLikePattern(s) + "%"
+	}
+	return patterns
+}
+
+/**
+ * 案件の単価が絞り込み条件の範囲に入るか（読み取れない単価は除かない）
+ * SQLのproject_price_matchesと同じ判定。SQLの正規表現と読み方がずれても範囲外の案件を返さないよう、取り出した後にも確かめる
+ */
+func (f *ProjectFilter) matchesPrice(p Project) bool {
+	if f == nil || (f.MinPrice == 0 && f.MaxPrice == 0) || p.PriceRange == nil {
+		return true
+	}
+	return p.PriceRange.Overlaps(SalaryRange{Min: f.MinPrice, Max: f.MaxPrice})
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * 入力チェックモジュール
+ * チャットのメッセージをAIに渡す前に整える
+ *  - バイナリ・文字化け・意味のない文字列は400で断る（codeで理由がわかるようにする）
+ *  - 「これまでの指示を無視して…」のような指示文（プロンプトインジェクション）を取り除く
+ *  - 長すぎる職務経歴書は、区切りごとにスキル・年数・単価を含む行を優先して縮める
+ */
+
+// 入力を断る理由
+const (
+	InputErrorEmpty        = "empty_message"       // 空（指示文を除いたら何も残らない場合も含む）
+	InputErrorTooLong      = "message_too_long"    // 上限を大きく超える長さ
+	InputErrorEncoding     = "invalid_encoding"    // UTF-8として読めない
+	InputErrorBinary       = "binary_input"        // 制御文字を多く含む（バイナリ）
+	InputErrorGarbage      = "garbage_input"       // 文字化け・記号の羅列・エンコードされたデータ
+	InputErrorInjection    = "prompt_injection"    // 指示文しか書かれていない
+	InputWarningCondensed  = "condensed"           // 長いので縮めた
+	InputWarningInjection  = "injection_removed"   // 指示文を取り除いた
+	InputWarningControlTag = "control_tag_removed" // チャットの制御トークン・役割の見出しを取り除いた
+)
+
+// 入力を断るときのエラー
+type inputError struct {
+	Code    string
+	Message string
+}
+
+func (e *inputError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+const (
+	defaultChatMaxChars    = 12000  // これを超えたら縮める
+	defaultChatRejectChars = 100000 // これを超えたら断る
+	inputChunkRunes        = 2000   // 縮めるときの区切りの大きさ
+	inputRemovedMarker     = "（指示文を除去）"
+)
+
+var (
+	// 指示文のパターン
+	injectionPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b[^\n。]{0,40}?\b(previous|prior|above|earlier|all|any|system|your|the)\b[^\n。]{0,20}?\b(instructions?|prompts?|rules?|messages?|directions?)\b`),
+		regexp.MustCompile(`(?i)\b(you are now|from now on,? you|pretend to be|new instructions\s*:)`),
+		regexp.MustCompile(`(?i)\b(reveal|show|print|repeat|output)\b[^\n。]{0,20}?\b(system prompt|your instructions|the instructions)\b`),
+		regexp.MustCompile(`(これまで|今まで|以前|上記|前|先)の(指示|命令|プロンプト|設定|ルール)[^\n。]{0,10}(無視|忘れ|破棄|リセット)`),
+		regexp.MustCompile(`(指示|命令|プロンプト)を(無視|忘れ)`),
+		// 「システムプロンプトの設計を担当」のような経歴は残し、見せる・無視するなどの指示と一緒のときだけ取り除く
+		regexp.MustCompile(`システムプロンプト[^\n。]{0,15}?(見せ|表示|出力|教え|開示|公開|貼っ|貼り|無視|忘れ|書き換え|上書き)`),
+		regexp.MustCompile(`あなたは(今から|これから|以降)`),
+	}
+	// チャットテンプレートの制御トークンと、行頭の役割の見出し
+	controlTagPattern = regexp.MustCompile(`(?im)^\s*(system|assistant|developer)\s*[:：]|<\|[a-z_]+\|>|\[/?INST\]|<</?SYS>>|</s>`)
+	// エンコードされたデータ（Base64・16進）のような長い英数字の並び
+	encodedBlobPattern = regexp.MustCompile(`[A-Za-z0-9+/=_\-]{200,}`)
+	// 経験年数・期間・単価を含む行
+	inputFactPattern = regexp.MustCompile(`\d+(\.\d+)?\s*(年|ヶ月|か月|カ月|ヵ月|万)|\d{4}\s*[/\-年.]\s*\d{1,2}`)
+)
+
+/**
+ * チャットのメッセージをチェックしてAIに渡せる形に整える
+ * @return []string 整えた内容（縮めた・指示文を取り除いたなど）
+ */
+func sanitizeChatMessage(message string) (string, []string, *inputError) {
+	if strings.TrimSpace(message) == "" {
+		return "", nil, &inputError{InputErrorEmpty, "メッセージが空です。"}
+	}
+	if !utf8.ValidString(message) {
+		return "", nil, &inputError{InputErrorEncoding, "メッセージをUTF-8として読み取れません。"}
+	}
+	rejectChars := envInt("CHAT_REJECT_CHARS", defaultChatRejectChars)
+	if utf8.RuneCountInString(message) > rejectChars {
+		return "", nil, &inputError{InputErrorTooLong, "メッセージが長すぎます（" + strconv.Itoa(rejectChars) + "文字まで）。"}
+	}
+	if err := checkInputContent(message); err != nil {
+		return "", nil, err
+	}
+
+	var warnings []string
+	text := strings.ReplaceAll(message, "\r\n", "\n")
+	if cleaned := controlTagPattern.ReplaceAllString(text, ""); cleaned != text {
+		text = cleaned
+		warnings = append(warnings, InputWarningControlTag)
+	}
+	if neutralized, found := neutralizeInjection(text); found {
+		text = neutralized
+		warnings = append(warnings, InputWarningInjection)
+		if strings.TrimSpace(strings.ReplaceAll(text, inputRemovedMarker, "")) == "" {
+			return "", nil, &inputError{InputErrorInjection, "スキルや経験についての内容が含まれていません。"}
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", nil, &inputError{InputErrorEmpty, "メッセージが空です。"}
+	}
+
+	if maxChars := envInt("CHAT_MAX_CHARS", defaultChatMaxChars); utf8.RuneCountInString(text) > maxChars {
+		text = condenseMessage(text, maxChars)
+		warnings = append(warnings, InputWarningCondensed)
+	}
+
+	return strings.TrimSpace(text), warnings, nil
+}
+
+/**
+ * バイナリ・文字化け・意味のない文字列でないか確認する
+ */
+func checkInputContent(message string) *inputError {
+	var total, control, replacement, word int
+	for _, r := range message {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			continue
+		case r == 0 || unicode.IsControl(r):
+			control++
+		case r == utf8.RuneError:
+			replacement++
+		case unicode.IsSpace(r):
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word++
+		}
+		total++
+	}
+	if total == 0 {
+		return &inputError{InputErrorEmpty, "メッセージが空です。"}
+	}
+
+	if strings.ContainsRune(message, 0) || control*100 > total {
+		return &inputError{InputErrorBinary, "バイナリデータのような入力は受け付けていません。テキストで送ってください。"}
+	}
+	if replacement*10 > total {
+		return &inputError{InputErrorGarbage, "文字化けしているようです。文字コードを確認してください。"}
+	}
+	if word*10 < total*3 {
+		return &inputError{InputErrorGarbage, "記号が多く、内容を読み取れません。"}
+	}
+	blobRunes := 0
+	for _, blob := range encodedBlobPattern.FindAllString(message, -1) {
+		blobRunes += len(blob)
+	}
+	if blobRunes*2 > total {
+		return &inputError{InputErrorGarbage, "エンコードされたデータのような入力は受け付けていません。"}
+	}
+	return nil
+}
+
+/**
+ * 指示文を含む文を取り除く
+ * 文の区切り（。！？改行、英文のピリオド）までをまとめて目印に置き換える
+ * @return bool 指示文が見つかったかどうか
+ */
+func neutralizeInjection(text string) (string, bool) {
+	found := false
+	for _, pattern := range injectionPatterns {
+		for {
+			loc := pattern.FindStringIndex(text)
+			if loc == nil {
+				break
+			}
+			found = true
+			start := 0
+			if idx := strings.LastIndexAny(text[:loc[0]], "。！？!?\n"); idx >= 0 {
+				_, size := utf8.DecodeRuneInString(text[idx:])
+				start = idx + size
+			}
+			if dot := strings.LastIndex(text[start:loc[0]], ". "); dot >= 0 {
+				start += dot + 2
+			}
+			end := len(text)
+			if idx := strings.IndexAny(text[loc[1]:], "。！？!?\n"); idx >= 0 {
+				end = loc[1] + idx
+				if text[end] != '\n' {
+					_, size := utf8.DecodeRuneInString(text[end:])
+					end += size
+				}
+			}
+			if dot := strings.Index(text[loc[1]:end], ". "); dot >= 0 {
+				end = loc[1] + dot + 1
+			}
+			text = text[:start] + inputRemovedMarker + text[end:]
+		}
+	}
+	return text, found
+}
+
+/**
+ * 長いメッセージを縮める
+ * 重複した行・記号だけの行を除いたうえで、区切り（約2000文字）ごとに同じ文字数を割り当て、
+ * 区切りの中ではスキル・年数・単価を含む行を優先して残す（順番は元のまま）
+ * 後半の職歴が丸ごと切り捨てられないよう、先頭から詰めるのではなく区切りごとに残す
+ */
+func condenseMessage(text string, maxChars int) string {
+	var lines []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.ContainsFunc(trimmed, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+		key := normalizeMessage(trimmed)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		lines = append(lines, trimmed)
+	}
+
+	joined := strings.Join(lines, "\n")
+	if utf8.RuneCountInString(joined) <= maxChars {
+		return joined
+	}
+
+	var chunks [][]string
+	var current []string
+	size := 0
+	for _, line := range lines {
+		n := utf8.RuneCountInString(line) + 1
+		if size+n > inputChunkRunes && len(current) > 0 {
+			chunks = append(chunks, current)
+			current, size = nil, 0
+		}
+		current = append(current, line)
+		size += n
+	}
+	chunks = append(chunks, current)
+
+	budget := maxChars / len(chunks)
+	var out []string
+	for _, chunk := range chunks {
+		out = append(out, condenseChunk(chunk, budget)...)
+	}
+	return truncateRunes(strings.Join(out, "\n"), maxChars)
+}
+
+/**
+ * 区切り1つ分の行から、文字数の範囲で残す行を選ぶ（重要な行が先、出力は元の順番）
+ */
+func condenseChunk(lines []string, budget int) []string {
+	keep := make([]string, len(lines))
+	remaining := budget
+	for _, important := range []bool{true, false} {
+		for i, line := range lines {
+			if keep[i] != "" || isImportantLine(line) != important || remaining <= 1 {
+				continue
+			}
+			if n := utf8.RuneCountInString(line) + 1; n <= remaining {
+				keep[i] = line
+				remaining -= n
+			} else if important {
+				keep[i] = string([]rune(line)[:remaining-1])
+				remaining = 0
+			}
+		}
+	}
+
+	var out []string
+	for _, line := range keep {
+		if line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+/**
+ * スキル・年数・単価を含む行かどうか
+ */
+func isImportantLine(line string) bool {
+	if inputFactPattern.MatchString(line) {
+		return true
+	}
+	return len(extractOfflineSkills(normalizeMessage(line))) > 0
+}
+
+/**
+ * 環境変数を正の整数として読む（読めなければデフォルト）
+ */
+func envInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnvWithDefault(key, strconv.Itoa(defaultValue)))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+/**
+ * リクエストのメッセージをチェックして置き換える
+ * 断る場合は400（codeつき）を書き込んでfalseを返す
+ */
+func guardChatRequest(c *gin.Context, req *ChatRequest) ([]string, bool) {
+	message, warnings, err := sanitizeChatMessage(req.Message)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Message, "code": err.Code})
+		return nil, false
+	}
+	req.Message = message
+	return warnings, true
+}
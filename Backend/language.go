@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+/**
+ * 入力の言語判定モジュール
+ * 英語で書かれたスキルシートでも日本語の案件（tbl_project）に当たるよう、
+ * 英語の入力からは日本語・カタカナの検索語（"backend" → "バックエンド"）を作って検索に足す
+ * スキル名そのもの（"Java" → "ジャバ"）はスキル辞書のエイリアスで照合する
+ */
+
+// 入力の言語
+const (
+	LanguageJapanese = "ja"
+	LanguageEnglish  = "en"
+)
+
+// 英語の入力から作る日本語の検索語の最大数
+const searchTermLimit = 6
+
+// 英語の語句と、対応する日本語の検索語（語句は単語の区切りで照合する）
+var englishSearchTerms = []struct {
+	Phrases []string
+	Terms   []string
+}{
+	{[]string{"back-end", "backend", "server-side", "server side"}, []string{"バックエンド", "サーバーサイド"}},
+	{[]string{"front-end", "frontend"}, []string{"フロントエンド"}},
+	{[]string{"full-stack", "full stack", "fullstack"}, []string{"フルスタック"}},
+	{[]string{"infrastructure", "infra", "devops", "site reliability"}, []string{"インフラ構築", "インフラ"}},
+	{[]string{"cloud migration", "migration", "replacement"}, []string{"移行", "リプレイス"}},
+	{[]string{"mobile app", "ios app", "android app", "mobile"}, []string{"スマホアプリ", "アプリ開発"}},
+	{[]string{"web application", "web app", "web service"}, []string{"Webアプリ"}},
+	{[]string{"maintenance", "operations", "operation"}, []string{"保守", "運用"}},
+	{[]string{"requirements definition", "requirements"}, []string{"要件定義"}},
+	{[]string{"system design", "architecture", "basic design", "detailed design"}, []string{"設計"}},
+	{[]string{"quality assurance", "testing", "qa"}, []string{"テスト"}},
+	{[]string{"project manager", "project management", "pmo"}, []string{"PM", "プロジェクトマネージャー"}},
+	{[]string{"project leader", "team lead", "tech lead", "team leader"}, []string{"PL", "リーダー"}},
+	{[]string{"data engineering", "data pipeline", "data platform"}, []string{"データ基盤"}},
+	{[]string{"data analysis", "data analyst", "data science"}, []string{"データ分析"}},
+	{[]string{"machine learning", "deep learning"}, []string{"機械学習"}},
+	{[]string{"security"}, []string{"セキュリティ"}},
+	{[]string{"network"}, []string{"ネットワーク"}},
+	{[]string{"database"}, []string{"データベース"}},
+	{[]string{"embedded", "firmware"}, []string{"組み込み"}},
+	{[]string{"game"}, []string{"ゲーム"}},
+	{[]string{"automated trading", "trading bot", "expert advisor"}, []string{"自動売買", "EA"}},
+	{[]string{"consulting", "consultant"}, []string{"コンサル"}},
+	{[]string{"help desk", "helpdesk", "it support"}, []string{"ヘルプデスク"}},
+	{[]string{"remote"}, []string{"リモート"}},
+}
+
+// 英文によく出る語（英字の多い日本語の職務経歴と英文を見分ける）
+var englishFunctionWords = map[string]bool{
+	"the": true, "and": true, "with": true, "of": true, "in": true, "for": true, "to": true,
+	"i": true, "my": true, "am": true, "have": true, "years": true, "year": true, "experience": true,
+}
+
+/**
+ * メッセージの言語を判定する
+ * 日本語の職務経歴も"Java"や"Spring Boot"など英字が多いので、
+ * 英字が日本語の文字（かな・漢字）の10倍を超え、かつ日本語の文字がないか英文によく出る語が2つ以上あるときだけ英語とする
+ */
+func detectLanguage(text string) string {
+	latin, japanese := 0, 0
+	for _, r := range text {
+		switch {
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			latin++
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Han):
+			japanese++
+		}
+	}
+	if latin == 0 || japanese*10 >= latin {
+		return LanguageJapanese
+	}
+	if japanese == 0 {
+		return LanguageEnglish
+	}
+
+	functionWords := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return r >= unicode.MaxASCII || !unicode.IsLetter(r) }) {
+		if englishFunctionWords[word] {
+			functionWords++
+		}
+	}
+	if functionWords >= 2 {
+		return LanguageEnglish
+	}
+	return LanguageJapanese
+}
+
+/**
+ * 英語のメッセージから日本語の検索語を作る（出てきた語句の表の順、最大searchTermLimit件）
+ */
+func japaneseSearchTerms(message string) []string {
+	text := normalizeMessage(message)
+	var terms []string
+	seen := map[string]bool{}
+	for _, entry := range englishSearchTerms {
+		if !containsAnyPhrase(text, entry.Phrases) {
+			continue
+		}
+		for _, term := range entry.Terms {
+			if seen[term] || len(terms) >= searchTermLimit {
+				continue
+			}
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+/**
+ * 語句のどれかが単語として含まれるか（"infra"が"infrastructure"の途中に当たらないように）
+ */
+func containsAnyPhrase(text string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if containsPhrase(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * 英語のテキストなら日本語の検索語を検索条件に足す（意味検索の文章にも足す）
+ */
+func addJapaneseSearchTerms(params *SearchParams, text string) {
+	if detectLanguage(text) != LanguageEnglish {
+		return
+	}
+	params.SearchTerms = japaneseSearchTerms(text)
+	if len(params.SearchTerms) > 0 {
+		params.Query = strings.TrimSpace(params.Query + "\n" + strings.Join(params.SearchTerms, " "))
+	}
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * LLMプロバイダー管理モジュール
+ * OpenRouter / Gemini / OpenAI互換エンドポイント / モックを同じインターフェースで扱う
+ * どのプロバイダーを使うかは環境変数で切り替える
+ */
+
+// プロバイダー名の定義
+const (
+	ProviderOpenRouter = "openrouter" // OpenRouter（OpenAI互換API）
+	ProviderGemini     = "gemini"     // Google Gemini API
+	ProviderOpenAI     = "openai"     // 汎用OpenAI互換エンドポイント（Ollama/vLLMなど）
+	ProviderMock       = "mock"       // 固定レスポンスを返すモック
+)
+
+// プロバイダーごとのデフォルト接続先
+const (
+	defaultOpenRouterBaseURL = "https://openrouter.ai/api/v1"
+	defaultGeminiBaseURL     = "https://generativelanguage.googleapis.com/v1beta"
+)
+
+// LLM設定の構造体
+type LLMConfig struct {
+	Provider       string  // プロバイダー名
+	Model          string  // モデル名
+	Temperature    float64 // 生成時のtemperature
+	BaseURL        string  // APIのベースURL
+	APIKey         string  // APIキー
+	RepairAttempts int     // スキーマ違反時にAIへ修正を依頼する最大回数
+}
+
+// LLM呼び出し結果の構造体
+type LLMResult struct {
+	Content string   // モデルの返答本文
+	Model   string   // 実際に応答したモデル名
+	Usage   LLMUsage // トークン使用量（プロバイダーが返さなければ0）
+}
+
+// トークン使用量（OpenAI互換APIのusageと同じ形）
+type LLMUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// LLMプロバイダーのインターフェース
+// 会話メッセージを受け取り、モデルの返答テキストを返す
+type LLMProvider interface {
+	Name() string
+	Model() string
+	Chat(ctx context.Context, messages []AIChatMessage) (LLMResult, error)
+}
+
+// ストリーミングに対応したLLMプロバイダーのインターフェース
+// 返答テキストを受け取った分だけonDeltaに渡し、最後に全文を返す
+type StreamingLLMProvider interface {
+	LLMProvider
+	ChatStream(ctx context.Context, messages []AIChatMessage, onDelta func(string)) (LLMResult, error)
+}
+
+// 使用中のLLMプロバイダー（nilの場合は呼び出しごとに設定から生成）
+// テストではモックに差し替えて使う
+var llmProvider LLMProvider
+
+/**
+ * 環境変数からLLM設定を読み込む
+ * @return LLMConfig LLM設定
+ */
+func LoadLLMConfig() LLMConfig {
+	provider := strings.ToLower(getEnvWithDefault("LLM_PROVIDER", ProviderOpenRouter))
+
+	temperature, err := strconv.ParseFloat(getEnvWithDefault("LLM_TEMPERATURE", "0.7"), 64)
+	if err != nil {
+		temperature = 0.7
+	}
+
+	repairAttempts, err := strconv.Atoi(getEnvWithDefault("LLM_REPAIR_ATTEMPTS", "2"))
+	if err != nil || repairAttempts < 0 {
+		repairAttempts = 2
+	}
+
+	config := LLMConfig{
+		Provider:       provider,
+		Model:          os.Getenv("LLM_MODEL"),
+		Temperature:    temperature,
+		BaseURL:        os.Getenv("LLM_BASE_URL"),
+		APIKey:         os.Getenv("LLM_API_KEY"),
+		RepairAttempts: repairAttempts,
+	}
+
+	// プロバイダーごとのデフォルト値を補完
+	switch provider {
+	case ProviderOpenRouter:
+		if config.Model == "" {
+			config.Model = "openai/gpt-3.5-turbo"
+		}
+		if config.BaseURL == "" {
+			config.BaseURL = defaultOpenRouterBaseURL
+		}
+		if config.APIKey == "" {
+			config.APIKey = os.Getenv("OPENROUTER_API_KEY")
+		}
+	case ProviderGemini:
+		if config.Model == "" {
+			config.Model = "gemini-1.5-flash"
+		}
+		if config.BaseURL == "" {
+			config.BaseURL = defaultGeminiBaseURL
+		}
+		if config.APIKey == "" {
+			config.APIKey = os.Getenv("GEMINI_API_KEY")
+		}
+	case ProviderMock:
+		if config.Model == "" {
+			config.Model = "mock"
+		}
+	}
+
+	return config
+}
+
+/**
+ * 設定からLLMプロバイダーを生成
+ * @param config LLM設定
+ * @return LLMProvider プロバイダー
+ * @return error 設定不備の場合のエラー
+ */
+func NewLLMProvider(config LLMConfig) (LLMProvider, error) {
+	switch config.Provider {
+	case ProviderOpenRouter:
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("OPENROUTER_API_KEY not set")
+		}
+		return &openAICompatibleProvider{name: ProviderOpenRouter, config: config}, nil
+	case ProviderOpenAI:
+		// 自前ホストのOllama/vLLMはAPIキー不要なのでURLとモデルだけ必須
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("LLM_BASE_URL not set")
+		}
+		if config.Model == "" {
+			return nil, fmt.Errorf("LLM_MODEL not set")
+		}
+		return &openAICompatibleProvider{name: ProviderOpenAI, config: config}, nil
+	case ProviderGemini:
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("GEMINI_API_KEY not set")
+		}
+		return &geminiProvider{config: config}, nil
+	case ProviderMock:
+		return newMockProvider(os.Getenv("LLM_MOCK_RESPONSE")), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", config.Provider)
+	}
+}
+
+/**
+ * 使用するLLMプロバイダーを取得
+ * 差し替え済みのプロバイダーがあればそれを優先する
+ * どちらの場合も、呼び出しごとに使用量を記録するラッパー（usage.go）を被せて返す
+ */
+func getLLMProvider() (LLMProvider, error) {
+	if llmProvider != nil {
+		return withUsageMetering(llmProvider), nil
+	}
+	provider, err := NewLLMProvider(LoadLLMConfig())
+	if err != nil {
+		return nil, err
+	}
+	return withUsageMetering(provider), nil
+}
+
+/**
+ * 現在使用するモデル名を取得（プロバイダーの生成には失敗してもよい）
+ * キャッシュキーなど、AIを呼ばずにモデル名だけ必要な場面で使う
+ */
+func currentLLMModel() string {
+	if llmProvider != nil {
+		return llmProvider.Model()
+	}
+	return LoadLLMConfig().Model
+}
+
+/**
+ * JSONボディ付きのPOSTリクエストを作成
+ */
+func newJSONRequest(ctx context.Context, url string, headers map[string]string, payload interface{}) (*http.Request, error) {
+	jsonBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	return req, nil
+}
+
+/**
+ * HTTP POSTでJSONを送信し、レスポンスボディを返す
+ * タイムアウト・再試行・サーキットブレーカーはaiclient.goで行う
+ * ステータスが200以外の場合は種類を判別したエラー（*AIError）を返す
+ */
+func postJSON(ctx context.Context, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+	resp, cancel, err := doAIRequest(ctx, url, LoadAIClientConfig().Timeout, func(ctx context.Context) (*http.Request, error) {
+		return newJSONRequest(ctx, url, headers, payload)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, &AIError{Kind: AIErrorTimeout, Err: fmt.Errorf("AI API call timed out: %v", err)}
+		}
+		return nil, &AIError{Kind: AIErrorUnavailable, Err: fmt.Errorf("failed to read response body: %v", err)}
+	}
+
+	return body, nil
+}
+
+/**
+ * 応答を読み取れなかったときのエラー
+ */
+func badAIResponse(format string, args ...interface{}) error {
+	return &AIError{Kind: AIErrorBadResponse, Err: fmt.Errorf(format, args...)}
+}
+
+// ============================================================
+// OpenAI互換プロバイダー（OpenRouter / Ollama / vLLM）
+// ============================================================
+
+type openAICompatibleProvider struct {
+	name   string
+	config LLMConfig
+}
+
+func (p *openAICompatibleProvider) Name() string  { return p.name }
+func (p *openAICompatibleProvider) Model() string { return p.config.Model }
+
+func (p *openAICompatibleProvider) Chat(ctx context.Context, messages []AIChatMessage) (LLMResult, error) {
+	reqBody := AIChatRequest{
+		Model:       p.config.Model,
+		Messages:    messages,
+		Temperature: p.config.Temperature,
+	}
+
+	headers := map[string]string{}
+	if p.config.APIKey != "" {
+		headers["Authorization"] = "Bearer " + p.config.APIKey
+	}
+
+	url := strings.TrimSuffix(p.config.BaseURL, "/") + "/chat/completions"
+	body, err := postJSON(ctx, url, headers, reqBody)
+	if err != nil {
+		return LLMResult{}, err
+	}
+
+	var chatResp AIChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return LLMResult{}, badAIResponse("failed to parse AI response: %v", err)
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return LLMResult{}, badAIResponse("empty choices from AI")
+	}
+
+	model := chatResp.Model
+	if model == "" {
+		model = p.config.Model
+	}
+
+	result := LLMResult{Content: chatResp.Choices[0].Message.Content, Model: model}
+	if chatResp.Usage != nil {
+		result.Usage = *chatResp.Usage
+	}
+	return result, nil
+}
+
+// ストリーミング時のチャンク（OpenAI互換のSSE形式）
+// usageはstream_options.include_usageを指定したときに最後のチャンクにだけ入る
+type aiChatStreamChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta AIChatMessage `json:"delta"`
+	} `json:"choices"`
+	Usage *LLMUsage `json:"usage"`
+}
+
+func (p *openAICompatibleProvider) ChatStream(ctx context.Context, messages []AIChatMessage, onDelta func(string)) (LLMResult, error) {
+	reqBody := AIChatRequest{
+		Model:         p.config.Model,
+		Messages:      messages,
+		Temperature:   p.config.Temperature,
+		Stream:        true,
+		StreamOptions: &AIChatStreamOptions{IncludeUsage: true},
+	}
+
+	headers := map[string]string{"Accept": "text/event-stream"}
+	if p.config.APIKey != "" {
+		headers["Authorization"] = "Bearer " + p.config.APIKey
+	}
+
+	// 再試行するのは返答が流れ始める前（200が返るまで）だけ
+	url := strings.TrimSuffix(p.config.BaseURL, "/") + "/chat/completions"
+	resp, cancel, err := doAIRequest(ctx, url, LoadAIClientConfig().StreamTimeout, func(ctx context.Context) (*http.Request, error) {
+		return newJSONRequest(ctx, url, headers, reqBody)
+	})
+	if err != nil {
+		return LLMResult{}, err
+	}
+	defer cancel()
+	defer resp.Body.Close()
+
+	result := LLMResult{Model: p.config.Model}
+	var content strings.Builder
+
+	// "data: {...}" の行を順に読み、deltaを連結していく
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk aiChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			continue
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			content.WriteString(chunk.Choices[0].Delta.Content)
+			if onDelta != nil {
+				onDelta(chunk.Choices[0].Delta.Content)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return LLMResult{}, &AIError{Kind: AIErrorTimeout, Err: fmt.Errorf("AI stream timed out: %v", err)}
+		}
+		return LLMResult{}, &AIError{Kind: AIErrorUnavailable, Err: fmt.Errorf("failed to read stream: %v", err)}
+	}
+
+	if content.Len() == 0 {
+		return LLMResult{}, badAIResponse("empty choices from AI")
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+// ============================================================
+// Geminiプロバイダー
+// ============================================================
+
+// Gemini generateContent API用の構造体定義
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent `json:"contents"`
+	GenerationConfig  struct {
+		Temperature      float64 `json:"temperature"`
+		ResponseMimeType string  `json:"responseMimeType,omitempty"`
+	} `json:"generationConfig"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+	ModelVersion  string `json:"modelVersion"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+type geminiProvider struct {
+	config LLMConfig
+}
+
+func (p *geminiProvider) Name() string  { return ProviderGemini }
+func (p *geminiProvider) Model() string { return p.config.Model }
+
+func (p *geminiProvider) Chat(ctx context.Context, messages []AIChatMessage) (LLMResult, error) {
+	var reqBody geminiRequest
+	reqBody.GenerationConfig.Temperature = p.config.Temperature
+	reqBody.GenerationConfig.ResponseMimeType = "application/json"
+
+	// systemメッセージはsystemInstructionに、assistantはmodelロールに変換
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			reqBody.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: m.Content}}}
+		case "assistant":
+			reqBody.Contents = append(reqBody.Contents, geminiContent{Role: "model", Parts: []geminiPart{{Text: m.Content}}})
+		default:
+			reqBody.Contents = append(reqBody.Contents, geminiContent{Role: "user", Parts: []geminiPart{{Text: m.Content}}})
+		}
+	}
+
+	url := fmt.Sprintf("%s/models/%s:generateContent", strings.TrimSuffix(p.config.BaseURL, "/"), p.config.Model)
+	body, err := postJSON(ctx, url, map[string]string{"x-goog-api-key": p.config.APIKey}, reqBody)
+	if err != nil {
+		return LLMResult{}, err
+	}
+
+	var geminiResp geminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return LLMResult{}, badAIResponse("failed to parse AI response: %v", err)
+	}
+
+	if len(geminiResp.Candidates) == 0 {
+		return LLMResult{}, badAIResponse("empty candidates from AI")
+	}
+
+	var text strings.Builder
+	for _, part := range geminiResp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+
+	model := geminiResp.ModelVersion
+	if model == "" {
+		model = p.config.Model
+	}
+
+	return LLMResult{
+		Content: text.String(),
+		Model:   model,
+		Usage: LLMUsage{
+			PromptTokens:     geminiResp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      geminiResp.UsageMetadata.TotalTokenCount,
+		},
+	}, nil
+}
+
+// ============================================================
+// モックプロバイダー
+// ============================================================
+
+// APIキーなしで動作確認するための固定レスポンス
+const defaultMockResponse = `{
+  "estimated_salary": "月額60万円〜80万円",
+  "strengths": "バックエンド開発の経験が豊富",
+  "suggestions": "クラウド関連の資格取得を推奨",
+  "structured_skills": [{"skill_name": "Java", "experience_years": 3}],
+  "search_prompt": "Java バックエンド開発",
+  "key_skills": ["Java"],
+  "preferred_role": "バックエンドエンジニア",
+  "experience_level": "中級"
+}`
+
+// 登録されたレスポンスを順番に返すモック
+// 最後のレスポンスは以降の呼び出しでも返し続ける
+type mockProvider struct {
+	mu        sync.Mutex
+	responses []string
+	calls     int
+	received  [][]AIChatMessage
+}
+
+/**
+ * モックプロバイダーを生成
+ * @param responses 呼び出し順に返すレスポンス（空の場合は固定レスポンス）
+ */
+func newMockProvider(responses ...string) *mockProvider {
+	var filtered []string
+	for _, r := range responses {
+		if r != "" {
+			filtered = append(filtered, r)
+		}
+	}
+	if len(filtered) == 0 {
+		filtered = []string{defaultMockResponse}
+	}
+	return &mockProvider{responses: filtered}
+}
+
+func (p *mockProvider) Name() string  { return ProviderMock }
+func (p *mockProvider) Model() string { return "mock" }
+
+func (p *mockProvider) Chat(ctx context.Context, messages []AIChatMessage) (LLMResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	index := p.calls
+	if index >= len(p.responses) {
+		index = len(p.responses) - 1
+	}
+	p.calls++
+	p.received = append(p.received, messages)
+
+	return LLMResult{Content: p.responses[index], Model: "mock"}, nil
+}
+
+// 1回のdeltaで返す文字数（ストリーミングの再現用）
+const mockStreamChunkSize = 16
+
+func (p *mockProvider) ChatStream(ctx context.Context, messages []AIChatMessage, onDelta func(string)) (LLMResult, error) {
+	result, err := p.Chat(ctx, messages)
+	if err != nil {
+		return LLMResult{}, err
+	}
+
+	runes := []rune(result.Content)
+	for start := 0; start < len(runes); start += mockStreamChunkSize {
+		end := start + mockStreamChunkSize
+		if end > len(runes) {
+			end = len(runes)
+		}
+		if onDelta != nil {
+			onDelta(string(runes[start:end]))
+		}
+	}
+
+	return result, nil
+}
+
+/**
+ * モックが呼び出された回数を返す
+ */
+func (p *mockProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+/**
+ * マイグレーション管理モジュール
+ * バックエンドが使う追加テーブルを起動時に作成・更新する
+ * tbl_projectのテーブル定義は日次バッチ側の管理なので触らない（検索用のインデックスだけ足す）
+ */
+
+// マイグレーション1件分の定義
+type Migration struct {
+	Version int    // バージョン番号（昇順に適用）
+	Name    string // マイグレーション名
+	SQL     string // 実行するSQL
+}
+
+// 適用するマイグレーションの一覧（追加するときは末尾にバージョンを増やして足す）
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_tbl_aicache",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS tbl_aicache (
+				aickey text NOT NULL,                              -- キャッシュキー（正規化メッセージ+モデル+プロンプト版のハッシュ）
+				aicmdl text NOT NULL,                              -- モデル名
+				aicprv text NOT NULL,                              -- プロンプトバージョン
+				aicanl jsonb NOT NULL,                             -- AI分析結果
+				aiccrt timestamp with time zone NOT NULL DEFAULT now(), -- 登録日時
+				aicexp timestamp with time zone NOT NULL,          -- 有効期限
+				CONSTRAINT tbl_aicache_pkey PRIMARY KEY (aickey)
+			);
+			CREATE INDEX IF NOT EXISTS idx_aicache_aicexp ON tbl_aicache (aicexp);
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "create_tbl_session",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS tbl_session (
+				sesid text NOT NULL,                               -- セッションID
+				sesmsg jsonb NOT NULL DEFAULT '[]'::jsonb,         -- 会話履歴
+				sesanl jsonb NULL,                                 -- 直近のAI分析結果
+				sesprm jsonb NULL,                                 -- 直近の検索条件
+				sescrt timestamp with time zone NOT NULL DEFAULT now(), -- 作成日時
+				sesupd timestamp with time zone NOT NULL DEFAULT now(), -- 更新日時
+				sesexp timestamp with time zone NOT NULL,          -- 有効期限
+				CONSTRAINT tbl_session_pkey PRIMARY KEY (sesid)
+			);
+			CREATE INDEX IF NOT EXISTS idx_session_sesexp ON tbl_session (sesexp);
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "create_tbl_skill",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS tbl_skill (
+				sklnam text NOT NULL,                              -- スキルの正式名
+				sklals jsonb NOT NULL DEFAULT '[]'::jsonb,         -- エイリアス（文字列の配列）
+				sklupd timestamp with time zone NOT NULL DEFAULT now(), -- 更新日時
+				CONSTRAINT tbl_skill_pkey PRIMARY KEY (sklnam)
+			);
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "create_tbl_aiusage",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS tbl_aiusage (
+				aiuid bigserial NOT NULL,                          -- 連番
+				aiuprv text NOT NULL,                              -- プロバイダー名
+				aiumdl text NOT NULL,                              -- モデル名
+				aiupur text NOT NULL,                              -- 用途（analysis / refine / rerank）
+				aiuptk integer NOT NULL DEFAULT 0,                 -- 入力トークン数
+				aiuctk integer NOT NULL DEFAULT 0,                 -- 出力トークン数
+				aiuttk integer NOT NULL DEFAULT 0,                 -- 合計トークン数
+				aiulat integer NOT NULL DEFAULT 0,                 -- 応答時間（ミリ秒）
+				aiusts text NOT NULL,                              -- 成否（ok / error）
+				aiuerr text NOT NULL DEFAULT '',                   -- エラーメッセージ
+				aiucrt timestamp with time zone NOT NULL DEFAULT now(), -- 呼び出し日時
+				CONSTRAINT tbl_aiusage_pkey PRIMARY KEY (aiuid)
+			);
+			CREATE INDEX IF NOT EXISTS idx_aiusage_aiucrt ON tbl_aiusage (aiucrt);
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "create_tbl_prompt",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS tbl_prompt (
+				prmnam text NOT NULL,                              -- テンプレート名（analysis / rerank）
+				prmver text NOT NULL,                              -- バージョン
+				prmtxt text NOT NULL,                              -- テンプレート本文（text/templateの書式）
+				prmcrt timestamp with time zone NOT NULL DEFAULT now(), -- 登録日時
+				CONSTRAINT tbl_prompt_pkey PRIMARY KEY (prmnam, prmver)
+			);
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "add_aiusage_prompt_version",
+		SQL: `
+			ALTER TABLE tbl_aiusage ADD COLUMN IF NOT EXISTS aiupvr text NOT NULL DEFAULT ''; -- プロンプトのバージョン
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "create_tbl_projectvec",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS tbl_projectvec (
+				pvcurl text NOT NULL,                              -- 案件URL（tbl_project.prourl）
+				pvcmdl text NOT NULL,                              -- 埋め込みのモデル名
+				pvchsh text NOT NULL,                              -- ベクトルにした文章のハッシュ（変わったら作り直す）
+				pvcvec real[] NOT NULL,                            -- ベクトル（pgvectorがあれば検索時にvectorへキャスト）
+				pvcupd timestamp with time zone NOT NULL DEFAULT now(), -- 更新日時
+				CONSTRAINT tbl_projectvec_pkey PRIMARY KEY (pvcurl, pvcmdl)
+			);
+			CREATE INDEX IF NOT EXISTS idx_projectvec_pvcmdl ON tbl_projectvec (pvcmdl);
+		`,
+	},
+	{
+		// SEARCH_BACKEND=trgm用（searchbackend.go）。pg_trgmを入れられない環境ではインデックスなしで続ける
+		Version: 8,
+		Name:    "create_project_trgm_indexes",
+		SQL: `
+			DO $$
+			BEGIN
+				CREATE EXTENSION IF NOT EXISTS pg_trgm;
+			EXCEPTION WHEN OTHERS THEN
+				RAISE NOTICE 'pg_trgm is not available: %', SQLERRM;
+			END
+			$$;
+			DO $$
+			BEGIN
+				IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') AND to_regclass('tbl_project') IS NOT NULL THEN
+					CREATE INDEX IF NOT EXISTS idx_project_prottl_trgm ON tbl_project USING gin (prottl gin_trgm_ops);
+					CREATE INDEX IF NOT EXISTS idx_project_proot1_trgm ON tbl_project USING gin (proot1 gin_trgm_ops);
+					CREATE INDEX IF NOT EXISTS idx_project_prodtl_trgm ON tbl_project USING gin (prodtl gin_trgm_ops);
+				END IF;
+			END
+			$$;
+		`,
+	},
+	{
+		// 単価の絞り込み（filter.go）をSQLで行うための関数。読み方はsalary.goのparseSalaryRange、比べ方はSalaryRange.Overlapsと同じ
+		// 単価が読み取れない案件はtrue（除かない）。min_price・max_priceの0は指定なし
+		Version: 9,
+		Name:    "create_project_price_matches",
+		SQL: `
+			CREATE OR REPLACE FUNCTION project_price_matches(price text, min_price bigint, max_price bigint)
+			RETURNS boolean
+			LANGUAGE plpgsql IMMUTABLE
+			AS $fn$
+			DECLARE
+				t text;
+				m text[];
+				lo numeric := 0;
+				hi numeric := 0;
+				swap numeric;
+			BEGIN
+				-- normalizeMessageと同じく小文字・半角にそろえ、数字の桁区切りを外す
+				t := translate(lower(coalesce(price, '')), '０１２３４５６７８９，．～－／ｈ　', '0123456789,.~-/h ');
+				t := regexp_replace(regexp_replace(t, '(\d),(\d)', '\1\2', 'g'), '(\d),(\d)', '\1\2', 'g');
+
+				-- 範囲（"60万円〜80万円" "60-80万"）→ 上限のみ（"〜90万円" "90万円まで"）→ 単独の金額（"80万円" "90万円以上"）
+				m := regexp_match(t, '(\d+(?:\.\d+)?)\s*(万円?|円)?\s*(?:〜|~|-|ー|から)\s*(\d+(?:\.\d+)?)\s*(万円?|円)');
+				IF m IS NOT NULL THEN
+					lo := trunc(m[1]::numeric * CASE WHEN coalesce(m[2], m[4]) LIKE '万%' THEN 10000 ELSE 1 END);
+					hi := trunc(m[3]::numeric * CASE WHEN m[4] LIKE '万%' THEN 10000 ELSE 1 END);
+				ELSE
+					m := regexp_match(t, '(?:〜|~|最大|上限)\s*(\d+(?:\.\d+)?)\s*(万円?|円)');
+					IF m IS NULL THEN
+						m := regexp_match(t, '(\d+(?:\.\d+)?)\s*(万円?|円)\s*(?:まで|以下|以内)');
+					END IF;
+					IF m IS NOT NULL THEN
+						hi := trunc(m[1]::numeric * CASE WHEN m[2] LIKE '万%' THEN 10000 ELSE 1 END);
+					ELSE
+						m := regexp_match(t, '(\d+(?:\.\d+)?)\s*(万円?|円)\s*(以上|〜|~|から)?');
+						IF m IS NULL THEN
+							RETURN true;
+						END IF;
+						lo := trunc(m[1]::numeric * CASE WHEN m[2] LIKE '万%' THEN 10000 ELSE 1 END);
+						hi := CASE WHEN m[3] IS NULL THEN lo ELSE 0 END;
+					END IF;
+				END IF;
+
+				-- 月額以外の表記を月額に換算（時給×160時間、日給×20日、年収÷12）
+				IF t LIKE '%時給%' OR t LIKE '%/h%' THEN
+					lo := lo * 160;
+					hi := hi * 160;
+				ELSIF t LIKE '%日給%' OR t LIKE '%日額%' THEN
+					lo := lo * 20;
+					hi := hi * 20;
+				ELSIF t ~ '年収|年俸|年額' THEN
+					lo := div(lo, 12);
+					hi := div(hi, 12);
+				END IF;
+				IF lo > 0 AND hi > 0 AND lo > hi THEN
+					swap := lo;
+					lo := hi;
+					hi := swap;
+				END IF;
+				IF lo = 0 AND hi = 0 THEN
+					RETURN true;
+				END IF;
+
+				IF hi > 0 AND min_price > 0 AND hi < min_price THEN
+					RETURN false;
+				END IF;
+				IF max_price > 0 AND lo > 0 AND max_price < lo THEN
+					RETURN false;
+				END IF;
+				RETURN true;
+			END
+			$fn$;
+		`,
+	},
+}
+
+/**
+ * 未適用のマイグレーションを順番に適用
+ * 適用済みのバージョンはtbl_migrationに記録する
+ * @param database データベース接続オブジェクト
+ * @return error エラー情報
+ */
+func RunMigrations(database *sql.DB) error {
+	if database == nil {
+		return fmt.Errorf("database connection is nil")
+	}
+
+	_, err := database.Exec(`
+		CREATE TABLE IF NOT EXISTS tbl_migration (
+			migver integer NOT NULL,
+			mignam text NOT NULL,
+			migapl timestamp with time zone NOT NULL DEFAULT now(),
+			CONSTRAINT tbl_migration_pkey PRIMARY KEY (migver)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create migration table: %v", err)
+	}
+
+	applied, err := loadAppliedMigrations(database)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+
+		tx, err := BeginTransaction(database)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(m.SQL); err != nil {
+			RollbackTransaction(tx)
+			return fmt.Errorf("migration %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+
+		if _, err := tx.Exec("INSERT INTO tbl_migration (migver, mignam) VALUES ($1, $2)", m.Version, m.Name); err != nil {
+			RollbackTransaction(tx)
+			return fmt.Errorf("failed to record migration %d: %v", m.Version, err)
+		}
+
+		if err := CommitTransaction(tx); err != nil {
+			return err
+		}
+
+		log.Printf("[INFO] Migration applied: %d %s", m.Version, m.Name)
+	}
+
+	return nil
+}
+
+/**
+ * 適用済みのマイグレーションバージョンを取得
+ */
+func loadAppliedMigrations(database *sql.DB) (map[int]bool, error) {
+	rows, err := database.Query("SELECT migver FROM tbl_migration")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan migration version: %v", err)
+		}
+		applied[version] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %v", err)
+	}
+
+	return applied, nil
+}
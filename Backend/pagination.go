@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * 検索結果のページ送りモジュール
+ * 「もっと見る」で、セッションの直近の検索条件の続きの案件をAIを呼ばずに返す
+ *
+ * 並びはキーワードのスコア（スキルのスコア＋単価の点数）の高い順、同点ならマッチしたスキルの多い順、掲載日（procrt）の新しい順、URL（prourl）の順
+ * カーソルは最後に返した案件のこの4つを持つので、案件が追加されても返した案件の並びは変わらない
+ * （追加された案件は、カーソルより後ろに並ぶものだけが続きのページに出る）
+ *
+ * 1ページ目はAIでの並べ直し・意味検索・サイトごとの件数制限で並びが変わるので、
+ * キーワードの並びで先頭から切れ目なく返した所までをカーソルにし、それより後ろで返した案件はカーソルに入れて除く
+ * 2ページ目以降はキーワードの並びのまま返す（サイトごとの件数制限・並べ直し・英訳はしない）
+ */
+
+// ページ送りのカーソル（base64urlにしたJSONをクライアントに渡す）
+type searchCursor struct {
+	Score      int      `json:"s"`           // 最後の案件のスコア
+	MatchCount int      `json:"m"`           // 最後の案件のマッチしたスキルの数
+	PostedAt   string   `json:"c"`           // 最後の案件の掲載日（procrt）
+	URL        string   `json:"u"`           // 最後の案件のURL（prourl、空なら先頭から）
+	Seen       []string `json:"x,omitempty"` // カーソルより後ろにあるが、前のページで返した案件のURL
+	Search     string   `json:"q"`           // 検索条件のハッシュ（セッションで新しく検索したら使えない）
+}
+
+// 続きの案件のレスポンス
+type ProjectPage struct {
+	SessionID  string    `json:"session_id"`            // 会話セッションID
+	Projects   []Project `json:"projects"`              // 続きの案件
+	NextCursor string    `json:"next_cursor,omitempty"` // さらに続きを取得するカーソル（続きがなければなし）
+}
+
+/**
+ * カーソルをクライアントに渡す文字列にする
+ */
+func encodeSearchCursor(cursor searchCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+/**
+ * クライアントから受け取ったカーソルを読み取る
+ */
+func decodeSearchCursor(raw string) (searchCursor, error) {
+	var cursor searchCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, fmt.Errorf("invalid cursor: %v", err)
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, fmt.Errorf("invalid cursor: %v", err)
+	}
+	if cursor.Search == "" {
+		return cursor, errors.New("invalid cursor: missing search")
+	}
+	return cursor, nil
+}
+
+/**
+ * 検索条件のハッシュ（セッションに保存する検索条件と同じJSONから作る）
+ * ランキングプロファイルも含むので、リクエストで上書きした配点の検索とそうでない検索のカーソルは取り違えない
+ */
+func searchParamsHash(params SearchParams) string {
+	data, _ := json.Marshal(params)
+	h := fnv.New64a()
+	h.Write(data)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+/**
+ * カーソルの位置の案件（並びの比較用）
+ */
+func (c searchCursor) position() rankedProject {
+	return rankedProject{Project: Project{URL: c.URL, PostedAt: c.PostedAt}, MatchScore: c.Score, MatchCount: c.MatchCount}
+}
+
+/**
+ * 案件がカーソルより後ろに並ぶか
+ */
+func (c searchCursor) before(rp rankedProject) bool {
+	return c.URL == "" || rankedBefore(c.position(), rp)
+}
+
+/**
+ * 次のページのカーソルを作る
+ * rankedはfromより後ろの候補（キーワードの並び）、shownは今回返した案件
+ * 先頭から切れ目なく返した（または前のページで返した）所までカーソルを進め、それより後ろで返した案件はSeenに残す
+ * @param full 候補がsearchCandidateLimit件に達したか（候補をすべて返しても続きがあるかもしれない）
+ * @return string カーソル（続きがなければ空）
+ */
+func nextSearchCursor(from searchCursor, ranked []rankedProject, shown []Project, full bool) string {
+	if len(shown) == 0 {
+		return ""
+	}
+
+	var shownURLs []string
+	returned := map[string]bool{}
+	for _, url := range from.Seen {
+		shownURLs = append(shownURLs, url)
+		returned[url] = true
+	}
+	for _, p := range shown {
+		if !returned[p.URL] {
+			shownURLs = append(shownURLs, p.URL)
+			returned[p.URL] = true
+		}
+	}
+
+	next := from
+	passed := map[string]bool{}
+	i := 0
+	for ; i < len(ranked) && returned[ranked[i].URL]; i++ {
+		next.Score, next.MatchCount = ranked[i].MatchScore+ranked[i].PriceScore, ranked[i].MatchCount
+		next.PostedAt, next.URL = ranked[i].PostedAt, ranked[i].URL
+		passed[ranked[i].URL] = true
+	}
+	if i == len(ranked) && !full {
+		return ""
+	}
+
+	next.Seen = nil
+	for _, url := range shownURLs {
+		if !passed[url] {
+			next.Seen = append(next.Seen, url)
+		}
+	}
+	return encodeSearchCursor(next)
+}
+
+/**
+ * カーソルより後ろの案件を、キーワードの並びでresult_limit件まで返す
+ * @return string さらに続きを取得するカーソル（続きがなければ空）
+ */
+func searchProjectsPage(params SearchParams, cursor searchCursor) ([]Project, string, error) {
+	params = withRankingProfile(params)
+	params.After = &cursor
+	candidates, full, err := searchCandidateWindow(params)
+	if err != nil {
+		return nil, "", err
+	}
+
+	seen := map[string]bool{}
+	for _, url := range cursor.Seen {
+		seen[url] = true
+	}
+
+	// SQLでは単価の点数を足す前のスコアで絞っているので、並べ替えた後にもう一度カーソルと比べる
+	var remaining []rankedProject
+	for _, rp := range candidates {
+		if cursor.before(rp) {
+			remaining = append(remaining, rp)
+		}
+	}
+
+	projects := []Project{}
+	limit := params.rankingProfile().ResultLimit
+	for _, rp := range remaining {
+		if len(projects) >= limit {
+			break
+		}
+		if !seen[rp.URL] {
+			projects = append(projects, rp.Project)
+		}
+	}
+
+	return projects, nextSearchCursor(cursor, remaining, projects, full), nil
+}
+
+/**
+ * セッションの直近の検索の続きの案件を返すハンドラー
+ * 分析結果・検索条件はセッションのものを使うので、AIは呼ばない
+ */
+func handleSessionProjects(c *gin.Context) {
+	raw := c.Query("cursor")
+	if raw == "" {
+		c.JSON(400, gin.H{"error": "Invalid request: cursor is required"})
+		return
+	}
+	cursor, err := decodeSearchCursor(raw)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	session, err := loadSession(c.Param("id"))
+	if err != nil {
+		log.Printf("Session error: %v", err)
+		c.JSON(500, gin.H{"error": "Session lookup failed"})
+		return
+	}
+	if session == nil {
+		c.JSON(404, gin.H{"error": "Session not found or expired"})
+		return
+	}
+	if session.LastSearch == nil || cursor.Search != searchParamsHash(*session.LastSearch) {
+		c.JSON(409, gin.H{"error": "Cursor does not match the latest search of this session"})
+		return
+	}
+
+	projects, next, err := searchProjectsPage(*session.LastSearch, cursor)
+	if err != nil {
+		log.Printf("Database search error: %v", err)
+		c.JSON(500, gin.H{"error": "Database search failed: " + err.Error()})
+		return
+	}
+
+	c.JSON(200, ProjectPage{SessionID: session.ID, Projects: projects, NextCursor: next})
+}
@@ -0,0 +1,934 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+/**
+ * PDFのテキスト抽出モジュール
+ * テキストレイヤーのあるPDF（WordやExcelから書き出したもの）から本文を取り出す
+ * 外部ライブラリを使わずに、オブジェクト・ページツリー・コンテンツストリームを最低限だけ読む
+ * 画像だけのPDF（スキャン）や暗号化されたPDFは対象外
+ */
+
+// PDFの値の型
+type pdfName string
+
+type pdfString []byte
+
+type pdfRef struct {
+	Num int
+	Gen int
+}
+
+type pdfDict map[pdfName]interface{}
+
+type pdfArray []interface{}
+
+// コンテンツストリーム・CMap中の演算子やキーワード
+type pdfKeyword string
+
+// PDFのオブジェクト1つ分（ストリームがあればstreamに生のバイト列）
+type pdfObject struct {
+	value  interface{}
+	stream []byte
+}
+
+// PDF文書
+type pdfDocument struct {
+	objects map[int]*pdfObject
+	cmaps   map[int]*pdfCMap // ToUnicodeのオブジェクト番号 → CMap
+}
+
+// ToUnicode CMap（文字コード → Unicode文字列）
+type pdfCMap struct {
+	codeLen int
+	table   map[uint32]string
+}
+
+// 展開後のストリームの最大サイズ（圧縮爆弾の対策）
+const pdfMaxStreamBytes = 32 << 20
+
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// ============================================================
+// 字句解析
+// ============================================================
+
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+/**
+ * 現在位置から後ろのバイト列（末尾を越えていれば空）
+ */
+func (l *pdfLexer) rest() []byte {
+	if l.pos >= len(l.data) {
+		return nil
+	}
+	return l.data[l.pos:]
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFWhitespace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+/**
+ * 次の値を1つ読む（辞書・配列は中身まで読む）
+ * 参照（"12 0 R"）は数値の後を先読みして判定する
+ * @return bool 末尾に達したかどうか
+ */
+func (l *pdfLexer) next() (interface{}, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, true
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		return l.readName(), false
+	case c == '(':
+		return l.readLiteralString(), false
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return l.readDict(), false
+	case c == '<':
+		return l.readHexString(), false
+	case c == '[':
+		l.pos++
+		return l.readArray(), false
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		// 対応の取れない区切り文字は読み飛ばす
+		if c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfKeyword(">>"), false
+		}
+		l.pos++
+		return pdfKeyword(string(c)), false
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return l.readNumberOrRef(), false
+	default:
+		return l.readKeyword(), false
+	}
+}
+
+func (l *pdfLexer) readToken() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFWhitespace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	if l.pos == start {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+func (l *pdfLexer) readName() pdfName {
+	l.pos++
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFWhitespace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	raw := string(l.data[start:l.pos])
+	if !strings.Contains(raw, "#") {
+		return pdfName(raw)
+	}
+	var sb strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if v, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+				sb.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		sb.WriteByte(raw[i])
+	}
+	return pdfName(sb.String())
+}
+
+func (l *pdfLexer) readKeyword() interface{} {
+	token := l.readToken()
+	switch token {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	return pdfKeyword(token)
+}
+
+func (l *pdfLexer) readNumber() (float64, bool) {
+	start := l.pos
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') {
+			l.pos++
+			continue
+		}
+		break
+	}
+	value, err := strconv.ParseFloat(string(l.data[start:l.pos]), 64)
+	return value, err == nil
+}
+
+func (l *pdfLexer) readNumberOrRef() interface{} {
+	value, ok := l.readNumber()
+	if !ok {
+		return pdfKeyword("")
+	}
+
+	// "num gen R" なら参照
+	save := l.pos
+	l.skipSpace()
+	if l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '9' {
+		gen, ok := l.readNumber()
+		l.skipSpace()
+		if ok && l.pos < len(l.data) && l.data[l.pos] == 'R' &&
+			(l.pos+1 == len(l.data) || isPDFWhitespace(l.data[l.pos+1]) || isPDFDelimiter(l.data[l.pos+1])) {
+			l.pos++
+			return pdfRef{Num: int(value), Gen: int(gen)}
+		}
+	}
+	l.pos = save
+	return value
+}
+
+func (l *pdfLexer) readLiteralString() pdfString {
+	l.pos++
+	var buf []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return buf
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return buf
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// 行の継続
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					value := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						value = value*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(value)
+				} else {
+					c = e
+				}
+			}
+		}
+		buf = append(buf, c)
+	}
+	return buf
+}
+
+func (l *pdfLexer) readHexString() pdfString {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		c := l.data[l.pos]
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	// 閉じの">"がないまま末尾に達したときは末尾で止める
+	if l.pos < len(l.data) {
+		l.pos++
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	buf := make([]byte, len(digits)/2)
+	for i := range buf {
+		v, _ := strconv.ParseUint(string(digits[i*2:i*2+2]), 16, 8)
+		buf[i] = byte(v)
+	}
+	return buf
+}
+
+func (l *pdfLexer) readArray() pdfArray {
+	array := pdfArray{}
+	for {
+		value, eof := l.next()
+		if eof {
+			return array
+		}
+		if k, ok := value.(pdfKeyword); ok && k == "]" {
+			return array
+		}
+		array = append(array, value)
+	}
+}
+
+func (l *pdfLexer) readDict() pdfDict {
+	dict := pdfDict{}
+	for {
+		key, eof := l.next()
+		if eof {
+			return dict
+		}
+		if k, ok := key.(pdfKeyword); ok && k == ">>" {
+			return dict
+		}
+		name, ok := key.(pdfName)
+		if !ok {
+			continue
+		}
+		value, eof := l.next()
+		if eof {
+			return dict
+		}
+		if k, ok := value.(pdfKeyword); ok && k == ">>" {
+			return dict
+		}
+		dict[name] = value
+	}
+}
+
+// ============================================================
+// 文書の読み込み
+// ============================================================
+
+/**
+ * PDFのバイト列からテキストを取り出す
+ * ページ順に、行ごとに改行を入れて返す
+ */
+func extractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\r\n\t "), []byte("%PDF")) {
+		return "", fmt.Errorf("not a PDF file")
+	}
+
+	doc := parsePDFDocument(data)
+	if doc.hasEncryption(data) {
+		return "", fmt.Errorf("encrypted PDF is not supported")
+	}
+
+	var sb strings.Builder
+	for _, page := range doc.pages() {
+		text := doc.pageText(page)
+		if text == "" {
+			continue
+		}
+		sb.WriteString(text)
+		sb.WriteString("\n")
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+/**
+ * "N G obj" を順に探してオブジェクトを読み込む（後から出てきた同じ番号で上書き）
+ * 圧縮オブジェクトストリーム（/Type /ObjStm）の中身も展開する
+ */
+func parsePDFDocument(data []byte) *pdfDocument {
+	doc := &pdfDocument{objects: map[int]*pdfObject{}, cmaps: map[int]*pdfCMap{}}
+
+	for _, m := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		num, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+		lexer := &pdfLexer{data: data, pos: m[1]}
+		value, _ := lexer.next()
+		obj := &pdfObject{value: value}
+
+		if dict, ok := value.(pdfDict); ok {
+			lexer.skipSpace()
+			if bytes.HasPrefix(lexer.rest(), []byte("stream")) {
+				obj.stream = readPDFStreamData(data, lexer.pos+len("stream"), dict)
+			}
+		}
+		doc.objects[num] = obj
+	}
+
+	// オブジェクトストリームの中身（直接書かれたオブジェクトは上書きしない）
+	for _, num := range doc.sortedObjectNumbers() {
+		obj := doc.objects[num]
+		dict, ok := obj.value.(pdfDict)
+		if !ok || dict[pdfName("Type")] != pdfName("ObjStm") {
+			continue
+		}
+		doc.expandObjectStream(dict, obj.stream)
+	}
+
+	return doc
+}
+
+/**
+ * "stream" の直後からストリームの生データを切り出す
+ * /Lengthが直接書かれていて、ファイルの残りに収まればそれを使い、なければendstreamまでを使う
+ */
+func readPDFStreamData(data []byte, start int, dict pdfDict) []byte {
+	if start < 0 || start > len(data) {
+		return nil
+	}
+	if bytes.HasPrefix(data[start:], []byte("\r\n")) {
+		start += 2
+	} else if start < len(data) && (data[start] == '\n' || data[start] == '\r') {
+		start++
+	}
+
+	// 負の値・残りより長い値（壊れたPDFや細工されたPDF）は使わない
+	if length, ok := dict[pdfName("Length")].(float64); ok && length >= 0 && length <= float64(len(data)-start) {
+		end := start + int(length)
+		if bytes.HasPrefix(bytes.TrimLeft(data[end:], "\r\n \t"), []byte("endstream")) {
+			return data[start:end]
+		}
+	}
+
+	end := bytes.Index(data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil
+	}
+	return bytes.TrimRight(data[start:start+end], "\r\n")
+}
+
+func (doc *pdfDocument) expandObjectStream(dict pdfDict, raw []byte) {
+	data, err := decodePDFStream(dict, raw)
+	if err != nil {
+		return
+	}
+	n, _ := dict[pdfName("N")].(float64)
+	first, _ := dict[pdfName("First")].(float64)
+	if first < 0 || first > float64(len(data)) {
+		return
+	}
+
+	header := &pdfLexer{data: data[:int(first)]}
+	for i := 0; i < int(n); i++ {
+		numValue, _ := header.next()
+		offsetValue, _ := header.next()
+		num, ok1 := numValue.(float64)
+		offset, ok2 := offsetValue.(float64)
+		if !ok1 || !ok2 {
+			return
+		}
+		if _, exists := doc.objects[int(num)]; exists {
+			continue
+		}
+		if offset < 0 || offset >= float64(len(data))-first {
+			continue
+		}
+		pos := int(first) + int(offset)
+		value, _ := (&pdfLexer{data: data, pos: pos}).next()
+		doc.objects[int(num)] = &pdfObject{value: value}
+	}
+}
+
+/**
+ * ストリームを展開する（FlateDecodeのみ対応、フィルターなしはそのまま）
+ */
+func decodePDFStream(dict pdfDict, raw []byte) ([]byte, error) {
+	var filters []pdfName
+	switch f := dict[pdfName("Filter")].(type) {
+	case pdfName:
+		filters = []pdfName{f}
+	case pdfArray:
+		for _, v := range f {
+			if name, ok := v.(pdfName); ok {
+				filters = append(filters, name)
+			}
+		}
+	}
+
+	data := raw
+	for _, f := range filters {
+		if f != "FlateDecode" {
+			return nil, fmt.Errorf("unsupported PDF filter: %s", f)
+		}
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := io.ReadAll(io.LimitReader(reader, pdfMaxStreamBytes))
+		reader.Close()
+		// 末尾が壊れていても読めた分は使う
+		if err != nil && len(decoded) == 0 {
+			return nil, err
+		}
+		data = decoded
+	}
+	return data, nil
+}
+
+/**
+ * 参照なら参照先の値を返す
+ */
+func (doc *pdfDocument) resolve(value interface{}) interface{} {
+	for i := 0; i < 8; i++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		obj, ok := doc.objects[ref.Num]
+		if !ok {
+			return nil
+		}
+		value = obj.value
+	}
+	return value
+}
+
+func (doc *pdfDocument) resolveDict(value interface{}) pdfDict {
+	dict, _ := doc.resolve(value).(pdfDict)
+	return dict
+}
+
+/**
+ * 暗号化されているか（trailerやXRefストリームに/Encryptがある）
+ */
+func (doc *pdfDocument) hasEncryption(data []byte) bool {
+	for _, obj := range doc.objects {
+		if dict, ok := obj.value.(pdfDict); ok && dict[pdfName("Type")] == pdfName("XRef") {
+			if _, ok := dict[pdfName("Encrypt")]; ok {
+				return true
+			}
+		}
+	}
+	if idx := bytes.LastIndex(data, []byte("trailer")); idx >= 0 {
+		value, _ := (&pdfLexer{data: data, pos: idx + len("trailer")}).next()
+		if trailer, ok := value.(pdfDict); ok {
+			_, encrypted := trailer[pdfName("Encrypt")]
+			return encrypted
+		}
+	}
+	return false
+}
+
+// ページとその（親から引き継いだ分も含む）リソース
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+/**
+ * ページツリーをたどってページを順に返す
+ * カタログが見つからなければ/Type /Pageのオブジェクトを番号順に返す
+ */
+func (doc *pdfDocument) pages() []pdfPage {
+	var pages []pdfPage
+	visited := map[int]bool{}
+
+	var walk func(node interface{}, inherited pdfDict)
+	walk = func(node interface{}, inherited pdfDict) {
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref.Num] {
+				return
+			}
+			visited[ref.Num] = true
+		}
+		dict := doc.resolveDict(node)
+		if dict == nil {
+			return
+		}
+		resources := inherited
+		if r := doc.resolveDict(dict[pdfName("Resources")]); r != nil {
+			resources = r
+		}
+		if dict[pdfName("Type")] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: dict, resources: resources})
+			return
+		}
+		kids, _ := doc.resolve(dict[pdfName("Kids")]).(pdfArray)
+		for _, kid := range kids {
+			walk(kid, resources)
+		}
+	}
+
+	for _, num := range doc.sortedObjectNumbers() {
+		dict, ok := doc.objects[num].value.(pdfDict)
+		if ok && dict[pdfName("Type")] == pdfName("Catalog") {
+			walk(dict[pdfName("Pages")], nil)
+			break
+		}
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	for _, num := range doc.sortedObjectNumbers() {
+		dict, ok := doc.objects[num].value.(pdfDict)
+		if ok && dict[pdfName("Type")] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: dict, resources: doc.resolveDict(dict[pdfName("Resources")])})
+		}
+	}
+	return pages
+}
+
+func (doc *pdfDocument) sortedObjectNumbers() []int {
+	nums := make([]int, 0, len(doc.objects))
+	for num := range doc.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+/**
+ * ページのコンテンツストリームを展開して連結する
+ */
+func (doc *pdfDocument) pageContent(page pdfPage) []byte {
+	var refs []interface{}
+	switch c := page.dict[pdfName("Contents")].(type) {
+	case pdfRef:
+		if array, ok := doc.resolve(c).(pdfArray); ok {
+			refs = array
+		} else {
+			refs = []interface{}{c}
+		}
+	case pdfArray:
+		refs = c
+	}
+
+	var content []byte
+	for _, r := range refs {
+		ref, ok := r.(pdfRef)
+		if !ok {
+			continue
+		}
+		obj, ok := doc.objects[ref.Num]
+		if !ok || obj.stream == nil {
+			continue
+		}
+		dict, _ := obj.value.(pdfDict)
+		data, err := decodePDFStream(dict, obj.stream)
+		if err != nil {
+			continue
+		}
+		content = append(content, data...)
+		content = append(content, '\n')
+	}
+	return content
+}
+
+/**
+ * フォントのToUnicode CMapを返す（なければnil）
+ */
+func (doc *pdfDocument) fontCMap(font pdfDict) *pdfCMap {
+	ref, ok := font[pdfName("ToUnicode")].(pdfRef)
+	if !ok {
+		return nil
+	}
+	if cmap, ok := doc.cmaps[ref.Num]; ok {
+		return cmap
+	}
+
+	var cmap *pdfCMap
+	if obj, ok := doc.objects[ref.Num]; ok && obj.stream != nil {
+		dict, _ := obj.value.(pdfDict)
+		if data, err := decodePDFStream(dict, obj.stream); err == nil {
+			cmap = parsePDFCMap(data)
+		}
+	}
+	doc.cmaps[ref.Num] = cmap
+	return cmap
+}
+
+// ============================================================
+// テキストの取り出し
+// ============================================================
+
+// 文字を置くフォントの情報
+type pdfFont struct {
+	cmap      *pdfCMap
+	composite bool // Type0（2バイトコード）かどうか
+}
+
+/**
+ * ページのテキストを取り出す
+ * Tj / TJ / ' / " の文字列を、フォントのToUnicodeで文字に変換して並べる
+ * 行の移動（Td / TD / T* / Tmでのy座標の変化）で改行を入れる
+ */
+func (doc *pdfDocument) pageText(page pdfPage) string {
+	fonts := map[pdfName]pdfFont{}
+	if fontDict := doc.resolveDict(page.resources[pdfName("Font")]); fontDict != nil {
+		for name, ref := range fontDict {
+			font := doc.resolveDict(ref)
+			if font == nil {
+				continue
+			}
+			fonts[name] = pdfFont{cmap: doc.fontCMap(font), composite: font[pdfName("Subtype")] == pdfName("Type0")}
+		}
+	}
+
+	var sb strings.Builder
+	var current pdfFont
+	var operands []interface{}
+	lastY := 0.0
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	show := func(value interface{}) {
+		if s, ok := value.(pdfString); ok {
+			sb.WriteString(current.decode(s))
+		}
+	}
+
+	lexer := &pdfLexer{data: doc.pageContent(page)}
+	for {
+		value, eof := lexer.next()
+		if eof {
+			break
+		}
+		op, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok {
+					current = fonts[name]
+				}
+			}
+		case "Tj":
+			if len(operands) >= 1 {
+				show(operands[len(operands)-1])
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) >= 1 {
+				show(operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				array, _ := operands[len(operands)-1].(pdfArray)
+				for _, item := range array {
+					// 大きく詰めた位置（単語の区切り）には英数字の後だけ空白を入れる
+					if n, ok := item.(float64); ok && n < -250 {
+						text := sb.String()
+						if text != "" && isASCIIWordRune(rune(text[len(text)-1])) {
+							sb.WriteString(" ")
+						}
+						continue
+					}
+					show(item)
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := operands[1].(float64); ok && ty != 0 {
+					newline()
+				}
+			}
+		case "T*":
+			newline()
+		case "Tm":
+			if len(operands) >= 6 {
+				if y, ok := operands[5].(float64); ok && y != lastY {
+					newline()
+					lastY = y
+				}
+			}
+		case "ID":
+			// インライン画像のバイナリはEIまで読み飛ばす
+			if end := bytes.Index(lexer.rest(), []byte("EI")); end >= 0 {
+				lexer.pos += end + 2
+			} else {
+				lexer.pos = len(lexer.data)
+			}
+		}
+		operands = operands[:0]
+	}
+
+	var lines []string
+	for _, line := range strings.Split(sb.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+/**
+ * 文字列をフォントに合わせて文字に変換する
+ * ToUnicodeがなければ1バイト文字はそのまま（Latin-1として）使い、2バイトコードは読めないので捨てる
+ */
+func (f pdfFont) decode(s pdfString) string {
+	if f.cmap != nil {
+		return f.cmap.decode(s)
+	}
+	if f.composite {
+		return ""
+	}
+	var sb strings.Builder
+	for _, c := range s {
+		if c >= 0x20 || c == '\t' {
+			sb.WriteRune(rune(c))
+		}
+	}
+	return sb.String()
+}
+
+func (m *pdfCMap) decode(s pdfString) string {
+	var sb strings.Builder
+	for i := 0; i+m.codeLen <= len(s); i += m.codeLen {
+		var code uint32
+		for j := 0; j < m.codeLen; j++ {
+			code = code<<8 | uint32(s[i+j])
+		}
+		if text, ok := m.table[code]; ok {
+			sb.WriteString(text)
+		}
+	}
+	return sb.String()
+}
+
+/**
+ * ToUnicode CMapを読む（codespacerange / bfchar / bfrange）
+ */
+func parsePDFCMap(data []byte) *pdfCMap {
+	cmap := &pdfCMap{codeLen: 1, table: map[uint32]string{}}
+	lexer := &pdfLexer{data: data}
+
+	var operands []interface{}
+	for {
+		value, eof := lexer.next()
+		if eof {
+			break
+		}
+		op, ok := value.(pdfKeyword)
+		if !ok {
+			operands = append(operands, value)
+			continue
+		}
+
+		switch op {
+		case "endcodespacerange":
+			if len(operands) >= 1 {
+				if lo, ok := operands[0].(pdfString); ok && len(lo) > 0 {
+					cmap.codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					cmap.table[pdfCode(src)] = decodeUTF16BE(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := pdfCode(lo), pdfCode(hi)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case pdfString:
+					units := utf16BEUnits(dst)
+					if len(units) == 0 {
+						continue
+					}
+					for code := start; code <= end; code++ {
+						shifted := append([]uint16{}, units...)
+						shifted[len(shifted)-1] += uint16(code - start)
+						cmap.table[code] = string(utf16.Decode(shifted))
+					}
+				case pdfArray:
+					for j, item := range dst {
+						if s, ok := item.(pdfString); ok && start+uint32(j) <= end {
+							cmap.table[start+uint32(j)] = decodeUTF16BE(s)
+						}
+					}
+				}
+			}
+		}
+		if strings.HasPrefix(string(op), "end") || strings.HasPrefix(string(op), "begin") {
+			operands = operands[:0]
+		}
+	}
+
+	return cmap
+}
+
+func pdfCode(s pdfString) uint32 {
+	var code uint32
+	for _, c := range s {
+		code = code<<8 | uint32(c)
+	}
+	return code
+}
+
+func utf16BEUnits(s pdfString) []uint16 {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return units
+}
+
+func decodeUTF16BE(s pdfString) string {
+	return string(utf16.Decode(utf16BEUnits(s)))
+}
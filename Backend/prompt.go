@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * プロンプトテンプレート管理モジュール
+ * AIに渡すシステムプロンプトを「名前 + バージョン」のテンプレートとして管理する
+ *
+ * テンプレートはprompts/<名前>/<バージョン>.tmpl（バイナリに埋め込み）を基本に、
+ * PROMPT_DIRで同じ構成のディレクトリを、PROMPT_SOURCE=dbでtbl_promptの行を重ねられる
+ * どのバージョンを使うかは環境ごとにPROMPT_VERSIONS（例: "analysis=v2,rerank=v1"）で選ぶ
+ * テンプレートはtext/templateの書式で、{{.ReasonLength}}のようなパラメーターを埋め込める
+ */
+
+//go:embed prompts
+var defaultPromptFS embed.FS
+
+// テンプレート名
+const (
+	PromptAnalysis   = "analysis"   // スキル解析（セッションでの絞り込みも同じプロンプト）
+	PromptRerank     = "rerank"     // 検索結果の並べ直し
+	PromptSkillSheet = "skillsheet" // スキルシートの強み・提案
+	PromptTranslate  = "translate"  // 案件のタイトル・概要の英訳
+)
+
+// PROMPT_VERSIONSで指定がないときに使うバージョン
+// 解析は絞り込み条件（filters）と除外するスキル・キーワードも返すv3。Vercel版（api/index.go）の既定も合わせる
+var defaultPromptVersions = map[string]string{
+	PromptAnalysis:   "v3",
+	PromptRerank:     "v1",
+	PromptSkillSheet: "v1",
+	PromptTranslate:  "v1",
+}
+
+// プロンプトテンプレート1件分
+type PromptTemplate struct {
+	Name    string `json:"name"`    // テンプレート名
+	Version string `json:"version"` // バージョン
+	Source  string `json:"source"`  // 読み込み元（embed / file / db）
+	Text    string `json:"text"`    // テンプレート本文
+	tmpl    *template.Template
+}
+
+// 読み込んだテンプレートの一覧
+type PromptSet struct {
+	templates map[string]map[string]*PromptTemplate // 名前 → バージョン → テンプレート
+}
+
+// リランキングプロンプトのパラメーター
+type rerankPromptData struct {
+	ReasonLength int // 理由の目安の文字数
+}
+
+// 英訳プロンプトのパラメーター
+type translatePromptData struct {
+	SummaryWords int // 概要の目安の単語数
+}
+
+var (
+	promptSetMu       sync.Mutex
+	promptSet         *PromptSet
+	promptSetLoadedAt time.Time
+)
+
+/**
+ * テンプレートを追加する（同じ名前・バージョンは後から追加したもので上書き）
+ */
+func (s *PromptSet) add(name, version, source, text string) error {
+	tmpl, err := template.New(name + "/" + version).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid prompt template %s/%s: %v", name, version, err)
+	}
+	if s.templates[name] == nil {
+		s.templates[name] = map[string]*PromptTemplate{}
+	}
+	s.templates[name][version] = &PromptTemplate{Name: name, Version: version, Source: source, Text: text, tmpl: tmpl}
+	return nil
+}
+
+/**
+ * 名前とバージョンからテンプレートを探す
+ */
+func (s *PromptSet) Get(name, version string) (*PromptTemplate, bool) {
+	t, ok := s.templates[name][version]
+	return t, ok
+}
+
+/**
+ * 全テンプレートを名前・バージョンの順に返す
+ */
+func (s *PromptSet) List() []*PromptTemplate {
+	var list []*PromptTemplate
+	for _, versions := range s.templates {
+		for _, t := range versions {
+			list = append(list, t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Version < list[j].Version
+	})
+	return list
+}
+
+/**
+ * <名前>/<バージョン>.tmpl の構成のディレクトリからテンプレートを読み込む
+ */
+func (s *PromptSet) addFS(fsys fs.FS, root, source string) error {
+	return fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != ".tmpl" {
+			return nil
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(p, root), "/")
+		name, file := path.Split(rel)
+		name = strings.Trim(name, "/")
+		if name == "" || strings.Contains(name, "/") {
+			return nil
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		return s.add(name, strings.TrimSuffix(file, ".tmpl"), source, string(data))
+	})
+}
+
+/**
+ * テンプレートを読み込む
+ * 埋め込みのprompts/、PROMPT_DIR、（PROMPT_SOURCE=dbのとき）tbl_promptの順に重ねる
+ */
+func loadPromptSet() (*PromptSet, error) {
+	set := &PromptSet{templates: map[string]map[string]*PromptTemplate{}}
+	if err := set.addFS(defaultPromptFS, "prompts", "embed"); err != nil {
+		return nil, fmt.Errorf("failed to load embedded prompts: %v", err)
+	}
+
+	if dir := os.Getenv("PROMPT_DIR"); dir != "" {
+		if err := set.addFS(os.DirFS(dir), ".", "file"); err != nil {
+			return nil, fmt.Errorf("failed to load prompt directory: %v", err)
+		}
+	}
+
+	if getEnvWithDefault("PROMPT_SOURCE", "file") == "db" && db != nil {
+		if err := loadPromptsFromDB(set); err != nil {
+			return nil, err
+		}
+	}
+
+	return set, nil
+}
+
+/**
+ * tbl_promptからテンプレートを読み込む
+ */
+func loadPromptsFromDB(set *PromptSet) error {
+	rows, err := db.Query("SELECT prmnam, prmver, prmtxt FROM tbl_prompt ORDER BY prmnam, prmver")
+	if err != nil {
+		return fmt.Errorf("failed to load prompt table: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, version, text string
+		if err := rows.Scan(&name, &version, &text); err != nil {
+			return fmt.Errorf("failed to scan prompt row: %v", err)
+		}
+		if err := set.add(name, version, "db", text); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("row iteration error: %v", err)
+	}
+
+	return nil
+}
+
+/**
+ * 埋め込みのテンプレートをtbl_promptに登録する（すでにある名前・バージョンの行は上書きしない）
+ * Vercel版（api/index.go）はtbl_promptからしかテンプレートを読めないので、起動時に同じ文言を置いておく
+ */
+func seedPromptTable(database *sql.DB) error {
+	set := &PromptSet{templates: map[string]map[string]*PromptTemplate{}}
+	if err := set.addFS(defaultPromptFS, "prompts", "embed"); err != nil {
+		return fmt.Errorf("failed to load embedded prompts: %v", err)
+	}
+
+	for _, t := range set.List() {
+		_, err := database.Exec(`
+			INSERT INTO tbl_prompt (prmnam, prmver, prmtxt) VALUES ($1, $2, $3)
+			ON CONFLICT (prmnam, prmver) DO NOTHING
+		`, t.Name, t.Version, t.Text)
+		if err != nil {
+			return fmt.Errorf("failed to seed prompt %s/%s: %v", t.Name, t.Version, err)
+		}
+	}
+	return nil
+}
+
+/**
+ * 現在のテンプレート一覧を返す
+ * PROMPT_TTL（デフォルト5分）を過ぎていれば読み直し、失敗した場合は前回の一覧を使い続ける
+ */
+func getPromptSet() *PromptSet {
+	promptSetMu.Lock()
+	defer promptSetMu.Unlock()
+
+	ttl, err := time.ParseDuration(getEnvWithDefault("PROMPT_TTL", "5m"))
+	if err != nil || ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	if promptSet != nil && time.Since(promptSetLoadedAt) < ttl {
+		return promptSet
+	}
+
+	set, err := loadPromptSet()
+	if err != nil {
+		log.Printf("Prompt template error: %v", err)
+		if promptSet == nil {
+			promptSet = &PromptSet{templates: map[string]map[string]*PromptTemplate{}}
+			promptSet.addFS(defaultPromptFS, "prompts", "embed")
+		}
+	} else {
+		promptSet = set
+	}
+	promptSetLoadedAt = time.Now()
+	return promptSet
+}
+
+/**
+ * テンプレートをすぐに読み直す
+ */
+func reloadPromptSet() (*PromptSet, error) {
+	set, err := loadPromptSet()
+	if err != nil {
+		return nil, err
+	}
+
+	promptSetMu.Lock()
+	promptSet = set
+	promptSetLoadedAt = time.Now()
+	promptSetMu.Unlock()
+
+	return set, nil
+}
+
+/**
+ * 使用するバージョンを返す
+ * PROMPT_VERSIONS（"analysis=v2,rerank=v1"）に指定があればそれを、なければ既定のバージョンを使う
+ */
+func activePromptVersion(name string) string {
+	for _, pair := range strings.Split(os.Getenv("PROMPT_VERSIONS"), ",") {
+		key, version, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(key) == name && strings.TrimSpace(version) != "" {
+			return strings.TrimSpace(version)
+		}
+	}
+	return defaultPromptVersions[name]
+}
+
+/**
+ * 使用中のバージョンのテンプレートにパラメーターを埋め込む
+ * @return string プロンプト本文
+ * @return string 使ったバージョン
+ */
+func renderPrompt(name string, data interface{}) (string, string, error) {
+	version := activePromptVersion(name)
+	t, ok := getPromptSet().Get(name, version)
+	if !ok {
+		return "", version, fmt.Errorf("prompt template %s/%s not found", name, version)
+	}
+
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", version, fmt.Errorf("failed to render prompt %s/%s: %v", name, version, err)
+	}
+	return buf.String(), version, nil
+}
+
+/**
+ * 使用中のバージョンのテンプレートがすべて揃っているか確認する（起動時用）
+ */
+func checkActivePrompts() error {
+	set := getPromptSet()
+	for name := range defaultPromptVersions {
+		version := activePromptVersion(name)
+		if _, ok := set.Get(name, version); !ok {
+			return fmt.Errorf("prompt template %s/%s not found", name, version)
+		}
+	}
+	return nil
+}
+
+type promptVersionKey struct{}
+
+/**
+ * 呼び出しに使ったプロンプトのバージョンをcontextに付ける（使用量の記録用）
+ */
+func withPromptVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, promptVersionKey{}, version)
+}
+
+/**
+ * contextからプロンプトのバージョンを取り出す（指定がなければ空）
+ */
+func promptVersionFromContext(ctx context.Context) string {
+	version, _ := ctx.Value(promptVersionKey{}).(string)
+	return version
+}
+
+/**
+ * テンプレートの一覧と使用中のバージョンを返す管理者用ハンドラー
+ */
+func handleGetPrompts(c *gin.Context) {
+	active := map[string]string{}
+	for name := range defaultPromptVersions {
+		active[name] = activePromptVersion(name)
+	}
+	c.JSON(200, gin.H{"prompts": getPromptSet().List(), "active": active})
+}
+
+/**
+ * テンプレートを読み直す管理者用ハンドラー
+ */
+func handleReloadPrompts(c *gin.Context) {
+	set, err := reloadPromptSet()
+	if err != nil {
+		log.Printf("Prompt template error: %v", err)
+		c.JSON(500, gin.H{"error": "Prompt reload failed: " + err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"total": len(set.List())})
+}
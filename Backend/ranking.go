@@ -0,0 +1,325 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"time"
+)
+
+/**
+ * 検索結果の並べ替えモジュール
+ * SQLで取り出した候補に、スキルのスコアと希望単価との一致度を付けて並べ替え、
+ * サイトごとの件数を制限して最終的な検索結果にする
+ */
+
+// SQLで取り出す候補の件数（返す件数・サイトごとの件数はランキングプロファイルで決める）
+const searchCandidateLimit = 50
+
+// 1ページ目にSQLで1サイトから取り出す候補の件数
+// 1つのサイトの案件で候補が埋まらないように、サイトごとに上位だけを取り出す
+// 単価で除いたり並べ替えたりする分の余裕を持たせて、per_source_limitより多めに取る
+const searchCandidatePerSource = 15
+
+// 希望単価との一致度の点数
+const (
+	priceScoreInRange = 3 // 希望単価の範囲と重なる
+	priceScoreAbove   = 2 // 希望単価の上限より高い
+)
+
+// スキルのスコアの配点（ランキングプロファイルの既定値。rankprofile.go）
+const (
+	matchScoreTitle    = 5 // タイトルにマッチ
+	matchScoreSkills   = 3 // スキル欄にマッチ
+	matchScoreDetail   = 1 // 詳細にマッチ
+	matchScorePerSkill = 2 // マッチしたスキル1つあたりのボーナス
+)
+
+// 案件がマッチした理由の内訳
+type MatchExplanation struct {
+	Skills          []SkillMatch `json:"skills"`            // マッチしたスキルごとの内訳
+	SkillScore      int          `json:"skill_score"`       // 項目ごとの点数の合計
+	MatchCount      int          `json:"match_count"`       // マッチしたスキルの数
+	MultiSkillBonus int          `json:"multi_skill_bonus"` // マッチしたスキル数のボーナス（既定は1つにつき2点）
+	PriceScore      int          `json:"price_score"`       // 希望単価との一致度
+	FinalScore      int          `json:"final_score"`       // 最終スコア（並べ替えに使った値）
+
+	Similarity   *float64 `json:"similarity,omitempty"`    // 検索条件との意味の近さ（意味検索のときのみ）
+	BlendedScore *float64 `json:"blended_score,omitempty"` // 意味検索で並べ替えに使った値
+}
+
+// スキル1つ分のマッチの内訳
+type SkillMatch struct {
+	Skill  string       `json:"skill"`  // スキル（辞書の正式名）
+	Weight float64      `json:"weight"` // 配点に掛けた重み（経験年数・重点スキルかどうか）
+	Fields []FieldMatch `json:"fields"` // マッチした項目
+	Score  int          `json:"score"`  // このスキルの点数
+}
+
+// 項目1つ分のマッチ
+type FieldMatch struct {
+	Field string `json:"field"` // カラム名（prottl / proot1 / prodtl）
+	Label string `json:"label"` // 項目名
+	Score int    `json:"score"` // 点数
+}
+
+// 並べ替え用の候補
+type rankedProject struct {
+	Project
+	MatchScore int // スキルのスコア（SQLと同じ計算）
+	MatchCount int // マッチしたスキルの数
+	PriceScore int // 希望単価との一致度
+
+	Similarity   float64 // 検索条件との意味の近さ（意味検索のときのみ）
+	BlendedScore float64 // キーワードのスコアと意味の近さを混ぜた値（意味検索のときのみ）
+}
+
+/**
+ * 候補にスコアを付けて並べ替える
+ * 希望単価の下限に届かない案件はPriceFilterがtrueのときだけ除く（単価が読み取れない案件は残す）
+ * 絞り込み条件の単価の範囲に入らない案件も除く
+ */
+func scoreCandidates(candidates []Project, skills []searchSkill, params SearchParams) []rankedProject {
+	patterns := compileSearchSkillPatterns(skills)
+	profile := params.rankingProfile()
+
+	var ranked []rankedProject
+	for _, p := range candidates {
+		if r, ok := parseSalaryRange(p.Price); ok {
+			priceRange := r
+			p.PriceRange = &priceRange
+		}
+		if !params.Filter.matchesPrice(p) {
+			continue
+		}
+
+		explanation := explainProjectSkills(p, patterns, profile)
+		rp := rankedProject{MatchScore: explanation.FinalScore, MatchCount: explanation.MatchCount}
+
+		if params.DesiredSalary != nil && p.PriceRange != nil {
+			score, belowDesired := scoreProjectPrice(*p.PriceRange, *params.DesiredSalary)
+			if belowDesired && params.PriceFilter {
+				continue
+			}
+			rp.PriceScore = score
+		}
+
+		explanation.PriceScore = rp.PriceScore
+		explanation.FinalScore += rp.PriceScore
+		p.Match = &explanation
+		rp.Project = p
+
+		ranked = append(ranked, rp)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return rankedBefore(ranked[i], ranked[j])
+	})
+
+	return ranked
+}
+
+/**
+ * 検索結果の並びでaがbより前か
+ * スキルのスコア＋単価の点数の高い順、同点ならマッチしたスキルの多い順、掲載日（procrt）の新しい順、URL（prourl）の順
+ * 同点でも並びが1つに決まるので、ページ送りのカーソル（pagination.go）も同じ比較を使う
+ */
+func rankedBefore(a, b rankedProject) bool {
+	if scoreA, scoreB := a.MatchScore+a.PriceScore, b.MatchScore+b.PriceScore; scoreA != scoreB {
+		return scoreA > scoreB
+	}
+	if a.MatchCount != b.MatchCount {
+		return a.MatchCount > b.MatchCount
+	}
+	// 掲載日は表記（タイムゾーン・日付だけなど）が違っても同じ時刻なら同じとみなす。読めなければ文字列で比べる
+	postedA, okA := parsePostedAt(a.PostedAt)
+	postedB, okB := parsePostedAt(b.PostedAt)
+	switch {
+	case okA && okB:
+		if !postedA.Equal(postedB) {
+			return postedA.After(postedB)
+		}
+	case a.PostedAt != b.PostedAt:
+		return a.PostedAt > b.PostedAt
+	}
+	return a.URL < b.URL
+}
+
+// 掲載日（procrt）の表記
+var postedAtLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+/**
+ * 掲載日を時刻として読む
+ */
+func parsePostedAt(value string) (time.Time, bool) {
+	for _, layout := range postedAtLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+/**
+ * SQLで1サイトから取り出す候補の件数（per_source_limitが大きいプロファイルではそれより多く取る）
+ */
+func (r RankingProfile) candidatesPerSource() int {
+	if r.PerSourceLimit*2 > searchCandidatePerSource {
+		return r.PerSourceLimit * 2
+	}
+	return searchCandidatePerSource
+}
+
+/**
+ * 並べ替え済みの候補から、サイトごとの件数を制限して最終的な検索結果を選ぶ
+ */
+func selectProjects(ranked []rankedProject, profile RankingProfile) []Project {
+	projects := []Project{}
+	perSite := map[string]int{}
+	for _, rp := range ranked {
+		if len(projects) >= profile.ResultLimit {
+			break
+		}
+		if perSite[rp.Source] >= profile.PerSourceLimit {
+			continue
+		}
+		perSite[rp.Source]++
+		projects = append(projects, rp.Project)
+	}
+
+	return projects
+}
+
+// スキル1つ分の検索パターン
+type skillPattern struct {
+	Skill  string
+	Weight float64
+	Re     *regexp.Regexp
+}
+
+/**
+ * スキルの検索パターンをGoの正規表現にする（SQLの ~* と同じく大文字小文字を区別しない。重みは1）
+ */
+func compileSkillPatterns(skills []string) []skillPattern {
+	weighted := make([]searchSkill, len(skills))
+	for i, skill := range skills {
+		weighted[i] = searchSkill{Name: skill, Weight: 1}
+	}
+	return compileSearchSkillPatterns(weighted)
+}
+
+/**
+ * 重み付きのスキルの検索パターンをGoの正規表現にする
+ */
+func compileSearchSkillPatterns(skills []searchSkill) []skillPattern {
+	dict := getSkillDictionary()
+	var patterns []skillPattern
+	for _, skill := range skills {
+		re, err := regexp.Compile("(?i)" + dict.Pattern(skill.Name))
+		if err != nil {
+			continue
+		}
+		patterns = append(patterns, skillPattern{Skill: skill.Name, Weight: skill.Weight, Re: re})
+	}
+	return patterns
+}
+
+// スコアを付ける項目（配点はランキングプロファイルの値）
+var matchFields = []struct {
+	Column string
+	Label  string
+	value  func(p Project) string
+}{
+	{"prottl", "タイトル", func(p Project) string { return p.Title }},
+	{"proot1", "スキル欄", func(p Project) string { return p.Skills }},
+	{"prodtl", "詳細", func(p Project) string { return p.Detail }},
+}
+
+/**
+ * SQLと同じ計算でスキルのスコアを出し、内訳を返す
+ * 既定ではタイトル: 5点、スキル欄: 3点、詳細: 1点にスキルの重みを掛け、マッチしたスキル1つにつき2点
+ */
+func explainProjectSkills(p Project, patterns []skillPattern, profile RankingProfile) MatchExplanation {
+	explanation := MatchExplanation{Skills: []SkillMatch{}}
+	for _, sp := range patterns {
+		match := SkillMatch{Skill: sp.Skill, Weight: sp.Weight, Fields: []FieldMatch{}}
+		for _, f := range matchFields {
+			if sp.Re.MatchString(f.value(p)) {
+				score := profile.weightedFieldScore(f.Column, sp.Weight)
+				match.Fields = append(match.Fields, FieldMatch{Field: f.Column, Label: f.Label, Score: score})
+				match.Score += score
+			}
+		}
+		if len(match.Fields) == 0 {
+			continue
+		}
+		explanation.Skills = append(explanation.Skills, match)
+		explanation.SkillScore += match.Score
+	}
+	explanation.MatchCount = len(explanation.Skills)
+	explanation.MultiSkillBonus = explanation.MatchCount * profile.MultiSkillBonus
+	explanation.FinalScore = explanation.SkillScore + explanation.MultiSkillBonus
+	return explanation
+}
+
+/**
+ * 希望単価との一致度
+ * @return bool 案件の上限が希望の下限に届かないかどうか
+ */
+func scoreProjectPrice(project, desired SalaryRange) (int, bool) {
+	if project.Max > 0 && desired.Min > 0 && project.Max < desired.Min {
+		return 0, true
+	}
+	if desired.Max > 0 && project.Min > desired.Max {
+		return priceScoreAbove, false
+	}
+	if project.Overlaps(desired) {
+		return priceScoreInRange, false
+	}
+	return 0, false
+}
+
+/**
+ * 今回の検索条件を組み立てる
+ * 希望単価はメッセージにはっきり書かれていればそれで絞り込み（parseDesiredSalary）、なければ前回の絞り込みを引き継ぎ、
+ * それもなければAIの推定単価を並べ替えだけに使う
+ * 英語のメッセージからは日本語の検索語も作る（language.go）
+ */
+func buildSearchParams(session *Session, message string, analysis AIAnalysis) SearchParams {
+	params := SearchParams{
+		KeySkills: analysis.KeySkills,
+		Skills:    analysis.StructuredSkills,
+		Query:     semanticQueryText(message, analysis),
+	}
+
+	if desired, ok := parseDesiredSalary(message); ok {
+		params.DesiredSalary = &desired
+		params.PriceFilter = true
+	} else if session != nil && session.LastSearch != nil && session.LastSearch.PriceFilter {
+		params.DesiredSalary = session.LastSearch.DesiredSalary
+		params.PriceFilter = true
+	} else if analysis.SalaryRange != nil {
+		params.DesiredSalary = analysis.SalaryRange
+	}
+
+	// 英語の入力なら日本語の検索語を足し、続きのメッセージから作れなければ前回の検索語を引き継ぐ
+	addJapaneseSearchTerms(&params, message)
+	if len(params.SearchTerms) == 0 && session != nil && session.LastSearch != nil {
+		params.SearchTerms = session.LastSearch.SearchTerms
+	}
+
+	// 絞り込み条件は続きのメッセージにも引き継ぐ（指定し直した項目だけ今回の条件に置き換え、「条件をリセット」なら引き継がない）
+	var previousFilter *ProjectFilter
+	if session != nil && session.LastSearch != nil && !clearsFilters(message) {
+		previousFilter = session.LastSearch.Filter
+	}
+	params.Filter = mergeProjectFilters(analysis.Filters, previousFilter)
+
+	return params
+}
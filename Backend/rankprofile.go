@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * ランキングプロファイルモジュール
+ * キーワード検索の配点・足切り・サイトごとの件数・返す件数・照合するスキルの数と重みをまとめて管理する
+ *
+ * 既定値はこれまでの固定値（タイトル5 / スキル欄3 / 詳細1、1スキル2点、4点以上、1サイト3件、8件、重点スキル3個）
+ * スキルの配点には、経験年数と重点スキルかどうかで決まる重み（1以上）を掛ける
+ * RANKING_PROFILE_FILEのJSONで項目ごとに上書きでき、RANKING_PROFILE_TTLごとに読み直すので再デプロイなしで調整できる
+ * 管理者トークン付きのリクエストはranking_profileでそのリクエストだけ上書きできる（結果の比較用）
+ * 使ったプロファイルはレスポンスのranking_profileに返す
+ */
+
+// ランキングプロファイル
+type RankingProfile struct {
+	Name            string `json:"name"`              // プロファイル名（ログ・レスポンスで見分ける用）
+	TitleWeight     int    `json:"title_weight"`      // タイトルにマッチしたときの点数
+	SkillsWeight    int    `json:"skills_weight"`     // スキル欄にマッチしたときの点数
+	DetailWeight    int    `json:"detail_weight"`     // 詳細にマッチしたときの点数
+	MultiSkillBonus int    `json:"multi_skill_bonus"` // マッチしたスキル1つあたりのボーナス
+	MinScore        int    `json:"min_score"`         // 候補に残すスキルのスコアの下限
+	PerSourceLimit  int    `json:"per_source_limit"`  // 1サイトあたりの最大件数
+	ResultLimit     int    `json:"result_limit"`      // 最終的に返す件数
+	MaxKeySkills    int    `json:"max_key_skills"`    // 検索に使う重点スキルの最大数
+	MaxSearchSkills int    `json:"max_search_skills"` // 重点スキルと構造化スキルを合わせて照合する最大数
+
+	YearsWeight    float64 `json:"years_weight"`     // 経験年数1年あたりに重みに足す値
+	MaxYears       float64 `json:"max_years"`        // 重みに数える経験年数の上限
+	KeySkillWeight float64 `json:"key_skill_weight"` // 重点スキルの重みに掛ける値
+
+	Overridden bool `json:"overridden,omitempty"` // リクエストで上書きしたかどうか
+}
+
+// 照合するスキルの数の上限（SQLの条件が増えすぎないように）
+const (
+	rankingMaxKeySkillsLimit    = 10
+	rankingMaxSearchSkillsLimit = 20
+)
+
+var (
+	rankingProfileMu       sync.Mutex
+	rankingProfile         *RankingProfile
+	rankingProfileLoadedAt time.Time
+)
+
+/**
+ * 既定のランキングプロファイル（これまでの固定値）
+ */
+func defaultRankingProfile() RankingProfile {
+	return RankingProfile{
+		Name:            "default",
+		TitleWeight:     matchScoreTitle,
+		SkillsWeight:    matchScoreSkills,
+		DetailWeight:    matchScoreDetail,
+		MultiSkillBonus: matchScorePerSkill,
+		MinScore:        4,
+		PerSourceLimit:  3,
+		ResultLimit:     8,
+		MaxKeySkills:    3,
+		MaxSearchSkills: 10,
+		YearsWeight:     0.2,
+		MaxYears:        10,
+		KeySkillWeight:  1.5,
+	}
+}
+
+/**
+ * 値が使える範囲か確認する
+ */
+func (r RankingProfile) Validate() error {
+	switch {
+	case r.TitleWeight < 0 || r.SkillsWeight < 0 || r.DetailWeight < 0 || r.MultiSkillBonus < 0:
+		return errors.New("weights must not be negative")
+	case r.TitleWeight+r.SkillsWeight+r.DetailWeight == 0:
+		return errors.New("at least one field weight must be positive")
+	case r.MinScore < 0:
+		return errors.New("min_score must not be negative")
+	case r.PerSourceLimit < 1:
+		return errors.New("per_source_limit must be at least 1")
+	case r.ResultLimit < 1 || r.ResultLimit > searchCandidateLimit:
+		return fmt.Errorf("result_limit must be between 1 and %d", searchCandidateLimit)
+	case r.MaxKeySkills < 1 || r.MaxKeySkills > rankingMaxKeySkillsLimit:
+		return fmt.Errorf("max_key_skills must be between 1 and %d", rankingMaxKeySkillsLimit)
+	case r.MaxSearchSkills < r.MaxKeySkills || r.MaxSearchSkills > rankingMaxSearchSkillsLimit:
+		return fmt.Errorf("max_search_skills must be between max_key_skills and %d", rankingMaxSearchSkillsLimit)
+	case r.YearsWeight < 0 || r.MaxYears < 0:
+		return errors.New("years_weight and max_years must not be negative")
+	case r.KeySkillWeight < 1:
+		return errors.New("key_skill_weight must be at least 1")
+	}
+	return nil
+}
+
+/**
+ * 項目（カラム名）ごとの点数
+ */
+func (r RankingProfile) fieldWeight(column string) int {
+	switch column {
+	case "prottl":
+		return r.TitleWeight
+	case "proot1":
+		return r.SkillsWeight
+	case "prodtl":
+		return r.DetailWeight
+	}
+	return 0
+}
+
+/**
+ * スキルの重み（1 + 経験年数 × years_weight、重点スキルならさらにkey_skill_weight倍）
+ * 足切り（min_score）は重みを掛ける前の点数で判定するので、重みで並びは変わっても候補に残る案件は変わらない
+ */
+func (r RankingProfile) skillWeight(years float64, key bool) float64 {
+	weight := 1 + math.Min(math.Max(years, 0), r.MaxYears)*r.YearsWeight
+	if key {
+		weight *= r.KeySkillWeight
+	}
+	return math.Round(weight*100) / 100
+}
+
+/**
+ * 重み付きの項目の点数（SQLとGoで同じ値になるよう整数に丸める）
+ */
+func (r RankingProfile) weightedFieldScore(column string, weight float64) int {
+	return int(math.Round(float64(r.fieldWeight(column)) * weight))
+}
+
+/**
+ * JSONで指定された項目だけをプロファイルに上書きする（知らない項目はエラー）
+ */
+func overlayRankingProfile(base RankingProfile, raw []byte) (RankingProfile, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	profile := base
+	if err := decoder.Decode(&profile); err != nil {
+		return base, err
+	}
+	if err := profile.Validate(); err != nil {
+		return base, err
+	}
+	return profile, nil
+}
+
+/**
+ * ランキングプロファイルを読み込む
+ * RANKING_PROFILE_FILEがあれば、既定値にそのファイルの項目を重ねる
+ */
+func loadRankingProfile() (RankingProfile, error) {
+	profile := defaultRankingProfile()
+	path := os.Getenv("RANKING_PROFILE_FILE")
+	if path == "" {
+		return profile, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return profile, fmt.Errorf("failed to read ranking profile file: %v", err)
+	}
+	profile, err = overlayRankingProfile(profile, data)
+	if err != nil {
+		return profile, fmt.Errorf("invalid ranking profile file: %v", err)
+	}
+	profile.Overridden = false
+	return profile, nil
+}
+
+/**
+ * 現在のランキングプロファイルを返す
+ * RANKING_PROFILE_TTL（デフォルト5分）を過ぎていれば読み直す
+ * 読み直しに失敗した場合は前回のプロファイル（なければ既定値）を使い続ける
+ */
+func getRankingProfile() RankingProfile {
+	rankingProfileMu.Lock()
+	defer rankingProfileMu.Unlock()
+
+	if rankingProfile != nil && time.Since(rankingProfileLoadedAt) < envDuration("RANKING_PROFILE_TTL", 5*time.Minute) {
+		return *rankingProfile
+	}
+
+	profile, err := loadRankingProfile()
+	if err != nil {
+		log.Printf("Ranking profile error: %v", err)
+		if rankingProfile == nil {
+			profile = defaultRankingProfile()
+			rankingProfile = &profile
+		}
+	} else {
+		rankingProfile = &profile
+	}
+	rankingProfileLoadedAt = time.Now()
+	return *rankingProfile
+}
+
+/**
+ * ランキングプロファイルをすぐに読み直す
+ */
+func reloadRankingProfile() (RankingProfile, error) {
+	profile, err := loadRankingProfile()
+	if err != nil {
+		return profile, err
+	}
+
+	rankingProfileMu.Lock()
+	rankingProfile = &profile
+	rankingProfileLoadedAt = time.Now()
+	rankingProfileMu.Unlock()
+
+	return profile, nil
+}
+
+/**
+ * リクエストで使うランキングプロファイルを決める
+ * 上書き（ranking_profile）は管理者トークン付きのリクエストだけ受け付ける
+ * @return int エラー時のステータスコード
+ */
+func resolveRankingProfile(c *gin.Context, override json.RawMessage) (RankingProfile, int, error) {
+	profile := getRankingProfile()
+	if len(bytes.TrimSpace(override)) == 0 {
+		return profile, 200, nil
+	}
+	if !isAdminRequest(c) {
+		return profile, 403, errors.New("ranking_profile requires an admin token")
+	}
+
+	overridden, err := overlayRankingProfile(profile, override)
+	if err != nil {
+		return profile, 400, fmt.Errorf("Invalid request: invalid ranking_profile: %v", err)
+	}
+	overridden.Overridden = true
+	return overridden, 200, nil
+}
+
+/**
+ * 検索条件のランキングプロファイル（指定がなければ現在のプロファイル）
+ */
+func (p SearchParams) rankingProfile() RankingProfile {
+	if p.Ranking != nil {
+		return *p.Ranking
+	}
+	return getRankingProfile()
+}
+
+/**
+ * 検索条件にランキングプロファイルが決まっていなければ、現在のプロファイルに固定する
+ * 検索の途中でファイルを読み直しても、1回の検索の中では同じ値を使うように
+ */
+func withRankingProfile(params SearchParams) SearchParams {
+	if params.Ranking == nil {
+		profile := getRankingProfile()
+		params.Ranking = &profile
+	}
+	return params
+}
+
+/**
+ * 現在のランキングプロファイルを返す管理者用ハンドラー
+ */
+func handleGetRankingProfile(c *gin.Context) {
+	c.JSON(200, gin.H{"profile": getRankingProfile(), "default": defaultRankingProfile()})
+}
+
+/**
+ * ランキングプロファイルを読み直す管理者用ハンドラー
+ */
+func handleReloadRankingProfile(c *gin.Context) {
+	profile, err := reloadRankingProfile()
+	if err != nil {
+		log.Printf("Ranking profile error: %v", err)
+		c.JSON(500, gin.H{"error": "Ranking profile reload failed: " + err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"profile": profile})
+}
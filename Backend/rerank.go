@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * 案件のリランキングモジュール
+ * SQLのスコアは文字列の出現回数しか見ないため、「Javaが一度出てくるだけの案件」が
+ * 本当のJavaバックエンド案件より上に来ることがある。
+ * 候補をAIに渡し、分析結果全体と照らした適合度と一言の理由で並べ直す
+ */
+
+// リランキング設定の構造体
+type RerankConfig struct {
+	Enabled bool          // リランキングを行うかどうか
+	Timeout time.Duration // AIの応答を待つ時間（超えたらSQLの並びのまま）
+}
+
+// リランキングの返答のスキーマ
+const rerankSchemaJSON = `{
+  "type": "object",
+  "required": ["rankings"],
+  "properties": {
+    "rankings": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["id", "score", "reason"],
+        "properties": {
+          "id": {"type": "integer", "minimum": 0},
+          "score": {"type": "number", "minimum": 0},
+          "reason": {"type": "string"}
+        }
+      }
+    }
+  }
+}`
+
+var rerankSchema = mustParseSchema(rerankSchemaJSON)
+
+// AIに渡す案件詳細の最大文字数
+const rerankDetailMaxRunes = 200
+
+// AIに求める理由の目安の文字数（プロンプトのパラメーター）
+const rerankReasonLength = 40
+
+// AIの返答1件分
+type rerankResult struct {
+	ID     int     `json:"id"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+/**
+ * 環境変数からリランキング設定を読み込む
+ */
+func LoadRerankConfig() RerankConfig {
+	enabled, err := strconv.ParseBool(getEnvWithDefault("RERANK_ENABLED", "true"))
+	if err != nil {
+		enabled = true
+	}
+
+	timeout, err := time.ParseDuration(getEnvWithDefault("RERANK_TIMEOUT", "10s"))
+	if err != nil || timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return RerankConfig{Enabled: enabled, Timeout: timeout}
+}
+
+// 案件検索の付帯情報
+type searchOutcome struct {
+	Reranked   bool   // AIで並べ直したかどうか
+	SearchMode string // 実際に使った検索方法（意味検索に失敗したらkeyword）
+	Translated bool   // すべての案件に英訳を付けたかどうか
+	NextCursor string // 続きの案件を取得するカーソル（続きがなければ空）
+}
+
+/**
+ * 分析結果に合う案件を検索する（候補の取り出し → 意味の近さを混ぜる → AIでの並べ直し → サイトごとの件数制限 → 英訳）
+ * ルールベースで解析した場合はAIを使わないので並べ直さず、英訳もしない
+ * 意味検索に失敗した場合はログだけ出してキーワードの並びのまま続ける
+ * 続きのページ（pagination.go）はキーワードの並びで返すので、カーソルもキーワードの並びで作る
+ */
+func searchProjectsForAnalysis(ctx context.Context, params SearchParams, outcome analysisOutcome) ([]Project, searchOutcome, error) {
+	result := searchOutcome{SearchMode: SearchModeKeyword}
+	params = withRankingProfile(params)
+
+	candidates, full, err := searchCandidateWindow(params)
+	if err != nil {
+		return nil, result, err
+	}
+	keywordRanked := candidates
+
+	if params.SearchMode == SearchModeSemantic {
+		blended, err := applySemanticSearch(ctx, params, candidates)
+		if err != nil {
+			log.Printf("Semantic search skipped, keeping keyword ranking: %v", err)
+		} else {
+			candidates = blended
+			result.SearchMode = SearchModeSemantic
+		}
+	}
+
+	if outcome.Analyzer == AnalyzerAI {
+		candidates, result.Reranked = rerankCandidates(ctx, outcome.Analysis, candidates)
+	}
+
+	projects := selectProjects(candidates, params.rankingProfile())
+	result.NextCursor = nextSearchCursor(searchCursor{Search: searchParamsHash(params)}, keywordRanked, projects, full)
+	if params.Translate && outcome.Analyzer == AnalyzerAI {
+		projects, result.Translated = translateProjects(ctx, projects)
+	}
+
+	return projects, result, nil
+}
+
+/**
+ * 候補をAIで並べ直す
+ * 失敗・タイムアウト時はログだけ出して元の並びのまま返す
+ * @return bool 並べ直したかどうか
+ */
+func rerankCandidates(ctx context.Context, analysis AIAnalysis, candidates []rankedProject) ([]rankedProject, bool) {
+	config := LoadRerankConfig()
+	if !config.Enabled || len(candidates) < 2 {
+		return candidates, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	results, err := requestRerank(ctx, analysis, candidates)
+	if err != nil {
+		log.Printf("Rerank skipped, keeping SQL order: %v", err)
+		return candidates, false
+	}
+
+	return applyRerank(candidates, results), true
+}
+
+/**
+ * AIに候補の評価を依頼する
+ */
+func requestRerank(ctx context.Context, analysis AIAnalysis, candidates []rankedProject) ([]rerankResult, error) {
+	provider, err := getLLMProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	systemPrompt, version, err := renderPrompt(PromptRerank, rerankPromptData{ReasonLength: rerankReasonLength})
+	if err != nil {
+		return nil, err
+	}
+
+	analysisJSON, err := json.Marshal(analysis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode analysis: %v", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("スキル分析結果:\n")
+	sb.Write(analysisJSON)
+	sb.WriteString("\n\n案件候補:\n")
+	for i, c := range candidates {
+		fmt.Fprintf(&sb, "[ID:%d] %s / スキル: %s / 単価: %s / 詳細: %s\n",
+			i, c.Title, c.Skills, c.Price, truncateRunes(strings.Join(strings.Fields(c.Detail), " "), rerankDetailMaxRunes))
+	}
+
+	result, err := provider.Chat(withPromptVersion(withAIPurpose(ctx, AIPurposeRerank), version), []AIChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: sb.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jsonText := extractJSONText(result.Content)
+	var raw interface{}
+	if err := json.Unmarshal([]byte(jsonText), &raw); err != nil {
+		return nil, fmt.Errorf("invalid rerank JSON: %v", err)
+	}
+	if errs := validateSchema(rerankSchema, raw, "$"); len(errs) > 0 {
+		return nil, fmt.Errorf("rerank response failed schema validation: %s", strings.Join(errs, "; "))
+	}
+
+	var response struct {
+		Rankings []rerankResult `json:"rankings"`
+	}
+	if err := json.Unmarshal([]byte(jsonText), &response); err != nil {
+		return nil, fmt.Errorf("invalid rerank JSON: %v", err)
+	}
+	if len(response.Rankings) == 0 {
+		return nil, fmt.Errorf("rerank response has no rankings")
+	}
+
+	return response.Rankings, nil
+}
+
+/**
+ * AIの評価を候補に反映して並べ直す
+ * 評価されなかった候補は、評価された候補の後ろに元の順番で並べる
+ */
+func applyRerank(candidates []rankedProject, results []rerankResult) []rankedProject {
+	reranked := make([]rankedProject, len(candidates))
+	copy(reranked, candidates)
+
+	scored := make([]bool, len(reranked))
+	for _, r := range results {
+		if r.ID < 0 || r.ID >= len(reranked) || scored[r.ID] {
+			continue
+		}
+		score := int(r.Score + 0.5)
+		if score > 100 {
+			score = 100
+		}
+		reranked[r.ID].RelevanceScore = &score
+		reranked[r.ID].RelevanceReason = strings.TrimSpace(r.Reason)
+		scored[r.ID] = true
+	}
+
+	sort.SliceStable(reranked, func(i, j int) bool {
+		a, b := reranked[i].RelevanceScore, reranked[j].RelevanceScore
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		return *a > *b
+	})
+
+	return reranked
+}
+
+/**
+ * 文字数（rune）で切り詰める
+ */
+func truncateRunes(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max]) + "…"
+}
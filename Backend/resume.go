@@ -0,0 +1,633 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+/**
+ * 職務経歴書のアップロードモジュール
+ * Word（.docx）・Excel（.xlsx）・テキストレイヤーのあるPDF・テキスト（.txt / .md）から本文を取り出し、
+ * /api/chat と同じ解析・検索にかける
+ * .docx / .xlsx はzipの中のXMLを、PDFはpdftext.goで読む
+ */
+
+// 職務経歴書アップロードのレスポンス
+type ResumeResponse struct {
+	ChatResponse
+	ExtractedText string `json:"extracted_text"` // 取り出したテキスト（確認用）
+	FileName      string `json:"file_name"`      // アップロードされたファイル名
+	FileType      string `json:"file_type"`      // ファイルの種類（pdf / docx / xlsx / txt）
+	Truncated     bool   `json:"truncated"`      // 文字数の上限で切り詰めたかどうか
+}
+
+// 対応していないファイル形式
+var errUnsupportedResumeType = errors.New("unsupported file type")
+
+const (
+	defaultResumeMaxBytes = 5 << 20  // アップロードできるファイルの最大サイズ
+	defaultResumeMaxChars = 20000    // AIに渡す最大文字数
+	resumeFormOverhead    = 1 << 20  // multipartの区切りなど、ファイル以外の分の余裕
+	resumeMaxXMLBytes     = 32 << 20 // zip内の1ファイルを展開したときの最大サイズ（圧縮爆弾の対策）
+)
+
+// 3行以上続く空行
+var resumeBlankLines = regexp.MustCompile(`\n{3,}`)
+
+/**
+ * アップロードできるファイルの最大サイズ（RESUME_MAX_BYTES）
+ */
+func resumeMaxBytes() int64 {
+	value, err := strconv.ParseInt(getEnvWithDefault("RESUME_MAX_BYTES", strconv.Itoa(defaultResumeMaxBytes)), 10, 64)
+	if err != nil || value <= 0 {
+		return defaultResumeMaxBytes
+	}
+	return value
+}
+
+/**
+ * AIに渡す最大文字数（RESUME_MAX_CHARS）
+ */
+func resumeMaxChars() int {
+	value, err := strconv.Atoi(getEnvWithDefault("RESUME_MAX_CHARS", strconv.Itoa(defaultResumeMaxChars)))
+	if err != nil || value <= 0 {
+		return defaultResumeMaxChars
+	}
+	return value
+}
+
+/**
+ * 職務経歴書アップロードのハンドラー
+ * multipartのfileに職務経歴書、session_id / analyzer / search_mode / translate は /api/chat と同じ意味
+ */
+func handleResumeUpload(c *gin.Context) {
+	maxBytes := resumeMaxBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+resumeFormOverhead)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(413, gin.H{"error": fmt.Sprintf("File too large (max %d bytes)", maxBytes)})
+			return
+		}
+		c.JSON(400, gin.H{"error": "Invalid request: file is required"})
+		return
+	}
+	if fileHeader.Size > maxBytes {
+		c.JSON(413, gin.H{"error": fmt.Sprintf("File too large (max %d bytes)", maxBytes)})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	file.Close()
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	fileType := resumeFileType(fileHeader.Filename)
+	text, err := extractResumeText(fileType, data)
+	if err != nil {
+		log.Printf("Resume extraction error (%s): %v", fileHeader.Filename, err)
+		if errors.Is(err, errUnsupportedResumeType) {
+			c.JSON(415, gin.H{"error": "Unsupported file type. Use .pdf, .docx, .xlsx, .txt or .md"})
+			return
+		}
+		c.JSON(422, gin.H{"error": "ファイルからテキストを取り出せませんでした。", "detail": err.Error()})
+		return
+	}
+	if text == "" {
+		c.JSON(422, gin.H{"error": "ファイルからテキストを取り出せませんでした。画像のみのPDFには対応していません。"})
+		return
+	}
+
+	truncated := false
+	if maxChars := resumeMaxChars(); utf8.RuneCountInString(text) > maxChars {
+		text = string([]rune(text)[:maxChars])
+		truncated = true
+	}
+
+	filters, err := parseFilterParam(c.PostForm("filters"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	clearFilters, _ := strconv.ParseBool(c.PostForm("clear_filters"))
+
+	response, ok := processChatRequest(c, ChatRequest{
+		Message:      text,
+		SessionID:    c.PostForm("session_id"),
+		Analyzer:     c.PostForm("analyzer"),
+		SearchMode:   c.PostForm("search_mode"),
+		Translate:    parseTranslateFlag(c.PostForm("translate")),
+		Filters:      filters,
+		ClearFilters: clearFilters,
+
+		RankingProfile: json.RawMessage(c.PostForm("ranking_profile")),
+	})
+	if !ok {
+		return
+	}
+
+	c.JSON(200, ResumeResponse{
+		ChatResponse:  response,
+		ExtractedText: text,
+		FileName:      fileHeader.Filename,
+		FileType:      fileType,
+		Truncated:     truncated,
+	})
+}
+
+/**
+ * 拡張子からファイルの種類を決める
+ */
+func resumeFileType(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".pdf":
+		return "pdf"
+	case ".docx":
+		return "docx"
+	case ".xlsx":
+		return "xlsx"
+	case ".txt", ".md":
+		return "txt"
+	default:
+		return ""
+	}
+}
+
+/**
+ * ファイルの種類に応じてテキストを取り出し、空行や行末の空白を整える
+ */
+func extractResumeText(fileType string, data []byte) (string, error) {
+	var text string
+	var err error
+	switch fileType {
+	case "pdf":
+		text, err = extractPDFText(data)
+	case "docx":
+		text, err = extractDocxText(data)
+	case "xlsx":
+		text, err = extractXlsxText(data)
+	case "txt":
+		text, err = decodePlainText(data)
+	default:
+		return "", errUnsupportedResumeType
+	}
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t　")
+	}
+	text = resumeBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text), nil
+}
+
+/**
+ * テキストファイルを文字列にする
+ * UTF-8（BOM付きも可）とUTF-16（BOM付き）のほか、UTF-8として読めなければShift_JISとして読む
+ */
+func decodePlainText(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return string(data[3:]), nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		decoded, err := unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		if err != nil {
+			return "", fmt.Errorf("invalid UTF-16 text: %v", err)
+		}
+		return string(decoded), nil
+	case utf8.Valid(data):
+		return string(data), nil
+	}
+
+	decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("unknown text encoding: %v", err)
+	}
+	return string(decoded), nil
+}
+
+// ============================================================
+// Word（.docx）
+// ============================================================
+
+/**
+ * .docxの本文（word/document.xml）からテキストを取り出す
+ * 段落ごとに改行し、表のセルは段落として並べる
+ */
+func extractDocxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("invalid docx file: %v", err)
+	}
+	document, err := readZipEntry(zr, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid docx XML: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteString("\t")
+			case "br", "cr":
+				sb.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// ============================================================
+// Excel（.xlsx）
+// ============================================================
+
+// 日付として表示する組み込みの表示形式（ja-JPの和暦・年月日を含む）
+var xlsxBuiltinDateFormats = map[int]bool{
+	14: true, 15: true, 16: true, 17: true, 22: true,
+	27: true, 28: true, 29: true, 30: true, 31: true, 32: true, 33: true, 34: true, 35: true, 36: true,
+	50: true, 51: true, 52: true, 53: true, 54: true, 55: true, 56: true, 57: true, 58: true,
+}
+
+// 表示形式の文字列から、引用符・角括弧の中を除く
+var xlsxFormatLiteral = regexp.MustCompile(`"[^"]*"|\[[^\]]*\]`)
+
+// Excelのシリアル値の起点（1900年方式）
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+/**
+ * .xlsxの全シートからテキストを取り出す
+ * 1行を1行のテキストにし、セルはタブで区切る。日付の表示形式のセルは年月日に直す
+ * シートが複数あるときは「■ シート名」の見出しを付ける
+ */
+func extractXlsxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("invalid xlsx file: %v", err)
+	}
+
+	sharedStrings, err := readXlsxSharedStrings(zr)
+	if err != nil {
+		return "", err
+	}
+	dateStyles := readXlsxDateStyles(zr)
+
+	sheets, err := readXlsxSheetList(zr)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, sheet := range sheets {
+		raw, err := readZipEntry(zr, sheet.path)
+		if err != nil {
+			return "", err
+		}
+		text, err := readXlsxSheet(raw, sharedStrings, dateStyles)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if len(sheets) > 1 {
+			sb.WriteString("■ " + sheet.name + "\n")
+		}
+		sb.WriteString(text)
+		sb.WriteString("\n")
+	}
+
+	return sb.String(), nil
+}
+
+// シート1つ分の名前とzip内のパス
+type xlsxSheet struct {
+	name string
+	path string
+}
+
+/**
+ * workbook.xmlとそのrelsから、シートを並び順に返す
+ */
+func readXlsxSheetList(zr *zip.Reader) ([]xlsxSheet, error) {
+	workbookXML, err := readZipEntry(zr, "xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(workbookXML, &workbook); err != nil {
+		return nil, fmt.Errorf("invalid xlsx workbook: %v", err)
+	}
+
+	targets := map[string]string{}
+	if relsXML, err := readZipEntry(zr, "xl/_rels/workbook.xml.rels"); err == nil {
+		var rels struct {
+			Relationships []struct {
+				ID     string `xml:"Id,attr"`
+				Target string `xml:"Target,attr"`
+			} `xml:"Relationship"`
+		}
+		if err := xml.Unmarshal(relsXML, &rels); err == nil {
+			for _, r := range rels.Relationships {
+				target := strings.TrimPrefix(r.Target, "/")
+				if !strings.HasPrefix(target, "xl/") {
+					target = path.Join("xl", target)
+				}
+				targets[r.ID] = target
+			}
+		}
+	}
+
+	var sheets []xlsxSheet
+	for i, s := range workbook.Sheets {
+		target, ok := targets[s.RID]
+		if !ok {
+			target = fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1)
+		}
+		sheets = append(sheets, xlsxSheet{name: s.Name, path: target})
+	}
+	return sheets, nil
+}
+
+/**
+ * 共有文字列（xl/sharedStrings.xml）を読む。ふりがな（rPh）は除く
+ */
+func readXlsxSharedStrings(zr *zip.Reader) ([]string, error) {
+	raw, err := readZipEntry(zr, "xl/sharedStrings.xml")
+	if err != nil {
+		// 文字列のセルが1つもないブックにはsharedStrings.xmlがない
+		return nil, nil
+	}
+
+	var stringsList []string
+	var current strings.Builder
+	decoder := xml.NewDecoder(bytes.NewReader(raw))
+	inText, inPhonetic := false, false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid xlsx shared strings: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				current.Reset()
+			case "t":
+				inText = true
+			case "rPh":
+				inPhonetic = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				stringsList = append(stringsList, current.String())
+			case "t":
+				inText = false
+			case "rPh":
+				inPhonetic = false
+			}
+		case xml.CharData:
+			if inText && !inPhonetic {
+				current.Write(t)
+			}
+		}
+	}
+	return stringsList, nil
+}
+
+/**
+ * スタイル（xl/styles.xml）から、日付の表示形式を使うセルスタイルの番号を集める
+ */
+func readXlsxDateStyles(zr *zip.Reader) map[int]string {
+	dateStyles := map[int]string{}
+	raw, err := readZipEntry(zr, "xl/styles.xml")
+	if err != nil {
+		return dateStyles
+	}
+
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := xml.Unmarshal(raw, &styles); err != nil {
+		return dateStyles
+	}
+
+	customFormats := map[int]string{}
+	for _, f := range styles.NumFmts {
+		customFormats[f.ID] = f.Code
+	}
+
+	for i, xf := range styles.CellXfs {
+		if code, ok := customFormats[xf.NumFmtID]; ok {
+			if layout := xlsxDateLayout(code); layout != "" {
+				dateStyles[i] = layout
+			}
+		} else if xlsxBuiltinDateFormats[xf.NumFmtID] {
+			dateStyles[i] = "2006/01/02"
+		}
+	}
+	return dateStyles
+}
+
+/**
+ * 表示形式が日付ならGoの日付レイアウトを返す（日付でなければ空）
+ * 日の指定がなければ年月まで（"yyyy/mm" → "2006/01"）
+ */
+func xlsxDateLayout(code string) string {
+	stripped := strings.ToLower(xlsxFormatLiteral.ReplaceAllString(code, ""))
+	if !strings.Contains(stripped, "y") && !strings.Contains(stripped, "d") {
+		return ""
+	}
+	if strings.Contains(stripped, "d") {
+		return "2006/01/02"
+	}
+	return "2006/01"
+}
+
+/**
+ * シート1枚分のXMLをテキストにする
+ */
+func readXlsxSheet(raw []byte, sharedStrings []string, dateStyles map[int]string) (string, error) {
+	var sb strings.Builder
+	var row []string
+	var cellType, value string
+	style := -1
+	inValue := false
+
+	decoder := xml.NewDecoder(bytes.NewReader(raw))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid xlsx sheet: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = row[:0]
+			case "c":
+				cellType, value, style = "", "", -1
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "t":
+						cellType = attr.Value
+					case "s":
+						style, _ = strconv.Atoi(attr.Value)
+					}
+				}
+			case "v", "t":
+				inValue = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				if text := xlsxCellText(cellType, value, style, sharedStrings, dateStyles); text != "" {
+					row = append(row, text)
+				}
+			case "row":
+				if len(row) > 0 {
+					sb.WriteString(strings.Join(row, "\t"))
+					sb.WriteString("\n")
+				}
+			}
+		case xml.CharData:
+			if inValue {
+				value += string(t)
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
+
+/**
+ * セル1つ分の値を表示用の文字列にする
+ */
+func xlsxCellText(cellType, value string, style int, sharedStrings []string, dateStyles map[int]string) string {
+	value = strings.TrimSpace(value)
+	switch cellType {
+	case "s":
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 || index >= len(sharedStrings) {
+			return ""
+		}
+		return strings.TrimSpace(sharedStrings[index])
+	case "b":
+		if value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "inlineStr", "str", "e":
+		return value
+	}
+
+	if layout, ok := dateStyles[style]; ok {
+		if serial, err := strconv.ParseFloat(value, 64); err == nil && serial > 0 {
+			return xlsxEpoch.Add(time.Duration(serial * float64(24*time.Hour))).Format(layout)
+		}
+	}
+	return value
+}
+
+/**
+ * zip内のファイルを展開して読む（展開後のサイズに上限を設ける）
+ */
+func readZipEntry(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %v", name, err)
+		}
+		defer rc.Close()
+
+		data, err := io.ReadAll(io.LimitReader(rc, resumeMaxXMLBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", name, err)
+		}
+		if len(data) > resumeMaxXMLBytes {
+			return nil, fmt.Errorf("%s is too large", name)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s not found", name)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/**
+ * 単価の数値化モジュール
+ * "月額60万円〜80万円" "〜90万円/月" "400,000円 〜 500,000円" のような文字列を
+ * 月額の円（下限・上限）に変換する
+ */
+
+// 月額単価の範囲（円）。0はその側の指定なし
+type SalaryRange struct {
+	Min int `json:"min"` // 下限（円/月）
+	Max int `json:"max"` // 上限（円/月）
+}
+
+var (
+	// 範囲指定（例: "60万円〜80万円", "60-80万", "400000円~500000円"）。前側の単位は省略可
+	salaryRangePattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(万円?|円)?\s*(?:〜|~|-|ー|から)\s*(\d+(?:\.\d+)?)\s*(万円?|円)`)
+	// 上限のみ（例: "〜90万円", "最大90万円", "90万円まで"）
+	salaryMaxPattern       = regexp.MustCompile(`(?:〜|~|最大|上限)\s*(\d+(?:\.\d+)?)\s*(万円?|円)`)
+	salaryMaxSuffixPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(万円?|円)\s*(?:まで|以下|以内)`)
+	// 単独の金額（例: "80万円", "90万円以上", "90万円〜"）
+	salarySinglePattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(万円?|円)\s*(以上|〜|~|から)?`)
+	// 数字の桁区切り
+	salaryDigitSeparator = regexp.MustCompile(`(\d),(\d)`)
+
+	// 単価ではない金額・数量（例: "月間100万PV", "1000万ユーザー", "予算300万円"）
+	nonSalaryAmountPattern = regexp.MustCompile(`(?:予算|売上|年商|資本金|調達)\s*\d+(?:\.\d+)?\s*(?:億|万)?円?|\d+(?:\.\d+)?\s*万\s*(?:[a-zァ-ヴ]|人|件|回|行|台|社)`)
+	// 希望単価だと分かる言い回し（例: "単価80万", "月80万", "80万円/月", "80万円以上希望"）
+	salaryContextPattern = regexp.MustCompile(`単価|月額|月給|月収|年収|年俸|年額|時給|日給|日額|報酬|月\s*\d|/\s*月|\d\s*(?:万円?|円)\s*(?:以上|以下|まで|以内|〜|~)?\s*(?:を|で|が)?\s*希望`)
+	// 希望単価を探す単位（文・読点ごと）
+	salaryClauseSeparator = regexp.MustCompile(`[、。,!?\n]`)
+)
+
+/**
+ * 文字列から単価の範囲を取り出し、月額の円に換算する
+ * 時給・日給・年収の表記は月額に換算する（時給×160時間、日給×20日、年収÷12）
+ * @return bool 金額が見つかったかどうか
+ */
+func parseSalaryRange(text string) (SalaryRange, bool) {
+	normalized := normalizeMessage(text)
+	for salaryDigitSeparator.MatchString(normalized) {
+		normalized = salaryDigitSeparator.ReplaceAllString(normalized, "$1$2")
+	}
+
+	var r SalaryRange
+	if m := salaryRangePattern.FindStringSubmatch(normalized); m != nil {
+		minUnit := m[2]
+		if minUnit == "" {
+			minUnit = m[4]
+		}
+		r = SalaryRange{Min: salaryAmount(m[1], minUnit), Max: salaryAmount(m[3], m[4])}
+	} else if m := salaryMaxPattern.FindStringSubmatch(normalized); m != nil {
+		r = SalaryRange{Max: salaryAmount(m[1], m[2])}
+	} else if m := salaryMaxSuffixPattern.FindStringSubmatch(normalized); m != nil {
+		r = SalaryRange{Max: salaryAmount(m[1], m[2])}
+	} else if m := salarySinglePattern.FindStringSubmatch(normalized); m != nil {
+		amount := salaryAmount(m[1], m[2])
+		r = SalaryRange{Min: amount, Max: amount}
+		if m[3] != "" {
+			r.Max = 0
+		}
+	} else {
+		return SalaryRange{}, false
+	}
+
+	// 月額以外の表記を月額に換算
+	switch {
+	case strings.Contains(normalized, "時給") || strings.Contains(normalized, "/h"):
+		r = SalaryRange{Min: r.Min * 160, Max: r.Max * 160}
+	case strings.Contains(normalized, "日給") || strings.Contains(normalized, "日額"):
+		r = SalaryRange{Min: r.Min * 20, Max: r.Max * 20}
+	case strings.Contains(normalized, "年収") || strings.Contains(normalized, "年俸") || strings.Contains(normalized, "年額"):
+		r = SalaryRange{Min: r.Min / 12, Max: r.Max / 12}
+	}
+
+	// 下限と上限が逆に書かれていたら入れ替える
+	if r.Min > 0 && r.Max > 0 && r.Min > r.Max {
+		r.Min, r.Max = r.Max, r.Min
+	}
+
+	return r, r.Min > 0 || r.Max > 0
+}
+
+/**
+ * メッセージから希望単価を取り出す
+ * 単価・月額・希望などの言い回しがある文だけを見る。"月間100万PV" "予算300万円のPJ" は希望単価にしない
+ * @return bool 希望単価がはっきり書かれていたかどうか
+ */
+func parseDesiredSalary(message string) (SalaryRange, bool) {
+	text := stripNonSalaryAmounts(normalizeMessage(message))
+	for _, clause := range salaryClauseSeparator.Split(text, -1) {
+		if !salaryContextPattern.MatchString(clause) {
+			continue
+		}
+		if r, ok := parseSalaryRange(clause); ok {
+			return r, true
+		}
+	}
+	return SalaryRange{}, false
+}
+
+/**
+ * 単価ではない金額・数量を取り除く（normalizeMessage済みのテキストに使う）
+ */
+func stripNonSalaryAmounts(text string) string {
+	return nonSalaryAmountPattern.ReplaceAllString(text, " ")
+}
+
+/**
+ * AIの推定単価（estimated_salary）を数値化する。読み取れなければnil
+ */
+func parseEstimatedSalary(estimated string) *SalaryRange {
+	r, ok := parseSalaryRange(estimated)
+	if !ok {
+		return nil
+	}
+	return &r
+}
+
+/**
+ * 数値と単位から円に換算
+ */
+func salaryAmount(number, unit string) int {
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0
+	}
+	if strings.HasPrefix(unit, "万") {
+		value *= 10000
+	}
+	return int(value)
+}
+
+/**
+ * 2つの単価の範囲が重なるか（0の側は上限・下限なしとして扱う）
+ */
+func (r SalaryRange) Overlaps(other SalaryRange) bool {
+	if r.Max > 0 && other.Min > 0 && r.Max < other.Min {
+		return false
+	}
+	if other.Max > 0 && r.Min > 0 && other.Max < r.Min {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/**
+ * AI分析結果のスキーマ検証モジュール
+ * AIAnalysisの契約をJSON Schemaで定義し、AIの返答が契約を満たすかを検証する
+ * 検証エラーはそのままAIに差し戻して修正させる（chat.goの修正ループで使用）
+ */
+
+// AIAnalysisのJSON Schema（AIへの修正依頼にもこの内容をそのまま渡す）
+const aiAnalysisSchemaJSON = `{
+  "type": "object",
+  "required": [
+    "estimated_salary", "strengths", "suggestions", "structured_skills",
+    "search_prompt", "key_skills", "preferred_role", "experience_level"
+  ],
+  "properties": {
+    "estimated_salary": {"type": "string"},
+    "strengths": {"type": "string"},
+    "suggestions": {"type": "string"},
+    "structured_skills": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["skill_name", "experience_years"],
+        "properties": {
+          "skill_name": {"type": "string"},
+          "experience_years": {"type": "number", "minimum": 0}
+        }
+      }
+    },
+    "search_prompt": {"type": "string"},
+    "key_skills": {"type": "array", "items": {"type": "string"}},
+    "preferred_role": {"type": "string"},
+    "experience_level": {"type": "string", "enum": ["初級", "中級", "上級", "エキスパート"]},
+    "excluded_skills": {"type": "array", "items": {"type": "string"}},
+    "excluded_keywords": {"type": "array", "items": {"type": "string"}},
+    "filters": {
+      "type": "object",
+      "properties": {
+        "min_price": {"type": "number", "minimum": 0},
+        "max_price": {"type": "number", "minimum": 0},
+        "work_style": {"type": "string", "enum": ["", "remote", "onsite"]},
+        "period": {"type": "string", "enum": ["", "long", "short"]},
+        "sources": {"type": "array", "items": {"type": "string"}},
+        "exclude_sources": {"type": "array", "items": {"type": "string"}},
+        "posted_since": {"type": "string"},
+        "excluded_skills": {"type": "array", "items": {"type": "string"}},
+        "excluded_keywords": {"type": "array", "items": {"type": "string"}}
+      }
+    }
+  }
+}`
+
+// JSON Schemaのうち、AIAnalysisの検証に必要なキーワードだけを扱う構造体
+type jsonSchema struct {
+	Type       string                 `json:"type"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	Enum       []interface{}          `json:"enum"`
+	Minimum    *float64               `json:"minimum"`
+}
+
+// パース済みのAIAnalysisスキーマ
+var aiAnalysisSchema = mustParseSchema(aiAnalysisSchemaJSON)
+
+/**
+ * スキーマ定義をパースする（定義ミスは起動時に気づけるようpanicにする）
+ */
+func mustParseSchema(raw string) *jsonSchema {
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		panic(fmt.Sprintf("invalid JSON schema: %v", err))
+	}
+	return &schema
+}
+
+/**
+ * 値がスキーマを満たすか検証する
+ * @param schema スキーマ
+ * @param value json.Unmarshalでinterface{}にデコードした値
+ * @param path エラーメッセージ用のフィールドパス
+ * @return []string 検証エラーの一覧（問題がなければ空）
+ */
+func validateSchema(schema *jsonSchema, value interface{}, path string) []string {
+	var errs []string
+
+	if schema.Type != "" && !matchesSchemaType(schema.Type, value) {
+		return []string{fmt.Sprintf("%s: %s型である必要があります（実際: %s）", path, schema.Type, describeJSONType(value))}
+	}
+
+	if len(schema.Enum) > 0 && !isJSONContainer(value) {
+		found := false
+		for _, candidate := range schema.Enum {
+			if candidate == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			var options []string
+			for _, candidate := range schema.Enum {
+				options = append(options, fmt.Sprint(candidate))
+			}
+			errs = append(errs, fmt.Sprintf("%s: %s のいずれかである必要があります（実際: %v）", path, strings.Join(options, "/"), value))
+		}
+	}
+
+	if schema.Minimum != nil {
+		if number, ok := value.(float64); ok && number < *schema.Minimum {
+			errs = append(errs, fmt.Sprintf("%s: %v以上である必要があります（実際: %v）", path, *schema.Minimum, number))
+		}
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for _, key := range schema.Required {
+			if _, ok := typed[key]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: 必須フィールド %s がありません", path, key))
+			}
+		}
+		// エラー順を安定させるためキーをソートして検証
+		keys := make([]string, 0, len(schema.Properties))
+		for key := range schema.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if child, ok := typed[key]; ok {
+				errs = append(errs, validateSchema(schema.Properties[key], child, path+"."+key)...)
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range typed {
+				errs = append(errs, validateSchema(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+
+	return errs
+}
+
+/**
+ * JSON Schemaの型名と値の型が一致するか判定
+ */
+func matchesSchemaType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == float64(int64(number))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	}
+	return true
+}
+
+/**
+ * オブジェクト・配列かどうか（==で比較できない値の判定）
+ */
+func isJSONContainer(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+/**
+ * エラーメッセージ用にJSON値の型名を返す
+ */
+func describeJSONType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+/**
+ * AIの返答テキストからJSON部分を取り出す
+ * コードブロックや前後の説明文が付いていても最初の{から最後の}までを使う
+ */
+func extractJSONText(responseText string) string {
+	cleanedText := strings.TrimSpace(responseText)
+	cleanedText = strings.TrimPrefix(cleanedText, "```json")
+	cleanedText = strings.TrimPrefix(cleanedText, "```")
+	cleanedText = strings.TrimSuffix(cleanedText, "```")
+	cleanedText = strings.TrimSpace(cleanedText)
+
+	start := strings.Index(cleanedText, "{")
+	end := strings.LastIndex(cleanedText, "}")
+	if start >= 0 && end > start {
+		return cleanedText[start : end+1]
+	}
+	return cleanedText
+}
+
+/**
+ * AIの返答をパースしてスキーマ検証する
+ * @param responseText AIの返答テキスト
+ * @return AIAnalysis パース結果
+ * @return []string 検証エラー（JSONとして不正な場合も含む）
+ */
+func parseAIAnalysis(responseText string) (AIAnalysis, []string) {
+	jsonText := extractJSONText(responseText)
+
+	var raw interface{}
+	if err := json.Unmarshal([]byte(jsonText), &raw); err != nil {
+		return AIAnalysis{}, []string{fmt.Sprintf("有効なJSONではありません: %v", err)}
+	}
+
+	if errs := validateSchema(aiAnalysisSchema, raw, "$"); len(errs) > 0 {
+		return AIAnalysis{}, errs
+	}
+
+	var analysis AIAnalysis
+	if err := json.Unmarshal([]byte(jsonText), &analysis); err != nil {
+		return AIAnalysis{}, []string{fmt.Sprintf("AIAnalysisに変換できません: %v", err)}
+	}
+
+	return analysis, nil
+}
+
+/**
+ * スキーマ違反をAIに差し戻すための修正依頼メッセージを作成
+ */
+func buildRepairMessage(errs []string) string {
+	var sb strings.Builder
+	sb.WriteString("前回の回答は次の理由でスキーマに適合しませんでした。\n")
+	for _, e := range errs {
+		sb.WriteString("- " + e + "\n")
+	}
+	sb.WriteString("\n以下のJSON Schemaを満たすように修正し、JSONのみを返してください。\n")
+	sb.WriteString(aiAnalysisSchemaJSON)
+	return sb.String()
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+/**
+ * 検索バックエンドモジュール
+ * 候補を取り出すSQLを、SEARCH_BACKENDで次の2つから選ぶ（採点の方法・結果はどちらも同じ）
+ *  - regex: 全件にスキルの正規表現（~*）を当てる従来の検索
+ *  - trgm: エイリアスの部分一致（ILIKE）でpg_trgmのGINインデックスを使って候補を絞り、
+ *          残った行だけに正規表現を当てて採点する
+ *
+ * tsvector（全文検索）は使わない。PostgreSQL標準の辞書は日本語を単語に分けないため、
+ * "バックエンドエンジニア"の中の"バックエンド"のような照合ができず、今の採点と同じ結果にならない
+ */
+
+// 検索バックエンド
+const (
+	SearchBackendRegex = "regex" // 全件を正規表現で照合（従来の検索）
+	SearchBackendTrgm  = "trgm"  // pg_trgmのインデックスで絞ってから正規表現で採点
+)
+
+// trgmのときに使うインデックス（マイグレーションで作成）
+var trgmSearchIndexes = []string{"idx_project_prottl_trgm", "idx_project_proot1_trgm", "idx_project_prodtl_trgm"}
+
+/**
+ * 検索バックエンドを決める（SEARCH_BACKEND、デフォルトregex）
+ */
+func resolveSearchBackend() (string, error) {
+	switch backend := getEnvWithDefault("SEARCH_BACKEND", SearchBackendRegex); backend {
+	case SearchBackendRegex, SearchBackendTrgm:
+		return backend, nil
+	default:
+		return "", fmt.Errorf("unknown search backend: %s", backend)
+	}
+}
+
+/**
+ * 起動時に検索バックエンドの設定を確認する
+ * trgmでインデックスが揃っていない場合は警告だけ出す（結果は同じで、速くならないだけ）
+ */
+func checkSearchBackend(database *sql.DB) error {
+	backend, err := resolveSearchBackend()
+	if err != nil {
+		return err
+	}
+	if backend != SearchBackendTrgm {
+		return nil
+	}
+
+	rows, err := database.Query(`SELECT indexname FROM pg_indexes WHERE tablename = 'tbl_project' AND indexname = ANY($1)`, pq.Array(trgmSearchIndexes))
+	if err != nil {
+		return fmt.Errorf("failed to check search indexes: %v", err)
+	}
+	defer rows.Close()
+
+	found := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to scan search index: %v", err)
+		}
+		found[name] = true
+	}
+	for _, name := range trgmSearchIndexes {
+		if !found[name] {
+			log.Printf("[WARN] Search index %s not found; SEARCH_BACKEND=trgm will scan tbl_project without it", name)
+		}
+	}
+	return rows.Err()
+}
+
+// 候補を取り出す条件（どちらのバックエンドも同じ）
+type candidateQuery struct {
+	Skills  []searchSkill  // 照合するスキル（重み付き）
+	Profile RankingProfile // 配点・足切り
+	Filter  *ProjectFilter // 絞り込み条件（単価以外をSQLで絞る）
+	After   *searchCursor  // ページ送りのカーソル（指定時はカーソルより後ろだけ）
+}
+
+/**
+ * 検索バックエンドに合わせて候補を取り出すSQLを組み立てる
+ */
+func buildCandidateQuery(backend string, q candidateQuery) (string, []interface{}) {
+	if backend == SearchBackendTrgm {
+		return buildTrgmCandidateQuery(q)
+	}
+	return buildRegexCandidateQuery(q)
+}
+
+/**
+ * 候補を取り出すSQLを実行し、かかった時間と一緒に返す
+ */
+func queryCandidates(backend string, q candidateQuery) ([]Project, time.Duration, error) {
+	query, args := buildCandidateQuery(backend, q)
+
+	start := time.Now()
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("database query failed: %v", err)
+	}
+	defer rows.Close()
+
+	projects, err := scanProjects(rows)
+	return projects, time.Since(start), err
+}
+
+/**
+ * SEARCH_BACKEND_COMPAREがtrueなら、もう一方のバックエンドでも検索して件数・並び・時間をログに出す
+ * 切り替える前に結果が変わらないことと速さを本番のデータで確かめるためのもの
+ */
+func compareSearchBackends(backend string, q candidateQuery, projects []Project, elapsed time.Duration) {
+	compare, err := strconv.ParseBool(getEnvWithDefault("SEARCH_BACKEND_COMPARE", "false"))
+	if err != nil || !compare {
+		return
+	}
+
+	other := SearchBackendTrgm
+	if backend == SearchBackendTrgm {
+		other = SearchBackendRegex
+	}
+	otherProjects, otherElapsed, err := queryCandidates(other, q)
+	if err != nil {
+		log.Printf("Search backend compare failed (%s): %v", other, err)
+		return
+	}
+
+	same := len(projects) == len(otherProjects)
+	for i := 0; same && i < len(projects); i++ {
+		same = projects[i].URL == otherProjects[i].URL
+	}
+	log.Printf("Search backend compare: skills=%v %s=%d件/%v %s=%d件/%v same=%t",
+		q.Skills, backend, len(projects), elapsed, other, len(otherProjects), otherElapsed, same)
+}
+
+/**
+ * 採点した候補を取り出すSQL（どちらのバックエンドも同じ。点数はランキングプロファイルの値）
+ *  1. 重みを掛ける前のスコア（base_score）がmin_score（既定4）以上の案件のみ（タイトルマッチまたは複数箇所マッチ）
+ *  2. 複数スキルマッチにボーナス（match_count * multi_skill_bonus）
+ *  3. 経験年数などの重みを掛けたスコア（match_score）の高い順（同点ならマッチしたスキルの多い順、掲載日の新しい順、URLの順）に、
+ *     1サイトあたりcandidatesPerSource件、合わせてsearchCandidateLimit件まで
+ *     返す件数のサイトごとの制限と最終的な件数はselectProjectsで決める
+ * afterを指定した場合は、ページ送りのカーソルより確実に前に並ぶ案件を除く（2ページ目以降はサイトごとに制限しない）
+ * 単価の点数（0以上）はGoで足すので、スキルのスコアがカーソルより高い案件と、同じスコアでマッチ数・掲載日・URLが前の案件を除く
+ */
+func candidateSelectSQL(profile RankingProfile, after *searchCursor, args []interface{}) (string, []interface{}) {
+	where := fmt.Sprintf("base_score >= %d", profile.MinScore)
+	if after != nil && after.URL != "" {
+		args = append(args, after.Score, after.MatchCount, after.PostedAt, after.URL)
+		n := len(args)
+		where += fmt.Sprintf(" AND (match_score < $%d OR (match_score = $%d AND (match_count < $%d OR (match_count = $%d AND (procrt < $%d OR (procrt = $%d AND prourl > $%d))))))",
+			n-3, n-3, n-2, n-2, n-1, n-1, n)
+	}
+
+	if after != nil {
+		return fmt.Sprintf(`
+		SELECT prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt
+		FROM scored_projects
+		WHERE %s
+		ORDER BY match_score DESC, match_count DESC, procrt DESC, prourl
+		LIMIT %d
+`, where, searchCandidateLimit), args
+	}
+
+	return fmt.Sprintf(`,
+		ranked_projects AS (
+			SELECT
+				prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt, match_score, match_count,
+				ROW_NUMBER() OVER (PARTITION BY prostn ORDER BY match_score DESC, match_count DESC, procrt DESC, prourl) as rn
+			FROM scored_projects
+			WHERE %s
+		)
+		SELECT prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt
+		FROM ranked_projects
+		WHERE rn <= %d
+		ORDER BY match_score DESC, match_count DESC, procrt DESC, prourl
+		LIMIT %d
+`, where, profile.candidatesPerSource(), searchCandidateLimit), args
+}
+
+/**
+ * regex: 全件にスキルの正規表現を当てて採点する
+ * スキルは全エイリアスを単語境界つきの正規表現にして照合する（"Go"が"Google"に当たらないように）
+ */
+func buildRegexCandidateQuery(q candidateQuery) (string, []interface{}) {
+	dict := getSkillDictionary()
+	skills, profile := q.Skills, q.Profile
+
+	// スコアリングクエリ：重点スキルにマッチする案件を優先
+	// 各スキルの出現回数とマッチしたスキル数をカウント
+	var scoreConditions []string
+	var baseConditions []string
+	var matchCountConditions []string
+	var args []interface{}
+	argIndex := 1
+
+	for _, skill := range skills {
+		// 各スキルに対して、タイトル/詳細/スキル欄での出現をスコア化
+		// 既定ではタイトル: 5点、スキル欄: 3点、詳細: 1点（並べ替えにはスキルの重みを掛けた点数を使う）
+		scoreCondition := fmt.Sprintf(`
+			(CASE WHEN prottl ~* $%d THEN %d ELSE 0 END) +
+			(CASE WHEN proot1 ~* $%d THEN %d ELSE 0 END) +
+			(CASE WHEN prodtl ~* $%d THEN %d ELSE 0 END)
+		`, argIndex, profile.weightedFieldScore("prottl", skill.Weight), argIndex+1, profile.weightedFieldScore("proot1", skill.Weight), argIndex+2, profile.weightedFieldScore("prodtl", skill.Weight))
+		scoreConditions = append(scoreConditions, scoreCondition)
+
+		// 足切り用の重みなしの点数
+		baseCondition := fmt.Sprintf(`
+			(CASE WHEN prottl ~* $%d THEN %d ELSE 0 END) +
+			(CASE WHEN proot1 ~* $%d THEN %d ELSE 0 END) +
+			(CASE WHEN prodtl ~* $%d THEN %d ELSE 0 END)
+		`, argIndex, profile.TitleWeight, argIndex+1, profile.SkillsWeight, argIndex+2, profile.DetailWeight)
+		baseConditions = append(baseConditions, baseCondition)
+
+		// マッチしたスキルの数をカウント（ボーナスポイント用）
+		matchCountCondition := fmt.Sprintf(`
+			(CASE WHEN prottl ~* $%d OR proot1 ~* $%d OR prodtl ~* $%d THEN 1 ELSE 0 END)
+		`, argIndex, argIndex+1, argIndex+2)
+		matchCountConditions = append(matchCountConditions, matchCountCondition)
+
+		pattern := dict.Pattern(skill.Name)
+		args = append(args, pattern, pattern, pattern)
+		argIndex += 3
+	}
+
+	scoreSum := strings.Join(scoreConditions, " + ")
+	baseSum := strings.Join(baseConditions, " + ")
+	matchCountSum := strings.Join(matchCountConditions, " + ")
+
+	// 少なくとも1つのプライマリスキルにマッチする案件のみ取得
+	var whereConditions []string
+	for i := range skills {
+		baseIndex := i * 3
+		whereCondition := fmt.Sprintf("(prottl ~* $%d OR proot1 ~* $%d OR prodtl ~* $%d)",
+			baseIndex+1, baseIndex+2, baseIndex+3)
+		whereConditions = append(whereConditions, whereCondition)
+	}
+	whereClause := "(" + strings.Join(whereConditions, " OR ") + ")"
+	filterConditions, args := q.Filter.sqlConditions(args)
+	for _, condition := range filterConditions {
+		whereClause += " AND " + condition
+	}
+
+	selectSQL, args := candidateSelectSQL(profile, q.After, args)
+	query := fmt.Sprintf(`
+		WITH scored_projects AS (
+			SELECT
+				prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt,
+				(%s) + ((%s) * %d) as match_score,
+				(%s) + ((%s) * %d) as base_score,
+				(%s) as match_count
+			FROM tbl_project
+			WHERE %s
+		)`, scoreSum, matchCountSum, profile.MultiSkillBonus, baseSum, matchCountSum, profile.MultiSkillBonus, matchCountSum, whereClause)
+
+	return query + selectSQL, args
+}
+
+/**
+ * trgm: エイリアスの部分一致（ILIKE）で候補を絞ってから、正規表現で採点する
+ * 正規表現に当たる行はエイリアスを必ず含むので、絞り込みで落ちる行はなく、結果はregexと同じになる
+ * 3文字未満のエイリアス（"go"など）はトライグラムを作れないので、そのスキルを含む検索ではインデックスが効かない
+ */
+func buildTrgmCandidateQuery(q candidateQuery) (string, []interface{}) {
+	dict := getSkillDictionary()
+	skills, profile := q.Skills, q.Profile
+
+	var args []interface{}
+	var likeConditions, flagColumns, scoreTerms, baseTerms, matchCountTerms []string
+	for i, skill := range skills {
+		for _, alias := range dict.Aliases(skill.Name) {
+			args = append(args, "%"+escapeLikePattern(alias)+"%")
+			n := len(args)
+			likeConditions = append(likeConditions, fmt.Sprintf("prottl ILIKE $%d OR proot1 ILIKE $%d OR prodtl ILIKE $%d", n, n, n))
+		}
+
+		// スキルごとに項目別の一致を1回だけ計算する（既定ではタイトル: 5点、スキル欄: 3点、詳細: 1点）
+		// 並べ替えにはスキルの重みを掛けた点数、足切りには重みなしの点数を使う
+		args = append(args, dict.Pattern(skill.Name))
+		n := len(args)
+		flagColumns = append(flagColumns, fmt.Sprintf("prottl ~* $%d AS t%d, proot1 ~* $%d AS s%d, prodtl ~* $%d AS d%d", n, i, n, i, n, i))
+		scoreTerms = append(scoreTerms, fmt.Sprintf("(CASE WHEN t%d THEN %d ELSE 0 END) + (CASE WHEN s%d THEN %d ELSE 0 END) + (CASE WHEN d%d THEN %d ELSE 0 END)",
+			i, profile.weightedFieldScore("prottl", skill.Weight), i, profile.weightedFieldScore("proot1", skill.Weight), i, profile.weightedFieldScore("prodtl", skill.Weight)))
+		baseTerms = append(baseTerms, fmt.Sprintf("(CASE WHEN t%d THEN %d ELSE 0 END) + (CASE WHEN s%d THEN %d ELSE 0 END) + (CASE WHEN d%d THEN %d ELSE 0 END)",
+			i, profile.TitleWeight, i, profile.SkillsWeight, i, profile.DetailWeight))
+		matchCountTerms = append(matchCountTerms, fmt.Sprintf("(CASE WHEN t%d OR s%d OR d%d THEN 1 ELSE 0 END)", i, i, i))
+	}
+
+	scoreSum := strings.Join(scoreTerms, " + ")
+	baseSum := strings.Join(baseTerms, " + ")
+	matchCountSum := strings.Join(matchCountTerms, " + ")
+
+	whereClause := "(" + strings.Join(likeConditions, " OR ") + ")"
+	filterConditions, args := q.Filter.sqlConditions(args)
+	for _, condition := range filterConditions {
+		whereClause += " AND " + condition
+	}
+
+	selectSQL, args := candidateSelectSQL(profile, q.After, args)
+	query := fmt.Sprintf(`
+		WITH matched_projects AS (
+			SELECT
+				prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt,
+				%s
+			FROM tbl_project
+			WHERE %s
+		),
+		scored_projects AS (
+			SELECT
+				prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt,
+				(%s) + ((%s) * %d) as match_score,
+				(%s) + ((%s) * %d) as base_score,
+				(%s) as match_count
+			FROM matched_projects
+		)`, strings.Join(flagColumns, ", "), whereClause, scoreSum, matchCountSum, profile.MultiSkillBonus,
+		baseSum, matchCountSum, profile.MultiSkillBonus, matchCountSum)
+
+	return query + selectSQL, args
+}
+
+/**
+ * LIKEの特殊文字（\ % _）をエスケープする
+ */
+func escapeLikePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
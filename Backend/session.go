@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * 会話セッション管理モジュール
+ * 「もっと高単価で」「PHPは除外して」のような追加の要望を、
+ * 前回の分析結果と検索条件を踏まえて解釈できるよう会話をtbl_sessionに保存する
+ */
+
+// セッション内の1発言
+type SessionMessage struct {
+	Role      string    `json:"role"`       // user / assistant
+	Content   string    `json:"content"`    // 発言内容
+	CreatedAt time.Time `json:"created_at"` // 発言日時
+}
+
+// 会話セッションの構造体
+type Session struct {
+	ID           string           `json:"id"`            // セッションID
+	Messages     []SessionMessage `json:"messages"`      // 会話履歴
+	LastAnalysis *AIAnalysis      `json:"last_analysis"` // 直近のAI分析結果
+	LastSearch   *SearchParams    `json:"last_search"`   // 直近の検索条件
+	CreatedAt    time.Time        `json:"created_at"`    // 作成日時
+	UpdatedAt    time.Time        `json:"updated_at"`    // 更新日時
+	ExpiresAt    time.Time        `json:"expires_at"`    // 有効期限
+}
+
+// セッション一覧用の概要
+type SessionSummary struct {
+	ID           string    `json:"id"`
+	MessageCount int       `json:"message_count"`
+	KeySkills    []string  `json:"key_skills"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// AIに渡す会話履歴の最大件数（古いものから切り捨てる）
+const maxSessionHistory = 10
+
+/**
+ * セッションの有効期間を取得（SESSION_TTL、デフォルト24時間）
+ */
+func getSessionTTL() time.Duration {
+	ttl, err := time.ParseDuration(getEnvWithDefault("SESSION_TTL", "24h"))
+	if err != nil || ttl <= 0 {
+		return 24 * time.Hour
+	}
+	return ttl
+}
+
+/**
+ * 新しいセッションを生成（まだ保存はしない）
+ */
+func newSession() (*Session, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %v", err)
+	}
+	now := time.Now()
+	return &Session{
+		ID:        hex.EncodeToString(buf),
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(getSessionTTL()),
+	}, nil
+}
+
+/**
+ * セッションを取得
+ * 存在しない・期限切れの場合はnilを返す
+ */
+func loadSession(id string) (*Session, error) {
+	var s Session
+	var messages, analysis, params []byte
+
+	err := db.QueryRow(`
+		SELECT sesid, sesmsg, sesanl, sesprm, sescrt, sesupd, sesexp
+		FROM tbl_session
+		WHERE sesid = $1 AND sesexp > NOW()
+	`, id).Scan(&s.ID, &messages, &analysis, &params, &s.CreatedAt, &s.UpdatedAt, &s.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("session lookup failed: %v", err)
+	}
+
+	if err := json.Unmarshal(messages, &s.Messages); err != nil {
+		return nil, fmt.Errorf("failed to decode session messages: %v", err)
+	}
+	if analysis != nil {
+		if err := json.Unmarshal(analysis, &s.LastAnalysis); err != nil {
+			return nil, fmt.Errorf("failed to decode session analysis: %v", err)
+		}
+	}
+	if params != nil {
+		if err := json.Unmarshal(params, &s.LastSearch); err != nil {
+			return nil, fmt.Errorf("failed to decode session search params: %v", err)
+		}
+	}
+
+	return &s, nil
+}
+
+/**
+ * セッションを保存（更新のたびに有効期限を延長する）
+ */
+func saveSession(s *Session) error {
+	s.UpdatedAt = time.Now()
+	s.ExpiresAt = s.UpdatedAt.Add(getSessionTTL())
+
+	messages, err := json.Marshal(s.Messages)
+	if err != nil {
+		return fmt.Errorf("failed to encode session messages: %v", err)
+	}
+	analysis, err := json.Marshal(s.LastAnalysis)
+	if err != nil {
+		return fmt.Errorf("failed to encode session analysis: %v", err)
+	}
+	params, err := json.Marshal(s.LastSearch)
+	if err != nil {
+		return fmt.Errorf("failed to encode session search params: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO tbl_session (sesid, sesmsg, sesanl, sesprm, sescrt, sesupd, sesexp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (sesid) DO UPDATE
+		SET sesmsg = EXCLUDED.sesmsg, sesanl = EXCLUDED.sesanl, sesprm = EXCLUDED.sesprm,
+			sesupd = EXCLUDED.sesupd, sesexp = EXCLUDED.sesexp
+	`, s.ID, messages, analysis, params, s.CreatedAt, s.UpdatedAt, s.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("session save failed: %v", err)
+	}
+
+	return nil
+}
+
+/**
+ * 分析結果を会話履歴に残すための要約文を作成
+ */
+func summarizeAnalysis(analysis AIAnalysis) string {
+	return fmt.Sprintf("推定単価: %s / 重点スキル: %s / 役割: %s / 経験レベル: %s",
+		analysis.EstimatedSalary,
+		strings.Join(analysis.KeySkills, ", "),
+		analysis.PreferredRole,
+		analysis.ExperienceLevel,
+	)
+}
+
+/**
+ * 前回の分析結果と検索条件を踏まえて、追加の要望を反映した分析結果を作る
+ * 会話履歴はそのままAIに渡し、前回の状態は最後のユーザー発言に添える
+ */
+func refineSkills(ctx context.Context, session *Session, message string, onField analysisFieldHandler) (AIAnalysis, error) {
+	systemPrompt, version, err := renderPrompt(PromptAnalysis, nil)
+	if err != nil {
+		return AIAnalysis{}, err
+	}
+	messages := []AIChatMessage{{Role: "system", Content: systemPrompt}}
+
+	history := session.Messages
+	if len(history) > maxSessionHistory {
+		history = history[len(history)-maxSessionHistory:]
+	}
+	for _, m := range history {
+		messages = append(messages, AIChatMessage{Role: m.Role, Content: m.Content})
+	}
+
+	previousAnalysis, _ := json.Marshal(session.LastAnalysis)
+	previousSearch, _ := json.Marshal(session.LastSearch)
+	messages = append(messages, AIChatMessage{
+		Role: "user",
+		Content: fmt.Sprintf(`前回の分析結果:
+%s
+
+前回の検索条件:
+%s
+
+追加の要望:
+%s
+
+追加の要望を前回の分析結果に反映し、分析結果全体を同じJSON形式で返してください。
+要望に書かれていない項目は前回の内容を引き継いでください。`, previousAnalysis, previousSearch, message),
+	})
+
+	return runAnalysis(withPromptVersion(withAIPurpose(ctx, AIPurposeRefine), version), messages, onField)
+}
+
+/**
+ * リクエストに対応するセッションを取得
+ * session_idが指定されていなければnilを返す（新規会話）
+ * @return int 取得できなかった場合のHTTPステータス
+ */
+func resolveSession(req ChatRequest) (*Session, int, error) {
+	if req.SessionID == "" {
+		return nil, 0, nil
+	}
+	if db == nil {
+		return nil, 500, fmt.Errorf("database connection is nil")
+	}
+
+	session, err := loadSession(req.SessionID)
+	if err != nil {
+		return nil, 500, err
+	}
+	if session == nil {
+		return nil, 404, fmt.Errorf("session not found or expired: %s", req.SessionID)
+	}
+	return session, 0, nil
+}
+
+/**
+ * セッションの有無に応じてスキル解析を行う
+ * 前回の分析結果があれば絞り込み、なければ通常の解析（キャッシュあり）
+ */
+func analyzeForSession(ctx context.Context, session *Session, message string, onField analysisFieldHandler) (AIAnalysis, bool, error) {
+	if session != nil && session.LastAnalysis != nil {
+		analysis, err := refineSkills(ctx, session, message, onField)
+		return analysis, false, err
+	}
+	return analyzeSkillsCachedStream(ctx, message, onField)
+}
+
+/**
+ * 今回のやり取りをセッションに記録して保存する
+ * セッションが未作成なら新規作成する。保存に失敗しても検索結果は返したいのでログのみ
+ * @return string セッションID（保存できなかった場合は空文字）
+ */
+func recordSessionTurn(session *Session, message string, analysis AIAnalysis, params SearchParams) string {
+	if db == nil {
+		return ""
+	}
+
+	if session == nil {
+		var err error
+		session, err = newSession()
+		if err != nil {
+			log.Printf("Session error: %v", err)
+			return ""
+		}
+	}
+
+	now := time.Now()
+	session.Messages = append(session.Messages,
+		SessionMessage{Role: "user", Content: message, CreatedAt: now},
+		SessionMessage{Role: "assistant", Content: summarizeAnalysis(analysis), CreatedAt: now},
+	)
+	session.LastAnalysis = &analysis
+	session.LastSearch = &params
+
+	if err := saveSession(session); err != nil {
+		log.Printf("Session error: %v", err)
+		return ""
+	}
+	return session.ID
+}
+
+/**
+ * セッション一覧を返すハンドラー
+ * 一覧を返す前に期限切れのセッションを削除する
+ */
+func handleListSessions(c *gin.Context) {
+	if _, err := db.Exec("DELETE FROM tbl_session WHERE sesexp <= NOW()"); err != nil {
+		log.Printf("Session cleanup failed: %v", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT sesid, sesmsg, sesanl, sescrt, sesupd, sesexp
+		FROM tbl_session
+		WHERE sesexp > NOW()
+		ORDER BY sesupd DESC
+	`)
+	if err != nil {
+		log.Printf("Database query failed: %v", err)
+		c.JSON(500, gin.H{"error": "Database query failed"})
+		return
+	}
+	defer rows.Close()
+
+	sessions := []SessionSummary{}
+	for rows.Next() {
+		var summary SessionSummary
+		var messages, analysis []byte
+		if err := rows.Scan(&summary.ID, &messages, &analysis, &summary.CreatedAt, &summary.UpdatedAt, &summary.ExpiresAt); err != nil {
+			log.Printf("Row scan error: %v", err)
+			continue
+		}
+
+		var history []SessionMessage
+		if err := json.Unmarshal(messages, &history); err == nil {
+			summary.MessageCount = len(history)
+		}
+		var lastAnalysis AIAnalysis
+		if analysis != nil && json.Unmarshal(analysis, &lastAnalysis) == nil {
+			summary.KeySkills = lastAnalysis.KeySkills
+		}
+
+		sessions = append(sessions, summary)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Row iteration error: %v", err)
+		c.JSON(500, gin.H{"error": "Row iteration failed"})
+		return
+	}
+
+	c.JSON(200, gin.H{"sessions": sessions, "total": len(sessions)})
+}
+
+/**
+ * セッション詳細を返すハンドラー
+ */
+func handleGetSession(c *gin.Context) {
+	session, err := loadSession(c.Param("id"))
+	if err != nil {
+		log.Printf("Session error: %v", err)
+		c.JSON(500, gin.H{"error": "Session lookup failed"})
+		return
+	}
+	if session == nil {
+		c.JSON(404, gin.H{"error": "Session not found or expired"})
+		return
+	}
+	c.JSON(200, session)
+}
+
+/**
+ * セッションを削除するハンドラー
+ */
+func handleDeleteSession(c *gin.Context) {
+	result, err := db.Exec("DELETE FROM tbl_session WHERE sesid = $1", c.Param("id"))
+	if err != nil {
+		log.Printf("Session delete failed: %v", err)
+		c.JSON(500, gin.H{"error": "Session delete failed"})
+		return
+	}
+
+	deleted, _ := result.RowsAffected()
+	if deleted == 0 {
+		c.JSON(404, gin.H{"error": "Session not found"})
+		return
+	}
+	c.JSON(200, gin.H{"deleted": deleted})
+}
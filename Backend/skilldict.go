@@ -0,0 +1,335 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * スキル辞書モジュール
+ * スキルの正式名と表記ゆれ（エイリアス）を管理し、検索前の正規化と
+ * 単語境界つきの検索パターンの生成を行う
+ *
+ * 辞書はskills.json（バイナリに埋め込み）を基本に、
+ * SKILL_DICTIONARY_FILEで別ファイルを、SKILL_DICTIONARY_SOURCE=dbでtbl_skillの内容を重ねられる
+ * SKILL_DICTIONARY_TTLごとに読み直すので、ファイルやテーブルの編集は再デプロイなしで反映される
+ */
+
+//go:embed skills.json
+var defaultSkillDictionaryJSON []byte
+
+// スキル辞書の1エントリ
+type SkillEntry struct {
+	Name    string   `json:"name"`    // 正式名
+	Aliases []string `json:"aliases"` // 表記ゆれ（大文字小文字は区別しない）
+}
+
+// スキル辞書
+type SkillDictionary struct {
+	Entries []SkillEntry
+	index   map[string]int // 正規化したエイリアス → Entriesの位置
+}
+
+var (
+	skillDictMu       sync.Mutex
+	skillDict         *SkillDictionary
+	skillDictLoadedAt time.Time
+)
+
+/**
+ * エントリから辞書を組み立てる
+ * 正式名もエイリアスとして扱い、同じ正式名のエントリはエイリアスをまとめる
+ */
+func newSkillDictionary(entries []SkillEntry) *SkillDictionary {
+	dict := &SkillDictionary{index: map[string]int{}}
+	positions := map[string]int{}
+
+	for _, e := range entries {
+		name := strings.TrimSpace(e.Name)
+		if name == "" {
+			continue
+		}
+		pos, ok := positions[strings.ToLower(name)]
+		if !ok {
+			pos = len(dict.Entries)
+			positions[strings.ToLower(name)] = pos
+			dict.Entries = append(dict.Entries, SkillEntry{Name: name})
+		}
+
+		for _, alias := range append([]string{name}, e.Aliases...) {
+			key := normalizeMessage(alias)
+			if key == "" {
+				continue
+			}
+			if _, exists := dict.index[key]; exists {
+				continue
+			}
+			dict.index[key] = pos
+			dict.Entries[pos].Aliases = append(dict.Entries[pos].Aliases, key)
+		}
+	}
+
+	return dict
+}
+
+/**
+ * スキル名を正式名に変換（"Golang" → "Go", "React.js" → "React"）
+ * @return bool 辞書にあったかどうか（なければ入力をそのまま返す）
+ */
+func (d *SkillDictionary) Canonical(skill string) (string, bool) {
+	if pos, ok := d.index[normalizeMessage(skill)]; ok {
+		return d.Entries[pos].Name, true
+	}
+	return strings.TrimSpace(skill), false
+}
+
+/**
+ * スキルのエイリアス一覧を返す（辞書になければスキル名そのもの）
+ */
+func (d *SkillDictionary) Aliases(skill string) []string {
+	if pos, ok := d.index[normalizeMessage(skill)]; ok {
+		return d.Entries[pos].Aliases
+	}
+	return []string{normalizeMessage(skill)}
+}
+
+/**
+ * スキル名のリストを正式名にそろえて重複を除く（順序は保つ）
+ */
+func (d *SkillDictionary) NormalizeSkills(skills []string) []string {
+	var normalized []string
+	seen := map[string]bool{}
+	for _, s := range skills {
+		name, _ := d.Canonical(s)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		normalized = append(normalized, name)
+	}
+	return normalized
+}
+
+/**
+ * スキルの全エイリアスを単語境界つきでまとめた正規表現を返す
+ * PostgreSQLの ~* とGoのregexp（(?i)を付ける）の両方でそのまま使える書き方にしている
+ */
+func (d *SkillDictionary) Pattern(skill string) string {
+	var parts []string
+	for _, alias := range d.Aliases(skill) {
+		parts = append(parts, aliasPattern(alias))
+	}
+	return strings.Join(parts, "|")
+}
+
+// 英数字で始まる/終わるエイリアスの境界
+// 後ろに数字が続くのは許す（"Java8", "Vue3"）
+const (
+	asciiBoundaryBefore    = `(^|[^a-z0-9_])`
+	asciiBoundaryAfter     = `([^a-z_]|$)`
+	katakanaBoundaryBefore = `(^|[^ァ-ヺー])`
+	katakanaBoundaryAfter  = `([^ァ-ヺー]|$)`
+)
+
+/**
+ * エイリアス1つ分の単語境界つきパターン
+ * 英字のエイリアスは英字に、カタカナのエイリアスはカタカナに続いていないことを条件にする
+ * （"go"が"google"/"mongodb"に、"ジャバ"が"ジャバスクリプト"に当たらないように）
+ */
+func aliasPattern(alias string) string {
+	first, _ := utf8.DecodeRuneInString(alias)
+	last, _ := utf8.DecodeLastRuneInString(alias)
+
+	pattern := regexp.QuoteMeta(alias)
+	switch {
+	case isASCIIWordRune(first):
+		pattern = asciiBoundaryBefore + pattern
+	case isKatakanaRune(first):
+		pattern = katakanaBoundaryBefore + pattern
+	}
+	switch {
+	case isASCIIWordRune(last):
+		pattern += asciiBoundaryAfter
+	case isKatakanaRune(last):
+		pattern += katakanaBoundaryAfter
+	}
+	return "(" + pattern + ")"
+}
+
+/**
+ * text[start:end]に見つかったエイリアスが単語境界を満たすか（aliasPatternと同じ規則）
+ * textは正規化済み（小文字）であること
+ */
+func isAliasBoundary(text string, start, end int) bool {
+	first, _ := utf8.DecodeRuneInString(text[start:end])
+	last, _ := utf8.DecodeLastRuneInString(text[start:end])
+
+	if start > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(text[:start])
+		if isASCIIWordRune(first) && (isASCIIWordRune(prev) || prev == '_') {
+			return false
+		}
+		if isKatakanaRune(first) && isKatakanaRune(prev) {
+			return false
+		}
+	}
+	if end < len(text) {
+		next, _ := utf8.DecodeRuneInString(text[end:])
+		if isASCIIWordRune(last) && ((next >= 'a' && next <= 'z') || next == '_') {
+			return false
+		}
+		if isKatakanaRune(last) && isKatakanaRune(next) {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCIIWordRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+func isKatakanaRune(r rune) bool {
+	return (r >= 'ァ' && r <= 'ヺ') || r == 'ー'
+}
+
+/**
+ * スキル辞書を読み込む
+ * SKILL_DICTIONARY_FILEがあればそのファイル、なければ埋め込みのskills.jsonを使い、
+ * SKILL_DICTIONARY_SOURCE=dbのときはtbl_skillの内容を重ねる
+ */
+func loadSkillDictionary() (*SkillDictionary, error) {
+	raw := defaultSkillDictionaryJSON
+	if path := os.Getenv("SKILL_DICTIONARY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read skill dictionary file: %v", err)
+		}
+		raw = data
+	}
+
+	var entries []SkillEntry
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse skill dictionary: %v", err)
+	}
+
+	if getEnvWithDefault("SKILL_DICTIONARY_SOURCE", "file") == "db" && db != nil {
+		dbEntries, err := loadSkillEntriesFromDB()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, dbEntries...)
+	}
+
+	return newSkillDictionary(entries), nil
+}
+
+/**
+ * tbl_skillから辞書エントリを読み込む
+ */
+func loadSkillEntriesFromDB() ([]SkillEntry, error) {
+	rows, err := db.Query("SELECT sklnam, sklals FROM tbl_skill ORDER BY sklnam")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load skill dictionary table: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []SkillEntry
+	for rows.Next() {
+		var e SkillEntry
+		var aliases []byte
+		if err := rows.Scan(&e.Name, &aliases); err != nil {
+			return nil, fmt.Errorf("failed to scan skill dictionary row: %v", err)
+		}
+		if err := json.Unmarshal(aliases, &e.Aliases); err != nil {
+			return nil, fmt.Errorf("invalid aliases for skill %s: %v", e.Name, err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %v", err)
+	}
+
+	return entries, nil
+}
+
+/**
+ * 現在のスキル辞書を返す
+ * SKILL_DICTIONARY_TTL（デフォルト5分）を過ぎていれば読み直す
+ * 読み直しに失敗した場合は前回の辞書を使い続ける
+ */
+func getSkillDictionary() *SkillDictionary {
+	skillDictMu.Lock()
+	defer skillDictMu.Unlock()
+
+	ttl, err := time.ParseDuration(getEnvWithDefault("SKILL_DICTIONARY_TTL", "5m"))
+	if err != nil || ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	if skillDict != nil && time.Since(skillDictLoadedAt) < ttl {
+		return skillDict
+	}
+
+	dict, err := loadSkillDictionary()
+	if err != nil {
+		log.Printf("Skill dictionary error: %v", err)
+		if skillDict == nil {
+			var entries []SkillEntry
+			json.Unmarshal(defaultSkillDictionaryJSON, &entries)
+			skillDict = newSkillDictionary(entries)
+		}
+	} else {
+		skillDict = dict
+	}
+	skillDictLoadedAt = time.Now()
+	return skillDict
+}
+
+/**
+ * スキル辞書をすぐに読み直す
+ */
+func reloadSkillDictionary() (*SkillDictionary, error) {
+	dict, err := loadSkillDictionary()
+	if err != nil {
+		return nil, err
+	}
+
+	skillDictMu.Lock()
+	skillDict = dict
+	skillDictLoadedAt = time.Now()
+	skillDictMu.Unlock()
+
+	return dict, nil
+}
+
+/**
+ * 現在のスキル辞書を返す管理者用ハンドラー
+ */
+func handleGetSkillDictionary(c *gin.Context) {
+	dict := getSkillDictionary()
+	c.JSON(200, gin.H{"skills": dict.Entries, "total": len(dict.Entries)})
+}
+
+/**
+ * スキル辞書を読み直す管理者用ハンドラー
+ */
+func handleReloadSkillDictionary(c *gin.Context) {
+	dict, err := reloadSkillDictionary()
+	if err != nil {
+		log.Printf("Skill dictionary error: %v", err)
+		c.JSON(500, gin.H{"error": "Skill dictionary reload failed: " + err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"total": len(dict.Entries)})
+}
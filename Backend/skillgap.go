@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * スキルギャップ分析モジュール
+ * AIの提案（suggestions）は一般論になりがちなので、tbl_projectの実際の案件から
+ * 「どのスキルを身につけると応募できる案件が増えるか・単価が上がるか」を件数つきで出す
+ *
+ * 案件ごとにタイトルとスキル欄から辞書のスキルを読み取り（どちらにもなければ詳細から）、
+ * 案件のスキルをすべて持っていれば「応募できる案件」とみなす
+ */
+
+// スキルギャップ分析のリクエスト
+type SkillGapRequest struct {
+	StructuredSkills []Skill `json:"structured_skills"` // 今のスキル（session_idを指定した場合は不要）
+	SessionID        string  `json:"session_id"`        // 前回の分析結果のスキルを使う場合のセッションID
+	Limit            int     `json:"limit"`             // 提案の件数（デフォルト5、最大20）
+}
+
+// スキルギャップ分析の結果
+type SkillGapReport struct {
+	UserSkills       []string   `json:"user_skills"`        // 今のスキル（辞書の正式名）
+	TotalProjects    int        `json:"total_projects"`     // 集計した案件数
+	MatchedProjects  int        `json:"matched_projects"`   // 今のスキルで応募できる案件数
+	RelatedProjects  int        `json:"related_projects"`   // 今のスキルを1つ以上求める案件数
+	BaselineAvgPrice int        `json:"baseline_avg_price"` // 基準の平均単価（応募できる案件、なければ今のスキルを求める案件）
+	ByProjects       []SkillGap `json:"by_projects"`        // 応募できる案件が多く増えるスキル
+	ByPrice          []SkillGap `json:"by_price"`           // 平均単価が大きく上がるスキル
+}
+
+// 提案するスキル1つ分
+type SkillGap struct {
+	Skill            string   `json:"skill"`             // スキル名
+	CoOccurrence     int      `json:"co_occurrence"`     // 今のスキルと一緒に求められている案件数
+	UnlockedProjects int      `json:"unlocked_projects"` // 身につけると新たに応募できる案件数
+	PricedProjects   int      `json:"priced_projects"`   // 一緒に求められている案件のうち単価を読み取れた件数
+	AvgPrice         int      `json:"avg_price"`         // 一緒に求められている案件の平均単価（円/月）
+	PriceUplift      int      `json:"price_uplift"`      // 基準の平均単価との差（円/月）
+	CoSkills         []string `json:"co_skills"`         // 一緒に求められることが多い今のスキル（多い順）
+}
+
+// 集計用の案件1件分
+type marketProject struct {
+	skills []string // 求められているスキル（辞書の正式名）
+	price  int      // 月額単価の目安（円、読み取れなければ0）
+}
+
+const (
+	defaultSkillGapLimit = 5
+	maxSkillGapLimit     = 20
+	// 単価の比較に必要な最低件数（少ない件数の平均は外れ値に振られるため）
+	skillGapMinPricedProjects = 3
+)
+
+var (
+	marketProjectsMu       sync.Mutex
+	marketProjects         []marketProject
+	marketProjectsLoadedAt time.Time
+)
+
+/**
+ * スキルギャップ分析のハンドラー
+ */
+func handleSkillGap(c *gin.Context) {
+	var req SkillGapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	skills := req.StructuredSkills
+	if req.SessionID != "" {
+		session, status, err := resolveSession(ChatRequest{SessionID: req.SessionID})
+		if err != nil {
+			log.Printf("Session error: %v", err)
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if session.LastAnalysis != nil {
+			skills = session.LastAnalysis.StructuredSkills
+		}
+	}
+	if len(skills) == 0 {
+		c.JSON(400, gin.H{"error": "Invalid request: structured_skills or session_id is required"})
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSkillGapLimit
+	}
+	if limit > maxSkillGapLimit {
+		limit = maxSkillGapLimit
+	}
+
+	projects, err := getMarketProjects()
+	if err != nil {
+		log.Printf("Skill gap error: %v", err)
+		c.JSON(500, gin.H{"error": "Database query failed: " + err.Error()})
+		return
+	}
+
+	names := make([]string, len(skills))
+	for i, s := range skills {
+		names[i] = s.SkillName
+	}
+	c.JSON(200, buildSkillGapReport(projects, names, limit))
+}
+
+/**
+ * 集計用の案件一覧を返す
+ * SKILL_GAP_TTL（デフォルト1時間）の間は前回読み込んだ一覧を使う
+ */
+func getMarketProjects() ([]marketProject, error) {
+	marketProjectsMu.Lock()
+	defer marketProjectsMu.Unlock()
+
+	ttl, err := time.ParseDuration(getEnvWithDefault("SKILL_GAP_TTL", "1h"))
+	if err != nil || ttl <= 0 {
+		ttl = time.Hour
+	}
+	if marketProjects != nil && time.Since(marketProjectsLoadedAt) < ttl {
+		return marketProjects, nil
+	}
+
+	projects, err := loadMarketProjects()
+	if err != nil {
+		return nil, err
+	}
+	marketProjects = projects
+	marketProjectsLoadedAt = time.Now()
+	return marketProjects, nil
+}
+
+/**
+ * tbl_projectから案件を読み込み、求められているスキルと単価を取り出す
+ * 件数はSKILL_GAP_MAX_PROJECTS（デフォルト5000）まで、新しい順
+ */
+func loadMarketProjects() ([]marketProject, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+
+	maxProjects, err := strconv.Atoi(getEnvWithDefault("SKILL_GAP_MAX_PROJECTS", "5000"))
+	if err != nil || maxProjects <= 0 {
+		maxProjects = 5000
+	}
+
+	rows, err := db.Query(`
+		SELECT prottl, prodtl, proprc, proot1
+		FROM tbl_project
+		ORDER BY procrt DESC
+		LIMIT $1
+	`, maxProjects)
+	if err != nil {
+		return nil, fmt.Errorf("database query failed: %v", err)
+	}
+	defer rows.Close()
+
+	projects := []marketProject{}
+	for rows.Next() {
+		var title, detail, price, skills *string
+		if err := rows.Scan(&title, &detail, &price, &skills); err != nil {
+			log.Printf("Row scan error: %v", err)
+			continue
+		}
+
+		p := marketProject{skills: projectSkillNames(stringValue(title) + " " + stringValue(skills))}
+		if len(p.skills) == 0 {
+			p.skills = projectSkillNames(stringValue(detail))
+		}
+		if len(p.skills) == 0 {
+			continue
+		}
+		if r, ok := parseSalaryRange(stringValue(price)); ok {
+			p.price = salaryMidpoint(r)
+		}
+		projects = append(projects, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %v", err)
+	}
+
+	return projects, nil
+}
+
+/**
+ * 案件の文章から辞書にあるスキル名を取り出す
+ */
+func projectSkillNames(text string) []string {
+	var names []string
+	for _, s := range extractOfflineSkills(normalizeMessage(text)) {
+		names = append(names, s.SkillName)
+	}
+	return names
+}
+
+/**
+ * NULLの文字列を空文字にする
+ */
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+/**
+ * 単価の範囲の目安（上限・下限の中間、片側しかなければその値）
+ */
+func salaryMidpoint(r SalaryRange) int {
+	switch {
+	case r.Min > 0 && r.Max > 0:
+		return (r.Min + r.Max) / 2
+	case r.Min > 0:
+		return r.Min
+	default:
+		return r.Max
+	}
+}
+
+/**
+ * 今のスキルと案件の一覧からスキルギャップを集計する
+ * 候補は「今のスキルと一緒に求められているスキル」に限る
+ */
+func buildSkillGapReport(projects []marketProject, skills []string, limit int) SkillGapReport {
+	dict := getSkillDictionary()
+	owned := map[string]bool{}
+	report := SkillGapReport{UserSkills: []string{}, TotalProjects: len(projects), ByProjects: []SkillGap{}, ByPrice: []SkillGap{}}
+	for _, name := range dict.NormalizeSkills(skills) {
+		owned[name] = true
+		report.UserSkills = append(report.UserSkills, name)
+	}
+
+	type candidate struct {
+		gap      SkillGap
+		priceSum int
+		coSkills map[string]int
+	}
+	candidates := map[string]*candidate{}
+
+	var matchedSum, matchedPriced, relatedSum, relatedPriced int
+	for _, p := range projects {
+		var ownedSkills, missing []string
+		for _, s := range p.skills {
+			if owned[s] {
+				ownedSkills = append(ownedSkills, s)
+			} else {
+				missing = append(missing, s)
+			}
+		}
+		if len(ownedSkills) == 0 {
+			continue
+		}
+
+		report.RelatedProjects++
+		if p.price > 0 {
+			relatedSum += p.price
+			relatedPriced++
+		}
+		if len(missing) == 0 {
+			report.MatchedProjects++
+			if p.price > 0 {
+				matchedSum += p.price
+				matchedPriced++
+			}
+			continue
+		}
+
+		for _, s := range missing {
+			c, ok := candidates[s]
+			if !ok {
+				c = &candidate{gap: SkillGap{Skill: s}, coSkills: map[string]int{}}
+				candidates[s] = c
+			}
+			c.gap.CoOccurrence++
+			if len(missing) == 1 {
+				c.gap.UnlockedProjects++
+			}
+			if p.price > 0 {
+				c.priceSum += p.price
+				c.gap.PricedProjects++
+			}
+			for _, o := range ownedSkills {
+				c.coSkills[o]++
+			}
+		}
+	}
+
+	if matchedPriced > 0 {
+		report.BaselineAvgPrice = matchedSum / matchedPriced
+	} else if relatedPriced > 0 {
+		report.BaselineAvgPrice = relatedSum / relatedPriced
+	}
+
+	var gaps []SkillGap
+	for _, c := range candidates {
+		if c.gap.PricedProjects > 0 {
+			c.gap.AvgPrice = c.priceSum / c.gap.PricedProjects
+			if report.BaselineAvgPrice > 0 {
+				c.gap.PriceUplift = c.gap.AvgPrice - report.BaselineAvgPrice
+			}
+		}
+		c.gap.CoSkills = rankCoSkills(c.coSkills)
+		gaps = append(gaps, c.gap)
+	}
+
+	// 応募できる案件の増加数の多い順（同じなら一緒に求められている件数、スキル名の順）
+	sort.Slice(gaps, func(i, j int) bool {
+		if gaps[i].UnlockedProjects != gaps[j].UnlockedProjects {
+			return gaps[i].UnlockedProjects > gaps[j].UnlockedProjects
+		}
+		if gaps[i].CoOccurrence != gaps[j].CoOccurrence {
+			return gaps[i].CoOccurrence > gaps[j].CoOccurrence
+		}
+		return gaps[i].Skill < gaps[j].Skill
+	})
+	for _, g := range gaps {
+		if len(report.ByProjects) >= limit {
+			break
+		}
+		if g.UnlockedProjects > 0 {
+			report.ByProjects = append(report.ByProjects, g)
+		}
+	}
+
+	// 平均単価の上がり幅の大きい順（単価を読み取れた件数が少ないスキルは除く）
+	byPrice := make([]SkillGap, 0, len(gaps))
+	for _, g := range gaps {
+		if g.PricedProjects >= skillGapMinPricedProjects && g.PriceUplift > 0 {
+			byPrice = append(byPrice, g)
+		}
+	}
+	sort.SliceStable(byPrice, func(i, j int) bool {
+		if byPrice[i].PriceUplift != byPrice[j].PriceUplift {
+			return byPrice[i].PriceUplift > byPrice[j].PriceUplift
+		}
+		return byPrice[i].PricedProjects > byPrice[j].PricedProjects
+	})
+	if len(byPrice) > limit {
+		byPrice = byPrice[:limit]
+	}
+	report.ByPrice = byPrice
+
+	return report
+}
+
+/**
+ * 一緒に求められた回数の多い順にスキル名を並べる
+ */
+func rankCoSkills(counts map[string]int) []string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * スキルシート解析モジュール
+ * フロントエンドのスキルシート（職務経歴の構造化データ）から、経験年数・経験レベル・役割を計算で求める
+ * 文章からAIに年数を推測させると、同時期の案件を二重に数えたり丸め方がぶれたりするため、
+ * 期間の重なりをまとめて数える。AIには強みと提案の文章だけを書かせる
+ */
+
+// スキルシート解析のレスポンス
+type SkillSheetResponse struct {
+	ChatResponse
+	Experience SkillSheetExperience `json:"experience"` // 経験年数の計算内容
+}
+
+// スキルシートから計算した経験の内訳
+type SkillSheetExperience struct {
+	TotalYears float64           `json:"total_years"`        // 職歴全体の年数（期間の重なりは1回だけ数える）
+	Skills     []SkillExperience `json:"skills"`             // 技術ごとの経験
+	Roles      []string          `json:"roles"`              // 経験した役割（上位の役割から順）
+	Warnings   []string          `json:"warnings,omitempty"` // 期間を読み取れなかったプロジェクトなど
+}
+
+// 技術1つ分の経験
+type SkillExperience struct {
+	SkillName       string   `json:"skill_name"`       // スキル名（辞書の正式名）
+	ExperienceYears float64  `json:"experience_years"` // 経験年数（小数第1位まで）
+	Months          int      `json:"months"`           // 経験月数
+	Periods         []string `json:"periods"`          // 重なりをまとめた期間（"2020-04〜2022-03"）
+	LastUsed        string   `json:"last_used"`        // 最後に使った年月
+}
+
+// スキルシートの返答のスキーマ
+const skillSheetSchemaJSON = `{
+  "type": "object",
+  "required": ["strengths", "suggestions"],
+  "properties": {
+    "strengths": {"type": "string"},
+    "suggestions": {"type": "string"}
+  }
+}`
+
+var skillSheetSchema = mustParseSchema(skillSheetSchemaJSON)
+
+// スキルシートでは単価を推定しない
+const skillSheetSalaryNote = "スキルシートからは推定していません"
+
+// 役割の区分（上から順に判定し、rankが大きいほど上位の役割）
+var skillSheetRoles = []struct {
+	Keywords []string
+	Role     string
+	Rank     int
+}{
+	{[]string{"pmo"}, "PMO", 4},
+	{[]string{"pm", "プロジェクトマネージャ", "マネージャ", "マネジャ"}, "プロジェクトマネージャー", 4},
+	{[]string{"アーキテクト"}, "アーキテクト", 3},
+	{[]string{"pl", "リーダー", "リーダ", "リード"}, "リードエンジニア", 3},
+	{[]string{"se", "システムエンジニア"}, "システムエンジニア", 2},
+	{[]string{"pg", "プログラマ", "メンバー", "開発者"}, "プログラマー", 1},
+}
+
+// 上流工程のフェーズ
+var upstreamPhases = map[string]bool{"要件定義": true, "基本設計": true}
+
+// 経験レベル（低い順）
+var experienceLevels = []string{"初級", "中級", "上級", "エキスパート"}
+
+// 年月（"2024-04", "2024/4", "2024-04-01"）
+var yearMonthPattern = regexp.MustCompile(`^(\d{4})[-/](\d{1,2})(?:[-/]\d{1,2})?$`)
+
+// 月単位の期間（月は西暦年*12+月-1の通し番号、両端を含む）
+type monthRange struct {
+	start, end int
+}
+
+// プロジェクト1件分の役割の判定結果
+type projectRole struct {
+	role string
+	rank int
+	end  int
+}
+
+/**
+ * スキルシート解析のハンドラー
+ * 経験年数・経験レベル・役割を計算し、強みと提案だけAIに書かせてから案件を検索する
+ * ?analyzer=rule を付けるとAIを使わない。?search_mode=semantic で意味検索、?translate=true で案件を英訳する
+ * ?filters={"work_style":"remote"} のように絞り込み条件（filter.go）をJSONで渡せる
+ */
+func handleAnalyzeSkillSheet(c *gin.Context) {
+	var sheet SkillSheet
+	if err := c.ShouldBindJSON(&sheet); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if len(sheet.ProjectExperiences) == 0 {
+		c.JSON(400, gin.H{"error": "Invalid request: projectExperiences is required"})
+		return
+	}
+
+	analyzer, err := resolveAnalyzer(c.Query("analyzer"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	searchMode, err := resolveSearchMode(c.Query("search_mode"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	ranking, status, err := resolveRankingProfile(c, json.RawMessage(c.Query("ranking_profile")))
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	filters, err := parseFilterParam(c.Query("filters"))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	experience, analysis := analyzeSkillSheet(sheet, time.Now())
+	outcome := analysisOutcome{Analysis: analysis, Analyzer: AnalyzerRule}
+
+	if analyzer == AnalyzerAI {
+		strengths, suggestions, version, err := requestSkillSheetNarrative(c.Request.Context(), sheet, analysis)
+		if err != nil {
+			log.Printf("Skill sheet narrative skipped, using rule-based text: %v", err)
+		} else {
+			outcome.Analysis.Strengths = strengths
+			outcome.Analysis.Suggestions = suggestions
+			outcome.Analyzer = AnalyzerAI
+			outcome.PromptVersion = version
+		}
+	}
+
+	sheetText := formatSkillSheet(sheet)
+	searchParams := buildSearchParams(nil, "", outcome.Analysis)
+	searchParams.Query = semanticQueryText(sheetText, outcome.Analysis)
+	// 見出しは日本語なので、言語の判定と検索語にはエンジニアが書いた欄だけを使う
+	writtenText := skillSheetWrittenText(sheet)
+	addJapaneseSearchTerms(&searchParams, writtenText)
+	searchParams.SearchMode = searchMode
+	searchParams.Translate = parseTranslateFlag(c.Query("translate"))
+	searchParams.Ranking = &ranking
+	searchParams.Filter = filters
+	projects, searched, err := searchProjectsForAnalysis(c.Request.Context(), searchParams, outcome)
+	if err != nil {
+		log.Printf("Database search error: %v", err)
+		c.JSON(500, gin.H{"error": "Database search failed: " + err.Error()})
+		return
+	}
+
+	// /api/chatで続けて絞り込めるよう、スキルシートの内容をセッションに記録する
+	sessionID := recordSessionTurn(nil, sheetText, outcome.Analysis, searchParams)
+
+	c.JSON(200, SkillSheetResponse{
+		ChatResponse: ChatResponse{
+			AIAnalysis:    outcome.Analysis,
+			Projects:      projects,
+			SessionID:     sessionID,
+			Analyzer:      outcome.Analyzer,
+			Degraded:      outcome.Analyzer == AnalyzerRule,
+			Reranked:      searched.Reranked,
+			PromptVersion: outcome.PromptVersion,
+			SearchMode:    searched.SearchMode,
+			InputLanguage: detectLanguage(writtenText),
+			SearchTerms:   searchParams.SearchTerms,
+			Translated:    searched.Translated,
+			NextCursor:    searched.NextCursor,
+			Filters:       searchParams.Filter,
+
+			RankingProfile: ranking,
+		},
+		Experience: experience,
+	})
+}
+
+/**
+ * スキルシートから経験の内訳と分析結果を計算する（AIは使わない）
+ * 終了年月が空のプロジェクトと、終了年月が未来のプロジェクトはnowの月までとして数える
+ */
+func analyzeSkillSheet(sheet SkillSheet, now time.Time) (SkillSheetExperience, AIAnalysis) {
+	current := now.Year()*12 + int(now.Month()) - 1
+	dict := getSkillDictionary()
+
+	experience := SkillSheetExperience{Skills: []SkillExperience{}, Roles: []string{}}
+	var career []monthRange
+	var roles []projectRole
+	hasUpstream := false
+
+	techRanges := map[string][]monthRange{}
+	var techOrder []string
+	for i, p := range sheet.ProjectExperiences {
+		name := strings.TrimSpace(p.ProjectName)
+		if name == "" {
+			name = fmt.Sprintf("%d件目", i+1)
+		}
+
+		period, err := parseProjectPeriod(p.StartDate, p.EndDate, current)
+		if err != nil {
+			experience.Warnings = append(experience.Warnings, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		career = append(career, period)
+
+		for _, phase := range p.Phases {
+			if upstreamPhases[strings.TrimSpace(phase)] {
+				hasUpstream = true
+			}
+		}
+		if role, rank := classifyProjectRole(p); rank > 0 {
+			roles = append(roles, projectRole{role: role, rank: rank, end: period.end})
+		}
+
+		seen := map[string]bool{}
+		for _, tech := range p.Technologies {
+			canonical, _ := dict.Canonical(tech)
+			if canonical == "" || seen[canonical] {
+				continue
+			}
+			seen[canonical] = true
+			if _, ok := techRanges[canonical]; !ok {
+				techOrder = append(techOrder, canonical)
+			}
+			techRanges[canonical] = append(techRanges[canonical], period)
+		}
+	}
+
+	for _, tech := range techOrder {
+		merged := mergeMonthRanges(techRanges[tech])
+		months := countMonths(merged)
+		periods := make([]string, len(merged))
+		for i, r := range merged {
+			periods[i] = formatYearMonth(r.start) + "〜" + formatYearMonth(r.end)
+		}
+		experience.Skills = append(experience.Skills, SkillExperience{
+			SkillName:       tech,
+			ExperienceYears: monthsToYears(months),
+			Months:          months,
+			Periods:         periods,
+			LastUsed:        formatYearMonth(merged[len(merged)-1].end),
+		})
+	}
+	// 経験の長い順、同じなら最近使った順
+	sort.SliceStable(experience.Skills, func(i, j int) bool {
+		if experience.Skills[i].Months != experience.Skills[j].Months {
+			return experience.Skills[i].Months > experience.Skills[j].Months
+		}
+		return experience.Skills[i].LastUsed > experience.Skills[j].LastUsed
+	})
+
+	experience.TotalYears = monthsToYears(countMonths(mergeMonthRanges(career)))
+
+	// 役割は上位のものから、同じ順位なら最近のものから
+	sort.SliceStable(roles, func(i, j int) bool {
+		if roles[i].rank != roles[j].rank {
+			return roles[i].rank > roles[j].rank
+		}
+		return roles[i].end > roles[j].end
+	})
+	maxRank := 0
+	seenRoles := map[string]bool{}
+	for _, r := range roles {
+		if r.rank > maxRank {
+			maxRank = r.rank
+		}
+		if !seenRoles[r.role] {
+			seenRoles[r.role] = true
+			experience.Roles = append(experience.Roles, r.role)
+		}
+	}
+
+	skills := make([]Skill, len(experience.Skills))
+	keySkills := []string{}
+	for i, s := range experience.Skills {
+		skills[i] = Skill{SkillName: s.SkillName, ExperienceYears: s.ExperienceYears}
+		if i < 3 {
+			keySkills = append(keySkills, s.SkillName)
+		}
+	}
+
+	preferredRole := preferredSkillSheetRole(roles)
+	level := skillSheetExperienceLevel(experience.TotalYears, maxRank, hasUpstream)
+
+	return experience, AIAnalysis{
+		EstimatedSalary:  skillSheetSalaryNote,
+		Strengths:        describeSkillSheetStrengths(experience, preferredRole),
+		Suggestions:      "AIを使わない集計の結果です。詳しい分析は時間をおいて再度お試しください。",
+		StructuredSkills: skills,
+		SearchPrompt:     strings.TrimSpace(strings.Join(keySkills, " ") + " " + preferredRole),
+		KeySkills:        keySkills,
+		PreferredRole:    preferredRole,
+		ExperienceLevel:  level,
+	}
+}
+
+/**
+ * プロジェクトの期間を読み取る
+ * @param current 現在の月（終了年月が空・未来の場合に使う）
+ */
+func parseProjectPeriod(startDate, endDate string, current int) (monthRange, error) {
+	start, ok := parseYearMonth(startDate)
+	if !ok {
+		return monthRange{}, fmt.Errorf("開始年月を読み取れません（%q）", startDate)
+	}
+	end := current
+	if strings.TrimSpace(endDate) != "" {
+		if end, ok = parseYearMonth(endDate); !ok {
+			return monthRange{}, fmt.Errorf("終了年月を読み取れません（%q）", endDate)
+		}
+	}
+	if start > current {
+		return monthRange{}, fmt.Errorf("開始年月が未来です（%s）", startDate)
+	}
+	if end > current {
+		end = current
+	}
+	if end < start {
+		return monthRange{}, fmt.Errorf("終了年月が開始年月より前です（%s〜%s）", startDate, endDate)
+	}
+	return monthRange{start: start, end: end}, nil
+}
+
+/**
+ * "YYYY-MM" を月の通し番号にする
+ */
+func parseYearMonth(value string) (int, bool) {
+	m := yearMonthPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, false
+	}
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	if month < 1 || month > 12 {
+		return 0, false
+	}
+	return year*12 + month - 1, true
+}
+
+/**
+ * 月の通し番号を "YYYY-MM" にする
+ */
+func formatYearMonth(index int) string {
+	return fmt.Sprintf("%04d-%02d", index/12, index%12+1)
+}
+
+/**
+ * 重なる期間・隣り合う期間をまとめる
+ */
+func mergeMonthRanges(ranges []monthRange) []monthRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sorted := make([]monthRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+
+	merged := []monthRange{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if r.start <= last.end+1 {
+			if r.end > last.end {
+				last.end = r.end
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+/**
+ * まとめた期間の月数を合計する（両端の月を含む）
+ */
+func countMonths(ranges []monthRange) int {
+	total := 0
+	for _, r := range ranges {
+		total += r.end - r.start + 1
+	}
+	return total
+}
+
+/**
+ * 月数を年数にする（小数第1位で四捨五入）
+ */
+func monthsToYears(months int) float64 {
+	return float64(int(float64(months)/12*10+0.5)) / 10
+}
+
+/**
+ * プロジェクトの役割を判定する
+ * 役割の欄から判定し、判定できなければ担当フェーズから判定する
+ * @return int 役割の順位（判定できなければ0）
+ */
+func classifyProjectRole(p ProjectExperience) (string, int) {
+	text := normalizeMessage(p.Role)
+	if text != "" {
+		for _, r := range skillSheetRoles {
+			for _, keyword := range r.Keywords {
+				if containsRoleKeyword(text, keyword) {
+					return r.Role, r.Rank
+				}
+			}
+		}
+		for _, r := range offlineRoleKeywords {
+			if containsRoleKeyword(text, r.Keyword) {
+				return r.Role, 2
+			}
+		}
+	}
+
+	upstream, implementation, operation := false, false, false
+	for _, phase := range p.Phases {
+		switch phase = strings.TrimSpace(phase); {
+		case upstreamPhases[phase]:
+			upstream = true
+		case phase == "保守・運用":
+			operation = true
+		case phase != "":
+			implementation = true
+		}
+	}
+	switch {
+	case upstream:
+		return "システムエンジニア", 2
+	case implementation:
+		return "プログラマー", 1
+	case operation:
+		return "運用保守エンジニア", 1
+	default:
+		return "", 0
+	}
+}
+
+/**
+ * 役割のキーワードを含むか（英字のキーワードだけ単語境界を見る）
+ */
+func containsRoleKeyword(text, keyword string) bool {
+	for offset := 0; offset < len(text); {
+		idx := strings.Index(text[offset:], keyword)
+		if idx < 0 {
+			return false
+		}
+		start := offset + idx
+		if !isASCIIWordRune(rune(keyword[0])) || isAliasBoundary(text, start, start+len(keyword)) {
+			return true
+		}
+		offset = start + len(keyword)
+	}
+	return false
+}
+
+/**
+ * 希望する役割を決める（直近のプロジェクトの役割、同じ時期なら上位の役割）
+ */
+func preferredSkillSheetRole(roles []projectRole) string {
+	if len(roles) == 0 {
+		return "エンジニア"
+	}
+	latest := roles[0]
+	for _, r := range roles[1:] {
+		if r.end > latest.end || (r.end == latest.end && r.rank > latest.rank) {
+			latest = r
+		}
+	}
+	return latest.role
+}
+
+/**
+ * 経験レベルを決める
+ * 職歴の年数で決めたうえで、リーダー以上の役割があれば上級以上、上流工程の経験があれば中級以上にする
+ */
+func skillSheetExperienceLevel(totalYears float64, maxRank int, hasUpstream bool) string {
+	level := experienceLevelForYears(totalYears)
+	index := 0
+	for i, l := range experienceLevels {
+		if l == level {
+			index = i
+		}
+	}
+	if maxRank >= 3 && index < 2 {
+		index = 2
+	}
+	if hasUpstream && index < 1 {
+		index = 1
+	}
+	return experienceLevels[index]
+}
+
+/**
+ * AIを使わない場合の強みの説明
+ */
+func describeSkillSheetStrengths(experience SkillSheetExperience, role string) string {
+	var described []string
+	for i, s := range experience.Skills {
+		if i >= 5 {
+			break
+		}
+		described = append(described, fmt.Sprintf("%s（%s年）", s.SkillName, strconv.FormatFloat(s.ExperienceYears, 'f', -1, 64)))
+	}
+	if len(described) == 0 {
+		return fmt.Sprintf("%s年の職歴があります。", strconv.FormatFloat(experience.TotalYears, 'f', -1, 64))
+	}
+	return fmt.Sprintf("%s年の職歴で、%sの経験があります。直近は%sとして参画しています。",
+		strconv.FormatFloat(experience.TotalYears, 'f', -1, 64), strings.Join(described, "、"), role)
+}
+
+/**
+ * スキルシートを文章にする（AIへの入力とセッションの記録用）
+ */
+func formatSkillSheet(sheet SkillSheet) string {
+	var sb strings.Builder
+	if summary := strings.TrimSpace(sheet.Summary); summary != "" {
+		sb.WriteString("概要: " + summary + "\n")
+	}
+	for i, p := range sheet.ProjectExperiences {
+		end := p.EndDate
+		if end == "" {
+			end = "現在"
+		}
+		fmt.Fprintf(&sb, "\nプロジェクト%d: %s\n", i+1, p.ProjectName)
+		fmt.Fprintf(&sb, "  期間: %s〜%s\n", p.StartDate, end)
+		if p.Role != "" {
+			sb.WriteString("  役割: " + p.Role + "\n")
+		}
+		if len(p.Phases) > 0 {
+			sb.WriteString("  対応フェーズ: " + strings.Join(p.Phases, ", ") + "\n")
+		}
+		if len(p.Technologies) > 0 {
+			sb.WriteString("  使用技術: " + strings.Join(p.Technologies, ", ") + "\n")
+		}
+		if description := strings.TrimSpace(p.Description); description != "" {
+			sb.WriteString("  業務内容: " + description + "\n")
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+/**
+ * スキルシートのうちエンジニアが文章で書いた欄（概要・プロジェクト名・役割・フェーズ・業務内容）
+ */
+func skillSheetWrittenText(sheet SkillSheet) string {
+	parts := []string{sheet.Summary}
+	for _, p := range sheet.ProjectExperiences {
+		parts = append(parts, p.ProjectName, p.Role, strings.Join(p.Phases, ", "), p.Description)
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
+/**
+ * 強みと提案の文章をAIに書かせる
+ * 経験年数などの集計済みの値はそのまま渡し、AIには書き換えさせない
+ */
+func requestSkillSheetNarrative(ctx context.Context, sheet SkillSheet, analysis AIAnalysis) (string, string, string, error) {
+	provider, err := getLLMProvider()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	systemPrompt, version, err := renderPrompt(PromptSkillSheet, nil)
+	if err != nil {
+		return "", "", version, err
+	}
+
+	facts, err := json.Marshal(map[string]interface{}{
+		"structured_skills": analysis.StructuredSkills,
+		"key_skills":        analysis.KeySkills,
+		"preferred_role":    analysis.PreferredRole,
+		"experience_level":  analysis.ExperienceLevel,
+	})
+	if err != nil {
+		return "", "", version, fmt.Errorf("failed to encode skill sheet facts: %v", err)
+	}
+
+	// 自由記述の欄に書かれた指示文はAIに渡さない
+	sheetText, _ := neutralizeInjection(controlTagPattern.ReplaceAllString(formatSkillSheet(sheet), ""))
+
+	result, err := provider.Chat(withPromptVersion(withAIPurpose(ctx, AIPurposeSkillSheet), version), []AIChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: "集計結果:\n" + string(facts) + "\n\nスキルシート:\n" + sheetText},
+	})
+	if err != nil {
+		return "", "", version, err
+	}
+
+	jsonText := extractJSONText(result.Content)
+	var raw interface{}
+	if err := json.Unmarshal([]byte(jsonText), &raw); err != nil {
+		return "", "", version, fmt.Errorf("invalid skill sheet JSON: %v", err)
+	}
+	if errs := validateSchema(skillSheetSchema, raw, "$"); len(errs) > 0 {
+		return "", "", version, fmt.Errorf("skill sheet response failed schema validation: %s", strings.Join(errs, "; "))
+	}
+
+	var response struct {
+		Strengths   string `json:"strengths"`
+		Suggestions string `json:"suggestions"`
+	}
+	if err := json.Unmarshal([]byte(jsonText), &response); err != nil {
+		return "", "", version, fmt.Errorf("invalid skill sheet JSON: %v", err)
+	}
+
+	return strings.TrimSpace(response.Strengths), strings.TrimSpace(response.Suggestions), version, nil
+}
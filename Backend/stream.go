@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * チャットのストリーミング（Server-Sent Events）モジュール
+ * AIの返答と案件検索の途中経過を順番にクライアントへ送る
+ *
+ * 送信するイベント:
+ *   analyzing      分析開始
+ *   analysis_field AIAnalysisのフィールドが確定するたびに {field, value}
+ *   project        ランキング順に {rank, project}
+ *   done           ChatResponse全体
+ *   error          {error, detail}
+ */
+
+// AIAnalysisのフィールドが確定したときに呼ばれるコールバック
+type analysisFieldHandler func(name string, value json.RawMessage)
+
+// ストリーミング中のJSONから、確定したトップレベルのフィールドを取り出すスキャナー
+// 文字列・ネストの状態を追跡し、深さ1のカンマか閉じ括弧でフィールドの終わりを判定する
+type jsonFieldScanner struct {
+	buf         []byte
+	pos         int  // 走査済みの位置
+	depth       int  // 括弧の深さ
+	inString    bool // 文字列リテラルの中かどうか
+	escaped     bool // 直前がバックスラッシュかどうか
+	memberStart int  // 現在のフィールドの開始位置
+	finished    bool // トップレベルのオブジェクトが閉じたかどうか
+	emitted     map[string]bool
+	onField     analysisFieldHandler
+}
+
+/**
+ * フィールドスキャナーを生成
+ */
+func newJSONFieldScanner(onField analysisFieldHandler) *jsonFieldScanner {
+	return &jsonFieldScanner{emitted: map[string]bool{}, onField: onField}
+}
+
+/**
+ * 受け取ったテキストを追加で走査する
+ */
+func (s *jsonFieldScanner) Feed(chunk string) {
+	s.buf = append(s.buf, chunk...)
+
+	for ; s.pos < len(s.buf) && !s.finished; s.pos++ {
+		ch := s.buf[s.pos]
+
+		if s.inString {
+			switch {
+			case s.escaped:
+				s.escaped = false
+			case ch == '\\':
+				s.escaped = true
+			case ch == '"':
+				s.inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			// トップレベルのオブジェクトが始まるまでの文字列（説明文など）は無視する
+			if s.depth > 0 {
+				s.inString = true
+			}
+		case '{', '[':
+			// トップレベルはオブジェクトのみ（説明文中の[]は無視）
+			if s.depth == 0 && ch == '[' {
+				continue
+			}
+			s.depth++
+			if s.depth == 1 {
+				s.memberStart = s.pos + 1
+			}
+		case '}', ']':
+			if s.depth == 1 {
+				s.emitMember(s.memberStart, s.pos)
+				s.finished = true
+			}
+			if s.depth > 0 {
+				s.depth--
+			}
+		case ',':
+			if s.depth == 1 {
+				s.emitMember(s.memberStart, s.pos)
+				s.memberStart = s.pos + 1
+			}
+		}
+	}
+}
+
+/**
+ * 1フィールド分のテキストをパースしてコールバックに渡す
+ */
+func (s *jsonFieldScanner) emitMember(start, end int) {
+	member := strings.TrimSpace(string(s.buf[start:end]))
+	if member == "" || s.onField == nil {
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte("{"+member+"}"), &fields); err != nil {
+		return
+	}
+
+	for name, value := range fields {
+		if s.emitted[name] {
+			continue
+		}
+		s.emitted[name] = true
+		s.onField(name, value)
+	}
+}
+
+/**
+ * フィールド通知付きでAIを呼び出す
+ * ストリーミング対応のプロバイダーなら返答を受け取りながら、
+ * 非対応のプロバイダーなら返答全体を受け取ってからフィールドを通知する
+ */
+func chatWithFields(ctx context.Context, provider LLMProvider, messages []AIChatMessage, onField analysisFieldHandler) (LLMResult, error) {
+	if onField == nil {
+		return provider.Chat(ctx, messages)
+	}
+
+	scanner := newJSONFieldScanner(onField)
+	if streaming, ok := provider.(StreamingLLMProvider); ok {
+		return streaming.ChatStream(ctx, messages, scanner.Feed)
+	}
+
+	result, err := provider.Chat(ctx, messages)
+	if err != nil {
+		return LLMResult{}, err
+	}
+	scanner.Feed(result.Content)
+	return result, nil
+}
+
+/**
+ * 分析結果の全フィールドを順番に通知する（キャッシュヒット時用）
+ */
+func emitAnalysisFields(analysis AIAnalysis, onField analysisFieldHandler) {
+	if onField == nil {
+		return
+	}
+	raw, err := json.Marshal(analysis)
+	if err != nil {
+		return
+	}
+	newJSONFieldScanner(onField).Feed(string(raw))
+}
+
+// 20261016 結果が出るまで画面が固まって見えるので、途中経過をSSEで流すようにした。
+/**
+ * チャットAPIのストリーミング版ハンドラー
+ * POSTはChatRequestのJSON、GETは?message=で受け付ける（EventSourceから使えるように）
+ */
+func handleChatStream(c *gin.Context) {
+	var req ChatRequest
+	if c.Request.Method == "GET" {
+		req.Message = c.Query("message")
+		req.SessionID = c.Query("session_id")
+		req.Analyzer = c.Query("analyzer")
+		req.SearchMode = c.Query("search_mode")
+		req.Translate = parseTranslateFlag(c.Query("translate"))
+		req.RankingProfile = json.RawMessage(c.Query("ranking_profile"))
+		req.ClearFilters, _ = strconv.ParseBool(c.Query("clear_filters"))
+		if req.Message == "" {
+			c.JSON(400, gin.H{"error": "Invalid request: message is required"})
+			return
+		}
+		filters, err := parseFilterParam(c.Query("filters"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		req.Filters = filters
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	pipeline, ok := prepareChatPipeline(c, req)
+	if !ok {
+		return
+	}
+
+	// プロキシ（nginx等）にバッファリングさせない
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	send := func(event string, data interface{}) {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+
+	send("analyzing", gin.H{"message": "AIがスキルを分析しています"})
+
+	response, err := pipeline.run(c.Request.Context(), func(name string, value json.RawMessage) {
+		send("analysis_field", gin.H{"field": name, "value": value})
+	})
+	var searchErr *chatSearchError
+	if errors.As(err, &searchErr) {
+		log.Printf("Database search error: %v", searchErr.err)
+		send("error", gin.H{"error": "Database search failed: " + searchErr.err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("AI API error: %v", err)
+		// SSEはすでに200で始まっているので、本来のステータスはイベントの中で返す
+		event := gin.H{"error": describeAIError(err), "detail": err.Error(), "status": aiErrorStatus(err)}
+		var aiErr *AIError
+		if errors.As(err, &aiErr) {
+			event["code"] = aiErr.Kind
+			if aiErr.RetryAfter > 0 {
+				event["retry_after"] = retryAfterSeconds(aiErr.RetryAfter)
+			}
+		}
+		send("error", event)
+		return
+	}
+
+	for i, p := range response.Projects {
+		send("project", gin.H{"rank": i + 1, "project": p})
+	}
+	send("done", response)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
@@ -10,25 +11,71 @@ import (
 // チャットリクエストの構造体
 // `json:"message"` はJSONのフィールド名とGoのフィールド名を紐付けるタグです
 type ChatRequest struct {
-	Message string `json:"message" binding:"required"`
+	Message    string `json:"message" binding:"required"`
+	SessionID  string `json:"session_id"`  // 会話セッションID（指定時は前回の結果を踏まえて絞り込む）
+	Analyzer   string `json:"analyzer"`    // 解析方法（ai / rule、未指定ならSKILL_ANALYZER）
+	SearchMode string `json:"search_mode"` // 検索方法（keyword / semantic、未指定ならSEARCH_MODE）
+	Translate  bool   `json:"translate"`   // 案件のタイトル・概要を英訳して返すか
+
+	Filters      *ProjectFilter `json:"filters,omitempty"` // 案件の絞り込み条件（メッセージから取り出した条件と合わせて使う）
+	ClearFilters bool           `json:"clear_filters"`     // セッションに残っている前回までの絞り込み条件を使わない
+
+	RankingProfile json.RawMessage `json:"ranking_profile,omitempty"` // ランキングプロファイルの上書き（管理者トークン付きのみ）
 }
 
 // チャットレスポンスの構造体
 type ChatResponse struct {
-	AIAnalysis AIAnalysis `json:"ai_analysis"` // AI分析結果
-	Projects   []Project  `json:"projects"`    // マッチした案件リスト
+	AIAnalysis    AIAnalysis     `json:"ai_analysis"`              // AI分析結果
+	Projects      []Project      `json:"projects"`                 // マッチした案件リスト
+	CacheHit      bool           `json:"cache_hit"`                // 分析結果をキャッシュから返したかどうか
+	SessionID     string         `json:"session_id,omitempty"`     // 会話セッションID（続けて絞り込むときに送り返す）
+	Analyzer      string         `json:"analyzer"`                 // 実際に使った解析方法（ai / rule）
+	Degraded      bool           `json:"degraded"`                 // AIを使わない簡易解析の結果かどうか
+	DesiredSalary *SalaryRange   `json:"desired_salary,omitempty"` // 検索に使った希望単価
+	Reranked      bool           `json:"reranked"`                 // 案件をAIで並べ直したかどうか
+	PromptVersion string         `json:"prompt_version,omitempty"` // AIで解析したときのプロンプトのバージョン
+	InputWarnings []string       `json:"input_warnings,omitempty"` // メッセージを整えた内容（condensed / injection_removed など）
+	SearchMode    string         `json:"search_mode"`              // 実際に使った検索方法（keyword / semantic）
+	InputLanguage string         `json:"input_language"`           // メッセージの言語（ja / en）
+	SearchTerms   []string       `json:"search_terms,omitempty"`   // 英語の入力から作った日本語の検索語
+	Translated    bool           `json:"translated"`               // すべての案件に英訳を付けたかどうか
+	NextCursor    string         `json:"next_cursor,omitempty"`    // 続きの案件を取得するカーソル（GET /api/sessions/:id/projects）
+	Filters       *ProjectFilter `json:"filters,omitempty"`        // 検索に使った絞り込み条件（リクエストとメッセージの条件を合わせたもの）
+
+	RankingProfile RankingProfile `json:"ranking_profile"` // 検索に使ったランキングプロファイル
+}
+
+// 検索条件の構造体（セッションに前回の条件として保存する）
+type SearchParams struct {
+	KeySkills     []string       `json:"key_skills"`               // 重点スキル
+	Skills        []Skill        `json:"skills"`                   // 構造化されたスキルリスト
+	DesiredSalary *SalaryRange   `json:"desired_salary,omitempty"` // 希望単価（ランキングに使う）
+	PriceFilter   bool           `json:"price_filter,omitempty"`   // 希望単価の下限に届かない案件を除くかどうか
+	SearchTerms   []string       `json:"search_terms,omitempty"`   // 英語の入力から作った日本語の検索語（重点スキルと一緒に照合する）
+	Filter        *ProjectFilter `json:"filter,omitempty"`         // 案件の絞り込み条件
+
+	Query      string `json:"-"` // 意味検索に使う文章（分析結果の検索用プロンプト・メッセージなど）
+	SearchMode string `json:"-"` // 検索方法（keyword / semantic）
+	Translate  bool   `json:"-"` // 案件を英訳するかどうか
+
+	Ranking *RankingProfile `json:"ranking,omitempty"` // ランキングプロファイル（nilなら現在のプロファイル）。続きのページも同じ配点で並べるよう保存する
+	After   *searchCursor   `json:"-"`                 // ページ送りのカーソル（指定時はカーソルより後ろの候補だけを取り出す）
 }
 
 // AI分析結果の構造体
 type AIAnalysis struct {
-	EstimatedSalary  string   `json:"estimated_salary"`  // 推定単価
-	Strengths        string   `json:"strengths"`         // 強み
-	Suggestions      string   `json:"suggestions"`       // キャリアアップ提案
-	StructuredSkills []Skill  `json:"structured_skills"` // 構造化されたスキルリスト
-	SearchPrompt     string   `json:"search_prompt"`     // 自動生成された検索用プロンプト
-	KeySkills        []string `json:"key_skills"`        // 重点スキル（検索優先度高）
-	PreferredRole    string   `json:"preferred_role"`    // 希望する役割
-	ExperienceLevel  string   `json:"experience_level"`  // 経験レベル（初級/中級/上級/エキスパート）
+	EstimatedSalary  string         `json:"estimated_salary"`            // 推定単価
+	Strengths        string         `json:"strengths"`                   // 強み
+	Suggestions      string         `json:"suggestions"`                 // キャリアアップ提案
+	StructuredSkills []Skill        `json:"structured_skills"`           // 構造化されたスキルリスト
+	SearchPrompt     string         `json:"search_prompt"`               // 自動生成された検索用プロンプト
+	KeySkills        []string       `json:"key_skills"`                  // 重点スキル（検索優先度高）
+	PreferredRole    string         `json:"preferred_role"`              // 希望する役割
+	ExperienceLevel  string         `json:"experience_level"`            // 経験レベル（初級/中級/上級/エキスパート）
+	SalaryRange      *SalaryRange   `json:"salary_range,omitempty"`      // estimated_salaryを数値化したもの（AIの出力ではなくサーバー側で付ける）
+	Filters          *ProjectFilter `json:"filters,omitempty"`           // メッセージに書かれた案件の絞り込み条件（リモートのみ・lancers以外など）
+	ExcludedSkills   []string       `json:"excluded_skills,omitempty"`   // やりたくないスキル（このスキルがある案件は返さない）
+	ExcludedKeywords []string       `json:"excluded_keywords,omitempty"` // 避けたい案件の特徴（"保守" "SES" "常駐"など。この語がある案件は返さない）
 }
 
 // スキル情報の構造体
@@ -39,14 +86,41 @@ type Skill struct {
 
 // 案件情報の構造体
 type Project struct {
-	URL      string `json:"url"`       // 案件URL
-	Title    string `json:"title"`     // 案件タイトル
-	Detail   string `json:"detail"`    // 案件詳細
-	Price    string `json:"price"`     // 単価
-	Period   string `json:"period"`    // 期間
-	Skills   string `json:"skills"`    // 必要スキル
-	Source   string `json:"source"`    // ソース（サイト名）
-	PostedAt string `json:"posted_at"` // 掲載日
+	URL             string            `json:"url"`                        // 案件URL
+	Title           string            `json:"title"`                      // 案件タイトル
+	Detail          string            `json:"detail"`                     // 案件詳細
+	Price           string            `json:"price"`                      // 単価
+	Period          string            `json:"period"`                     // 期間
+	Skills          string            `json:"skills"`                     // 必要スキル
+	Source          string            `json:"source"`                     // ソース（サイト名）
+	PostedAt        string            `json:"posted_at"`                  // 掲載日
+	PriceRange      *SalaryRange      `json:"price_range,omitempty"`      // 単価を数値化したもの（読み取れた場合のみ）
+	RelevanceScore  *int              `json:"relevance_score,omitempty"`  // AIによる適合度（0〜100、リランキングした場合のみ）
+	RelevanceReason string            `json:"relevance_reason,omitempty"` // AIによる適合度の理由
+	Match           *MatchExplanation `json:"match,omitempty"`            // マッチした理由の内訳（検索結果のみ）
+	TitleEn         string            `json:"title_en,omitempty"`         // タイトルの英訳（translateを指定した場合のみ）
+	SummaryEn       string            `json:"summary_en,omitempty"`       // 概要の英訳（translateを指定した場合のみ）
+}
+
+// スキルシートの構造体（フロントエンドのtypes.tsのSkillSheetと同じ形）
+type SkillSheet struct {
+	TemplateName       string              `json:"templateName"`       // テンプレート名
+	Summary            string              `json:"summary"`            // 自己PR・概要
+	ProjectExperiences []ProjectExperience `json:"projectExperiences"` // 職務経歴
+}
+
+// 職務経歴の各プロジェクトの構造体
+type ProjectExperience struct {
+	ID                          string   `json:"id"`                                    // フロントエンドでのID
+	ProjectName                 string   `json:"projectName"`                           // プロジェクト名
+	Role                        string   `json:"role"`                                  // 役割（PM、PL、SEなど）
+	StartDate                   string   `json:"startDate"`                             // 開始年月（"YYYY-MM"）
+	EndDate                     string   `json:"endDate"`                               // 終了年月（"YYYY-MM"、空なら現在も継続中）
+	DevelopmentMethodology      string   `json:"developmentMethodology"`                // 開発手法
+	DevelopmentMethodologyOther string   `json:"developmentMethodologyOther,omitempty"` // 開発手法（その他の内容）
+	Phases                      []string `json:"phases"`                                // 対応フェーズ
+	Technologies                []string `json:"technologies"`                          // 使用技術
+	Description                 string   `json:"description"`                           // 業務内容
 }
 
 // 20251220 旧バージョンのsearchProjectsは互換性のためとりあえず残す。新しいやつはchat.goに移した。いつか消すかも。
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-ADMIN テストケース
+// admin.go の管理者認証ミドルウェアのテスト
+// ============================================================
+
+func setupAdminRouter() *gin.Engine {
+	r := gin.New()
+	r.GET("/api/admin/ping", requireAdmin(), func(c *gin.Context) {
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+	return r
+}
+
+// UT-ADMIN-001: ADMIN_TOKEN未設定なら管理機能は無効
+func TestRequireAdmin_Disabled(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "")
+
+	req := httptest.NewRequest("GET", "/api/admin/ping", nil)
+	w := httptest.NewRecorder()
+	setupAdminRouter().ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("UT-ADMIN-001 FAIL: 期待ステータス %d, 実際 %d", http.StatusForbidden, w.Code)
+	}
+}
+
+// UT-ADMIN-002: トークン不一致
+func TestRequireAdmin_InvalidToken(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	req := httptest.NewRequest("GET", "/api/admin/ping", nil)
+	req.Header.Set("X-Admin-Token", "wrong")
+	w := httptest.NewRecorder()
+	setupAdminRouter().ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("UT-ADMIN-002 FAIL: 期待ステータス %d, 実際 %d", http.StatusUnauthorized, w.Code)
+	}
+}
+
+// UT-ADMIN-003: Bearerトークンでも認証できる
+func TestRequireAdmin_BearerToken(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	req := httptest.NewRequest("GET", "/api/admin/ping", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	setupAdminRouter().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("UT-ADMIN-003 FAIL: 期待ステータス %d, 実際 %d", http.StatusOK, w.Code)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-AICLIENT テストケース
+// aiclient.go のタイムアウト・再試行・サーキットブレーカー・エラーの種類のテスト
+// ============================================================
+
+// サーキットブレーカーの状態を消す
+func resetAIBreakers() {
+	aiBreakersMu.Lock()
+	aiBreakers = map[string]*circuitBreaker{}
+	aiBreakersMu.Unlock()
+}
+
+// 呼ばれた回数を数えるテスト用のAPIサーバー（handlerにはその回数が渡る）
+func newCountingServer(calls *int32, handler func(w http.ResponseWriter, n int32)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(w, atomic.AddInt32(calls, 1))
+	}))
+}
+
+// OpenAI互換プロバイダーでAPIサーバーを1回呼び出す
+func callTestProvider(url string) error {
+	provider, _ := NewLLMProvider(LLMConfig{Provider: ProviderOpenAI, Model: "llama3", BaseURL: url})
+	_, err := provider.Chat(context.Background(), []AIChatMessage{{Role: "user", Content: "Java"}})
+	return err
+}
+
+// 返されたエラーの種類
+func aiErrorKind(err error) string {
+	var aiErr *AIError
+	if errors.As(err, &aiErr) {
+		return aiErr.Kind
+	}
+	return ""
+}
+
+const testChatCompletion = `{"model": "llama3", "choices": [{"message": {"role": "assistant", "content": "{}"}}]}`
+
+// UT-AICLIENT-001: 一時的なエラー（503・429）は間隔をあけて再試行し、成功すればそれを返す
+func TestDoAIRequest_RetriesTransientErrors(t *testing.T) {
+	resetAIBreakers()
+	t.Setenv("AI_RETRY_BASE_DELAY", "1ms")
+
+	var calls int32
+	server := newCountingServer(&calls, func(w http.ResponseWriter, n int32) {
+		switch n {
+		case 1:
+			w.WriteHeader(503)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(429)
+			w.Write([]byte(`{"error": "rate limit exceeded"}`))
+		default:
+			w.Write([]byte(testChatCompletion))
+		}
+	})
+	defer server.Close()
+
+	if err := callTestProvider(server.URL); err != nil {
+		t.Fatalf("UT-AICLIENT-001 FAIL: 再試行で成功すべき: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("UT-AICLIENT-001 FAIL: 期待 3回, 実際 %d回", calls)
+	}
+}
+
+// UT-AICLIENT-002: Retry-Afterに従って待ち、上限より長ければ再試行せずに待ち時間を返す
+func TestDoAIRequest_RetryAfter(t *testing.T) {
+	resetAIBreakers()
+	t.Setenv("AI_RETRY_BASE_DELAY", "1ms")
+	t.Setenv("AI_RETRY_MAX_DELAY", "5s")
+
+	var calls int32
+	server := newCountingServer(&calls, func(w http.ResponseWriter, n int32) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(429)
+	})
+	defer server.Close()
+
+	err := callTestProvider(server.URL)
+	var aiErr *AIError
+	if !errors.As(err, &aiErr) || aiErr.Kind != AIErrorRateLimited || aiErr.RetryAfter != 120*time.Second {
+		t.Fatalf("UT-AICLIENT-002 FAIL: レート制限と待ち時間を返すべき: %#v", err)
+	}
+	if calls != 1 {
+		t.Errorf("UT-AICLIENT-002 FAIL: 上限より長いRetry-Afterでは再試行しない: %d回", calls)
+	}
+
+	config := AIClientConfig{RetryBaseDelay: 10 * time.Millisecond, RetryMaxDelay: 5 * time.Second}
+	if d := retryDelay(config, 0, 2*time.Second); d != 2*time.Second {
+		t.Errorf("UT-AICLIENT-002 FAIL: Retry-Afterより短く待ってはいけない: %v", d)
+	}
+	if d := retryDelay(config, 3, 0); d < 64*time.Millisecond || d > 96*time.Millisecond {
+		t.Errorf("UT-AICLIENT-002 FAIL: 待ち時間は倍々に伸びるべき: %v", d)
+	}
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	if d := parseRetryAfter("Fri, 16 Oct 2026 12:00:30 GMT", now); d != 30*time.Second {
+		t.Errorf("UT-AICLIENT-002 FAIL: HTTP日付のRetry-Afterを読めない: %v", d)
+	}
+	if d := parseRetryAfter("soon", now); d != 0 {
+		t.Errorf("UT-AICLIENT-002 FAIL: 読めないRetry-Afterは0: %v", d)
+	}
+}
+
+// UT-AICLIENT-003: エラーの種類を判別し、HTTPステータスに変換する（利用上限は再試行しない）
+func TestClassifyAIStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		kind   string
+		http   int
+	}{
+		{429, `{"error": {"code": "insufficient_quota"}}`, AIErrorQuota, 429},
+		{402, `{"error": "Insufficient credits"}`, AIErrorQuota, 429},
+		{429, `{"error": {"status": "RESOURCE_EXHAUSTED", "message": "Quota exceeded for metric: GenerateRequestsPerMinutePerProjectPerModel"}}`, AIErrorRateLimited, 429},
+		{503, `overloaded`, AIErrorUnavailable, 503},
+		{504, ``, AIErrorTimeout, 504},
+		{400, `{"error": "invalid model"}`, AIErrorBadResponse, 502},
+	}
+	for _, tt := range tests {
+		err := classifyAIStatus(tt.status, "", tt.body)
+		if err.Kind != tt.kind || aiErrorStatus(err) != tt.http {
+			t.Errorf("UT-AICLIENT-003 FAIL: %d %s → 期待 %s/%d, 実際 %s/%d", tt.status, tt.body, tt.kind, tt.http, err.Kind, aiErrorStatus(err))
+		}
+	}
+	if aiErrorStatus(errors.New("OPENROUTER_API_KEY not set")) != 500 {
+		t.Error("UT-AICLIENT-003 FAIL: 種類のないエラーは500")
+	}
+
+	resetAIBreakers()
+	var calls int32
+	server := newCountingServer(&calls, func(w http.ResponseWriter, n int32) {
+		w.WriteHeader(429)
+		w.Write([]byte(`{"error": {"code": "insufficient_quota"}}`))
+	})
+	defer server.Close()
+	if err := callTestProvider(server.URL); !isQuotaError(err) || calls != 1 {
+		t.Errorf("UT-AICLIENT-003 FAIL: 利用上限は再試行せずに返すべき: %v (%d回)", err, calls)
+	}
+}
+
+// UT-AICLIENT-004: 時間内に応答がなければタイムアウトのエラーになる
+func TestDoAIRequest_Timeout(t *testing.T) {
+	resetAIBreakers()
+	t.Setenv("AI_TIMEOUT", "50ms")
+	t.Setenv("AI_MAX_RETRIES", "0")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	err := callTestProvider(server.URL)
+	if aiErrorKind(err) != AIErrorTimeout || aiErrorStatus(err) != 504 {
+		t.Errorf("UT-AICLIENT-004 FAIL: タイムアウトのエラーになるべき: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("UT-AICLIENT-004 FAIL: タイムアウトが効いていない: %v", elapsed)
+	}
+}
+
+// UT-AICLIENT-005: 失敗が続いたらAPIを呼ばずにすぐ失敗し、時間が過ぎたら1回試して閉じる
+func TestDoAIRequest_CircuitBreaker(t *testing.T) {
+	resetAIBreakers()
+	t.Setenv("AI_MAX_RETRIES", "0")
+	t.Setenv("AI_BREAKER_THRESHOLD", "2")
+	t.Setenv("AI_BREAKER_COOLDOWN", "100ms")
+
+	var calls int32
+	var healthy atomic.Bool
+	server := newCountingServer(&calls, func(w http.ResponseWriter, n int32) {
+		if !healthy.Load() {
+			w.WriteHeader(500)
+			return
+		}
+		w.Write([]byte(testChatCompletion))
+	})
+	defer server.Close()
+
+	callTestProvider(server.URL)
+	callTestProvider(server.URL)
+	err := callTestProvider(server.URL)
+	var aiErr *AIError
+	if !errors.As(err, &aiErr) || aiErr.Kind != AIErrorUnavailable || aiErr.RetryAfter <= 0 {
+		t.Errorf("UT-AICLIENT-005 FAIL: ブレーカーが開いてすぐ失敗すべき: %#v", err)
+	}
+	if calls != 2 {
+		t.Errorf("UT-AICLIENT-005 FAIL: 開いている間はAPIを呼ばない: %d回", calls)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	healthy.Store(true)
+	if err := callTestProvider(server.URL); err != nil {
+		t.Errorf("UT-AICLIENT-005 FAIL: 時間が過ぎたら試しに呼ぶべき: %v", err)
+	}
+	if err := callTestProvider(server.URL); err != nil || calls != 4 {
+		t.Errorf("UT-AICLIENT-005 FAIL: 成功したらブレーカーは閉じる: %v (%d回)", err, calls)
+	}
+}
+
+// UT-AICLIENT-006: /api/chatはレート制限を500ではなく429（Retry-Afterつき）で返す
+func TestHandleChat_AIRateLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resetAIBreakers()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(429)
+		w.Write([]byte(`{"error": "rate limit exceeded"}`))
+	}))
+	defer server.Close()
+
+	originalDB := db
+	db = nil
+	defer func() { db = originalDB }()
+
+	t.Setenv("LLM_PROVIDER", "openrouter")
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Setenv("LLM_BASE_URL", server.URL)
+	t.Setenv("AI_CACHE_ENABLED", "false")
+	t.Setenv("SKILL_ANALYZER", "ai")
+
+	router := gin.New()
+	router.POST("/api/chat", handleChat)
+
+	body, _ := json.Marshal(ChatRequest{Message: "Java 5年"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/chat", bytes.NewReader(body)))
+
+	var resp map[string]string
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != 429 || resp["code"] != AIErrorRateLimited {
+		t.Errorf("UT-AICLIENT-006 FAIL: 期待 429 %s, 実際 %d %v", AIErrorRateLimited, w.Code, resp)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("UT-AICLIENT-006 FAIL: Retry-Afterが付いていない: %q", w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-CACHE テストケース
+// cache.go のキャッシュキー生成・キャッシュ参照・削除のテスト
+// ============================================================
+
+// UT-CACHE-001: 表記揺れを吸収して同じキーになる
+func TestBuildCacheKey_Normalized(t *testing.T) {
+	a := buildCacheKey("Ｊａｖａ　5年\n TypeScript 2年", "model-a", "v1")
+	b := buildCacheKey("java 5年 typescript   2年", "model-a", "v1")
+	if a != b {
+		t.Error("UT-CACHE-001 FAIL: 全角・大文字・空白の違いは同じキーになるべき")
+	}
+}
+
+// UT-CACHE-002: モデルやプロンプトバージョンが変われば別キー
+func TestBuildCacheKey_ModelAndPromptVersion(t *testing.T) {
+	base := buildCacheKey("Java 5年", "model-a", "v1")
+	if base == buildCacheKey("Java 5年", "model-b", "v1") {
+		t.Error("UT-CACHE-002 FAIL: モデルが違えば別キーになるべき")
+	}
+	if base == buildCacheKey("Java 5年", "model-a", "v2") {
+		t.Error("UT-CACHE-002 FAIL: プロンプトバージョンが違えば別キーになるべき")
+	}
+}
+
+// UT-CACHE-003: TTLの読み込み
+func TestLoadCacheConfig(t *testing.T) {
+	t.Setenv("AI_CACHE_ENABLED", "false")
+	t.Setenv("AI_CACHE_TTL", "90m")
+
+	config := LoadCacheConfig()
+	if config.Enabled {
+		t.Error("UT-CACHE-003 FAIL: AI_CACHE_ENABLED=falseで無効になるべき")
+	}
+	if config.TTL.Minutes() != 90 {
+		t.Errorf("UT-CACHE-003 FAIL: 期待 TTL=90m, 実際 %v", config.TTL)
+	}
+}
+
+// UT-CACHE-004: キャッシュヒット時はAIを呼ばない
+func TestAnalyzeSkillsCached_Hit(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	provider := newMockProvider()
+	originalProvider := llmProvider
+	llmProvider = provider
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("AI_CACHE_ENABLED", "true")
+
+	cached, _ := json.Marshal(AIAnalysis{KeySkills: []string{"Go"}, ExperienceLevel: "上級"})
+	mock.ExpectQuery("SELECT aicanl FROM tbl_aicache").
+		WithArgs(buildCacheKey("Go 5年", "mock", activePromptVersion(PromptAnalysis))).
+		WillReturnRows(sqlmock.NewRows([]string{"aicanl"}).AddRow(cached))
+
+	analysis, hit, err := analyzeSkillsCached("Go 5年")
+	if err != nil {
+		t.Fatalf("UT-CACHE-004 FAIL: エラーが発生: %v", err)
+	}
+	if !hit {
+		t.Error("UT-CACHE-004 FAIL: キャッシュヒットになるべき")
+	}
+	if len(analysis.KeySkills) != 1 || analysis.KeySkills[0] != "Go" {
+		t.Errorf("UT-CACHE-004 FAIL: キャッシュの内容が返るべき: %+v", analysis)
+	}
+	if provider.Calls() != 0 {
+		t.Errorf("UT-CACHE-004 FAIL: キャッシュヒット時にAIが呼ばれた（%d回）", provider.Calls())
+	}
+}
+
+// UT-CACHE-005: キャッシュミス時はAIを呼んで保存する
+func TestAnalyzeSkillsCached_Miss(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	provider := newMockProvider()
+	originalProvider := llmProvider
+	llmProvider = provider
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("AI_CACHE_ENABLED", "true")
+
+	mock.ExpectQuery("SELECT aicanl FROM tbl_aicache").WillReturnRows(sqlmock.NewRows([]string{"aicanl"}))
+	mock.ExpectExec("INSERT INTO tbl_aicache").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, hit, err := analyzeSkillsCached("Java 3年")
+	if err != nil {
+		t.Fatalf("UT-CACHE-005 FAIL: エラーが発生: %v", err)
+	}
+	if hit {
+		t.Error("UT-CACHE-005 FAIL: キャッシュミスになるべき")
+	}
+	if provider.Calls() != 1 {
+		t.Errorf("UT-CACHE-005 FAIL: AIが1回呼ばれるべき（実際 %d回）", provider.Calls())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-CACHE-005 FAIL: キャッシュに保存されていない: %v", err)
+	}
+}
+
+// UT-CACHE-006: キャッシュ障害時もAI分析は成功する
+func TestAnalyzeSkillsCached_DBError(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	originalProvider := llmProvider
+	llmProvider = newMockProvider()
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("AI_CACHE_ENABLED", "true")
+
+	mock.ExpectQuery("SELECT aicanl FROM tbl_aicache").WillReturnError(fmt.Errorf("relation does not exist"))
+	mock.ExpectExec("INSERT INTO tbl_aicache").WillReturnError(fmt.Errorf("relation does not exist"))
+
+	if _, _, err := analyzeSkillsCached("Java 3年"); err != nil {
+		t.Errorf("UT-CACHE-006 FAIL: キャッシュ障害でAI分析が失敗した: %v", err)
+	}
+}
+
+// UT-CACHE-007: 管理者用キャッシュ削除エンドポイント
+func TestHandlePurgeCache(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	mock.ExpectExec("DELETE FROM tbl_aicache WHERE aicexp <= NOW()").WillReturnResult(sqlmock.NewResult(0, 3))
+
+	r := gin.New()
+	r.DELETE("/api/admin/cache", requireAdmin(), handlePurgeCache)
+
+	req := httptest.NewRequest("DELETE", "/api/admin/cache?expired=true", nil)
+	req.Header.Set("X-Admin-Token", "secret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("UT-CACHE-007 FAIL: 期待ステータス %d, 実際 %d", http.StatusOK, w.Code)
+	}
+
+	var resp map[string]int64
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp["deleted"] != 3 {
+		t.Errorf("UT-CACHE-007 FAIL: 期待 deleted=3, 実際 %d", resp["deleted"])
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// ============================================================
+// UT-EXTRACT テストケース
+// extractor.go のルールベース解析とAI上限時の切り替えのテスト
+// ============================================================
+
+// UT-EXTRACT-001: スキル名と経験年数の表記パターン
+func TestExtractSkillsOffline_Years(t *testing.T) {
+	analysis := extractSkillsOffline("Java3年、TypeScriptを2年、Go歴1.5年、React 6ヶ月", nil)
+
+	want := map[string]float64{"Java": 3, "TypeScript": 2, "Go": 1.5, "React": 0.5}
+	if len(analysis.StructuredSkills) != len(want) {
+		t.Fatalf("UT-EXTRACT-001 FAIL: 期待 %d件, 実際 %+v", len(want), analysis.StructuredSkills)
+	}
+	for _, s := range analysis.StructuredSkills {
+		if years, ok := want[s.SkillName]; !ok || years != s.ExperienceYears {
+			t.Errorf("UT-EXTRACT-001 FAIL: %s の年数が不正: %v", s.SkillName, s.ExperienceYears)
+		}
+	}
+	if len(analysis.KeySkills) != 3 || analysis.KeySkills[0] != "Java" {
+		t.Errorf("UT-EXTRACT-001 FAIL: 重点スキルは年数の長い順の上位3つのはず: %v", analysis.KeySkills)
+	}
+	if analysis.ExperienceLevel != "中級" {
+		t.Errorf("UT-EXTRACT-001 FAIL: 期待 中級, 実際 %s", analysis.ExperienceLevel)
+	}
+}
+
+// UT-EXTRACT-002: 単語境界（JavaとJavaScript、全角表記）
+func TestExtractSkillsOffline_Boundaries(t *testing.T) {
+	analysis := extractSkillsOffline("ＪａｖａＳｃｒｉｐｔ 4年とSpring Boot 2年", nil)
+
+	names := map[string]bool{}
+	for _, s := range analysis.StructuredSkills {
+		names[s.SkillName] = true
+	}
+	if names["Java"] || names["Spring"] {
+		t.Errorf("UT-EXTRACT-002 FAIL: 長い表記の一部に当たってはいけない: %+v", analysis.StructuredSkills)
+	}
+	if !names["JavaScript"] || !names["Spring Boot"] {
+		t.Errorf("UT-EXTRACT-002 FAIL: JavaScriptとSpring Bootを抽出すべき: %+v", analysis.StructuredSkills)
+	}
+}
+
+// UT-EXTRACT-003: 希望単価
+func TestExtractSkillsOffline_Salary(t *testing.T) {
+	cases := []struct {
+		message string
+		want    string
+	}{
+		{"PHP 5年 月80〜100万円希望", "月額80万円〜100万円"},
+		{"PHP 5年 80-100万", "月額80万円〜100万円"},
+		{"PHP 5年 月額90万円以上", "月額90万円"},
+		{"PHP 5年", "簡易分析のため推定なし"},
+		{"PHP 5年 年収800万円希望", "年収800万円"},
+	}
+	for _, c := range cases {
+		if got := extractSkillsOffline(c.message, nil).EstimatedSalary; got != c.want {
+			t.Errorf("UT-EXTRACT-003 FAIL: %q 期待 %s, 実際 %s", c.message, c.want, got)
+		}
+	}
+
+	// 年収は月額に換算してから単価の範囲にする
+	salary := parseEstimatedSalary(extractSkillsOffline("PHP 5年 年収840万円希望", nil).EstimatedSalary)
+	if salary == nil || salary.Min != 700000 {
+		t.Errorf("UT-EXTRACT-003 FAIL: 年収840万円は月額70万円になるはず, 実際 %+v", salary)
+	}
+}
+
+// UT-EXTRACT-003b: 役割キーワードは語の途中では判定しない
+func TestExtractSkillsOffline_RoleBoundary(t *testing.T) {
+	cases := []struct {
+		message string
+		want    string
+	}{
+		{"Java 5年 データベース設計が得意", "エンジニア"},
+		{"Java 5年 webアプリケーション開発", "エンジニア"},
+		{"Python 3年 データ基盤の構築", "データエンジニア"},
+		{"Swift 3年 スマホアプリ開発", "アプリエンジニア"},
+		{"pmo経験あり、次はpm希望", "プロジェクトマネージャー"},
+		{"Java 5年 pmoの補佐、今後はsre志望", "PMO"},
+	}
+	for _, c := range cases {
+		if got := extractSkillsOffline(c.message, nil).PreferredRole; got != c.want {
+			t.Errorf("UT-EXTRACT-003b FAIL: %q 期待 %s, 実際 %s", c.message, c.want, got)
+		}
+	}
+}
+
+// UT-EXTRACT-004: 抽出結果はスキーマを満たす
+func TestExtractSkillsOffline_SchemaValid(t *testing.T) {
+	analysis := extractSkillsOffline("バックエンドエンジニア志望 Python 7年 AWS 3年", nil)
+	if analysis.PreferredRole != "バックエンドエンジニア" {
+		t.Errorf("UT-EXTRACT-004 FAIL: 期待 バックエンドエンジニア, 実際 %s", analysis.PreferredRole)
+	}
+	raw, err := json.Marshal(analysis)
+	if err != nil {
+		t.Fatalf("UT-EXTRACT-004 FAIL: %v", err)
+	}
+	if _, errs := parseAIAnalysis(string(raw)); len(errs) > 0 {
+		t.Errorf("UT-EXTRACT-004 FAIL: スキーマ違反: %v", errs)
+	}
+}
+
+// UT-EXTRACT-005: AIの利用上限時はルールベースに切り替わる
+func TestAnalyzeChatRequest_QuotaFallback(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": {"code": "insufficient_quota"}}`))
+	}))
+	defer mockServer.Close()
+
+	t.Setenv("LLM_PROVIDER", "openrouter")
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Setenv("LLM_BASE_URL", mockServer.URL)
+	t.Setenv("AI_CACHE_ENABLED", "false")
+
+	outcome, err := analyzeChatRequest(context.Background(), nil, "Java3年", AnalyzerAI, nil)
+	if err != nil {
+		t.Fatalf("UT-EXTRACT-005 FAIL: 上限エラーはルールベースで救うべき: %v", err)
+	}
+	if outcome.Analyzer != AnalyzerRule {
+		t.Errorf("UT-EXTRACT-005 FAIL: 期待 analyzer=rule, 実際 %s", outcome.Analyzer)
+	}
+	if len(outcome.Analysis.KeySkills) != 1 || outcome.Analysis.KeySkills[0] != "Java" {
+		t.Errorf("UT-EXTRACT-005 FAIL: Javaを抽出すべき: %v", outcome.Analysis.KeySkills)
+	}
+}
+
+// UT-EXTRACT-006: 上限以外のエラーはそのまま返す / ruleの明示指定ではAIを呼ばない
+func TestAnalyzeChatRequest_ErrorsAndExplicitRule(t *testing.T) {
+	called := false
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mockServer.Close()
+
+	t.Setenv("LLM_PROVIDER", "openrouter")
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Setenv("LLM_BASE_URL", mockServer.URL)
+	t.Setenv("AI_CACHE_ENABLED", "false")
+
+	if _, err := analyzeChatRequest(context.Background(), nil, "Java3年", AnalyzerAI, nil); err == nil {
+		t.Error("UT-EXTRACT-006 FAIL: 500エラーはフォールバックせずに返すべき")
+	}
+
+	called = false
+	outcome, err := analyzeChatRequest(context.Background(), nil, "Java3年", AnalyzerRule, nil)
+	if err != nil || outcome.Analyzer != AnalyzerRule {
+		t.Errorf("UT-EXTRACT-006 FAIL: ruleで解析されるべき: %v %+v", err, outcome)
+	}
+	if called {
+		t.Error("UT-EXTRACT-006 FAIL: rule指定時はAIを呼んではいけない")
+	}
+
+	if _, err := resolveAnalyzer("gpt"); err == nil {
+		t.Error("UT-EXTRACT-006 FAIL: 不明な解析方法はエラーになるべき")
+	}
+}
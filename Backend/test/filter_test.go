@@ -0,0 +1,445 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-FILTER テストケース
+// filter.go の案件の絞り込み条件のテスト
+// ============================================================
+
+// UT-FILTER-001: メッセージから「のみ」「以上」「以外」「直近」などが付いた条件だけを取り出す
+func TestParseMessageFilters(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		message string
+		want    *ProjectFilter
+	}{
+		{
+			"Java 5年です。フルリモート、月60万以上、ランサーズ以外、直近3日の案件",
+			&ProjectFilter{MinPrice: 600000, WorkStyle: WorkStyleRemote, ExcludeSources: []string{"lancers"}, PostedSince: "2026-10-14"},
+		},
+		{
+			"長期希望で80万円まで。crowdworksのみ、1週間以内",
+			&ProjectFilter{MaxPrice: 800000, Period: PeriodLong, Sources: []string{"crowdworks"}, PostedSince: "2026-10-10"},
+		},
+		{"常駐のみ、単発案件", &ProjectFilter{WorkStyle: WorkStyleOnsite, Period: PeriodShort}},
+		// 経歴として書かれた単語だけでは絞り込まない
+		{"Goで長期のAPI開発、リモートワーク経験3年、長期プロジェクトのリーダー", nil},
+		{"客先常駐で金融系の開発を担当", nil},
+		{"リモートで開発を行った経験があります", nil},
+		{"在宅を希望します", &ProjectFilter{WorkStyle: WorkStyleRemote}},
+		// 下限が上限を超える条件は使わない
+		{"90万円以上、60万円以下", nil},
+	}
+
+	for _, tt := range tests {
+		got := parseMessageFilters(tt.message, now)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("UT-FILTER-001 FAIL: %s\n期待 %+v\n実際 %+v", tt.message, tt.want, got)
+		}
+	}
+}
+
+// UT-FILTER-002: 項目ごとに前にある条件を優先し、除外の条件はすべて合わせる。不正な値はエラー
+func TestMergeProjectFilters(t *testing.T) {
+	explicit := &ProjectFilter{MinPrice: 700000, Sources: []string{"lancers"}}
+	message := &ProjectFilter{MinPrice: 600000, WorkStyle: WorkStyleRemote, Sources: []string{"Lancers", "crowdworks"}}
+	previous := &ProjectFilter{WorkStyle: WorkStyleOnsite, ExcludeSources: []string{"freelance-start"}}
+
+	got := mergeProjectFilters(explicit, nil, message, previous)
+	want := &ProjectFilter{
+		MinPrice:       700000,
+		WorkStyle:      WorkStyleRemote,
+		Sources:        []string{"lancers"},
+		ExcludeSources: []string{"freelance-start"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UT-FILTER-002 FAIL: \n期待 %+v\n実際 %+v", want, got)
+	}
+
+	// 除外するサイト・スキル・キーワードは新しい指定があっても前回の分を残す
+	got = mergeProjectFilters(
+		&ProjectFilter{ExcludedSkills: []string{"Ruby"}, ExcludeSources: []string{"lancers"}},
+		&ProjectFilter{ExcludedSkills: []string{"php", "ruby"}, ExcludedKeywords: []string{"保守"}, ExcludeSources: []string{"crowdworks"}, WorkStyle: WorkStyleRemote},
+	)
+	if fmt.Sprint(got.ExcludedSkills) != "[Ruby php]" || fmt.Sprint(got.ExcludedKeywords) != "[保守]" ||
+		fmt.Sprint(got.ExcludeSources) != "[lancers crowdworks]" || got.WorkStyle != WorkStyleRemote {
+		t.Errorf("UT-FILTER-002 FAIL: 除外の条件はすべて合わせるべき: %+v", got)
+	}
+	if mergeProjectFilters(nil, &ProjectFilter{}) != nil {
+		t.Error("UT-FILTER-002 FAIL: 条件がなければnilのはず")
+	}
+
+	for _, f := range []ProjectFilter{
+		{MinPrice: -1},
+		{MinPrice: 800000, MaxPrice: 600000},
+		{WorkStyle: "hybrid"},
+		{Period: "forever"},
+		{PostedSince: "2026/10/01"},
+	} {
+		if err := f.Validate(); err == nil {
+			t.Errorf("UT-FILTER-002 FAIL: %+v はエラーになるべき", f)
+		}
+	}
+}
+
+// UT-FILTER-003: 候補を取り出すSQLに、スキルの条件とANDで絞り込み条件を付ける（どちらのバックエンドでも）
+func TestBuildCandidateQuery_Filter(t *testing.T) {
+	q := candidateQuery{
+		Skills:  []searchSkill{{Name: "Java", Weight: 1}},
+		Profile: defaultRankingProfile(),
+		Filter: &ProjectFilter{
+			WorkStyle:      WorkStyleRemote,
+			Period:         PeriodLong,
+			Sources:        []string{"lancers"},
+			ExcludeSources: []string{"crowd_works"},
+			PostedSince:    "2026-10-01",
+		},
+	}
+
+	for _, backend := range []string{SearchBackendRegex, SearchBackendTrgm} {
+		query, args := buildCandidateQuery(backend, q)
+		for _, want := range []string{
+			") AND (concat_ws(' ', prottl, prodtl, proprd, proot2) ~* $",
+			"AND concat_ws(' ', prottl, prodtl, proprd) ~* $",
+			"AND prostn ILIKE ANY($",
+			"AND NOT (coalesce(prostn, '') ILIKE ANY($",
+			"AND procrt >= $",
+		} {
+			if !strings.Contains(query, want) {
+				t.Errorf("UT-FILTER-003 FAIL: %s: SQLに %s がない", backend, want)
+			}
+		}
+		if args[len(args)-1] != "2026-10-01" {
+			t.Errorf("UT-FILTER-003 FAIL: %s: 掲載日の値が最後に渡っていない: %v", backend, args)
+		}
+		if !strings.Contains(strings.Join(strings.Fields(query), " "), fmt.Sprintf("$%d", len(args))) {
+			t.Errorf("UT-FILTER-003 FAIL: %s: プレースホルダーの番号が値の数と合わない", backend)
+		}
+	}
+
+	conditions, _ := (&ProjectFilter{ExcludeSources: []string{"crowd_works"}}).sqlConditions(nil)
+	if len(conditions) != 1 || containsLikePatterns([]string{"crowd_works"})[0] != `%crowd\_works%` {
+		t.Errorf("UT-FILTER-003 FAIL: サイト名のLIKEの特殊文字はエスケープするべき: %v", conditions)
+	}
+}
+
+// UT-FILTER-004: /api/projectsはクエリパラメーターで絞り込み、単価もSQLの関数で比べる
+func TestGetAllProjects_Filter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectQuery(`WHERE \(concat_ws(.|\n)+prostn ILIKE ANY\(\$3\) AND procrt >= \$4 AND project_price_matches\(proprc, \$5, \$6\)\s+ORDER BY procrt DESC`).
+		WithArgs(filterRemotePattern, filterNoRemotePattern, sqlmock.AnyArg(), "2026-10-01", 500000, 0).
+		WillReturnRows(sqlmock.NewRows(projectColumns).
+			AddRow("https://lancers.jp/1", "リモートJava案件", "詳細", "70万円", nil, "Java", nil, "lancers.jp", "2026-10-10").
+			AddRow("https://lancers.jp/2", "リモートPHP案件", "詳細", "40万円", nil, "PHP", nil, "lancers.jp", "2026-10-09").
+			AddRow("https://lancers.jp/3", "リモート保守", "詳細", "要相談", nil, "", nil, "lancers.jp", "2026-10-08"))
+
+	router := gin.New()
+	router.GET("/api/projects", getAllProjects)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/projects?work_style=remote&source=lancers&min_price=500000&posted_since=2026-10-01", nil))
+	if w.Code != 200 {
+		t.Fatalf("UT-FILTER-004 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+	}
+	var resp AllProjectsResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Total != 2 || resp.Projects[0].URL != "https://lancers.jp/1" || resp.Projects[1].URL != "https://lancers.jp/3" {
+		t.Errorf("UT-FILTER-004 FAIL: 下限に届かない案件だけ除くべき（読み取れない単価は残す）: %+v", resp.Projects)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-FILTER-004 FAIL: %v", err)
+	}
+
+	for _, query := range []string{"work_style=hybrid", "min_price=abc", "posted_since=yesterday", "min_price=800000&max_price=600000"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/projects?"+query, nil))
+		if w.Code != 400 {
+			t.Errorf("UT-FILTER-004 FAIL: %s は 400, 実際 %d", query, w.Code)
+		}
+	}
+}
+
+// UT-FILTER-005: /api/chatのfiltersとメッセージの条件を合わせて検索し、使った条件を返してセッションに残す
+func TestHandleChat_Filters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	router := gin.New()
+	router.POST("/api/chat", handleChat)
+	post := func(body ChatRequest) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/chat", bytes.NewReader(raw)))
+		return w
+	}
+
+	if w := post(ChatRequest{Message: "Java 5年", Analyzer: AnalyzerRule, Filters: &ProjectFilter{WorkStyle: "hybrid"}}); w.Code != 400 {
+		t.Errorf("UT-FILTER-005 FAIL: 不正なfiltersは 400, 実際 %d", w.Code)
+	}
+
+	mock.ExpectQuery(`AND \(concat_ws(.|\n)+AND prostn ILIKE ANY\(\$\d+\) AND NOT \(coalesce\(prostn, ''\) ILIKE ANY\(\$\d+\)\)`).
+		WillReturnRows(sqlmock.NewRows(projectColumns).
+			AddRow("https://crowdworks.jp/1", "リモートJava開発", "詳細", "80万円", nil, "Java", nil, "crowdworks.jp", "2026-10-10").
+			AddRow("https://crowdworks.jp/2", "リモートJava保守", "詳細", "50万円", nil, "Java", nil, "crowdworks.jp", "2026-10-09"))
+	var searchParams driver.Value
+	mock.ExpectExec("INSERT INTO tbl_session").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), capturedArg{&searchParams}, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := post(ChatRequest{
+		Message:  "Java 5年、リモートのみ、ランサーズ以外",
+		Analyzer: AnalyzerRule,
+		Filters:  &ProjectFilter{MinPrice: 600000, Sources: []string{"crowdworks"}},
+	})
+	if w.Code != 200 {
+		t.Fatalf("UT-FILTER-005 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+	}
+	var resp ChatResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	want := &ProjectFilter{MinPrice: 600000, WorkStyle: WorkStyleRemote, Sources: []string{"crowdworks"}, ExcludeSources: []string{"lancers"}}
+	if !reflect.DeepEqual(resp.Filters, want) {
+		t.Errorf("UT-FILTER-005 FAIL: \n期待 %+v\n実際 %+v", want, resp.Filters)
+	}
+	if len(resp.Projects) != 1 || resp.Projects[0].URL != "https://crowdworks.jp/1" {
+		t.Errorf("UT-FILTER-005 FAIL: 単価の下限に届かない案件は除くべき: %+v", resp.Projects)
+	}
+
+	var saved SearchParams
+	raw, _ := searchParams.([]byte)
+	json.Unmarshal(raw, &saved)
+	if !reflect.DeepEqual(saved.Filter, want) {
+		t.Errorf("UT-FILTER-005 FAIL: 続きのメッセージに引き継ぐ条件がセッションにない: %+v", saved.Filter)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-FILTER-005 FAIL: %v", err)
+	}
+}
+
+// UT-FILTER-006: 「NG」「避けたい」「以外で」などが付いた語を、辞書にあるスキルと、それ以外のキーワードに分けて取り出す
+func TestParseMessageExclusions(t *testing.T) {
+	tests := []struct {
+		message      string
+		wantSkills   []string
+		wantKeywords []string
+	}{
+		{"Java 5年です。PHPはNG、SES常駐・Excel VBAは避けたい", []string{"PHP"}, []string{"ses常駐", "excel vba"}},
+		{"Golangで開発したい。保守以外で", nil, []string{"保守"}},
+		{"PHP以外の案件、テストはやりたくない", []string{"PHP"}, []string{"テスト"}},
+		// 掲載サイト・働き方の条件、経歴の説明は除外にしない
+		{"ランサーズ以外、リモート以外はng", nil, nil},
+		{"Java以外にPythonも経験あり", nil, nil},
+		{"Spring Bootでの開発経験5年", nil, nil},
+		{"要件定義以外、設計・実装・テストを担当", nil, nil},
+		{"Java以外でもPythonの経験あり", nil, nil},
+		// 「案件」「系」は付けずに取り出し、英字と日本語の間の空白は1つの語として扱う
+		{"保守案件は避けたい", nil, []string{"保守"}},
+		{"金融系の案件はNG", nil, []string{"金融"}},
+		{"SES 常駐は避けたい", nil, []string{"ses常駐"}},
+	}
+
+	for _, tt := range tests {
+		skills, keywords := parseMessageExclusions(tt.message)
+		if fmt.Sprint(skills) != fmt.Sprint(tt.wantSkills) || fmt.Sprint(keywords) != fmt.Sprint(tt.wantKeywords) {
+			t.Errorf("UT-FILTER-006 FAIL: %s\n期待 %v %v\n実際 %v %v", tt.message, tt.wantSkills, tt.wantKeywords, skills, keywords)
+		}
+	}
+
+	// 除外したスキルは簡易解析のスキルにも、前回から引き継ぐスキルにも入れない
+	analysis := extractSkillsOffline("Java 5年、PHP 3年。PHPはNG", nil)
+	if fmt.Sprint(analysis.KeySkills) != "[Java]" || len(analysis.StructuredSkills) != 1 || fmt.Sprint(analysis.ExcludedSkills) != "[PHP]" {
+		t.Errorf("UT-FILTER-006 FAIL: 除外したスキルが残っている: %+v", analysis)
+	}
+	previous := &AIAnalysis{StructuredSkills: []Skill{{SkillName: "Java", ExperienceYears: 5}, {SkillName: "PHP", ExperienceYears: 3}}}
+	analysis = extractSkillsOffline("PHPは除外して", previous)
+	if fmt.Sprint(analysis.KeySkills) != "[Java]" {
+		t.Errorf("UT-FILTER-006 FAIL: 前回のスキルから除外したスキルを除くべき: %v", analysis.KeySkills)
+	}
+}
+
+// UT-FILTER-007: 除外するスキル・キーワードはタイトル・スキル欄・詳細へのNOT条件にし、検索するスキルからも除く
+func TestExcludedSkillsAndKeywords(t *testing.T) {
+	filter := &ProjectFilter{ExcludedSkills: []string{"php", "Excel VBA"}, ExcludedKeywords: []string{"SES", "100%保守"}}
+	conditions, args := filter.sqlConditions([]interface{}{"dummy"})
+	want := []string{
+		"NOT (concat_ws(' ', prottl, proot1, prodtl) ~* $2)",
+		"NOT (concat_ws(' ', prottl, proot1, prodtl) ILIKE ANY($3))",
+	}
+	if fmt.Sprint(conditions) != fmt.Sprint(want) || len(args) != 3 {
+		t.Fatalf("UT-FILTER-007 FAIL: \n期待 %v\n実際 %v (%d個の値)", want, conditions, len(args))
+	}
+	if pattern := args[1].(string); !strings.Contains(pattern, getSkillDictionary().Pattern("PHP")) || !strings.Contains(pattern, "excel vba") {
+		t.Errorf("UT-FILTER-007 FAIL: 辞書のエイリアスで除外するべき: %s", pattern)
+	}
+
+	if err := (&ProjectFilter{ExcludedKeywords: []string{"a"}}).Validate(); err == nil {
+		t.Error("UT-FILTER-007 FAIL: 1文字のキーワードはエラーになるべき")
+	}
+	if err := (&ProjectFilter{ExcludedSkills: make([]string, maxExcludedTerms+1)}).Validate(); err == nil {
+		t.Error("UT-FILTER-007 FAIL: 上限を超える数はエラーになるべき")
+	}
+
+	params := SearchParams{
+		KeySkills: []string{"php", "Java", "AWS"},
+		Skills:    []Skill{{SkillName: "PHP", ExperienceYears: 8}, {SkillName: "Java", ExperienceYears: 3}, {SkillName: "Docker", ExperienceYears: 2}},
+		Filter:    &ProjectFilter{ExcludedSkills: []string{"PHP"}},
+	}
+	if skills := searchSkillsForParams(params); fmt.Sprint(skills) != "[Java AWS Docker]" {
+		t.Errorf("UT-FILTER-007 FAIL: 除外したスキルで検索している: %v", skills)
+	}
+}
+
+// UT-FILTER-008: メッセージとリクエストの除外条件を合わせてSQLで除き、使った条件を返す
+func TestHandleChat_Exclusions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	router := gin.New()
+	router.POST("/api/chat", handleChat)
+
+	mock.ExpectQuery(`NOT \(concat_ws\(' ', prottl, proot1, prodtl\) ~\* \$\d+\) AND NOT \(concat_ws\(' ', prottl, proot1, prodtl\) ILIKE ANY\(\$\d+\)\)`).
+		WillReturnRows(sqlmock.NewRows(projectColumns).
+			AddRow("https://a.com/1", "Java開発", "詳細", "80万円", nil, "Java", nil, "siteA", "2026-10-10"))
+	mock.ExpectExec("INSERT INTO tbl_session").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	raw, _ := json.Marshal(ChatRequest{
+		Message:  "Java 5年、PHP 2年。PHPはNG",
+		Analyzer: AnalyzerRule,
+		Filters:  &ProjectFilter{ExcludedKeywords: []string{"SES"}},
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/chat", bytes.NewReader(raw)))
+	if w.Code != 200 {
+		t.Fatalf("UT-FILTER-008 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+	}
+	var resp ChatResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	want := &ProjectFilter{ExcludedSkills: []string{"PHP"}, ExcludedKeywords: []string{"SES"}}
+	if !reflect.DeepEqual(resp.Filters, want) {
+		t.Errorf("UT-FILTER-008 FAIL: \n期待 %+v\n実際 %+v", want, resp.Filters)
+	}
+	if fmt.Sprint(resp.AIAnalysis.ExcludedSkills) != "[PHP]" || fmt.Sprint(resp.AIAnalysis.KeySkills) != "[Java]" {
+		t.Errorf("UT-FILTER-008 FAIL: 分析結果に除外したスキルが残っている: %+v", resp.AIAnalysis)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-FILTER-008 FAIL: %v", err)
+	}
+}
+
+// UT-FILTER-009: 単価の条件もSQLで絞り込み（件数を絞る前に除く）、「条件をリセット」で前回の条件を引き継がない
+func TestPriceFilterSQLAndClear(t *testing.T) {
+	q := candidateQuery{
+		Skills:  []searchSkill{{Name: "Java", Weight: 1}},
+		Profile: defaultRankingProfile(),
+		Filter:  &ProjectFilter{MinPrice: 600000, MaxPrice: 900000},
+	}
+	for _, backend := range []string{SearchBackendRegex, SearchBackendTrgm} {
+		query, args := buildCandidateQuery(backend, q)
+		want := fmt.Sprintf("AND project_price_matches(proprc, $%d, $%d)", len(args)-1, len(args))
+		if !strings.Contains(query, want) || args[len(args)-2] != 600000 || args[len(args)-1] != 900000 {
+			t.Errorf("UT-FILTER-009 FAIL: %s: SQLに %s がない: %v", backend, want, args)
+		}
+		if strings.Index(query, want) > strings.Index(query, "LIMIT") {
+			t.Errorf("UT-FILTER-009 FAIL: %s: 単価の条件はLIMITより前で絞り込むべき", backend)
+		}
+	}
+	if conditions, _ := (&ProjectFilter{WorkStyle: WorkStyleRemote}).sqlConditions(nil); strings.Contains(fmt.Sprint(conditions), "project_price_matches") {
+		t.Errorf("UT-FILTER-009 FAIL: 単価の指定がなければ条件を付けない: %v", conditions)
+	}
+
+	session := &Session{LastSearch: &SearchParams{Filter: &ProjectFilter{MinPrice: 800000, ExcludedSkills: []string{"PHP"}}}}
+	analysis := AIAnalysis{KeySkills: []string{"Java"}, Filters: &ProjectFilter{WorkStyle: WorkStyleRemote}}
+	if params := buildSearchParams(session, "リモートのみで", analysis); params.Filter == nil || params.Filter.MinPrice != 800000 || params.Filter.WorkStyle != WorkStyleRemote {
+		t.Errorf("UT-FILTER-009 FAIL: 指定していない項目は前回の条件を引き継ぐべき: %+v", params.Filter)
+	}
+	for _, message := range []string{"絞り込みを解除して、リモートのみで", "条件をリセット。リモートのみ"} {
+		want := &ProjectFilter{WorkStyle: WorkStyleRemote}
+		if params := buildSearchParams(session, message, analysis); !reflect.DeepEqual(params.Filter, want) {
+			t.Errorf("UT-FILTER-009 FAIL: %s: 前回の条件を引き継いではいけない: %+v", message, params.Filter)
+		}
+	}
+}
+
+// UT-FILTER-010: 会話の続きで別の除外を足しても前回の除外は残り、「条件をリセット」で消える
+func TestHandleChat_ExclusionsAcrossTurns(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	router := gin.New()
+	router.POST("/api/chat", handleChat)
+
+	var savedAnalysis, savedParams driver.Value
+	post := func(message, sessionID string) ChatResponse {
+		if sessionID != "" {
+			now := time.Now()
+			mock.ExpectQuery("FROM tbl_session").WithArgs(sessionID).
+				WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(sessionID, []byte("[]"), savedAnalysis, savedParams, now, now, now.Add(time.Hour)))
+		}
+		mock.ExpectQuery("FROM tbl_project").
+			WillReturnRows(sqlmock.NewRows(projectColumns).AddRow("https://a.com/1", "Java開発", "詳細", "80万円", nil, "Java", nil, "siteA", "2026-10-10"))
+		mock.ExpectExec("INSERT INTO tbl_session").
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), capturedArg{&savedAnalysis}, capturedArg{&savedParams}, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		raw, _ := json.Marshal(ChatRequest{Message: message, SessionID: sessionID, Analyzer: AnalyzerRule})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/chat", bytes.NewReader(raw)))
+		if w.Code != 200 {
+			t.Fatalf("UT-FILTER-010 FAIL: %s: 期待 200, 実際 %d: %s", message, w.Code, w.Body.String())
+		}
+		var resp ChatResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	first := post("Java 5年、PHPとSESはNG", "")
+	second := post("RubyとExcel VBAも避けたい", first.SessionID)
+	if second.Filters == nil || fmt.Sprint(second.Filters.ExcludedSkills) != "[Ruby PHP]" || fmt.Sprint(second.Filters.ExcludedKeywords) != "[excel vba ses]" {
+		t.Errorf("UT-FILTER-010 FAIL: 前回の除外に今回の除外を足すべき: %+v", second.Filters)
+	}
+	third := post("条件をリセットして、保守は避けたい", first.SessionID)
+	if third.Filters == nil || len(third.Filters.ExcludedSkills) != 0 || fmt.Sprint(third.Filters.ExcludedKeywords) != "[保守]" {
+		t.Errorf("UT-FILTER-010 FAIL: リセットしたら今回の除外だけのはず: %+v", third.Filters)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-FILTER-010 FAIL: %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-LANG テストケース
+// language.go / translate.go の英語の入力への対応のテスト
+// ============================================================
+
+// 使い回す訳を消す
+func resetTranslationCache() {
+	translationCacheMu.Lock()
+	translationCache = map[string]cachedTranslation{}
+	translationCacheMu.Unlock()
+}
+
+// UT-LANG-001: 英字の多い日本語の職務経歴は日本語、英文は英語と判定する
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Java 5年、Spring Boot 3年、AWS 2年", LanguageJapanese},
+		{"AWS, Docker, Kubernetes, Terraform, GitHub Actions 3年", LanguageJapanese},
+		{"バックエンドエンジニアとしてGoで5年の経験があります", LanguageJapanese},
+		{"Backend engineer with 5 years of experience in Go and AWS.", LanguageEnglish},
+		{"Taro Yamada (山田太郎) - I have 6 years of experience with Java and Spring Boot.", LanguageEnglish},
+		{"Java", LanguageEnglish},
+		{"", LanguageJapanese},
+	}
+	for _, tt := range tests {
+		if got := detectLanguage(tt.text); got != tt.want {
+			t.Errorf("UT-LANG-001 FAIL: %q → 期待 %s, 実際 %s", tt.text, tt.want, got)
+		}
+	}
+}
+
+// UT-LANG-002: 英語の語句から日本語・カタカナの検索語を作る（単語の途中には当たらない）
+func TestJapaneseSearchTerms(t *testing.T) {
+	terms := japaneseSearchTerms("Backend engineer. Built AWS infrastructure and handled maintenance of web apps.")
+	for _, want := range []string{"バックエンド", "サーバーサイド", "インフラ構築", "保守"} {
+		if !slices.Contains(terms, want) {
+			t.Errorf("UT-LANG-002 FAIL: %q がない: %v", want, terms)
+		}
+	}
+	if len(terms) > searchTermLimit {
+		t.Errorf("UT-LANG-002 FAIL: 最大%d件: %v", searchTermLimit, terms)
+	}
+
+	if terms := japaneseSearchTerms("Information systems, gamification"); len(terms) != 0 {
+		t.Errorf("UT-LANG-002 FAIL: 単語の途中に当たった: %v", terms)
+	}
+
+	// 日本語の入力には検索語を足さない
+	params := SearchParams{}
+	addJapaneseSearchTerms(&params, "バックエンド backend Java 5年")
+	if len(params.SearchTerms) != 0 {
+		t.Errorf("UT-LANG-002 FAIL: 日本語の入力に検索語が付いた: %v", params.SearchTerms)
+	}
+}
+
+// UT-LANG-003: ルールベースの解析で英語の年数・役割を読み取る
+func TestExtractSkillsOffline_English(t *testing.T) {
+	analysis := extractSkillsOffline("Backend engineer. Java for 5 years, Go (2 yrs), React 6 months.", nil)
+
+	years := map[string]float64{}
+	for _, s := range analysis.StructuredSkills {
+		years[s.SkillName] = s.ExperienceYears
+	}
+	if years["Java"] != 5 || years["Go"] != 2 || years["React"] != 0.5 {
+		t.Errorf("UT-LANG-003 FAIL: 年数を読み取れていない: %v", years)
+	}
+	if analysis.PreferredRole != "バックエンドエンジニア" {
+		t.Errorf("UT-LANG-003 FAIL: 期待 バックエンドエンジニア, 実際 %s", analysis.PreferredRole)
+	}
+}
+
+// UT-LANG-004: 英語のメッセージの検索条件に検索語を足し、続きのメッセージでは引き継ぐ
+func TestBuildSearchParams_SearchTerms(t *testing.T) {
+	analysis := AIAnalysis{KeySkills: []string{"Go"}}
+	params := buildSearchParams(nil, "I am a backend engineer with 5 years of Go experience.", analysis)
+	if !slices.Contains(params.SearchTerms, "バックエンド") || !strings.Contains(params.Query, "バックエンド") {
+		t.Fatalf("UT-LANG-004 FAIL: 検索語が付いていない: %v / %q", params.SearchTerms, params.Query)
+	}
+	if skills := searchSkillsForParams(params); len(skills) < 2 || skills[0] != "Go" || !slices.Contains(skills, "バックエンド") {
+		t.Errorf("UT-LANG-004 FAIL: 重点スキルの後に検索語を照合するべき: %v", skills)
+	}
+
+	session := &Session{LastSearch: &params}
+	next := buildSearchParams(session, "もっと高単価の案件", analysis)
+	if !slices.Contains(next.SearchTerms, "バックエンド") {
+		t.Errorf("UT-LANG-004 FAIL: 前回の検索語を引き継ぐべき: %v", next.SearchTerms)
+	}
+}
+
+// UT-LANG-005: 英訳はAIにまとめて依頼し、同じ案件の訳は使い回す。失敗したら訳なしで返す
+func TestTranslateProjects(t *testing.T) {
+	resetTranslationCache()
+	provider := newMockProvider(`{"translations": [
+		{"id": 1, "title": "Backend development with Go", "summary": "Develop APIs in Go. Remote OK."},
+		{"id": 0, "title": "Java system maintenance", "summary": "Maintain a Java business system."}
+	]}`)
+	originalProvider := llmProvider
+	llmProvider = provider
+	defer func() { llmProvider = originalProvider }()
+
+	projects := []Project{
+		{URL: "https://test.com/java", Title: "Java業務システム保守", Detail: "保守運用"},
+		{URL: "https://test.com/go", Title: "Goでのバックエンド開発", Detail: "API開発。リモート可"},
+	}
+	translated, ok := translateProjects(context.Background(), projects)
+	if !ok || translated[0].TitleEn != "Java system maintenance" || translated[1].SummaryEn != "Develop APIs in Go. Remote OK." {
+		t.Fatalf("UT-LANG-005 FAIL: 訳が付いていない: %v %+v", ok, translated)
+	}
+	if projects[0].TitleEn != "" {
+		t.Error("UT-LANG-005 FAIL: 元の案件を書き換えてはいけない")
+	}
+
+	if _, ok := translateProjects(context.Background(), projects); !ok || provider.Calls() != 1 {
+		t.Errorf("UT-LANG-005 FAIL: 訳を使い回すべき: %d回", provider.Calls())
+	}
+
+	resetTranslationCache()
+	llmProvider = newMockProvider(`{"translations": "broken"}`)
+	translated, ok = translateProjects(context.Background(), projects)
+	if ok || translated[0].TitleEn != "" {
+		t.Errorf("UT-LANG-005 FAIL: 失敗したら訳なしで返すべき: %+v", translated)
+	}
+}
+
+// UT-LANG-006: /api/chatは英語の入力の言語・検索語を返し、検索語にマッチした案件を出す
+func TestHandleChat_EnglishInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(projectColumns).
+		AddRow("https://test.com/1", "Goでのバックエンド開発", "API開発", "80万円", nil, "Go", nil, "test", "2026-10-01"))
+	mock.ExpectExec("INSERT INTO tbl_session").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	router := gin.New()
+	router.POST("/api/chat", handleChat)
+
+	body, _ := json.Marshal(ChatRequest{Message: "Backend engineer with 5 years of Go experience.", Analyzer: AnalyzerRule, Translate: true})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/chat", bytes.NewReader(body)))
+	if w.Code != 200 {
+		t.Fatalf("UT-LANG-006 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp ChatResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.InputLanguage != LanguageEnglish || !slices.Contains(resp.SearchTerms, "バックエンド") {
+		t.Errorf("UT-LANG-006 FAIL: 言語・検索語が返っていない: %s %v", resp.InputLanguage, resp.SearchTerms)
+	}
+	if len(resp.Projects) != 1 || resp.Projects[0].Match == nil {
+		t.Fatalf("UT-LANG-006 FAIL: 案件が返っていない: %+v", resp.Projects)
+	}
+	var matched []string
+	for _, s := range resp.Projects[0].Match.Skills {
+		matched = append(matched, s.Skill)
+	}
+	if !slices.Contains(matched, "バックエンド") {
+		t.Errorf("UT-LANG-006 FAIL: 検索語がマッチの内訳にない: %v", matched)
+	}
+	// ルールベースの解析ではAIを使わないので英訳しない
+	if resp.Translated || resp.Projects[0].TitleEn != "" {
+		t.Error("UT-LANG-006 FAIL: ルールベースの解析で英訳した")
+	}
+}
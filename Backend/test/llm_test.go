@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ============================================================
+// UT-LLM テストケース
+// llm.go の設定読み込みと各プロバイダーのテスト
+// ============================================================
+
+// UT-LLM-001: デフォルト設定はOpenRouter
+func TestLoadLLMConfig_Default(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "")
+	t.Setenv("LLM_MODEL", "")
+	t.Setenv("LLM_BASE_URL", "")
+	t.Setenv("LLM_API_KEY", "")
+	t.Setenv("LLM_TEMPERATURE", "")
+	t.Setenv("OPENROUTER_API_KEY", "or-key")
+
+	config := LoadLLMConfig()
+	if config.Provider != ProviderOpenRouter {
+		t.Errorf("UT-LLM-001 FAIL: 期待 Provider=openrouter, 実際 %s", config.Provider)
+	}
+	if config.Model != "openai/gpt-3.5-turbo" {
+		t.Errorf("UT-LLM-001 FAIL: 期待 Model=openai/gpt-3.5-turbo, 実際 %s", config.Model)
+	}
+	if config.BaseURL != "https://openrouter.ai/api/v1" {
+		t.Errorf("UT-LLM-001 FAIL: BaseURLが不正: %s", config.BaseURL)
+	}
+	if config.APIKey != "or-key" {
+		t.Errorf("UT-LLM-001 FAIL: APIKeyはOPENROUTER_API_KEYから読み込まれるべき: %s", config.APIKey)
+	}
+	if config.Temperature != 0.7 {
+		t.Errorf("UT-LLM-001 FAIL: 期待 Temperature=0.7, 実際 %f", config.Temperature)
+	}
+}
+
+// UT-LLM-002: 環境変数で上書き
+func TestLoadLLMConfig_Override(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "openai")
+	t.Setenv("LLM_MODEL", "llama3")
+	t.Setenv("LLM_BASE_URL", "http://localhost:11434/v1")
+	t.Setenv("LLM_TEMPERATURE", "0.2")
+
+	config := LoadLLMConfig()
+	if config.Provider != ProviderOpenAI || config.Model != "llama3" {
+		t.Errorf("UT-LLM-002 FAIL: 設定が上書きされていない: %+v", config)
+	}
+	if config.Temperature != 0.2 {
+		t.Errorf("UT-LLM-002 FAIL: 期待 Temperature=0.2, 実際 %f", config.Temperature)
+	}
+}
+
+// UT-LLM-003: 必須項目の不足・不明なプロバイダー
+func TestNewLLMProvider_InvalidConfig(t *testing.T) {
+	cases := []struct {
+		config LLMConfig
+		want   string
+	}{
+		{LLMConfig{Provider: ProviderOpenRouter}, "OPENROUTER_API_KEY"},
+		{LLMConfig{Provider: ProviderGemini}, "GEMINI_API_KEY"},
+		{LLMConfig{Provider: ProviderOpenAI, Model: "llama3"}, "LLM_BASE_URL"},
+		{LLMConfig{Provider: "unknown"}, "unknown LLM provider"},
+	}
+
+	for _, c := range cases {
+		_, err := NewLLMProvider(c.config)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("UT-LLM-003 FAIL: %sを含むエラーが返るべき: %v", c.want, err)
+		}
+	}
+}
+
+// UT-LLM-004: OpenAI互換エンドポイントへのリクエスト内容
+func TestOpenAICompatibleProvider_Chat(t *testing.T) {
+	var received AIChatRequest
+	var authHeader string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("UT-LLM-004 FAIL: パスが不正: %s", r.URL.Path)
+		}
+		authHeader = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{"model": "llama3", "choices": [{"message": {"role": "assistant", "content": "{}"}}]}`))
+	}))
+	defer mockServer.Close()
+
+	provider, err := NewLLMProvider(LLMConfig{Provider: ProviderOpenAI, Model: "llama3", BaseURL: mockServer.URL + "/v1/", Temperature: 0.1})
+	if err != nil {
+		t.Fatalf("プロバイダー生成エラー: %v", err)
+	}
+
+	result, err := provider.Chat(context.Background(), []AIChatMessage{{Role: "user", Content: "Java 5年"}})
+	if err != nil {
+		t.Fatalf("UT-LLM-004 FAIL: エラーが発生: %v", err)
+	}
+	if result.Content != "{}" || result.Model != "llama3" {
+		t.Errorf("UT-LLM-004 FAIL: 結果が不正: %+v", result)
+	}
+	if received.Model != "llama3" || received.Temperature != 0.1 {
+		t.Errorf("UT-LLM-004 FAIL: リクエストに設定が反映されていない: %+v", received)
+	}
+	// APIキー未設定ならAuthorizationヘッダーは送らない
+	if authHeader != "" {
+		t.Errorf("UT-LLM-004 FAIL: APIキーなしでAuthorizationヘッダーが送信された: %s", authHeader)
+	}
+}
+
+// UT-LLM-005: Geminiのリクエスト変換とレスポンス結合
+func TestGeminiProvider_Chat(t *testing.T) {
+	var received geminiRequest
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/gemini-test:generateContent" {
+			t.Errorf("UT-LLM-005 FAIL: パスが不正: %s", r.URL.Path)
+		}
+		if r.Header.Get("x-goog-api-key") != "g-key" {
+			t.Errorf("UT-LLM-005 FAIL: APIキーヘッダーが不正")
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte(`{"candidates": [{"content": {"parts": [{"text": "{\"a\":"}, {"text": "1}"}]}}]}`))
+	}))
+	defer mockServer.Close()
+
+	provider, err := NewLLMProvider(LLMConfig{Provider: ProviderGemini, Model: "gemini-test", BaseURL: mockServer.URL, APIKey: "g-key"})
+	if err != nil {
+		t.Fatalf("プロバイダー生成エラー: %v", err)
+	}
+
+	result, err := provider.Chat(context.Background(), []AIChatMessage{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "Java 5年"},
+		{Role: "assistant", Content: "前回の返答"},
+	})
+	if err != nil {
+		t.Fatalf("UT-LLM-005 FAIL: エラーが発生: %v", err)
+	}
+	if result.Content != `{"a":1}` {
+		t.Errorf("UT-LLM-005 FAIL: partsが結合されていない: %s", result.Content)
+	}
+	if received.SystemInstruction == nil || received.SystemInstruction.Parts[0].Text != "system prompt" {
+		t.Error("UT-LLM-005 FAIL: systemメッセージがsystemInstructionに変換されていない")
+	}
+	if len(received.Contents) != 2 || received.Contents[1].Role != "model" {
+		t.Errorf("UT-LLM-005 FAIL: assistantはmodelロールに変換されるべき: %+v", received.Contents)
+	}
+}
+
+// UT-LLM-006: モックは登録順に返し、最後のレスポンスを返し続ける
+func TestMockProvider_Deterministic(t *testing.T) {
+	provider := newMockProvider("first", "second")
+
+	for i, want := range []string{"first", "second", "second"} {
+		result, err := provider.Chat(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("UT-LLM-006 FAIL: エラーが発生: %v", err)
+		}
+		if result.Content != want {
+			t.Errorf("UT-LLM-006 FAIL: %d回目 期待 %s, 実際 %s", i+1, want, result.Content)
+		}
+	}
+	if provider.Calls() != 3 {
+		t.Errorf("UT-LLM-006 FAIL: 期待 呼び出し回数 3, 実際 %d", provider.Calls())
+	}
+}
+
+// UT-LLM-007: モックプロバイダー経由でanalyzeSkillsが動く
+func TestAnalyzeSkills_MockProvider(t *testing.T) {
+	t.Setenv("LLM_PROVIDER", "mock")
+	t.Setenv("LLM_MOCK_RESPONSE", "")
+
+	analysis, err := analyzeSkills("Java 3年")
+	if err != nil {
+		t.Fatalf("UT-LLM-007 FAIL: エラーが発生: %v", err)
+	}
+	if len(analysis.KeySkills) == 0 || analysis.KeySkills[0] != "Java" {
+		t.Errorf("UT-LLM-007 FAIL: 固定レスポンスが返るべき: %+v", analysis)
+	}
+}
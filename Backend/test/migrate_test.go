@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================
+// UT-MIGRATE テストケース
+// migrate.go の RunMigrations のテスト
+// ============================================================
+
+// UT-MIGRATE-001: 未適用のマイグレーションだけを適用する
+func TestRunMigrations_AppliesPending(t *testing.T) {
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS tbl_migration").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT migver FROM tbl_migration").WillReturnRows(sqlmock.NewRows([]string{"migver"}))
+	for _, m := range migrations {
+		mock.ExpectBegin()
+		mock.ExpectExec(".+").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO tbl_migration").WithArgs(m.Version, m.Name).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	if err := RunMigrations(mockDB); err != nil {
+		t.Fatalf("UT-MIGRATE-001 FAIL: エラーが発生: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-MIGRATE-001 FAIL: 期待したSQLが実行されていない: %v", err)
+	}
+}
+
+// UT-MIGRATE-002: 適用済みなら何もしない
+func TestRunMigrations_AlreadyApplied(t *testing.T) {
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+
+	applied := sqlmock.NewRows([]string{"migver"})
+	for _, m := range migrations {
+		applied.AddRow(m.Version)
+	}
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS tbl_migration").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT migver FROM tbl_migration").WillReturnRows(applied)
+
+	if err := RunMigrations(mockDB); err != nil {
+		t.Fatalf("UT-MIGRATE-002 FAIL: エラーが発生: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-MIGRATE-002 FAIL: 余計なSQLが実行された: %v", err)
+	}
+}
+
+// UT-MIGRATE-003: 失敗したらロールバックしてエラーを返す
+func TestRunMigrations_Failure(t *testing.T) {
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS tbl_migration").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT migver FROM tbl_migration").WillReturnRows(sqlmock.NewRows([]string{"migver"}))
+	mock.ExpectBegin()
+	mock.ExpectExec(".+").WillReturnError(fmt.Errorf("syntax error"))
+	mock.ExpectRollback()
+
+	if err := RunMigrations(mockDB); err == nil {
+		t.Error("UT-MIGRATE-003 FAIL: マイグレーション失敗時はエラーが返るべき")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-MIGRATE-003 FAIL: ロールバックされていない: %v", err)
+	}
+}
+
+// UT-MIGRATE-004: nilのDB
+func TestRunMigrations_NilDB(t *testing.T) {
+	if err := RunMigrations(nil); err == nil {
+		t.Error("UT-MIGRATE-004 FAIL: nilのDBではエラーが返るべき")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-PAGE テストケース
+// pagination.go の検索結果のページ送りのテスト
+// ============================================================
+
+// SQLに渡された値を記録するsqlmockの引数
+type capturedArg struct {
+	value *driver.Value
+}
+
+func (a capturedArg) Match(v driver.Value) bool {
+	*a.value = v
+	return true
+}
+
+// UT-PAGE-001: 先頭から切れ目なく返した所までをカーソルにし、その後ろで返した案件は除く対象に残す
+func TestNextSearchCursor(t *testing.T) {
+	ranked := []rankedProject{
+		{Project: Project{URL: "https://a.com/1", PostedAt: "2026-10-03"}, MatchScore: 10},
+		{Project: Project{URL: "https://a.com/2", PostedAt: "2026-10-02"}, MatchScore: 10},
+		{Project: Project{URL: "https://b.com/1", PostedAt: "2026-10-01"}, MatchScore: 8, PriceScore: 2},
+		{Project: Project{URL: "https://c.com/1", PostedAt: "2026-09-30"}, MatchScore: 6},
+	}
+	shown := []Project{{URL: "https://a.com/1"}, {URL: "https://b.com/1"}}
+
+	raw := nextSearchCursor(searchCursor{Search: "abc"}, ranked, shown, false)
+	cursor, err := decodeSearchCursor(raw)
+	if err != nil {
+		t.Fatalf("UT-PAGE-001 FAIL: %v", err)
+	}
+	if cursor.URL != "https://a.com/1" || cursor.Score != 10 || cursor.PostedAt != "2026-10-03" || cursor.Search != "abc" {
+		t.Errorf("UT-PAGE-001 FAIL: カーソルの位置が不正: %+v", cursor)
+	}
+	if len(cursor.Seen) != 1 || cursor.Seen[0] != "https://b.com/1" {
+		t.Errorf("UT-PAGE-001 FAIL: カーソルより後ろで返した案件を覚えていない: %v", cursor.Seen)
+	}
+
+	// 同点の並びはスコア → 掲載日の新しい順 → URLの順
+	if !cursor.before(ranked[1]) || !cursor.before(ranked[2]) || cursor.before(ranked[0]) {
+		t.Error("UT-PAGE-001 FAIL: カーソルとの比較が不正")
+	}
+
+	if raw := nextSearchCursor(searchCursor{Search: "abc"}, ranked[:2], []Project{{URL: "https://a.com/1"}, {URL: "https://a.com/2"}}, false); raw != "" {
+		t.Error("UT-PAGE-001 FAIL: すべて返したら続きはないはず")
+	}
+	if raw := nextSearchCursor(searchCursor{Search: "abc"}, ranked[:2], []Project{{URL: "https://a.com/1"}, {URL: "https://a.com/2"}}, true); raw == "" {
+		t.Error("UT-PAGE-001 FAIL: 候補が上限に達していれば続きがあるはず")
+	}
+
+	for _, raw := range []string{"not-base64!", "bm90LWpzb24", encodeSearchCursor(searchCursor{URL: "https://a.com/1"})} {
+		if _, err := decodeSearchCursor(raw); err == nil {
+			t.Errorf("UT-PAGE-001 FAIL: %s はエラーになるべき", raw)
+		}
+	}
+}
+
+// Java案件（siteAが6件、siteB・siteCが3件ずつ。スコアは同じで掲載日の新しい順にa1..a6, b1..b3, c1..c3）
+func paginationRows(extra ...[]driver.Value) *sqlmock.Rows {
+	rows := sqlmock.NewRows(projectColumns)
+	day := 20
+	for _, site := range []struct {
+		name  string
+		count int
+	}{{"a", 6}, {"b", 3}, {"c", 3}} {
+		for i := 1; i <= site.count; i++ {
+			rows.AddRow(fmt.Sprintf("https://%s.com/%d", site.name, i), "Java開発", "詳細", "", nil, "Java", nil, "site"+site.name, fmt.Sprintf("2026-10-%02d", day))
+			day--
+		}
+	}
+	for _, row := range extra {
+		rows.AddRow(row...)
+	}
+	return rows
+}
+
+// UT-PAGE-002: 1ページ目の続きを、AIを呼ばず、重複・抜けなく、追加された案件で並びを崩さずに返す
+func TestHandleSessionProjects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	router := gin.New()
+	router.POST("/api/chat", handleChat)
+	router.GET("/api/sessions/:id/projects", handleSessionProjects)
+
+	var sessionID, searchParams driver.Value
+	mock.ExpectQuery(`ORDER BY match_score DESC, match_count DESC, procrt DESC, prourl`).WillReturnRows(paginationRows())
+	mock.ExpectExec("INSERT INTO tbl_session").
+		WithArgs(capturedArg{&sessionID}, sqlmock.AnyArg(), sqlmock.AnyArg(), capturedArg{&searchParams}, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	raw, _ := json.Marshal(ChatRequest{Message: "Java 5年", Analyzer: AnalyzerRule})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/chat", bytes.NewReader(raw)))
+	if w.Code != 200 {
+		t.Fatalf("UT-PAGE-002 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+	}
+	var first ChatResponse
+	json.Unmarshal(w.Body.Bytes(), &first)
+	if len(first.Projects) != 8 || first.NextCursor == "" {
+		t.Fatalf("UT-PAGE-002 FAIL: 1ページ目は8件とカーソル: %d件 %q", len(first.Projects), first.NextCursor)
+	}
+
+	get := func(cursor string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions/"+first.SessionID+"/projects?cursor="+cursor, nil))
+		return w
+	}
+	expectSession := func() {
+		now := time.Now()
+		mock.ExpectQuery("FROM tbl_session").WithArgs(first.SessionID).WillReturnRows(sqlmock.NewRows(sessionColumns).
+			AddRow(sessionID, []byte(`[]`), []byte(`{"key_skills":["Java"]}`), searchParams, now, now, now.Add(time.Hour)))
+	}
+
+	// カーソルより前に入った新着（2026-10-25）は出さず、後ろに入った案件（2026-10-01）は出す
+	expectSession()
+	mock.ExpectQuery(`match_score < \$\d+ OR \(match_score = \$\d+ AND \(match_count < \$\d+`).WillReturnRows(paginationRows(
+		[]driver.Value{"https://new.com/1", "Java開発", "詳細", "", nil, "Java", nil, "sitenew", "2026-10-25"},
+		[]driver.Value{"https://new.com/2", "Java開発", "詳細", "", nil, "Java", nil, "sitenew", "2026-10-01"},
+	))
+	w = get(first.NextCursor)
+	if w.Code != 200 {
+		t.Fatalf("UT-PAGE-002 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+	}
+	var second ProjectPage
+	json.Unmarshal(w.Body.Bytes(), &second)
+
+	var got []string
+	for _, p := range append(first.Projects, second.Projects...) {
+		got = append(got, p.URL)
+	}
+	want := []string{
+		"https://a.com/1", "https://a.com/2", "https://a.com/3", "https://b.com/1", "https://b.com/2", "https://b.com/3", "https://c.com/1", "https://c.com/2",
+		"https://a.com/4", "https://a.com/5", "https://a.com/6", "https://c.com/3", "https://new.com/2",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("UT-PAGE-002 FAIL: \n期待 %v\n実際 %v", want, got)
+	}
+	if second.NextCursor != "" || second.SessionID != first.SessionID {
+		t.Errorf("UT-PAGE-002 FAIL: 最後のページにカーソルはないはず: %+v", second)
+	}
+	if second.Projects[0].Match == nil {
+		t.Error("UT-PAGE-002 FAIL: 続きの案件にも内訳が付くはず")
+	}
+
+	// 同じセッションで新しく検索した後のカーソル・壊れたカーソル・カーソルなし
+	cursor, _ := decodeSearchCursor(first.NextCursor)
+	cursor.Search = "0000000000000000"
+	expectSession()
+	if w := get(encodeSearchCursor(cursor)); w.Code != 409 {
+		t.Errorf("UT-PAGE-002 FAIL: 古い検索のカーソルは 409, 実際 %d", w.Code)
+	}
+	if w := get("broken"); w.Code != 400 {
+		t.Errorf("UT-PAGE-002 FAIL: 壊れたカーソルは 400, 実際 %d", w.Code)
+	}
+	if w := get(""); w.Code != 400 {
+		t.Errorf("UT-PAGE-002 FAIL: カーソルなしは 400, 実際 %d", w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-PAGE-002 FAIL: %v", err)
+	}
+}
+
+// UT-PAGE-003: ランキングプロファイルはセッションの検索条件に残してハッシュにも含め、掲載日は時刻として比べる
+func TestSearchParamsHash_RankingAndPostedAt(t *testing.T) {
+	profile := defaultRankingProfile()
+	params := SearchParams{KeySkills: []string{"Java"}, Ranking: &profile}
+
+	raw, _ := json.Marshal(params)
+	var restored SearchParams
+	json.Unmarshal(raw, &restored)
+	if restored.Ranking == nil || *restored.Ranking != profile {
+		t.Fatalf("UT-PAGE-003 FAIL: ランキングプロファイルが保存されていない: %s", raw)
+	}
+	if searchParamsHash(restored) != searchParamsHash(params) {
+		t.Error("UT-PAGE-003 FAIL: 保存し直してもハッシュは同じはず")
+	}
+
+	overridden := profile
+	overridden.TitleWeight = 10
+	overridden.Overridden = true
+	if searchParamsHash(SearchParams{KeySkills: []string{"Java"}, Ranking: &overridden}) == searchParamsHash(params) {
+		t.Error("UT-PAGE-003 FAIL: 配点が違う検索のハッシュは変わるはず")
+	}
+
+	// 同じ時刻の別表記は同点、タイムゾーンが違っても新しい方が先
+	a := rankedProject{Project: Project{URL: "https://a.com/1", PostedAt: "2026-10-01T09:00:00+09:00"}, MatchScore: 8}
+	b := rankedProject{Project: Project{URL: "https://b.com/1", PostedAt: "2026-10-01T00:00:00Z"}, MatchScore: 8}
+	if !rankedBefore(a, b) || rankedBefore(b, a) {
+		t.Error("UT-PAGE-003 FAIL: 同じ時刻ならURLの順のはず")
+	}
+	c := rankedProject{Project: Project{URL: "https://a.com/2", PostedAt: "2026-10-01T08:00:00+09:00"}, MatchScore: 8}
+	d := rankedProject{Project: Project{URL: "https://b.com/2", PostedAt: "2026-09-30T23:30:00Z"}, MatchScore: 8}
+	if !rankedBefore(d, c) {
+		t.Error("UT-PAGE-003 FAIL: タイムゾーンをそろえて新しい順に並べるはず")
+	}
+}
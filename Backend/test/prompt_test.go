@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================
+// UT-PROMPT テストケース
+// prompt.go のプロンプトテンプレートの読み込み・バージョン選択のテスト
+// ============================================================
+
+// テスト間でテンプレートの読み込み結果を持ち越さないようにする
+func resetPromptSet(t *testing.T) {
+	promptSetMu.Lock()
+	promptSet = nil
+	promptSetMu.Unlock()
+	t.Cleanup(func() {
+		promptSetMu.Lock()
+		promptSet = nil
+		promptSetMu.Unlock()
+	})
+}
+
+// UT-PROMPT-001: 埋め込みのテンプレートが既定のバージョンで揃っている
+func TestCheckActivePrompts_Default(t *testing.T) {
+	resetPromptSet(t)
+	t.Setenv("PROMPT_VERSIONS", "")
+	t.Setenv("PROMPT_DIR", "")
+
+	if err := checkActivePrompts(); err != nil {
+		t.Fatalf("UT-PROMPT-001 FAIL: %v", err)
+	}
+
+	text, version, err := renderPrompt(PromptAnalysis, nil)
+	if err != nil || version != "v3" {
+		t.Fatalf("UT-PROMPT-001 FAIL: 解析はv3が使われるべき: %s, %v", version, err)
+	}
+	if !strings.Contains(text, "structured_skills") || !strings.Contains(text, "excluded_skills") || !strings.Contains(text, "```json") {
+		t.Errorf("UT-PROMPT-001 FAIL: 解析プロンプトの本文が不正: %s", text)
+	}
+}
+
+// UT-PROMPT-002: パラメーターが埋め込まれ、足りなければエラー
+func TestRenderPrompt_Parameters(t *testing.T) {
+	resetPromptSet(t)
+	t.Setenv("PROMPT_VERSIONS", "")
+
+	text, _, err := renderPrompt(PromptRerank, rerankPromptData{ReasonLength: 25})
+	if err != nil {
+		t.Fatalf("UT-PROMPT-002 FAIL: %v", err)
+	}
+	if !strings.Contains(text, "reasonは25文字程度") {
+		t.Errorf("UT-PROMPT-002 FAIL: パラメーターが埋め込まれていない: %s", text)
+	}
+
+	if _, _, err := renderPrompt(PromptRerank, nil); err == nil {
+		t.Error("UT-PROMPT-002 FAIL: パラメーターなしはエラーになるべき")
+	}
+}
+
+// UT-PROMPT-003: PROMPT_DIRのテンプレートを重ね、PROMPT_VERSIONSで環境ごとに選ぶ
+func TestRenderPrompt_DirectoryAndVersion(t *testing.T) {
+	resetPromptSet(t)
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, PromptAnalysis), 0755)
+	os.WriteFile(filepath.Join(dir, PromptAnalysis, "v2.tmpl"), []byte("v2のプロンプト"), 0644)
+	t.Setenv("PROMPT_DIR", dir)
+	t.Setenv("PROMPT_VERSIONS", " analysis = v2 , rerank=v1")
+
+	text, version, err := renderPrompt(PromptAnalysis, nil)
+	if err != nil || version != "v2" || text != "v2のプロンプト" {
+		t.Errorf("UT-PROMPT-003 FAIL: v2が使われるべき: %s, %s, %v", version, text, err)
+	}
+	if activePromptVersion(PromptRerank) != "v1" {
+		t.Errorf("UT-PROMPT-003 FAIL: rerankはv1のはず: %s", activePromptVersion(PromptRerank))
+	}
+
+	t.Setenv("PROMPT_VERSIONS", "analysis=v9")
+	if err := checkActivePrompts(); err == nil {
+		t.Error("UT-PROMPT-003 FAIL: 存在しないバージョンはエラーになるべき")
+	}
+}
+
+// UT-PROMPT-004: PROMPT_SOURCE=dbのときはtbl_promptの行を重ねる
+func TestLoadPromptSet_Database(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	t.Setenv("PROMPT_DIR", "")
+	t.Setenv("PROMPT_SOURCE", "db")
+	mock.ExpectQuery("SELECT prmnam, prmver, prmtxt FROM tbl_prompt").
+		WillReturnRows(sqlmock.NewRows([]string{"prmnam", "prmver", "prmtxt"}).
+			AddRow("rerank", "v2", "理由は{{.ReasonLength}}文字で"))
+
+	set, err := loadPromptSet()
+	if err != nil {
+		t.Fatalf("UT-PROMPT-004 FAIL: %v", err)
+	}
+	tmpl, ok := set.Get(PromptRerank, "v2")
+	if !ok || tmpl.Source != "db" {
+		t.Errorf("UT-PROMPT-004 FAIL: DBのテンプレートが読み込まれていない: %+v", tmpl)
+	}
+	if _, ok := set.Get(PromptAnalysis, "v1"); !ok {
+		t.Error("UT-PROMPT-004 FAIL: 埋め込みのテンプレートも残るべき")
+	}
+
+	// 不正なテンプレートは読み込みエラー
+	mock.ExpectQuery("SELECT prmnam, prmver, prmtxt FROM tbl_prompt").
+		WillReturnRows(sqlmock.NewRows([]string{"prmnam", "prmver", "prmtxt"}).
+			AddRow("rerank", "v3", "{{.ReasonLength"))
+	if _, err := loadPromptSet(); err == nil {
+		t.Error("UT-PROMPT-004 FAIL: 構文エラーのテンプレートはエラーになるべき")
+	}
+}
+
+// UT-PROMPT-004b: 埋め込みのテンプレートをtbl_promptに登録し、すでにある行は上書きしない
+func TestSeedPromptTable(t *testing.T) {
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+
+	embedded := &PromptSet{templates: map[string]map[string]*PromptTemplate{}}
+	if err := embedded.addFS(defaultPromptFS, "prompts", "embed"); err != nil {
+		t.Fatalf("UT-PROMPT-004b FAIL: %v", err)
+	}
+	for _, tmpl := range embedded.List() {
+		mock.ExpectExec(`INSERT INTO tbl_prompt .* ON CONFLICT \(prmnam, prmver\) DO NOTHING`).
+			WithArgs(tmpl.Name, tmpl.Version, tmpl.Text).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	if err := seedPromptTable(mockDB); err != nil {
+		t.Fatalf("UT-PROMPT-004b FAIL: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-PROMPT-004b FAIL: 埋め込みのテンプレートをすべて登録するはず: %v", err)
+	}
+}
+
+// UT-PROMPT-005: 解析に使ったプロンプトのバージョンが使用量の記録に渡る
+func TestAnalyzeSkills_RecordsPromptVersion(t *testing.T) {
+	resetPromptSet(t)
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, PromptAnalysis), 0755)
+	os.WriteFile(filepath.Join(dir, PromptAnalysis, "v2.tmpl"), []byte("JSONで返してください"), 0644)
+	t.Setenv("PROMPT_DIR", dir)
+	t.Setenv("PROMPT_VERSIONS", "analysis=v2")
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	provider := newMockProvider()
+	originalProvider := llmProvider
+	llmProvider = provider
+	defer func() { llmProvider = originalProvider }()
+
+	mock.ExpectExec("INSERT INTO tbl_aiusage").
+		WithArgs(ProviderMock, "mock", AIPurposeAnalysis, "v2", 0, 0, 0, sqlmock.AnyArg(), "ok", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	if _, err := analyzeSkillsStream(context.Background(), "Java 3年", nil); err != nil {
+		t.Fatalf("UT-PROMPT-005 FAIL: %v", err)
+	}
+	if provider.received[0][0].Content != "JSONで返してください" {
+		t.Errorf("UT-PROMPT-005 FAIL: v2のプロンプトが使われていない: %s", provider.received[0][0].Content)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-PROMPT-005 FAIL: バージョンが記録されていない: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================
+// UT-RANK テストケース
+// ranking.go のマッチ理由の内訳のテスト
+// ============================================================
+
+// UT-RANK-001: スキルごと・項目ごとの内訳と最終スコア
+func TestExplainProjectSkills(t *testing.T) {
+	p := Project{
+		Title:  "【Go】API開発",
+		Skills: "Golang, AWS",
+		Detail: "Go言語でのマイクロサービス開発。Google Cloudの経験尚可",
+	}
+
+	explanation := explainProjectSkills(p, compileSkillPatterns([]string{"Go", "AWS", "PHP"}), defaultRankingProfile())
+
+	if explanation.MatchCount != 2 || len(explanation.Skills) != 2 {
+		t.Fatalf("UT-RANK-001 FAIL: GoとAWSの2スキルがマッチするはず: %+v", explanation)
+	}
+	goMatch := explanation.Skills[0]
+	if goMatch.Skill != "Go" || goMatch.Score != 9 || len(goMatch.Fields) != 3 {
+		t.Errorf("UT-RANK-001 FAIL: Goはタイトル・スキル欄・詳細で9点のはず: %+v", goMatch)
+	}
+	if goMatch.Fields[0].Field != "prottl" || goMatch.Fields[1].Field != "proot1" || goMatch.Fields[2].Field != "prodtl" {
+		t.Errorf("UT-RANK-001 FAIL: 項目のカラム名が不正: %+v", goMatch.Fields)
+	}
+	if awsMatch := explanation.Skills[1]; awsMatch.Score != 3 {
+		t.Errorf("UT-RANK-001 FAIL: AWSはスキル欄のみで3点のはず: %+v", awsMatch)
+	}
+	if explanation.SkillScore != 12 || explanation.MultiSkillBonus != 4 || explanation.FinalScore != 16 {
+		t.Errorf("UT-RANK-001 FAIL: 期待 12+4=16, 実際 %+v", explanation)
+	}
+}
+
+// UT-RANK-002: 検索結果の案件に内訳が付き、単価の点数も最終スコアに入る
+func TestSearchProjectsWithParams_MatchExplanation(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	columns := []string{"prourl", "prottl", "prodtl", "proprc", "proprd", "proot1", "proot2", "prostn", "procrt"}
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("https://test.com/1", "【Java】バックエンド開発", "Spring Boot", "80万円", "長期", "Java", nil, "site-a", "2024-12-01"))
+
+	projects, err := searchProjectsWithParams(SearchParams{
+		KeySkills:     []string{"Java"},
+		DesiredSalary: &SalaryRange{Min: 700000, Max: 900000},
+	})
+	if err != nil {
+		t.Fatalf("UT-RANK-002 FAIL: エラーが発生: %v", err)
+	}
+	if len(projects) != 1 || projects[0].Match == nil {
+		t.Fatalf("UT-RANK-002 FAIL: 内訳が付いていない: %+v", projects)
+	}
+
+	match := projects[0].Match
+	if match.SkillScore != 8 || match.MultiSkillBonus != 2 || match.PriceScore != priceScoreInRange {
+		t.Errorf("UT-RANK-002 FAIL: 内訳が不正: %+v", match)
+	}
+	if match.FinalScore != 8+2+priceScoreInRange {
+		t.Errorf("UT-RANK-002 FAIL: 最終スコアは内訳の合計のはず: %d", match.FinalScore)
+	}
+}
+
+// UT-RANK-003: 構造化スキルは経験年数と重点スキルかどうかで重みを付け、重みの高い順に上限まで照合する
+func TestWeightedSearchSkills(t *testing.T) {
+	params := SearchParams{
+		KeySkills: []string{"Java"},
+		Skills: []Skill{
+			{SkillName: "Python", ExperienceYears: 1},
+			{SkillName: "Java", ExperienceYears: 10},
+			{SkillName: "AWS", ExperienceYears: 3},
+			{SkillName: "Docker", ExperienceYears: 0.5},
+		},
+	}
+
+	skills := weightedSearchSkills(params)
+	want := []searchSkill{{"Java", 4.5}, {"AWS", 1.6}, {"Python", 1.2}, {"Docker", 1.1}}
+	if len(skills) != len(want) {
+		t.Fatalf("UT-RANK-003 FAIL: 期待 %v, 実際 %v", want, skills)
+	}
+	for i := range want {
+		if skills[i] != want[i] {
+			t.Errorf("UT-RANK-003 FAIL: %d番目 期待 %v, 実際 %v", i+1, want[i], skills[i])
+		}
+	}
+
+	profile := defaultRankingProfile()
+	profile.MaxSearchSkills = 2
+	params.Ranking = &profile
+	if names := searchSkillsForParams(params); len(names) != 2 || names[0] != "Java" || names[1] != "AWS" {
+		t.Errorf("UT-RANK-003 FAIL: max_search_skillsで打ち切るべき: %v", names)
+	}
+
+	if skills := weightedSearchSkills(SearchParams{KeySkills: []string{"Java"}}); len(skills) != 1 || skills[0].Weight != 1 {
+		t.Errorf("UT-RANK-003 FAIL: 構造化スキルがなければ重みは1のはず: %v", skills)
+	}
+	if skills := weightedSearchSkills(SearchParams{Skills: params.Skills}); skills != nil {
+		t.Errorf("UT-RANK-003 FAIL: 重点スキルがなければ検索しないはず: %v", skills)
+	}
+}
+
+// UT-RANK-004: 経験の長いスキルの案件が上に来て、マッチしなければ経験の浅いスキルの案件も返す
+func TestSearchProjectsWithParams_WeightedSkills(t *testing.T) {
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	params := SearchParams{
+		KeySkills: []string{"Java"},
+		Skills: []Skill{
+			{SkillName: "Java", ExperienceYears: 10},
+			{SkillName: "Python", ExperienceYears: 1},
+		},
+	}
+
+	// Javaはタイトル5点×4.5=23点、Pythonは5点×1.2=6点。足切りは重みなしの点数で行う
+	mock.ExpectQuery(`THEN 23 ELSE 0 END(.|\n)+THEN 6 ELSE 0 END(.|\n)+WHERE base_score >= 4`).WillReturnRows(sqlmock.NewRows(projectColumns).
+		AddRow("https://a.com/1", "Pythonでのデータ分析", "詳細", "70万円", nil, "Python", nil, "siteA", "2026-10-01").
+		AddRow("https://b.com/1", "Javaでの業務システム開発", "詳細", "70万円", nil, "Java", nil, "siteB", "2026-09-30"))
+	projects, err := searchProjectsWithParams(params)
+	if err != nil {
+		t.Fatalf("UT-RANK-004 FAIL: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-RANK-004 FAIL: SQLに重み付きの配点が入っていない: %v", err)
+	}
+	if len(projects) != 2 || projects[0].URL != "https://b.com/1" {
+		t.Fatalf("UT-RANK-004 FAIL: Javaの案件が先のはず: %+v", projects)
+	}
+	if m := projects[0].Match; m == nil || m.SkillScore != 23+14 || m.Skills[0].Weight != 4.5 {
+		t.Errorf("UT-RANK-004 FAIL: 内訳に重み付きの点数を使うべき: %+v", m)
+	}
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(projectColumns).
+		AddRow("https://a.com/1", "Pythonでのデータ分析", "詳細", "70万円", nil, "Python", nil, "siteA", "2026-10-01"))
+	projects, err = searchProjectsWithParams(params)
+	if err != nil || len(projects) != 1 || projects[0].URL != "https://a.com/1" {
+		t.Errorf("UT-RANK-004 FAIL: Javaの案件がなければPythonの案件を返すはず: %v %+v", err, projects)
+	}
+}
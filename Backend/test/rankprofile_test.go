@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-RANKPROF テストケース
+// rankprofile.go のランキングプロファイルのテスト
+// ============================================================
+
+// RANKING_PROFILE_FILEを設定してプロファイルを読み直す（終わったら既定値に戻す）
+func useRankingProfileFile(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ranking.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("ファイル作成エラー: %v", err)
+	}
+	t.Setenv("RANKING_PROFILE_FILE", path)
+	t.Cleanup(func() {
+		os.Unsetenv("RANKING_PROFILE_FILE")
+		reloadRankingProfile()
+	})
+}
+
+// UT-RANKPROF-001: ファイルに書いた項目だけを既定値に重ね、不正なファイルは前回のプロファイルのまま
+func TestLoadRankingProfile(t *testing.T) {
+	useRankingProfileFile(t, `{"name": "title-heavy", "title_weight": 8, "result_limit": 12}`)
+	profile, err := reloadRankingProfile()
+	if err != nil {
+		t.Fatalf("UT-RANKPROF-001 FAIL: %v", err)
+	}
+	want := defaultRankingProfile()
+	want.Name, want.TitleWeight, want.ResultLimit = "title-heavy", 8, 12
+	if profile != want || getRankingProfile() != want {
+		t.Errorf("UT-RANKPROF-001 FAIL: 期待 %+v, 実際 %+v", want, profile)
+	}
+
+	for _, content := range []string{`{"title_wieght": 8}`, `{"result_limit": 0}`, `{"max_key_skills": 50}`, `{"detail_weight": -1}`} {
+		os.WriteFile(os.Getenv("RANKING_PROFILE_FILE"), []byte(content), 0o644)
+		if _, err := reloadRankingProfile(); err == nil {
+			t.Errorf("UT-RANKPROF-001 FAIL: %s はエラーになるべき", content)
+		}
+	}
+	if getRankingProfile() != want {
+		t.Error("UT-RANKPROF-001 FAIL: 読み直しに失敗したら前回のプロファイルを使い続けるべき")
+	}
+}
+
+// UT-RANKPROF-002: 配点・足切り・サイトごとの件数・返す件数・重点スキルの数にプロファイルの値を使う
+func TestRankingProfile_Search(t *testing.T) {
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	profile := defaultRankingProfile()
+	profile.TitleWeight, profile.MultiSkillBonus, profile.MinScore = 10, 4, 7
+	profile.PerSourceLimit, profile.ResultLimit, profile.MaxKeySkills = 1, 2, 1
+
+	params := SearchParams{KeySkills: []string{"Go", "AWS"}, Ranking: &profile}
+	if skills := searchSkillsForParams(params); len(skills) != 1 || skills[0] != "Go" {
+		t.Errorf("UT-RANKPROF-002 FAIL: 重点スキルは1個まで: %v", skills)
+	}
+
+	mock.ExpectQuery(`THEN 10 ELSE 0 END(.|\n)+\* 4\) as match_score(.|\n)+base_score >= 7`).WillReturnRows(sqlmock.NewRows(projectColumns).
+		AddRow("https://a.com/1", "Goでのバックエンド開発", "API開発", "80万円", nil, "Go", nil, "siteA", "2026-10-01").
+		AddRow("https://a.com/2", "Go案件", "詳細", "70万円", nil, "Go", nil, "siteA", "2026-09-30").
+		AddRow("https://b.com/1", "Go API", "詳細", "70万円", nil, "", nil, "siteB", "2026-09-29").
+		AddRow("https://c.com/1", "Goツール", "詳細", "70万円", nil, "", nil, "siteC", "2026-09-28"))
+
+	projects, err := searchProjectsWithParams(params)
+	if err != nil {
+		t.Fatalf("UT-RANKPROF-002 FAIL: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-RANKPROF-002 FAIL: SQLにプロファイルの値が入っていない: %v", err)
+	}
+	if len(projects) != 2 || projects[0].URL != "https://a.com/1" || projects[1].URL != "https://b.com/1" {
+		t.Fatalf("UT-RANKPROF-002 FAIL: 1サイト1件・2件まで: %+v", projects)
+	}
+	if m := projects[0].Match; m == nil || m.SkillScore != 13 || m.MultiSkillBonus != 4 {
+		t.Errorf("UT-RANKPROF-002 FAIL: 内訳にプロファイルの配点を使うべき: %+v", m)
+	}
+}
+
+// UT-RANKPROF-003: ranking_profileの上書きは管理者トークン付きのリクエストだけ受け付け、使った値を返す
+func TestHandleChat_RankingProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	router := gin.New()
+	router.POST("/api/chat", handleChat)
+	post := func(body ChatRequest, token string) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/api/chat", bytes.NewReader(raw))
+		if token != "" {
+			req.Header.Set("X-Admin-Token", token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	override := json.RawMessage(`{"name": "experiment", "result_limit": 1}`)
+
+	if w := post(ChatRequest{Message: "Java 5年", Analyzer: AnalyzerRule, RankingProfile: override}, ""); w.Code != 403 {
+		t.Errorf("UT-RANKPROF-003 FAIL: トークンなしは 403, 実際 %d", w.Code)
+	}
+	if w := post(ChatRequest{Message: "Java 5年", Analyzer: AnalyzerRule, RankingProfile: json.RawMessage(`{"per_source_limit": 0}`)}, "secret"); w.Code != 400 {
+		t.Errorf("UT-RANKPROF-003 FAIL: 不正な値は 400, 実際 %d", w.Code)
+	}
+
+	for _, tt := range []struct {
+		override json.RawMessage
+		wantName string
+		wantLen  int
+	}{
+		{override, "experiment", 1},
+		{nil, "default", 2},
+	} {
+		mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(projectColumns).
+			AddRow("https://a.com/1", "Java開発", "詳細", "80万円", nil, "Java", nil, "siteA", "2026-10-01").
+			AddRow("https://b.com/1", "Java保守", "詳細", "70万円", nil, "Java", nil, "siteB", "2026-09-30"))
+		mock.ExpectExec("INSERT INTO tbl_session").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := post(ChatRequest{Message: "Java 5年", Analyzer: AnalyzerRule, RankingProfile: tt.override}, "secret")
+		if w.Code != 200 {
+			t.Fatalf("UT-RANKPROF-003 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+		}
+		var resp ChatResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.RankingProfile.Name != tt.wantName || resp.RankingProfile.Overridden != (tt.override != nil) || len(resp.Projects) != tt.wantLen {
+			t.Errorf("UT-RANKPROF-003 FAIL: %s: プロファイル %+v, %d件", tt.wantName, resp.RankingProfile, len(resp.Projects))
+		}
+		if !strings.Contains(w.Body.String(), `"ranking_profile":{"name"`) {
+			t.Errorf("UT-RANKPROF-003 FAIL: レスポンスにranking_profileがない")
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// ============================================================
+// UT-RERANK テストケース
+// rerank.go のAIによる候補の並べ直しのテスト
+// ============================================================
+
+// テスト用の候補（タイトルだけ変える）
+func rerankTestCandidates(titles ...string) []rankedProject {
+	var candidates []rankedProject
+	for _, title := range titles {
+		candidates = append(candidates, rankedProject{Project: Project{URL: "https://test.com/" + title, Title: title, Source: title}})
+	}
+	return candidates
+}
+
+// UT-RERANK-001: AIの適合度の高い順に並べ直し、理由を付ける
+func TestRerankCandidates_Reorders(t *testing.T) {
+	provider := newMockProvider(`{"rankings": [
+		{"id": 0, "score": 20, "reason": "Javaは補助的な扱い"},
+		{"id": 1, "score": 90, "reason": "Javaでのバックエンド開発が中心"},
+		{"id": 2, "score": 55.6, "reason": "一部Java"}
+	]}`)
+	originalProvider := llmProvider
+	llmProvider = provider
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("RERANK_ENABLED", "true")
+
+	reranked, ok := rerankCandidates(context.Background(), AIAnalysis{KeySkills: []string{"Java"}}, rerankTestCandidates("a", "b", "c"))
+	if !ok {
+		t.Fatal("UT-RERANK-001 FAIL: 並べ直されるべき")
+	}
+
+	order := reranked[0].Title + reranked[1].Title + reranked[2].Title
+	if order != "bca" {
+		t.Errorf("UT-RERANK-001 FAIL: 期待 bca, 実際 %s", order)
+	}
+	if reranked[0].RelevanceScore == nil || *reranked[0].RelevanceScore != 90 || reranked[0].RelevanceReason != "Javaでのバックエンド開発が中心" {
+		t.Errorf("UT-RERANK-001 FAIL: 適合度と理由が付いていない: %+v", reranked[0].Project)
+	}
+	if *reranked[1].RelevanceScore != 56 {
+		t.Errorf("UT-RERANK-001 FAIL: 適合度は四捨五入した整数のはず: %d", *reranked[1].RelevanceScore)
+	}
+}
+
+// UT-RERANK-002: 評価されなかった候補・不正なIDの扱い
+func TestApplyRerank_MissingAndInvalidIDs(t *testing.T) {
+	reranked := applyRerank(rerankTestCandidates("a", "b", "c"), []rerankResult{
+		{ID: 2, Score: 80, Reason: "ok"},
+		{ID: 2, Score: 10, Reason: "重複"},
+		{ID: 9, Score: 100, Reason: "存在しない"},
+	})
+
+	order := reranked[0].Title + reranked[1].Title + reranked[2].Title
+	if order != "cab" {
+		t.Errorf("UT-RERANK-002 FAIL: 評価なしの候補は元の順で後ろに並ぶべき: %s", order)
+	}
+	if *reranked[0].RelevanceScore != 80 {
+		t.Errorf("UT-RERANK-002 FAIL: 同じIDは最初の評価を使うべき: %d", *reranked[0].RelevanceScore)
+	}
+	if reranked[1].RelevanceScore != nil {
+		t.Error("UT-RERANK-002 FAIL: 評価なしの候補に適合度が付いている")
+	}
+}
+
+// UT-RERANK-003: 無効化されていればAIを呼ばない
+func TestRerankCandidates_Disabled(t *testing.T) {
+	provider := newMockProvider()
+	originalProvider := llmProvider
+	llmProvider = provider
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("RERANK_ENABLED", "false")
+
+	candidates := rerankTestCandidates("a", "b")
+	reranked, ok := rerankCandidates(context.Background(), AIAnalysis{}, candidates)
+	if ok || provider.Calls() != 0 {
+		t.Errorf("UT-RERANK-003 FAIL: 無効時はAIを呼ばないはず（%d回）", provider.Calls())
+	}
+	if reranked[0].Title != "a" {
+		t.Error("UT-RERANK-003 FAIL: 無効時は元の並びのはず")
+	}
+}
+
+// UT-RERANK-004: 不正な返答なら元の並びのまま
+func TestRerankCandidates_InvalidResponse(t *testing.T) {
+	originalProvider := llmProvider
+	llmProvider = newMockProvider(`{"rankings": [{"id": "first", "score": 90}]}`)
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("RERANK_ENABLED", "true")
+
+	reranked, ok := rerankCandidates(context.Background(), AIAnalysis{}, rerankTestCandidates("a", "b"))
+	if ok {
+		t.Error("UT-RERANK-004 FAIL: スキーマ違反の返答では並べ直さないはず")
+	}
+	if reranked[0].Title != "a" || reranked[0].RelevanceScore != nil {
+		t.Error("UT-RERANK-004 FAIL: 元の並びのまま返すべき")
+	}
+}
+
+// UT-RERANK-005: タイムアウトしたらSQLの並びのまま
+func TestRerankCandidates_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer mockServer.Close()
+	defer close(release)
+
+	t.Setenv("LLM_PROVIDER", "openrouter")
+	t.Setenv("OPENROUTER_API_KEY", "test-key")
+	t.Setenv("LLM_BASE_URL", mockServer.URL)
+	t.Setenv("RERANK_ENABLED", "true")
+	t.Setenv("RERANK_TIMEOUT", "50ms")
+
+	start := time.Now()
+	reranked, ok := rerankCandidates(context.Background(), AIAnalysis{}, rerankTestCandidates("a", "b"))
+	if ok || reranked[0].Title != "a" {
+		t.Error("UT-RERANK-005 FAIL: タイムアウト時は元の並びのはず")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("UT-RERANK-005 FAIL: タイムアウトで打ち切られていない: %v", elapsed)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// ============================================================
+// UT-RESUME テストケース
+// resume.go / pdftext.go の職務経歴書のアップロードとテキスト抽出のテスト
+// ============================================================
+
+// zipファイル（.docx / .xlsx）を組み立てる
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip作成エラー: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+// FlateDecodeのコンテンツストリームとToUnicode CMapを持つPDFを組み立てる
+func buildPDF(t *testing.T, content string) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte(content))
+	zw.Close()
+
+	cmap := "/CIDInit /ProcSet findresource begin\nbegincmap\n1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n" +
+		"2 beginbfchar\n<0001> <5E74>\n<0002> <958B767A>\nendbfchar\nendcmap\n"
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	pdf.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	pdf.WriteString("2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>\nendobj\n")
+	pdf.WriteString("3 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>\nendobj\n")
+	fmt.Fprintf(&pdf, "4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	pdf.Write(compressed.Bytes())
+	pdf.WriteString("\nendstream\nendobj\n")
+	pdf.WriteString("5 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n")
+	pdf.WriteString("6 0 obj\n<< /Type /Font /Subtype /Type0 /BaseFont /Gothic /Encoding /Identity-H /ToUnicode 7 0 R >>\nendobj\n")
+	fmt.Fprintf(&pdf, "7 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(cmap), cmap)
+	pdf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+// multipartのリクエストを組み立てる
+func newResumeRequest(t *testing.T, filename string, data []byte, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", filename)
+	part.Write(data)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	mw.Close()
+
+	r := gin.New()
+	r.POST("/api/resume", handleResumeUpload)
+	req := httptest.NewRequest("POST", "/api/resume", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// UT-RESUME-001: .docxの段落・タブ・改行をテキストにする
+func TestExtractDocxText(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>職務経歴書</w:t></w:r></w:p>
+<w:p><w:r><w:t>言語</w:t></w:r><w:r><w:tab/><w:t xml:space="preserve">Java </w:t></w:r><w:r><w:t>5年</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>Spring Boot</w:t><w:br/><w:t>AWS</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+</w:body></w:document>`
+	data := buildZip(t, map[string]string{"word/document.xml": document})
+
+	text, err := extractResumeText("docx", data)
+	if err != nil {
+		t.Fatalf("UT-RESUME-001 FAIL: エラーが発生: %v", err)
+	}
+	want := "職務経歴書\n言語\tJava 5年\nSpring Boot\nAWS"
+	if text != want {
+		t.Errorf("UT-RESUME-001 FAIL: 期待 %q, 実際 %q", want, text)
+	}
+}
+
+// UT-RESUME-002: .xlsxの共有文字列・数値・日付をシートの並び順に取り出す（ふりがなは除く）
+func TestExtractXlsxText(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="経歴" sheetId="1" r:id="rId2"/><sheet name="スキル" sheetId="2" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Target="worksheets/sheet2.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>期間</t></si><si><t>言語</t></si><si><r><t>Go</t></r><r><t>lang</t></r></si>
+<si><t>開発</t><rPh><t>カイハツ</t></rPh></si></sst>`,
+		"xl/styles.xml": `<styleSheet><numFmts><numFmt numFmtId="176" formatCode="yyyy&quot;年&quot;m&quot;月&quot;"/></numFmts>
+<cellXfs><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="176"/></cellXfs></styleSheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>3</v></c></row>
+<row r="2"><c r="A2" s="2"><v>44287</v></c><c r="B2" s="1"><v>45017</v></c><c r="C2" t="inlineStr"><is><t>PM</t></is></c></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+<row r="1"><c r="A1" t="s"><v>1</v></c><c r="B1" t="s"><v>2</v></c><c r="C1"><v>3</v></c></row>
+</sheetData></worksheet>`,
+	})
+
+	text, err := extractResumeText("xlsx", data)
+	if err != nil {
+		t.Fatalf("UT-RESUME-002 FAIL: エラーが発生: %v", err)
+	}
+	want := "■ 経歴\n期間\t開発\n2021/04\t2023/04/01\tPM\n\n■ スキル\n言語\tGolang\t3"
+	if text != want {
+		t.Errorf("UT-RESUME-002 FAIL: 期待 %q, 実際 %q", want, text)
+	}
+}
+
+// UT-RESUME-003: PDFのテキストレイヤーを読む（FlateDecode・ToUnicode CMap・TJ・行送り）
+func TestExtractPDFText(t *testing.T) {
+	content := "BT\n/F1 12 Tf 72 720 Td (Java) Tj [( ) -250 (5)] TJ /F2 12 Tf <0001> Tj\n0 -14 Td /F1 12 Tf (Spring \\(Boot\\)) Tj /F2 12 Tf <0002> Tj\nET"
+	text, err := extractResumeText("pdf", buildPDF(t, content))
+	if err != nil {
+		t.Fatalf("UT-RESUME-003 FAIL: エラーが発生: %v", err)
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "Java") || !strings.Contains(lines[0], "5年") || lines[1] != "Spring (Boot)開発" {
+		t.Errorf("UT-RESUME-003 FAIL: 抽出結果が不正: %q", text)
+	}
+
+	if _, err := extractPDFText([]byte("not a pdf")); err == nil {
+		t.Error("UT-RESUME-003 FAIL: PDFでないデータはエラーになるべき")
+	}
+	encrypted := append(buildPDF(t, "BT (x) Tj ET"), []byte("trailer\n<< /Root 1 0 R /Encrypt 8 0 R >>\n")...)
+	if _, err := extractPDFText(encrypted); err == nil {
+		t.Error("UT-RESUME-003 FAIL: 暗号化されたPDFはエラーになるべき")
+	}
+}
+
+// 壊れたPDF・細工されたPDFの例（UT-RESUME-003bとFuzzExtractPDFTextのシード）
+var malformedPDFs = []string{
+	"%PDF00000000000000000000000000000000 0 obj<<0000000000000000000000000000<",
+	"%PDF-1.4\n1 0 obj\n<< /Length -5 >>\nstream\nabc\nendstream\nendobj\n",
+	"%PDF-1.4\n1 0 obj\n<< /Length 99999999999 >>\nstream\nabc\nendstream\nendobj\n",
+	"%PDF-1.4\n1 0 obj\n<< /Length 3 >>\nstream",
+	"%PDF-1.4\n1 0 obj\n<< /Type /ObjStm /N 1 /First -4 >>\nstream\n1 0 (x)\nendstream\nendobj\n",
+	"%PDF-1.4\n1 0 obj\n<< /Type /ObjStm /N 1 /First 4 >>\nstream\n2 -9 (x)\nendstream\nendobj\n",
+	"%PDF-1.4\n1 0 obj\n<< /Length 9 >>\nstream\nBT ID\nendstream\nendobj\n",
+}
+
+// UT-RESUME-003b: 壊れたPDF・細工されたPDFでもpanicせず、エラーか空のテキストを返す
+func TestExtractPDFText_Malformed(t *testing.T) {
+	for _, data := range malformedPDFs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("UT-RESUME-003b FAIL: %q でpanic: %v", data, r)
+				}
+			}()
+			extractPDFText([]byte(data))
+		}()
+	}
+}
+
+// 任意のバイト列でpanicしないことを確かめる（go test -fuzz=FuzzExtractPDFText）
+func FuzzExtractPDFText(f *testing.F) {
+	for _, data := range malformedPDFs {
+		f.Add([]byte(data))
+	}
+	f.Add([]byte("%PDF-1.4\n1 0 obj\n<< /Length 11 >>\nstream\nBT (x) Tj ET\nendstream\nendobj\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		extractPDFText(data)
+	})
+}
+
+// UT-RESUME-004: テキストファイルはUTF-8（BOM付き）とShift_JISを読める
+func TestDecodePlainText(t *testing.T) {
+	sjis, _ := japanese.ShiftJIS.NewEncoder().Bytes([]byte("Java 5年\r\nAWS"))
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"UTF-8", []byte("Java 5年\nAWS")},
+		{"UTF-8 BOM", append([]byte{0xEF, 0xBB, 0xBF}, []byte("Java 5年\nAWS")...)},
+		{"Shift_JIS", sjis},
+	}
+	for _, tt := range tests {
+		text, err := extractResumeText("txt", tt.data)
+		if err != nil || text != "Java 5年\nAWS" {
+			t.Errorf("UT-RESUME-004 FAIL: %s → %q, %v", tt.name, text, err)
+		}
+	}
+}
+
+// UT-RESUME-005: アップロードしたファイルを解析・検索し、抽出したテキストと一緒に返す
+func TestHandleResumeUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	provider := newMockProvider()
+	originalProvider := llmProvider
+	llmProvider = provider
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("AI_CACHE_ENABLED", "false")
+	t.Setenv("RESUME_MAX_CHARS", "12")
+
+	columns := []string{"prourl", "prottl", "prodtl", "proprc", "proprd", "proot1", "proot2", "prostn", "procrt"}
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("https://test.com/1", "【Java】バックエンド開発", "Spring Boot", "70万円", "長期", "Java", nil, "freelance-start", "2024-12-01"))
+
+	w := newResumeRequest(t, "経歴書.md", []byte("# 職務経歴\n\nJava 5年\nSpring Boot 3年\n"), nil)
+	if w.Code != 200 {
+		t.Fatalf("UT-RESUME-005 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp ResumeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("UT-RESUME-005 FAIL: レスポンスのパースエラー: %v", err)
+	}
+	if resp.FileType != "txt" || resp.FileName != "経歴書.md" || !resp.Truncated {
+		t.Errorf("UT-RESUME-005 FAIL: ファイル情報が不正: %+v", resp)
+	}
+	if resp.ExtractedText != "# 職務経歴\n\nJava" {
+		t.Errorf("UT-RESUME-005 FAIL: 12文字に切り詰められていない: %q", resp.ExtractedText)
+	}
+	if len(resp.Projects) != 1 || len(resp.AIAnalysis.KeySkills) == 0 {
+		t.Errorf("UT-RESUME-005 FAIL: 通常のチャットと同じ結果が返っていない: %+v", resp.ChatResponse)
+	}
+	if len(provider.received) == 0 || !strings.Contains(provider.received[0][len(provider.received[0])-1].Content, "職務経歴\n\nJava") {
+		t.Error("UT-RESUME-005 FAIL: 抽出したテキストがAIに渡されていない")
+	}
+}
+
+// UT-RESUME-006: 未対応の形式は415、上限を超えるサイズは413、中身が不正・空なら422
+func TestHandleResumeUpload_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("RESUME_MAX_BYTES", "1024")
+
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		want     int
+	}{
+		{"未対応の形式", "resume.png", []byte("\x89PNG"), 415},
+		{"サイズ超過", "resume.txt", bytes.Repeat([]byte("a"), 2048), 413},
+		{"壊れたdocx", "resume.docx", []byte("not a zip"), 422},
+		{"画像のみのPDF", "resume.pdf", buildPDF(t, "q 100 0 0 100 0 0 cm /Im1 Do Q"), 422},
+		{"空のテキスト", "resume.txt", []byte("  \n\n "), 422},
+	}
+	for _, tt := range tests {
+		w := newResumeRequest(t, tt.filename, tt.data, nil)
+		if w.Code != tt.want {
+			t.Errorf("UT-RESUME-006 FAIL: %s → 期待 %d, 実際 %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	r := gin.New()
+	r.POST("/api/resume", handleResumeUpload)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/api/resume", strings.NewReader("")))
+	if w.Code != 400 {
+		t.Errorf("UT-RESUME-006 FAIL: fileがなければ400のはず: %d", w.Code)
+	}
+}
+
+// UT-RESUME-007: clear_filters=trueなら、セッションに残った前回の絞り込み条件を引き継がない
+func TestHandleResumeUpload_ClearFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+	t.Setenv("AI_CACHE_ENABLED", "false")
+
+	previous, _ := json.Marshal(SearchParams{Filter: &ProjectFilter{ExcludedSkills: []string{"PHP"}}})
+	for _, clear := range []string{"false", "true"} {
+		now := time.Now()
+		mock.ExpectQuery("FROM tbl_session").WithArgs("resume-session").
+			WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow("resume-session", []byte("[]"), nil, previous, now, now, now.Add(time.Hour)))
+		mock.ExpectQuery("FROM tbl_project").WillReturnRows(sqlmock.NewRows(projectColumns))
+		mock.ExpectExec("INSERT INTO tbl_session").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		w := newResumeRequest(t, "経歴書.txt", []byte("Java 5年"), map[string]string{
+			"session_id": "resume-session", "analyzer": AnalyzerRule, "clear_filters": clear,
+		})
+		if w.Code != 200 {
+			t.Fatalf("UT-RESUME-007 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+		}
+		var resp ResumeResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("UT-RESUME-007 FAIL: レスポンスのパースエラー: %v", err)
+		}
+		kept := resp.Filters != nil && len(resp.Filters.ExcludedSkills) > 0
+		if kept != (clear == "false") {
+			t.Errorf("UT-RESUME-007 FAIL: clear_filters=%s で前回の除外の扱いが不正: %+v", clear, resp.Filters)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-RESUME-007 FAIL: %v", err)
+	}
+}
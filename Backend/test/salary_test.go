@@ -0,0 +1,173 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================
+// UT-SALARY テストケース
+// salary.go の単価の数値化と、ranking.go の希望単価による並べ替えのテスト
+// ============================================================
+
+// UT-SALARY-001: 単価表記のパターン
+func TestParseSalaryRange(t *testing.T) {
+	cases := []struct {
+		text string
+		want SalaryRange
+	}{
+		{"月額60万円〜80万円", SalaryRange{Min: 600000, Max: 800000}},
+		{"月80〜100万円希望", SalaryRange{Min: 800000, Max: 1000000}},
+		{"70-80万円", SalaryRange{Min: 700000, Max: 800000}},
+		{"〜90万円/月", SalaryRange{Max: 900000}},
+		{"80万円", SalaryRange{Min: 800000, Max: 800000}},
+		{"90万円以上", SalaryRange{Min: 900000}},
+		{"400,000円 〜 500,000円", SalaryRange{Min: 400000, Max: 500000}},
+		{"６５万円", SalaryRange{Min: 650000, Max: 650000}},
+		{"時給3,000円〜4,000円", SalaryRange{Min: 480000, Max: 640000}},
+		{"年収960万円", SalaryRange{Min: 800000, Max: 800000}},
+		{"Java3年 月80〜100万円希望", SalaryRange{Min: 800000, Max: 1000000}},
+	}
+	for _, c := range cases {
+		got, ok := parseSalaryRange(c.text)
+		if !ok || got != c.want {
+			t.Errorf("UT-SALARY-001 FAIL: %q 期待 %+v, 実際 %+v (%v)", c.text, c.want, got, ok)
+		}
+	}
+}
+
+// UT-SALARY-002: 金額がなければ読み取らない
+func TestParseSalaryRange_NoAmount(t *testing.T) {
+	for _, text := range []string{"", "応相談", "Java 3〜5年", "スキル見合い"} {
+		if got, ok := parseSalaryRange(text); ok {
+			t.Errorf("UT-SALARY-002 FAIL: %q から金額を読み取ってはいけない: %+v", text, got)
+		}
+	}
+}
+
+// UT-SALARY-003: 希望単価はメッセージ優先、なければAIの推定単価（並べ替えのみ）
+func TestBuildSearchParams_DesiredSalary(t *testing.T) {
+	analysis := AIAnalysis{KeySkills: []string{"Java"}, SalaryRange: &SalaryRange{Min: 600000, Max: 700000}}
+
+	params := buildSearchParams(nil, "Java 5年 月80万円以上希望", analysis)
+	if params.DesiredSalary == nil || params.DesiredSalary.Min != 800000 || !params.PriceFilter {
+		t.Errorf("UT-SALARY-003 FAIL: メッセージの希望単価で絞り込むべき: %+v", params)
+	}
+
+	params = buildSearchParams(nil, "Java 5年", analysis)
+	if params.DesiredSalary == nil || params.DesiredSalary.Min != 600000 || params.PriceFilter {
+		t.Errorf("UT-SALARY-003 FAIL: AIの推定単価は並べ替えだけに使うべき: %+v", params)
+	}
+
+	session := &Session{LastSearch: &SearchParams{DesiredSalary: &SalaryRange{Min: 900000}, PriceFilter: true}}
+	params = buildSearchParams(session, "PHPは除外して", analysis)
+	if params.DesiredSalary == nil || params.DesiredSalary.Min != 900000 || !params.PriceFilter {
+		t.Errorf("UT-SALARY-003 FAIL: 前回の希望単価を引き継ぐべき: %+v", params)
+	}
+}
+
+// UT-SALARY-004: 希望単価に届かない案件を除き、範囲内の案件を上位にする
+func TestSearchProjectsWithParams_PriceRanking(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	columns := []string{"prourl", "prottl", "prodtl", "proprc", "proprd", "proot1", "proot2", "prostn", "procrt"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("https://test.com/cheap", "【Java】案件A", "Java開発", "〜50万円", "長期", "Java", nil, "site-a", "2024-12-03").
+		AddRow("https://test.com/unknown", "【Java】案件B", "Java開発", "スキル見合い", "長期", "Java", nil, "site-b", "2024-12-02").
+		AddRow("https://test.com/fit", "【Java】案件C", "Java開発", "80〜90万円", "長期", "Java", nil, "site-c", "2024-12-01")
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	projects, err := searchProjectsWithParams(SearchParams{
+		KeySkills:     []string{"Java"},
+		DesiredSalary: &SalaryRange{Min: 800000, Max: 1000000},
+		PriceFilter:   true,
+	})
+	if err != nil {
+		t.Fatalf("UT-SALARY-004 FAIL: エラーが発生: %v", err)
+	}
+
+	if len(projects) != 2 {
+		t.Fatalf("UT-SALARY-004 FAIL: 希望単価に届かない案件だけ除くべき: %+v", projects)
+	}
+	if projects[0].URL != "https://test.com/fit" {
+		t.Errorf("UT-SALARY-004 FAIL: 希望単価の範囲内の案件が先頭になるべき: %s", projects[0].URL)
+	}
+	if projects[0].PriceRange == nil || projects[0].PriceRange.Max != 900000 {
+		t.Errorf("UT-SALARY-004 FAIL: 案件の単価が数値化されていない: %+v", projects[0].PriceRange)
+	}
+	if projects[1].PriceRange != nil {
+		t.Errorf("UT-SALARY-004 FAIL: 読み取れない単価はnilのはず: %+v", projects[1].PriceRange)
+	}
+}
+
+// UT-SALARY-005: 1サイトあたり3件まで
+func TestSearchProjectsWithParams_PerSiteLimit(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	columns := []string{"prourl", "prottl", "prodtl", "proprc", "proprd", "proot1", "proot2", "prostn", "procrt"}
+	rows := sqlmock.NewRows(columns)
+	for i := 0; i < 5; i++ {
+		rows.AddRow("https://a.com/"+string(rune('0'+i)), "【Java】案件", "Java開発", "70万円", "長期", "Java", nil, "site-a", "2024-12-01")
+	}
+	rows.AddRow("https://b.com/1", "【Java】案件", "Java開発", "70万円", "長期", "Java", nil, "site-b", "2024-12-01")
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	projects, err := searchProjectsWithParams(SearchParams{KeySkills: []string{"Java"}})
+	if err != nil {
+		t.Fatalf("UT-SALARY-005 FAIL: エラーが発生: %v", err)
+	}
+	if len(projects) != 4 {
+		t.Errorf("UT-SALARY-005 FAIL: site-a 3件 + site-b 1件のはず, 実際 %d件", len(projects))
+	}
+}
+
+// UT-SALARY-006: 希望単価は単価・月額・希望などの言い回しがあるときだけ読み取る
+func TestParseDesiredSalary(t *testing.T) {
+	cases := []struct {
+		message string
+		want    SalaryRange
+	}{
+		{"Java 5年 月80万円以上希望", SalaryRange{Min: 800000}},
+		{"単価は70〜80万でお願いします", SalaryRange{Min: 700000, Max: 800000}},
+		{"Go 3年、90万円/月", SalaryRange{Min: 900000, Max: 900000}},
+		{"80万円希望です", SalaryRange{Min: 800000, Max: 800000}},
+		{"月間100万PVのサービスを担当、単価80万円以上", SalaryRange{Min: 800000}},
+	}
+	for _, c := range cases {
+		got, ok := parseDesiredSalary(c.message)
+		if !ok || got != c.want {
+			t.Errorf("UT-SALARY-006 FAIL: %q 期待 %+v, 実際 %+v (%v)", c.message, c.want, got, ok)
+		}
+	}
+
+	for _, message := range []string{
+		"月間100万PVのWebサービスを開発 Java 5年",
+		"1000万ユーザー規模のアプリ開発経験あり",
+		"予算300万円のPJでPMを担当",
+	} {
+		if got, ok := parseDesiredSalary(message); ok {
+			t.Errorf("UT-SALARY-006 FAIL: %q を希望単価にしてはいけない: %+v", message, got)
+		}
+		params := buildSearchParams(nil, message, AIAnalysis{KeySkills: []string{"Java"}})
+		if params.PriceFilter {
+			t.Errorf("UT-SALARY-006 FAIL: %q で単価の絞り込みをしてはいけない: %+v", message, params)
+		}
+	}
+}
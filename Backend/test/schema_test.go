@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// ============================================================
+// UT-SCHEMA テストケース
+// schema.go のスキーマ検証と、analyzeSkills の修正ループのテスト
+// ============================================================
+
+const validAnalysisJSON = `{
+	"estimated_salary": "月額60万円〜80万円",
+	"strengths": "Java経験が豊富",
+	"suggestions": "AWSの資格取得を推奨",
+	"structured_skills": [{"skill_name": "Java", "experience_years": 5}],
+	"search_prompt": "Java Spring Boot 開発",
+	"key_skills": ["Java", "Spring Boot"],
+	"preferred_role": "バックエンドエンジニア",
+	"experience_level": "上級"
+}`
+
+// UT-SCHEMA-001: 正常系：スキーマを満たすJSON
+func TestParseAIAnalysis_Valid(t *testing.T) {
+	analysis, errs := parseAIAnalysis(validAnalysisJSON)
+	if len(errs) != 0 {
+		t.Fatalf("UT-SCHEMA-001 FAIL: 検証エラーが発生: %v", errs)
+	}
+	if analysis.ExperienceLevel != "上級" || len(analysis.StructuredSkills) != 1 {
+		t.Errorf("UT-SCHEMA-001 FAIL: パース結果が不正: %+v", analysis)
+	}
+}
+
+// UT-SCHEMA-002: コードブロックや説明文で囲まれていても取り出せる
+func TestParseAIAnalysis_WrappedJSON(t *testing.T) {
+	wrapped := "以下が分析結果です。\n```json\n" + validAnalysisJSON + "\n```"
+	if _, errs := parseAIAnalysis(wrapped); len(errs) != 0 {
+		t.Errorf("UT-SCHEMA-002 FAIL: 検証エラーが発生: %v", errs)
+	}
+}
+
+// UT-SCHEMA-003: 異常系：必須フィールド不足・型違い・enum違反
+func TestParseAIAnalysis_Invalid(t *testing.T) {
+	cases := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"必須フィールド不足", strings.Replace(validAnalysisJSON, `"preferred_role": "バックエンドエンジニア",`, "", 1), "preferred_role"},
+		{"型違い", strings.Replace(validAnalysisJSON, `"experience_years": 5`, `"experience_years": "5年"`, 1), "$.structured_skills[0].experience_years"},
+		{"enum違反", strings.Replace(validAnalysisJSON, `"上級"`, `"シニア"`, 1), "$.experience_level"},
+		{"配列要素の型違い", strings.Replace(validAnalysisJSON, `["Java", "Spring Boot"]`, `["Java", 1]`, 1), "$.key_skills[1]"},
+		{"負の経験年数", strings.Replace(validAnalysisJSON, `"experience_years": 5`, `"experience_years": -1`, 1), "0以上"},
+		{"JSONではない", `分析できませんでした`, "有効なJSONではありません"},
+	}
+
+	for _, c := range cases {
+		_, errs := parseAIAnalysis(c.json)
+		if len(errs) == 0 {
+			t.Errorf("UT-SCHEMA-003 FAIL (%s): 検証エラーになるべき", c.name)
+			continue
+		}
+		if !strings.Contains(strings.Join(errs, "\n"), c.want) {
+			t.Errorf("UT-SCHEMA-003 FAIL (%s): エラーに %s が含まれるべき: %v", c.name, c.want, errs)
+		}
+	}
+}
+
+// UT-SCHEMA-004: 不正な返答を差し戻して修正された返答を採用する
+func TestAnalyzeSkills_RepairSuccess(t *testing.T) {
+	mock := newMockProvider(`{"estimated_salary": "60万円"}`, validAnalysisJSON)
+	originalProvider := llmProvider
+	llmProvider = mock
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("LLM_REPAIR_ATTEMPTS", "2")
+
+	analysis, err := analyzeSkills("Java 5年")
+	if err != nil {
+		t.Fatalf("UT-SCHEMA-004 FAIL: 修正後の返答が採用されるべき: %v", err)
+	}
+	if analysis.ExperienceLevel != "上級" {
+		t.Errorf("UT-SCHEMA-004 FAIL: 修正後の内容が返るべき: %+v", analysis)
+	}
+	if mock.Calls() != 2 {
+		t.Errorf("UT-SCHEMA-004 FAIL: 期待 呼び出し回数 2, 実際 %d", mock.Calls())
+	}
+
+	// 2回目のリクエストには前回の返答と検証エラーが含まれる
+	second := mock.received[1]
+	last := second[len(second)-1]
+	if second[len(second)-2].Role != "assistant" || !strings.Contains(last.Content, "必須フィールド strengths") {
+		t.Errorf("UT-SCHEMA-004 FAIL: 修正依頼に検証エラーが含まれるべき: %s", last.Content)
+	}
+}
+
+// UT-SCHEMA-005: 修正回数の上限を超えたらエラー
+func TestAnalyzeSkills_RepairExhausted(t *testing.T) {
+	mock := newMockProvider(`{"experience_level": "シニア"}`)
+	originalProvider := llmProvider
+	llmProvider = mock
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("LLM_REPAIR_ATTEMPTS", "1")
+
+	_, err := analyzeSkills("Java 5年")
+	if err == nil {
+		t.Fatal("UT-SCHEMA-005 FAIL: 修正できない場合はエラーが返るべき")
+	}
+	if mock.Calls() != 2 {
+		t.Errorf("UT-SCHEMA-005 FAIL: 期待 呼び出し回数 2（初回+修正1回）, 実際 %d", mock.Calls())
+	}
+	if !strings.Contains(err.Error(), "experience_level") {
+		t.Errorf("UT-SCHEMA-005 FAIL: エラーに検証内容が含まれるべき: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================
+// UT-SEARCHBE テストケース
+// searchbackend.go の検索バックエンドの切り替えのテスト
+// ============================================================
+
+// 候補の取り出しの結果（どちらのバックエンドでも同じ行を返す）
+func searchBackendRows() *sqlmock.Rows {
+	return sqlmock.NewRows(projectColumns).
+		AddRow("https://test.com/1", "Goでのバックエンド開発", "API開発", "80万円", nil, "Go", nil, "test", "2026-10-01").
+		AddRow("https://test.com/2", "Java業務システム", "Goも少し", "60万円", nil, "Java", nil, "test", "2026-09-01")
+}
+
+// UT-SEARCHBE-001: trgmはエイリアスの部分一致で絞り、採点にはregexと同じ正規表現を使う
+func TestBuildTrgmCandidateQuery(t *testing.T) {
+	query, args := buildTrgmCandidateQuery(candidateQuery{Skills: []searchSkill{{Name: "Go", Weight: 1}, {Name: "Java", Weight: 1}}, Profile: defaultRankingProfile()})
+	dict := getSkillDictionary()
+
+	want := []interface{}{"%go%", "%golang%", "%go言語%", "%ゴー言語%", dict.Pattern("Go"), "%java%", "%ジャバ%", dict.Pattern("Java")}
+	if len(args) != len(want) {
+		t.Fatalf("UT-SEARCHBE-001 FAIL: 引数 期待 %v, 実際 %v", want, args)
+	}
+	for i := range want {
+		if args[i] != want[i] {
+			t.Errorf("UT-SEARCHBE-001 FAIL: 引数%d 期待 %v, 実際 %v", i+1, want[i], args[i])
+		}
+	}
+	for _, part := range []string{"prottl ILIKE $1 OR proot1 ILIKE $1 OR prodtl ILIKE $1", "prottl ~* $5 AS t0", "prodtl ~* $8 AS d1", "WHERE base_score >= 4", "LIMIT 50"} {
+		if !strings.Contains(query, part) {
+			t.Errorf("UT-SEARCHBE-001 FAIL: %q がない:\n%s", part, query)
+		}
+	}
+
+	if got := escapeLikePattern(`100%_a\b`); got != `100\%\_a\\b` {
+		t.Errorf("UT-SEARCHBE-001 FAIL: LIKEのエスケープ 実際 %s", got)
+	}
+}
+
+// UT-SEARCHBE-002: SEARCH_BACKENDで取り出しのSQLが切り替わり、並べ替えの結果は変わらない
+func TestSearchBackend_Switch(t *testing.T) {
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	params := SearchParams{KeySkills: []string{"Go"}}
+	var results [][]Project
+	for _, backend := range []string{SearchBackendRegex, SearchBackendTrgm} {
+		t.Setenv("SEARCH_BACKEND", backend)
+		if backend == SearchBackendTrgm {
+			mock.ExpectQuery("ILIKE").WillReturnRows(searchBackendRows())
+		} else {
+			mock.ExpectQuery(`WITH scored_projects AS \(\s+SELECT`).WillReturnRows(searchBackendRows())
+		}
+		projects, err := searchProjectsWithParams(params)
+		if err != nil {
+			t.Fatalf("UT-SEARCHBE-002 FAIL: %s: %v", backend, err)
+		}
+		results = append(results, projects)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-SEARCHBE-002 FAIL: %v", err)
+	}
+
+	if len(results[0]) != 2 || len(results[0]) != len(results[1]) {
+		t.Fatalf("UT-SEARCHBE-002 FAIL: 件数が違う: %d / %d", len(results[0]), len(results[1]))
+	}
+	for i := range results[0] {
+		if results[0][i].URL != results[1][i].URL {
+			t.Errorf("UT-SEARCHBE-002 FAIL: %d件目が違う: %s / %s", i+1, results[0][i].URL, results[1][i].URL)
+		}
+	}
+}
+
+// UT-SEARCHBE-003: SEARCH_BACKEND_COMPAREがtrueなら、もう一方のバックエンドでも検索する
+func TestSearchBackend_Compare(t *testing.T) {
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	t.Setenv("SEARCH_BACKEND", SearchBackendTrgm)
+	t.Setenv("SEARCH_BACKEND_COMPARE", "true")
+	mock.ExpectQuery("ILIKE").WillReturnRows(searchBackendRows())
+	mock.ExpectQuery(`WITH scored_projects AS \(\s+SELECT`).WillReturnRows(searchBackendRows())
+
+	projects, err := searchProjectsWithParams(SearchParams{KeySkills: []string{"Go"}})
+	if err != nil || len(projects) != 2 {
+		t.Fatalf("UT-SEARCHBE-003 FAIL: %v %d件", err, len(projects))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-SEARCHBE-003 FAIL: 比較の検索をしていない: %v", err)
+	}
+}
+
+// UT-SEARCHBE-004: 不明なバックエンドは起動時にエラー、trgmはインデックスがなくても起動できる
+func TestCheckSearchBackend(t *testing.T) {
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+
+	t.Setenv("SEARCH_BACKEND", "fulltext")
+	if err := checkSearchBackend(mockDB); err == nil {
+		t.Error("UT-SEARCHBE-004 FAIL: 不明なバックエンドはエラーになるべき")
+	}
+	if _, err := searchCandidates(SearchParams{KeySkills: []string{"Go"}}); err == nil {
+		t.Error("UT-SEARCHBE-004 FAIL: 不明なバックエンドで検索できてしまった")
+	}
+
+	t.Setenv("SEARCH_BACKEND", SearchBackendTrgm)
+	mock.ExpectQuery("FROM pg_indexes").WillReturnRows(sqlmock.NewRows([]string{"indexname"}).AddRow("idx_project_prottl_trgm"))
+	if err := checkSearchBackend(mockDB); err != nil {
+		t.Errorf("UT-SEARCHBE-004 FAIL: インデックスが足りなくても起動できるべき: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-SEARCHBE-004 FAIL: %v", err)
+	}
+}
+
+// UT-SEARCHBE-005: 1ページ目はサイトごとに上位の候補だけを取り出し、同点ならマッチしたスキルの多い順
+func TestCandidateSelectSQL_PerSource(t *testing.T) {
+	profile := defaultRankingProfile()
+	for _, backend := range []string{SearchBackendRegex, SearchBackendTrgm} {
+		query, _ := buildCandidateQuery(backend, candidateQuery{Skills: []searchSkill{{Name: "Java", Weight: 1}}, Profile: profile})
+		for _, part := range []string{"PARTITION BY prostn ORDER BY match_score DESC, match_count DESC, procrt DESC, prourl", "WHERE rn <= 15", "ORDER BY match_score DESC, match_count DESC, procrt DESC, prourl", "LIMIT 50"} {
+			if !strings.Contains(query, part) {
+				t.Errorf("UT-SEARCHBE-005 FAIL: %s: %q がない:\n%s", backend, part, query)
+			}
+		}
+
+		// 2ページ目以降はサイトごとに制限しない
+		query, args := buildCandidateQuery(backend, candidateQuery{Skills: []searchSkill{{Name: "Java", Weight: 1}}, Profile: profile, After: &searchCursor{Score: 8, MatchCount: 1, PostedAt: "2026-10-01", URL: "https://a.com/1"}})
+		if strings.Contains(query, "PARTITION BY") || !strings.Contains(query, "match_count < $") {
+			t.Errorf("UT-SEARCHBE-005 FAIL: %s: ページ送りのSQLが不正:\n%s", backend, query)
+		}
+		if len(args) < 4 || args[len(args)-3] != 1 {
+			t.Errorf("UT-SEARCHBE-005 FAIL: %s: カーソルのマッチ数が引数にない: %v", backend, args)
+		}
+	}
+
+	a := rankedProject{Project: Project{URL: "https://b.com/1", PostedAt: "2026-10-01"}, MatchScore: 8, MatchCount: 2}
+	b := rankedProject{Project: Project{URL: "https://a.com/1", PostedAt: "2026-10-05"}, MatchScore: 8, MatchCount: 1}
+	if !rankedBefore(a, b) || rankedBefore(b, a) {
+		t.Error("UT-SEARCHBE-005 FAIL: 同点ならマッチしたスキルの多い案件が先")
+	}
+
+	wide := profile
+	wide.PerSourceLimit = 10
+	if got := wide.candidatesPerSource(); got != 20 {
+		t.Errorf("UT-SEARCHBE-005 FAIL: per_source_limitが大きいときの候補数 期待 20, 実際 %d", got)
+	}
+}
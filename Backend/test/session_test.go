@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-SESSION テストケース
+// session.go の会話セッションの保存・絞り込み・エンドポイントのテスト
+// ============================================================
+
+var sessionColumns = []string{"sesid", "sesmsg", "sesanl", "sesprm", "sescrt", "sesupd", "sesexp"}
+
+// UT-SESSION-001: 存在しない（期限切れ）セッションはnil
+func TestLoadSession_NotFound(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectQuery("FROM tbl_session").
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(sessionColumns))
+
+	session, err := loadSession("missing")
+	if err != nil {
+		t.Fatalf("UT-SESSION-001 FAIL: エラーが発生: %v", err)
+	}
+	if session != nil {
+		t.Error("UT-SESSION-001 FAIL: 見つからない場合はnilであるべき")
+	}
+}
+
+// UT-SESSION-002: 追加の要望は前回の分析結果・検索条件・履歴と一緒にAIへ渡す
+func TestRefineSkills_IncludesPreviousState(t *testing.T) {
+	provider := newMockProvider(validAnalysisJSON)
+	originalProvider := llmProvider
+	llmProvider = provider
+	defer func() { llmProvider = originalProvider }()
+
+	session := &Session{
+		ID: "abc",
+		Messages: []SessionMessage{
+			{Role: "user", Content: "Java 5年"},
+			{Role: "assistant", Content: "重点スキル: Java, PHP"},
+		},
+		LastAnalysis: &AIAnalysis{KeySkills: []string{"Java", "PHP"}, EstimatedSalary: "60-70万円"},
+		LastSearch:   &SearchParams{KeySkills: []string{"Java", "PHP"}},
+	}
+
+	if _, err := refineSkills(t.Context(), session, "PHPは除外して", nil); err != nil {
+		t.Fatalf("UT-SESSION-002 FAIL: エラーが発生: %v", err)
+	}
+
+	if len(provider.received) != 1 {
+		t.Fatalf("UT-SESSION-002 FAIL: AI呼び出しは1回のはず, 実際 %d", len(provider.received))
+	}
+	messages := provider.received[0]
+	if len(messages) != 4 {
+		t.Fatalf("UT-SESSION-002 FAIL: system+履歴2件+今回の発言の4件のはず, 実際 %d", len(messages))
+	}
+	if messages[1].Content != "Java 5年" || messages[2].Role != "assistant" {
+		t.Error("UT-SESSION-002 FAIL: 会話履歴がそのまま渡されていない")
+	}
+	last := messages[3].Content
+	for _, want := range []string{"PHPは除外して", "60-70万円", `"key_skills":["Java","PHP"]`} {
+		if !strings.Contains(last, want) {
+			t.Errorf("UT-SESSION-002 FAIL: 最後の発言に %q が含まれていない", want)
+		}
+	}
+}
+
+// UT-SESSION-003: 不明なsession_idは404
+func TestHandleChat_UnknownSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectQuery("FROM tbl_session").
+		WithArgs("expired").
+		WillReturnRows(sqlmock.NewRows(sessionColumns))
+
+	router := gin.New()
+	router.POST("/api/chat", handleChat)
+
+	body, _ := json.Marshal(ChatRequest{Message: "もっと高単価で", SessionID: "expired"})
+	req, _ := http.NewRequest("POST", "/api/chat", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 404 {
+		t.Errorf("UT-SESSION-003 FAIL: 期待 404, 実際 %d", w.Code)
+	}
+}
+
+// UT-SESSION-004: 新規会話ではセッションを作成して保存する
+func TestRecordSessionTurn_CreatesSession(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectExec("INSERT INTO tbl_session").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	analysis := AIAnalysis{KeySkills: []string{"Go"}}
+	id := recordSessionTurn(nil, "Go 3年", analysis, SearchParams{KeySkills: analysis.KeySkills})
+	if len(id) != 32 {
+		t.Errorf("UT-SESSION-004 FAIL: 32桁のセッションIDが返るべき, 実際 %q", id)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-SESSION-004 FAIL: %v", err)
+	}
+}
+
+// UT-SESSION-005: 保存済みセッションの取得
+func TestHandleGetSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	now := time.Now()
+	mock.ExpectQuery("FROM tbl_session").
+		WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows(sessionColumns).AddRow(
+			"abc",
+			[]byte(`[{"role":"user","content":"Go 3年"}]`),
+			[]byte(`{"key_skills":["Go"]}`),
+			[]byte(`{"key_skills":["Go"],"skills":null}`),
+			now, now, now.Add(time.Hour),
+		))
+
+	router := gin.New()
+	router.GET("/api/sessions/:id", handleGetSession)
+
+	req, _ := http.NewRequest("GET", "/api/sessions/abc", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("UT-SESSION-005 FAIL: 期待 200, 実際 %d", w.Code)
+	}
+	var session Session
+	if err := json.Unmarshal(w.Body.Bytes(), &session); err != nil {
+		t.Fatalf("UT-SESSION-005 FAIL: レスポンスのパースに失敗: %v", err)
+	}
+	if len(session.Messages) != 1 || session.LastAnalysis == nil || session.LastAnalysis.KeySkills[0] != "Go" {
+		t.Errorf("UT-SESSION-005 FAIL: セッション内容が正しくない: %+v", session)
+	}
+}
+
+// UT-SESSION-006: 存在しないセッションの削除は404
+func TestHandleDeleteSession_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectExec("DELETE FROM tbl_session").
+		WithArgs("missing").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	router := gin.New()
+	router.DELETE("/api/sessions/:id", handleDeleteSession)
+
+	req, _ := http.NewRequest("DELETE", "/api/sessions/missing", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 404 {
+		t.Errorf("UT-SESSION-006 FAIL: 期待 404, 実際 %d", w.Code)
+	}
+}
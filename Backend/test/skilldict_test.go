@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// ============================================================
+// UT-SKILLDICT テストケース
+// skilldict.go のスキル名の正規化・検索パターン・辞書の読み込みのテスト
+// ============================================================
+
+// 辞書のキャッシュを捨てて、次の呼び出しで読み直させる
+func resetSkillDictionary(t *testing.T) {
+	t.Helper()
+	skillDictMu.Lock()
+	skillDict = nil
+	skillDictMu.Unlock()
+	t.Cleanup(func() {
+		skillDictMu.Lock()
+		skillDict = nil
+		skillDictMu.Unlock()
+	})
+}
+
+// UT-SKILLDICT-001: 表記ゆれを正式名にそろえる
+func TestSkillDictionary_Canonical(t *testing.T) {
+	resetSkillDictionary(t)
+	dict := getSkillDictionary()
+
+	cases := map[string]string{
+		"Golang":   "Go",
+		"React.js": "React",
+		"JS":       "JavaScript",
+		"ジャバ":      "Java",
+		"ゴー言語":     "Go",
+		"ｋ８ｓ":      "Kubernetes",
+	}
+	for input, want := range cases {
+		got, ok := dict.Canonical(input)
+		if !ok || got != want {
+			t.Errorf("UT-SKILLDICT-001 FAIL: %s → 期待 %s, 実際 %s (%v)", input, want, got, ok)
+		}
+	}
+
+	if got, ok := dict.Canonical("COBOL"); ok || got != "COBOL" {
+		t.Errorf("UT-SKILLDICT-001 FAIL: 辞書にないスキルはそのまま返すべき: %s (%v)", got, ok)
+	}
+}
+
+// UT-SKILLDICT-002: 単語境界つきのパターン
+func TestSkillDictionary_PatternBoundaries(t *testing.T) {
+	resetSkillDictionary(t)
+	dict := getSkillDictionary()
+
+	cases := []struct {
+		skill string
+		text  string
+		want  bool
+	}{
+		{"Go", "Go言語でのAPI開発", true},
+		{"Go", "Golang / AWS", true},
+		{"Go", "Go1.21以上", true},
+		{"Go", "Google Analyticsの設定", false},
+		{"Go", "MongoDBの運用", false},
+		{"Go", "Djangoでの開発", false},
+		{"Java", "【Java】バックエンド", true},
+		{"Java", "JavaScript/TypeScript", false},
+		{"Java", "ジャバでの開発経験", true},
+		{"Java", "ジャバスクリプト", false},
+		{"JavaScript", "JS（ES6）", true},
+		{"JavaScript", "JSON形式", false},
+		{"React", "React.js / Next.js", true},
+		{"C++", "C++での組み込み開発", true},
+	}
+	for _, c := range cases {
+		re := regexp.MustCompile("(?i)" + dict.Pattern(c.skill))
+		if got := re.MatchString(c.text); got != c.want {
+			t.Errorf("UT-SKILLDICT-002 FAIL: %s を %q で照合: 期待 %v, 実際 %v", c.skill, c.text, c.want, got)
+		}
+	}
+}
+
+// UT-SKILLDICT-003: 正規化して重複を除く
+func TestSkillDictionary_NormalizeSkills(t *testing.T) {
+	resetSkillDictionary(t)
+
+	got := getSkillDictionary().NormalizeSkills([]string{"Golang", "Go", "JS", "JavaScript", "COBOL"})
+	want := []string{"Go", "JavaScript", "COBOL"}
+	if len(got) != len(want) {
+		t.Fatalf("UT-SKILLDICT-003 FAIL: 期待 %v, 実際 %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("UT-SKILLDICT-003 FAIL: 期待 %v, 実際 %v", want, got)
+		}
+	}
+}
+
+// UT-SKILLDICT-004: 検索SQLには全エイリアスを展開したパターンを渡す
+func TestSearchProjectsWithPriority_ExpandsAliases(t *testing.T) {
+	resetSkillDictionary(t)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	pattern := getSkillDictionary().Pattern("Go")
+	columns := []string{"prourl", "prottl", "prodtl", "proprc", "proprd", "proot1", "proot2", "prostn", "procrt"}
+	mock.ExpectQuery(`prottl ~\*`).
+		WithArgs(pattern, pattern, pattern).
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	// "Golang"と"Go"は同じスキルなので1つにまとめられる
+	if _, err := searchProjectsWithPriority([]string{"Golang", "Go"}, nil); err != nil {
+		t.Fatalf("UT-SKILLDICT-004 FAIL: エラーが発生: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-SKILLDICT-004 FAIL: %v", err)
+	}
+}
+
+// UT-SKILLDICT-005: SKILL_DICTIONARY_FILEで辞書を差し替えられる
+func TestLoadSkillDictionary_File(t *testing.T) {
+	resetSkillDictionary(t)
+
+	path := filepath.Join(t.TempDir(), "skills.json")
+	if err := os.WriteFile(path, []byte(`[{"name": "COBOL", "aliases": ["コボル"]}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SKILL_DICTIONARY_FILE", path)
+
+	dict, err := reloadSkillDictionary()
+	if err != nil {
+		t.Fatalf("UT-SKILLDICT-005 FAIL: エラーが発生: %v", err)
+	}
+	if got, ok := dict.Canonical("コボル"); !ok || got != "COBOL" {
+		t.Errorf("UT-SKILLDICT-005 FAIL: ファイルのエイリアスが使われていない: %s", got)
+	}
+	if _, ok := dict.Canonical("golang"); ok {
+		t.Error("UT-SKILLDICT-005 FAIL: ファイル指定時は埋め込みの辞書を使わないはず")
+	}
+
+	t.Setenv("SKILL_DICTIONARY_FILE", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := reloadSkillDictionary(); err == nil {
+		t.Error("UT-SKILLDICT-005 FAIL: ファイルがなければエラーになるべき")
+	}
+}
+
+// UT-SKILLDICT-006: SKILL_DICTIONARY_SOURCE=dbでtbl_skillのエイリアスを重ねる
+func TestLoadSkillDictionary_DB(t *testing.T) {
+	resetSkillDictionary(t)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	t.Setenv("SKILL_DICTIONARY_SOURCE", "db")
+	mock.ExpectQuery("SELECT sklnam, sklals FROM tbl_skill").
+		WillReturnRows(sqlmock.NewRows([]string{"sklnam", "sklals"}).
+			AddRow("Go", []byte(`["ごー"]`)).
+			AddRow("COBOL", []byte(`["cobol85"]`)))
+
+	dict, err := reloadSkillDictionary()
+	if err != nil {
+		t.Fatalf("UT-SKILLDICT-006 FAIL: エラーが発生: %v", err)
+	}
+	if got, _ := dict.Canonical("ごー"); got != "Go" {
+		t.Errorf("UT-SKILLDICT-006 FAIL: 既存スキルにエイリアスが追加されていない: %s", got)
+	}
+	if got, _ := dict.Canonical("golang"); got != "Go" {
+		t.Errorf("UT-SKILLDICT-006 FAIL: ファイルのエイリアスも残るべき: %s", got)
+	}
+	if got, ok := dict.Canonical("cobol85"); !ok || got != "COBOL" {
+		t.Errorf("UT-SKILLDICT-006 FAIL: テーブルだけのスキルが追加されていない: %s", got)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-SKILLGAP テストケース
+// skillgap.go の案件データからのスキルギャップ分析のテスト
+// ============================================================
+
+// 集計用の案件一覧のキャッシュを消す
+func resetMarketProjects() {
+	marketProjectsMu.Lock()
+	marketProjects = nil
+	marketProjectsMu.Unlock()
+}
+
+// UT-SKILLGAP-001: 応募できる案件の増加数と単価の上がり幅を件数つきで出す
+func TestBuildSkillGapReport(t *testing.T) {
+	projects := []marketProject{
+		{skills: []string{"Java"}, price: 600000},
+		{skills: []string{"Java", "Spring Boot"}, price: 600000},
+		{skills: []string{"Java", "AWS"}, price: 800000},
+		{skills: []string{"Java", "AWS"}, price: 900000},
+		{skills: []string{"Java", "AWS", "Kubernetes"}, price: 1000000},
+		{skills: []string{"Java", "Kotlin"}, price: 0},
+		{skills: []string{"Java", "Kotlin"}, price: 0},
+		{skills: []string{"PHP", "AWS"}, price: 500000},
+	}
+
+	report := buildSkillGapReport(projects, []string{"java", "Spring Boot"}, 5)
+
+	if report.TotalProjects != 8 || report.RelatedProjects != 7 || report.MatchedProjects != 2 || report.BaselineAvgPrice != 600000 {
+		t.Errorf("UT-SKILLGAP-001 FAIL: 集計が不正: %+v", report)
+	}
+	if len(report.ByProjects) != 2 || report.ByProjects[0].Skill != "AWS" || report.ByProjects[1].Skill != "Kotlin" {
+		t.Fatalf("UT-SKILLGAP-001 FAIL: 案件数の順位が不正: %+v", report.ByProjects)
+	}
+	aws := report.ByProjects[0]
+	if aws.UnlockedProjects != 2 || aws.CoOccurrence != 3 || aws.PricedProjects != 3 || aws.AvgPrice != 900000 || aws.PriceUplift != 300000 {
+		t.Errorf("UT-SKILLGAP-001 FAIL: AWSの件数・単価が不正: %+v", aws)
+	}
+	if len(aws.CoSkills) != 1 || aws.CoSkills[0] != "Java" {
+		t.Errorf("UT-SKILLGAP-001 FAIL: 一緒に求められるスキルが不正: %v", aws.CoSkills)
+	}
+	// Kubernetesは1件しか単価がないので単価の提案には出さない
+	if len(report.ByPrice) != 1 || report.ByPrice[0].Skill != "AWS" {
+		t.Errorf("UT-SKILLGAP-001 FAIL: 単価の順位が不正: %+v", report.ByPrice)
+	}
+}
+
+// UT-SKILLGAP-002: tbl_projectのタイトル・スキル欄からスキルを読み取り、一定時間キャッシュする
+func TestHandleSkillGap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resetMarketProjects()
+	defer resetMarketProjects()
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectQuery("FROM tbl_project").
+		WithArgs(5000).
+		WillReturnRows(sqlmock.NewRows([]string{"prottl", "prodtl", "proprc", "proot1"}).
+			AddRow("【Go】API開発", "詳細", "70万円", "Go, Docker").
+			AddRow("【Go】基盤開発", "詳細", "80万円", "Golang").
+			AddRow("バックエンド開発", "Go・AWSでの開発", "〜90万円", nil).
+			AddRow("営業事務", "Excel", "30万円", nil))
+
+	r := gin.New()
+	r.POST("/api/skill-gap", handleSkillGap)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/api/skill-gap",
+			bytes.NewReader([]byte(`{"structured_skills": [{"skill_name": "Golang", "experience_years": 3}]}`))))
+		if w.Code != 200 {
+			t.Fatalf("UT-SKILLGAP-002 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+		}
+
+		var report SkillGapReport
+		json.Unmarshal(w.Body.Bytes(), &report)
+		if report.TotalProjects != 3 || report.MatchedProjects != 1 || report.BaselineAvgPrice != 800000 {
+			t.Errorf("UT-SKILLGAP-002 FAIL: 集計が不正: %+v", report)
+		}
+		if len(report.ByProjects) != 2 || report.ByProjects[0].Skill != "AWS" || report.ByProjects[1].Skill != "Docker" {
+			t.Errorf("UT-SKILLGAP-002 FAIL: 提案が不正: %+v", report.ByProjects)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-SKILLGAP-002 FAIL: 案件の読み込みが1回ではない: %v", err)
+	}
+}
+
+// UT-SKILLGAP-003: スキルの指定がなければ400、DBエラーなら500、存在しないセッションは404
+func TestHandleSkillGap_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resetMarketProjects()
+	defer resetMarketProjects()
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectQuery("FROM tbl_session").WithArgs("expired").WillReturnRows(sqlmock.NewRows(sessionColumns))
+	mock.ExpectQuery("FROM tbl_project").WillReturnError(fmt.Errorf("connection refused"))
+
+	r := gin.New()
+	r.POST("/api/skill-gap", handleSkillGap)
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{}`, 400},
+		{`{"session_id": "expired"}`, 404},
+		{`{"structured_skills": [{"skill_name": "Java", "experience_years": 1}]}`, 500},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/api/skill-gap", bytes.NewReader([]byte(tt.body))))
+		if w.Code != tt.want {
+			t.Errorf("UT-SKILLGAP-003 FAIL: %s → 期待 %d, 実際 %d", tt.body, tt.want, w.Code)
+		}
+	}
+}
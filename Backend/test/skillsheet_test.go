@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-SKILLSHEET テストケース
+// skillsheet.go のスキルシートからの経験年数・経験レベル・役割の計算のテスト
+// ============================================================
+
+var skillSheetNow = time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+
+// UT-SKILLSHEET-001: 重なる期間は1回だけ数え、表記ゆれは正式名にまとめる
+func TestAnalyzeSkillSheet_MergesOverlaps(t *testing.T) {
+	sheet := SkillSheet{ProjectExperiences: []ProjectExperience{
+		{ProjectName: "A", StartDate: "2018-04", EndDate: "2020-03", Technologies: []string{"Java", "Golang"}},
+		{ProjectName: "B", StartDate: "2019-04", EndDate: "2021-03", Technologies: []string{"java", "Spring Boot"}},
+		{ProjectName: "C", StartDate: "2021-04", EndDate: "2021-09", Technologies: []string{"Go"}},
+		{ProjectName: "D", StartDate: "2023-01", EndDate: "2023-12", Technologies: []string{"Java"}},
+	}}
+
+	experience, analysis := analyzeSkillSheet(sheet, skillSheetNow)
+
+	byName := map[string]SkillExperience{}
+	for _, s := range experience.Skills {
+		byName[s.SkillName] = s
+	}
+	java := byName["Java"]
+	if java.Months != 48 || java.ExperienceYears != 4 || strings.Join(java.Periods, ",") != "2018-04〜2021-03,2023-01〜2023-12" || java.LastUsed != "2023-12" {
+		t.Errorf("UT-SKILLSHEET-001 FAIL: Javaの経験が不正: %+v", java)
+	}
+	if goSkill := byName["Go"]; goSkill.Months != 30 || goSkill.ExperienceYears != 2.5 || len(goSkill.Periods) != 2 {
+		t.Errorf("UT-SKILLSHEET-001 FAIL: GolangとGoがまとまっていない: %+v", experience.Skills)
+	}
+	if experience.TotalYears != 4.5 {
+		t.Errorf("UT-SKILLSHEET-001 FAIL: 職歴の年数が不正: %v", experience.TotalYears)
+	}
+	if len(analysis.KeySkills) != 3 || analysis.KeySkills[0] != "Java" || analysis.KeySkills[1] != "Go" {
+		t.Errorf("UT-SKILLSHEET-001 FAIL: 重点スキルが経験の長い順になっていない: %v", analysis.KeySkills)
+	}
+	if analysis.StructuredSkills[0].SkillName != "Java" || analysis.StructuredSkills[0].ExperienceYears != 4 {
+		t.Errorf("UT-SKILLSHEET-001 FAIL: structured_skillsが不正: %+v", analysis.StructuredSkills)
+	}
+}
+
+// UT-SKILLSHEET-002: 終了年月が空・未来なら現在まで、読み取れない期間は警告にして数えない
+func TestAnalyzeSkillSheet_Periods(t *testing.T) {
+	sheet := SkillSheet{ProjectExperiences: []ProjectExperience{
+		{ProjectName: "継続中", StartDate: "2026-01", EndDate: "", Technologies: []string{"TypeScript"}},
+		{ProjectName: "予定あり", StartDate: "2025-11", EndDate: "2027-03", Technologies: []string{"React"}},
+		{ProjectName: "逆転", StartDate: "2024-05", EndDate: "2024-01", Technologies: []string{"PHP"}},
+		{ProjectName: "", StartDate: "", Technologies: []string{"Ruby"}},
+	}}
+
+	experience, _ := analyzeSkillSheet(sheet, skillSheetNow)
+
+	if len(experience.Skills) != 2 || experience.Skills[0].SkillName != "React" || experience.Skills[0].Months != 12 || experience.Skills[1].Months != 10 {
+		t.Errorf("UT-SKILLSHEET-002 FAIL: 期間の計算が不正: %+v", experience.Skills)
+	}
+	if len(experience.Warnings) != 2 || !strings.HasPrefix(experience.Warnings[0], "逆転") || !strings.HasPrefix(experience.Warnings[1], "4件目") {
+		t.Errorf("UT-SKILLSHEET-002 FAIL: 警告が不正: %v", experience.Warnings)
+	}
+}
+
+// UT-SKILLSHEET-003: 経験レベルと役割は役割の欄と担当フェーズから決める
+func TestAnalyzeSkillSheet_LevelAndRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		projects  []ProjectExperience
+		wantLevel string
+		wantRole  string
+	}{
+		{
+			"実装のみ1年",
+			[]ProjectExperience{{StartDate: "2025-10", EndDate: "2026-09", Phases: []string{"実装", "単体テスト"}}},
+			"初級", "プログラマー",
+		},
+		{
+			"上流工程の経験で中級",
+			[]ProjectExperience{{StartDate: "2025-10", EndDate: "2026-09", Phases: []string{"基本設計", "実装"}}},
+			"中級", "システムエンジニア",
+		},
+		{
+			"PLの経験で上級、希望は直近の役割",
+			[]ProjectExperience{
+				{StartDate: "2023-04", EndDate: "2024-03", Role: "PL"},
+				{StartDate: "2024-04", EndDate: "", Role: "バックエンドエンジニア"},
+			},
+			"上級", "バックエンドエンジニア",
+		},
+		{
+			"10年以上はエキスパート",
+			[]ProjectExperience{{StartDate: "2014-04", EndDate: "2026-03", Role: "PMO支援"}},
+			"エキスパート", "PMO",
+		},
+		{
+			"英字の役割は単語境界を見る",
+			[]ProjectExperience{{StartDate: "2025-10", EndDate: "2026-09", Role: "Database担当"}},
+			"初級", "エンジニア",
+		},
+	}
+
+	for _, tt := range tests {
+		_, analysis := analyzeSkillSheet(SkillSheet{ProjectExperiences: tt.projects}, skillSheetNow)
+		if analysis.ExperienceLevel != tt.wantLevel || analysis.PreferredRole != tt.wantRole {
+			t.Errorf("UT-SKILLSHEET-003 FAIL: %s → %s / %s, 期待 %s / %s",
+				tt.name, analysis.ExperienceLevel, analysis.PreferredRole, tt.wantLevel, tt.wantRole)
+		}
+	}
+}
+
+// スキルシート解析のリクエストを送る
+func postSkillSheet(t *testing.T, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := gin.New()
+	r.POST("/api/skillsheet/analyze", handleAnalyzeSkillSheet)
+	req := httptest.NewRequest("POST", path, bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// UT-SKILLSHEET-004: AIには強みと提案だけを書かせ、年数はAIの返答で上書きしない
+func TestHandleAnalyzeSkillSheet(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	provider := newMockProvider(`{"strengths": "Javaの設計から実装まで担えます。", "suggestions": "クラウドの経験を積みましょう。", "structured_skills": [{"skill_name": "Java", "experience_years": 20}]}`)
+	originalProvider := llmProvider
+	llmProvider = provider
+	defer func() { llmProvider = originalProvider }()
+
+	columns := []string{"prourl", "prottl", "prodtl", "proprc", "proprd", "proot1", "proot2", "prostn", "procrt"}
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("https://test.com/1", "【Java】バックエンド開発", "Spring Boot", "70万円", "長期", "Java", nil, "freelance-start", "2024-12-01"))
+
+	body := `{"templateName": "", "summary": "Javaのバックエンドが得意です", "projectExperiences": [
+		{"id": "1", "projectName": "基幹システム", "role": "SE", "startDate": "2020-04", "endDate": "2023-03",
+		 "developmentMethodology": "ウォーターフォール", "phases": ["基本設計", "実装"], "technologies": ["Java", "Spring Boot"], "description": "受発注"}]}`
+	w := postSkillSheet(t, "/api/skillsheet/analyze", body)
+	if w.Code != 200 {
+		t.Fatalf("UT-SKILLSHEET-004 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp SkillSheetResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("UT-SKILLSHEET-004 FAIL: レスポンスのパースエラー: %v", err)
+	}
+	if resp.AIAnalysis.Strengths != "Javaの設計から実装まで担えます。" || resp.Analyzer != AnalyzerAI || resp.Degraded || resp.PromptVersion != "v1" {
+		t.Errorf("UT-SKILLSHEET-004 FAIL: AIの文章が使われていない: %+v", resp.ChatResponse)
+	}
+	if resp.AIAnalysis.StructuredSkills[0].ExperienceYears != 3 || resp.Experience.Skills[0].Months != 36 {
+		t.Errorf("UT-SKILLSHEET-004 FAIL: 年数がAIの返答で上書きされた: %+v", resp.AIAnalysis.StructuredSkills)
+	}
+	if resp.AIAnalysis.ExperienceLevel != "中級" || resp.AIAnalysis.PreferredRole != "システムエンジニア" || len(resp.Projects) != 1 {
+		t.Errorf("UT-SKILLSHEET-004 FAIL: 分析結果・検索結果が不正: %+v", resp.ChatResponse)
+	}
+	prompt := provider.received[0][len(provider.received[0])-1].Content
+	if !strings.Contains(prompt, `"experience_years":3`) || !strings.Contains(prompt, "期間: 2020-04〜2023-03") {
+		t.Errorf("UT-SKILLSHEET-004 FAIL: 集計結果とスキルシートがAIに渡されていない: %s", prompt)
+	}
+}
+
+// UT-SKILLSHEET-005: AIが使えなくても集計結果と簡易の文章で返す
+func TestHandleAnalyzeSkillSheet_AIFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	originalProvider := llmProvider
+	llmProvider = newMockProvider(`強みは...`)
+	defer func() { llmProvider = originalProvider }()
+
+	mock.ExpectQuery("SELECT").WillReturnError(fmt.Errorf("connection refused"))
+	w := postSkillSheet(t, "/api/skillsheet/analyze",
+		`{"projectExperiences": [{"startDate": "2024-04", "endDate": "2025-03", "technologies": ["Python"]}]}`)
+	if w.Code != 500 {
+		t.Errorf("UT-SKILLSHEET-005 FAIL: 検索エラーは500のはず: %d", w.Code)
+	}
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"prourl", "prottl", "prodtl", "proprc", "proprd", "proot1", "proot2", "prostn", "procrt"}))
+	w = postSkillSheet(t, "/api/skillsheet/analyze",
+		`{"projectExperiences": [{"startDate": "2024-04", "endDate": "2025-03", "technologies": ["Python"]}]}`)
+	var resp SkillSheetResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != 200 || !resp.Degraded || resp.Analyzer != AnalyzerRule || !strings.Contains(resp.AIAnalysis.Strengths, "Python（1年）") {
+		t.Errorf("UT-SKILLSHEET-005 FAIL: 簡易の文章で返っていない: %d %+v", w.Code, resp.ChatResponse)
+	}
+
+	for _, tc := range []struct{ path, body string }{
+		{"/api/skillsheet/analyze", `{"projectExperiences": []}`},
+		{"/api/skillsheet/analyze", `not json`},
+		{"/api/skillsheet/analyze?analyzer=unknown", `{"projectExperiences": [{"startDate": "2024-04"}]}`},
+	} {
+		if w := postSkillSheet(t, tc.path, tc.body); w.Code != 400 {
+			t.Errorf("UT-SKILLSHEET-005 FAIL: %s %s は400のはず: %d", tc.path, tc.body, w.Code)
+		}
+	}
+}
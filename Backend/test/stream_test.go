@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-STREAM テストケース
+// stream.go のフィールドスキャナーと handleChatStream のテスト
+// ============================================================
+
+// SSEのレスポンスボディをイベント名とデータの組に分解する
+type sseEvent struct {
+	Name string
+	Data string
+}
+
+func parseSSEEvents(body string) []sseEvent {
+	var events []sseEvent
+	for _, block := range strings.Split(body, "\n\n") {
+		var ev sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			if strings.HasPrefix(line, "event:") {
+				ev.Name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			} else if strings.HasPrefix(line, "data:") {
+				ev.Data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			}
+		}
+		if ev.Name != "" {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+// UT-STREAM-001: 細切れのJSONからフィールドを確定順に取り出す
+func TestJSONFieldScanner_Chunked(t *testing.T) {
+	var names []string
+	values := map[string]string{}
+	scanner := newJSONFieldScanner(func(name string, value json.RawMessage) {
+		names = append(names, name)
+		values[name] = string(value)
+	})
+
+	input := "```json\n" + `{"a": "x,{y}\"z", "b": [1, {"c": 2}], "d": {"e": "f"}}` + "\n```"
+	for _, r := range input {
+		scanner.Feed(string(r))
+	}
+
+	if strings.Join(names, ",") != "a,b,d" {
+		t.Fatalf("UT-STREAM-001 FAIL: 期待 a,b,d の順, 実際 %v", names)
+	}
+	if values["a"] != `"x,{y}\"z"` || values["b"] != `[1, {"c": 2}]` {
+		t.Errorf("UT-STREAM-001 FAIL: 値が不正: %v", values)
+	}
+}
+
+// UT-STREAM-002: 未完成のフィールドは通知しない
+func TestJSONFieldScanner_Incomplete(t *testing.T) {
+	var names []string
+	scanner := newJSONFieldScanner(func(name string, value json.RawMessage) {
+		names = append(names, name)
+	})
+
+	scanner.Feed(`{"a": 1, "b": "途中`)
+	if len(names) != 1 || names[0] != "a" {
+		t.Errorf("UT-STREAM-002 FAIL: 確定したaだけが通知されるべき: %v", names)
+	}
+}
+
+// UT-STREAM-003: analyzing → analysis_field → project → done の順で送信される
+func TestHandleChatStream_Events(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	originalProvider := llmProvider
+	llmProvider = newMockProvider()
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("AI_CACHE_ENABLED", "false")
+
+	columns := []string{"prourl", "prottl", "prodtl", "proprc", "proprd", "proot1", "proot2", "prostn", "procrt"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("https://test.com/1", "【Java】バックエンド開発", "Spring Boot", "70万円", "長期", "Java", nil, "freelance-start", "2024-12-01").
+		AddRow("https://test.com/2", "【Java】API開発", "Java開発", "60万円", "長期", "Java", nil, "lancers", "2024-11-30")
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+
+	r := gin.New()
+	r.POST("/api/chat/stream", handleChatStream)
+
+	req := httptest.NewRequest("POST", "/api/chat/stream", strings.NewReader(`{"message": "Java 3年"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("UT-STREAM-003 FAIL: 期待ステータス %d, 実際 %d", http.StatusOK, w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		t.Errorf("UT-STREAM-003 FAIL: Content-Typeがtext/event-streamではない: %s", w.Header().Get("Content-Type"))
+	}
+
+	events := parseSSEEvents(w.Body.String())
+	var order []string
+	fieldCount := 0
+	for _, ev := range events {
+		if ev.Name == "analysis_field" {
+			fieldCount++
+		}
+		if len(order) == 0 || order[len(order)-1] != ev.Name {
+			order = append(order, ev.Name)
+		}
+	}
+
+	if strings.Join(order, ",") != "analyzing,analysis_field,project,done" {
+		t.Errorf("UT-STREAM-003 FAIL: イベント順が不正: %v", order)
+	}
+	if fieldCount != 8 {
+		t.Errorf("UT-STREAM-003 FAIL: 期待 analysis_field 8件, 実際 %d件", fieldCount)
+	}
+
+	var done ChatResponse
+	if err := json.Unmarshal([]byte(events[len(events)-1].Data), &done); err != nil {
+		t.Fatalf("UT-STREAM-003 FAIL: doneイベントのパースエラー: %v", err)
+	}
+	if len(done.Projects) != 2 || done.AIAnalysis.KeySkills[0] != "Java" {
+		t.Errorf("UT-STREAM-003 FAIL: doneイベントの内容が不正: %+v", done)
+	}
+}
+
+// UT-STREAM-004: AIエラーはerrorイベントで通知する
+func TestHandleChatStream_AIError(t *testing.T) {
+	originalProvider := llmProvider
+	llmProvider = newMockProvider(`壊れた返答`)
+	defer func() { llmProvider = originalProvider }()
+	t.Setenv("AI_CACHE_ENABLED", "false")
+	t.Setenv("LLM_REPAIR_ATTEMPTS", "0")
+
+	r := gin.New()
+	r.GET("/api/chat/stream", handleChatStream)
+
+	req := httptest.NewRequest("GET", "/api/chat/stream?message=Java", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	events := parseSSEEvents(w.Body.String())
+	if len(events) == 0 || events[len(events)-1].Name != "error" {
+		t.Errorf("UT-STREAM-004 FAIL: 最後のイベントはerrorであるべき: %v", events)
+	}
+}
+
+// UT-STREAM-005: GETでmessageがない場合は400
+func TestHandleChatStream_MissingMessage(t *testing.T) {
+	r := gin.New()
+	r.GET("/api/chat/stream", handleChatStream)
+
+	req := httptest.NewRequest("GET", "/api/chat/stream", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("UT-STREAM-005 FAIL: 期待ステータス %d, 実際 %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+// UT-STREAM-006: OpenAI互換のストリーミング応答をdeltaごとに受け取る
+func TestOpenAICompatibleProvider_ChatStream(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body AIChatRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		if !body.Stream {
+			t.Error("UT-STREAM-006 FAIL: stream=trueで送信されるべき")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"model\":\"m\",\"choices\":[{\"delta\":{\"content\":\"{\\\"a\\\":\"}}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"1}\"}}]}\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer mockServer.Close()
+
+	provider := &openAICompatibleProvider{name: ProviderOpenAI, config: LLMConfig{Model: "m", BaseURL: mockServer.URL}}
+
+	var deltas []string
+	result, err := provider.ChatStream(context.Background(), nil, func(d string) { deltas = append(deltas, d) })
+	if err != nil {
+		t.Fatalf("UT-STREAM-006 FAIL: エラーが発生: %v", err)
+	}
+	if result.Content != `{"a":1}` || len(deltas) != 2 {
+		t.Errorf("UT-STREAM-006 FAIL: 結果が不正: %+v, deltas=%v", result, deltas)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-USAGE テストケース
+// usage.go のAI使用量の記録・集計のテスト
+// ============================================================
+
+// UT-USAGE-001: OpenAI互換APIのusageを読み取り、用途・プロンプトのバージョン・モデルと一緒に記録する
+func TestMeteredProvider_RecordsUsage(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"model": "llama3", "choices": [{"message": {"role": "assistant", "content": "{}"}}],
+			"usage": {"prompt_tokens": 120, "completion_tokens": 30, "total_tokens": 150}}`))
+	}))
+	defer mockServer.Close()
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectExec("INSERT INTO tbl_aiusage").
+		WithArgs(ProviderOpenAI, "llama3", AIPurposeRerank, "v3", 120, 30, 150, sqlmock.AnyArg(), "ok", "").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	provider, err := NewLLMProvider(LLMConfig{Provider: ProviderOpenAI, Model: "llama3", BaseURL: mockServer.URL})
+	if err != nil {
+		t.Fatalf("プロバイダー生成エラー: %v", err)
+	}
+
+	ctx := withPromptVersion(withAIPurpose(context.Background(), AIPurposeRerank), "v3")
+	result, err := withUsageMetering(provider).Chat(ctx, []AIChatMessage{{Role: "user", Content: "Java 5年"}})
+	if err != nil {
+		t.Fatalf("UT-USAGE-001 FAIL: エラーが発生: %v", err)
+	}
+	if result.Usage.TotalTokens != 150 {
+		t.Errorf("UT-USAGE-001 FAIL: usageが読み取られていない: %+v", result.Usage)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-USAGE-001 FAIL: 使用量が記録されていない: %v", err)
+	}
+}
+
+// UT-USAGE-002: 失敗した呼び出しもエラーとして記録する
+func TestMeteredProvider_RecordsError(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(429)
+		w.Write([]byte(`{"error": "insufficient_quota"}`))
+	}))
+	defer mockServer.Close()
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectExec("INSERT INTO tbl_aiusage").
+		WithArgs(ProviderOpenAI, "llama3", AIPurposeOther, "", 0, 0, 0, sqlmock.AnyArg(), "error", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	provider, _ := NewLLMProvider(LLMConfig{Provider: ProviderOpenAI, Model: "llama3", BaseURL: mockServer.URL})
+	if _, err := withUsageMetering(provider).Chat(context.Background(), []AIChatMessage{{Role: "user", Content: "Java"}}); err == nil {
+		t.Fatal("UT-USAGE-002 FAIL: エラーが返るべき")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-USAGE-002 FAIL: エラーが記録されていない: %v", err)
+	}
+}
+
+// UT-USAGE-003: ストリーミング対応のプロバイダーはラップ後もストリーミングできる
+func TestWithUsageMetering_KeepsStreaming(t *testing.T) {
+	originalDB := db
+	db = nil
+	defer func() { db = originalDB }()
+
+	provider := withUsageMetering(newMockProvider(`{"a": 1}`))
+	streaming, ok := provider.(StreamingLLMProvider)
+	if !ok {
+		t.Fatal("UT-USAGE-003 FAIL: StreamingLLMProviderのままであるべき")
+	}
+	if withUsageMetering(provider) != provider {
+		t.Error("UT-USAGE-003 FAIL: 二重にラップされた")
+	}
+
+	var deltas int
+	result, err := streaming.ChatStream(context.Background(), nil, func(string) { deltas++ })
+	if err != nil || result.Content != `{"a": 1}` || deltas == 0 {
+		t.Errorf("UT-USAGE-003 FAIL: ストリーミング結果が不正: %+v, deltas=%d, err=%v", result, deltas, err)
+	}
+}
+
+// UT-USAGE-004: 単価表は完全一致→最長の前方一致で探し、AI_PRICE_TABLEで上書きできる
+func TestLookupModelPrice(t *testing.T) {
+	t.Setenv("AI_PRICE_TABLE", `{"gemini-1.5-flash-8b": {"prompt": 0.0375, "completion": 0.15}, "openai/gpt-3.5-turbo": {"prompt": 1, "completion": 2}}`)
+	prices := loadModelPrices()
+
+	tests := []struct {
+		model      string
+		wantPrompt float64
+		wantOK     bool
+	}{
+		{"openai/gpt-3.5-turbo", 1, true},
+		{"gemini-1.5-flash-002", 0.075, true},
+		{"gemini-1.5-flash-8b-001", 0.0375, true},
+		{"unknown-model", 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := lookupModelPrice(prices, tt.model)
+		if ok != tt.wantOK || price.Prompt != tt.wantPrompt {
+			t.Errorf("UT-USAGE-004 FAIL: %s → %+v, %v", tt.model, price, ok)
+		}
+	}
+
+	if cost := estimateAICost(ModelPrice{Prompt: 1, Completion: 2}, 1000000, 500000); cost != 2 {
+		t.Errorf("UT-USAGE-004 FAIL: 概算コストが不正: %v", cost)
+	}
+}
+
+// UT-USAGE-005: 日別・モデル別・プロンプト別・合計の集計と概算コスト
+func TestHandleGetAIUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("AI_PRICE_TABLE", "")
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	columns := []string{"date", "aiumdl", "calls", "errors", "prompt", "completion", "total", "latency"}
+	mock.ExpectQuery("FROM tbl_aiusage").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("2026-10-15", "openai/gpt-3.5-turbo", 2, 0, 1000000, 0, 1000000, 100.0).
+			AddRow("2026-10-16", "openai/gpt-3.5-turbo", 1, 1, 0, 1000000, 1000000, 400.0).
+			AddRow("2026-10-16", "local-llama", 3, 0, 300, 300, 600, 50.0))
+	mock.ExpectQuery("GROUP BY 1, 2").
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"aiupur", "aiupvr", "calls", "errors", "prompt", "completion", "total", "latency"}).
+			AddRow("analysis", "v1", 4, 1, 1000300, 1000300, 2000600, 200.0).
+			AddRow("rerank", "v2", 2, 0, 0, 0, 0, 100.0))
+
+	r := gin.New()
+	r.GET("/api/admin/ai-usage", handleGetAIUsage)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/ai-usage?days=7", nil))
+
+	if w.Code != 200 {
+		t.Fatalf("UT-USAGE-005 FAIL: 期待 200, 実際 %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Daily   []AIUsageSummary `json:"daily"`
+		Models  []AIUsageSummary `json:"models"`
+		Prompts []AIUsageSummary `json:"prompts"`
+		Total   AIUsageSummary   `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	if len(resp.Daily) != 2 || resp.Daily[1].Calls != 4 || resp.Daily[1].Errors != 1 {
+		t.Errorf("UT-USAGE-005 FAIL: 日別の集計が不正: %+v", resp.Daily)
+	}
+	if len(resp.Models) != 2 || resp.Models[0].Model != "openai/gpt-3.5-turbo" || resp.Models[0].EstimatedCostUSD != 2 || resp.Models[0].AvgLatencyMs != 200 {
+		t.Errorf("UT-USAGE-005 FAIL: モデル別の集計が不正: %+v", resp.Models)
+	}
+	if resp.Models[1].Priced == nil || *resp.Models[1].Priced {
+		t.Errorf("UT-USAGE-005 FAIL: 単価表にないモデルはpriced=falseのはず: %+v", resp.Models[1])
+	}
+	if len(resp.Prompts) != 2 || resp.Prompts[1].Purpose != "rerank" || resp.Prompts[1].PromptVersion != "v2" {
+		t.Errorf("UT-USAGE-005 FAIL: プロンプト別の集計が不正: %+v", resp.Prompts)
+	}
+	if resp.Total.Calls != 6 || resp.Total.TotalTokens != 2000600 || resp.Total.EstimatedCostUSD != 2 {
+		t.Errorf("UT-USAGE-005 FAIL: 合計が不正: %+v", resp.Total)
+	}
+}
+
+// UT-USAGE-006: 集計期間の指定が不正なら400、DBエラーなら500
+func TestHandleGetAIUsage_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock作成エラー: %v", err)
+	}
+	defer mockDB.Close()
+
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectQuery("FROM tbl_aiusage").WillReturnError(fmt.Errorf("relation does not exist"))
+
+	r := gin.New()
+	r.GET("/api/admin/ai-usage", handleGetAIUsage)
+
+	for _, days := range []string{"0", "abc", "366"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/ai-usage?days="+days, nil))
+		if w.Code != 400 {
+			t.Errorf("UT-USAGE-006 FAIL: days=%s は400のはず: %d", days, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/ai-usage", nil))
+	if w.Code != 500 {
+		t.Errorf("UT-USAGE-006 FAIL: DBエラーは500のはず: %d", w.Code)
+	}
+}
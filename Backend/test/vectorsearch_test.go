@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================
+// UT-VECTOR テストケース
+// embedding.go / vectorsearch.go の埋め込みと意味検索のテスト
+// ============================================================
+
+var projectColumns = []string{"prourl", "prottl", "prodtl", "proprc", "proprd", "proot1", "proot2", "prostn", "procrt"}
+
+// 埋め込みをローカルのhashに差し替え、ベクトルはGoで計算する
+func useHashEmbedder(t *testing.T) Embedder {
+	t.Helper()
+	emb, err := NewEmbedder(EmbeddingConfig{Provider: EmbedderHash, Dimensions: 256})
+	if err != nil {
+		t.Fatalf("埋め込み作成エラー: %v", err)
+	}
+	original := embedder
+	embedder = emb
+	t.Cleanup(func() { embedder = original })
+	t.Setenv("VECTOR_STORE", VectorStoreMemory)
+	invalidateProjectVectors()
+	return emb
+}
+
+// pq.Float32Arrayとして読める文字列
+func pgArrayLiteral(vector []float32) string {
+	parts := make([]string, len(vector))
+	for i, v := range vector {
+		parts[i] = fmt.Sprint(v)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// 失敗する埋め込み
+type failingEmbedder struct{}
+
+func (failingEmbedder) Name() string  { return "failing" }
+func (failingEmbedder) Model() string { return "failing" }
+func (failingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, errors.New("embedding API unavailable")
+}
+
+// UT-VECTOR-001: hashの埋め込みで、言い方の違う同じ分野の文章が近くなる
+func TestHashEmbed_Similarity(t *testing.T) {
+	tests := []struct {
+		query   string
+		near    string
+		far     string
+		comment string
+	}{
+		{"サーバーサイド保守", "バックエンドAPIの運用・改修（Java/Spring Boot）", "iPhoneアプリのUIデザイン", "バックエンド"},
+		{"EA開発", "MQL4による自動売買ツールの開発", "社内ヘルプデスク対応", "FX"},
+	}
+	for _, tt := range tests {
+		query := hashEmbed(tt.query, 256)
+		near := cosineSimilarity(query, hashEmbed(tt.near, 256))
+		far := cosineSimilarity(query, hashEmbed(tt.far, 256))
+		if near <= far || near <= 0.2 {
+			t.Errorf("UT-VECTOR-001 FAIL: %s: %q は %q（%.3f）の方が %q（%.3f）より近いべき", tt.comment, tt.query, tt.near, near, tt.far, far)
+		}
+	}
+
+	v := hashEmbed("Java Spring Boot", 256)
+	if s := cosineSimilarity(v, v); s < 0.999 || s > 1.001 {
+		t.Errorf("UT-VECTOR-001 FAIL: 同じベクトルの類似度は1: %f", s)
+	}
+	if s := cosineSimilarity(v, make([]float32, 256)); s != 0 {
+		t.Errorf("UT-VECTOR-001 FAIL: 0ベクトルとの類似度は0: %f", s)
+	}
+	if s := cosineSimilarity(v, v[:10]); s != 0 {
+		t.Errorf("UT-VECTOR-001 FAIL: 次元が違えば0: %f", s)
+	}
+}
+
+// UT-VECTOR-002: OpenAI互換の埋め込みはBatchSize件ずつ呼び出し、indexの順に並べる
+func TestOpenAIEmbedder_Batches(t *testing.T) {
+	resetAIBreakers()
+	originalDB := db
+	db = nil
+	defer func() { db = originalDB }()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path != "/embeddings" || r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(400)
+			return
+		}
+		var req openAIEmbeddingRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		// 逆順で返す
+		var data []string
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, fmt.Sprintf(`{"index": %d, "embedding": [%d, 1]}`, i, len(req.Input[i])))
+		}
+		fmt.Fprintf(w, `{"model": %q, "data": [%s], "usage": {"prompt_tokens": 3, "total_tokens": 3}}`, req.Model, strings.Join(data, ","))
+	}))
+	defer server.Close()
+
+	emb, err := NewEmbedder(EmbeddingConfig{Provider: EmbedderOpenAI, Model: "test-embed", BaseURL: server.URL, APIKey: "test-key", BatchSize: 2})
+	if err != nil {
+		t.Fatalf("UT-VECTOR-002 FAIL: 埋め込み作成エラー: %v", err)
+	}
+	vectors, err := emb.Embed(context.Background(), []string{"a", "bb", "ccc"})
+	if err != nil {
+		t.Fatalf("UT-VECTOR-002 FAIL: エラーが発生: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("UT-VECTOR-002 FAIL: 期待 2回, 実際 %d回", calls)
+	}
+	for i, want := range []float32{1, 2, 3} {
+		if len(vectors) != 3 || vectors[i][0] != want {
+			t.Fatalf("UT-VECTOR-002 FAIL: 入力の順に並んでいない: %v", vectors)
+		}
+	}
+}
+
+// UT-VECTOR-003: 内容が変わった案件だけをベクトルにして保存する
+func TestSyncProjectEmbeddings(t *testing.T) {
+	emb := useHashEmbedder(t)
+
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	unchanged := embeddingTextHash(projectEmbeddingText("Java案件", "Java", "詳細"))
+	mock.ExpectQuery("LEFT JOIN tbl_projectvec").
+		WithArgs(emb.Model(), 5000).
+		WillReturnRows(sqlmock.NewRows([]string{"prourl", "prottl", "prodtl", "proot1", "pvchsh"}).
+			AddRow("https://test.com/1", "Java案件", "詳細", "Java", unchanged).
+			AddRow("https://test.com/2", "MQL4 EA開発", "自動売買", "MQL4", nil).
+			AddRow("https://test.com/3", "Go案件", "詳細", "Go", "old-hash"))
+	mock.ExpectExec("INSERT INTO tbl_projectvec").
+		WithArgs("https://test.com/2", emb.Model(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("ON CONFLICT \\(pvcurl, pvcmdl\\)").
+		WithArgs("https://test.com/3", emb.Model(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	result, err := syncProjectEmbeddings(context.Background())
+	if err != nil {
+		t.Fatalf("UT-VECTOR-003 FAIL: エラーが発生: %v", err)
+	}
+	if result.Checked != 3 || result.Embedded != 2 || result.Store != VectorStoreMemory {
+		t.Errorf("UT-VECTOR-003 FAIL: 期待 checked=3 embedded=2 store=memory, 実際 %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-VECTOR-003 FAIL: %v", err)
+	}
+}
+
+// UT-VECTOR-004: 意味検索はキーワードで見つからない近い案件も加え、近さを混ぜて並べる
+func TestSearchProjectsForAnalysis_Semantic(t *testing.T) {
+	useHashEmbedder(t)
+	t.Setenv("SEMANTIC_WEIGHT", "0.9")
+
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	backend := projectEmbeddingText("バックエンド保守運用", "Java", "サーバーサイドAPIの改修")
+	design := projectEmbeddingText("UIデザイン", "Figma", "iPhoneアプリの画面設計")
+	keyword := projectEmbeddingText("Java開発", "Java", "業務システム")
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(projectColumns).
+		AddRow("https://test.com/java", "Java開発", "業務システム", "60万円", nil, "Java", nil, "test", "2026-10-01"))
+	mock.ExpectQuery("SELECT pvcurl, pvcvec FROM tbl_projectvec").WillReturnRows(sqlmock.NewRows([]string{"pvcurl", "pvcvec"}).
+		AddRow("https://test.com/java", pgArrayLiteral(hashEmbed(keyword, 256))).
+		AddRow("https://test.com/backend", pgArrayLiteral(hashEmbed(backend, 256))).
+		AddRow("https://test.com/design", pgArrayLiteral(hashEmbed(design, 256))))
+	mock.ExpectQuery("WHERE prourl = ANY").WithArgs(sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows(projectColumns).
+		AddRow("https://test.com/backend", "バックエンド保守運用", "サーバーサイドAPIの改修", "70万円", nil, "Java", nil, "test", "2026-10-01"))
+
+	params := SearchParams{KeySkills: []string{"Java"}, Query: "サーバーサイド保守 Java", SearchMode: SearchModeSemantic}
+	projects, searched, err := searchProjectsForAnalysis(context.Background(), params, analysisOutcome{Analyzer: AnalyzerRule})
+	if err != nil {
+		t.Fatalf("UT-VECTOR-004 FAIL: エラーが発生: %v", err)
+	}
+	if searched.SearchMode != SearchModeSemantic {
+		t.Errorf("UT-VECTOR-004 FAIL: 期待 semantic, 実際 %s", searched.SearchMode)
+	}
+	var urls []string
+	for _, p := range projects {
+		urls = append(urls, p.URL)
+		if p.Match == nil || p.Match.Similarity == nil || p.Match.BlendedScore == nil {
+			t.Errorf("UT-VECTOR-004 FAIL: %s に類似度の内訳がない", p.URL)
+		}
+	}
+	if len(urls) != 2 || urls[0] != "https://test.com/backend" {
+		t.Errorf("UT-VECTOR-004 FAIL: 意味の近い案件が先頭に来るべき（遠い案件は加えない）: %v", urls)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("UT-VECTOR-004 FAIL: %v", err)
+	}
+}
+
+// UT-VECTOR-005: 埋め込みに失敗したらキーワードの並びのまま返す
+func TestSearchProjectsForAnalysis_SemanticFallback(t *testing.T) {
+	original := embedder
+	embedder = failingEmbedder{}
+	defer func() { embedder = original }()
+
+	mockDB, mock := newMockDB(t)
+	defer mockDB.Close()
+	originalDB := db
+	db = mockDB
+	defer func() { db = originalDB }()
+
+	mock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(projectColumns).
+		AddRow("https://test.com/java", "Java開発", "業務システム", "60万円", nil, "Java", nil, "test", "2026-10-01"))
+
+	params := SearchParams{KeySkills: []string{"Java"}, Query: "Java", SearchMode: SearchModeSemantic}
+	projects, searched, err := searchProjectsForAnalysis(context.Background(), params, analysisOutcome{Analyzer: AnalyzerRule})
+	if err != nil {
+		t.Fatalf("UT-VECTOR-005 FAIL: エラーが発生: %v", err)
+	}
+	if searched.SearchMode != SearchModeKeyword || len(projects) != 1 {
+		t.Errorf("UT-VECTOR-005 FAIL: キーワード検索の結果を返すべき: %s %d件", searched.SearchMode, len(projects))
+	}
+	if projects[0].Match != nil && projects[0].Match.Similarity != nil {
+		t.Error("UT-VECTOR-005 FAIL: キーワード検索では類似度を付けない")
+	}
+}
+
+// UT-VECTOR-006: 検索方法はSEARCH_MODEが既定、知らない値は400
+func TestResolveSearchMode(t *testing.T) {
+	t.Setenv("SEARCH_MODE", "")
+	if mode, err := resolveSearchMode(""); err != nil || mode != SearchModeKeyword {
+		t.Errorf("UT-VECTOR-006 FAIL: 既定はkeyword: %s %v", mode, err)
+	}
+	t.Setenv("SEARCH_MODE", SearchModeSemantic)
+	if mode, _ := resolveSearchMode(""); mode != SearchModeSemantic {
+		t.Errorf("UT-VECTOR-006 FAIL: SEARCH_MODEに従うべき: %s", mode)
+	}
+	if mode, _ := resolveSearchMode(SearchModeKeyword); mode != SearchModeKeyword {
+		t.Errorf("UT-VECTOR-006 FAIL: リクエストの指定を優先すべき: %s", mode)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/chat", handleChat)
+
+	body, _ := json.Marshal(ChatRequest{Message: "Java 5年", SearchMode: "fuzzy"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/chat", bytes.NewReader(body)))
+	if w.Code != 400 || !strings.Contains(w.Body.String(), "unknown search mode") {
+		t.Errorf("UT-VECTOR-006 FAIL: 期待 400, 実際 %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * 案件の英訳モジュール
+ * 英語でスキルシートを書くエンジニア向けに、検索結果の案件タイトルと概要を設定中のLLMで英訳する
+ * リクエストでtranslateを指定したときだけ行い、失敗・タイムアウト時は訳なしで返す
+ * tbl_projectは日次バッチで入れ替わるので、訳は案件URLごとにTRANSLATION_CACHE_TTLの間使い回す
+ */
+
+// 英訳設定の構造体
+type TranslateConfig struct {
+	Timeout  time.Duration // AIの応答を待つ時間（超えたら訳なし）
+	CacheTTL time.Duration // 訳を使い回す時間
+}
+
+// 英訳の返答のスキーマ
+const translateSchemaJSON = `{
+  "type": "object",
+  "required": ["translations"],
+  "properties": {
+    "translations": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["id", "title", "summary"],
+        "properties": {
+          "id": {"type": "integer", "minimum": 0},
+          "title": {"type": "string"},
+          "summary": {"type": "string"}
+        }
+      }
+    }
+  }
+}`
+
+var translateSchema = mustParseSchema(translateSchemaJSON)
+
+const (
+	translateDetailMaxRunes = 400 // AIに渡す案件詳細の最大文字数
+	translateSummaryWords   = 40  // AIに求める概要の目安の単語数（プロンプトのパラメーター）
+)
+
+// AIの返答1件分
+type translateResult struct {
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+}
+
+// 使い回す訳1件分
+type cachedTranslation struct {
+	Title     string
+	Summary   string
+	ExpiresAt time.Time
+}
+
+var (
+	translationCacheMu sync.Mutex
+	translationCache   = map[string]cachedTranslation{} // プロンプトのバージョン + 案件URL → 訳
+)
+
+/**
+ * 環境変数から英訳設定を読み込む
+ */
+func LoadTranslateConfig() TranslateConfig {
+	timeout, err := time.ParseDuration(getEnvWithDefault("TRANSLATE_TIMEOUT", "15s"))
+	if err != nil || timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	return TranslateConfig{Timeout: timeout, CacheTTL: envDuration("TRANSLATION_CACHE_TTL", 24*time.Hour)}
+}
+
+/**
+ * 案件のタイトルと概要に英訳を付ける
+ * 使い回せる訳がない案件だけをまとめて1回でAIに依頼する
+ * @return bool すべての案件に訳を付けられたかどうか
+ */
+func translateProjects(ctx context.Context, projects []Project) ([]Project, bool) {
+	if len(projects) == 0 {
+		return projects, false
+	}
+	config := LoadTranslateConfig()
+	version := activePromptVersion(PromptTranslate)
+
+	translated := make([]Project, len(projects))
+	copy(translated, projects)
+
+	var pending []int
+	now := time.Now()
+	translationCacheMu.Lock()
+	for i, p := range translated {
+		if cached, ok := translationCache[version+"\x00"+p.URL]; ok && now.Before(cached.ExpiresAt) {
+			translated[i].TitleEn = cached.Title
+			translated[i].SummaryEn = cached.Summary
+			continue
+		}
+		pending = append(pending, i)
+	}
+	translationCacheMu.Unlock()
+
+	if len(pending) == 0 {
+		return translated, true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	targets := make([]Project, len(pending))
+	for i, idx := range pending {
+		targets[i] = translated[idx]
+	}
+	results, err := requestTranslation(ctx, targets)
+	if err != nil {
+		log.Printf("Translation skipped: %v", err)
+		return projects, false
+	}
+
+	done := make([]bool, len(pending))
+	translationCacheMu.Lock()
+	// 期限切れの訳はここで捨てる
+	for key, cached := range translationCache {
+		if now.After(cached.ExpiresAt) {
+			delete(translationCache, key)
+		}
+	}
+	for _, r := range results {
+		if r.ID < 0 || r.ID >= len(pending) || done[r.ID] || strings.TrimSpace(r.Title) == "" {
+			continue
+		}
+		p := &translated[pending[r.ID]]
+		p.TitleEn = strings.TrimSpace(r.Title)
+		p.SummaryEn = strings.TrimSpace(r.Summary)
+		translationCache[version+"\x00"+p.URL] = cachedTranslation{Title: p.TitleEn, Summary: p.SummaryEn, ExpiresAt: now.Add(config.CacheTTL)}
+		done[r.ID] = true
+	}
+	translationCacheMu.Unlock()
+
+	for _, ok := range done {
+		if !ok {
+			return translated, false
+		}
+	}
+	return translated, true
+}
+
+/**
+ * AIに案件の英訳を依頼する
+ */
+func requestTranslation(ctx context.Context, projects []Project) ([]translateResult, error) {
+	provider, err := getLLMProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	systemPrompt, version, err := renderPrompt(PromptTranslate, translatePromptData{SummaryWords: translateSummaryWords})
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Projects:\n")
+	for i, p := range projects {
+		fmt.Fprintf(&sb, "[ID:%d] %s / スキル: %s / 単価: %s / 期間: %s / 詳細: %s\n",
+			i, p.Title, p.Skills, p.Price, p.Period, truncateRunes(strings.Join(strings.Fields(p.Detail), " "), translateDetailMaxRunes))
+	}
+
+	result, err := provider.Chat(withPromptVersion(withAIPurpose(ctx, AIPurposeTranslate), version), []AIChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: sb.String()},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jsonText := extractJSONText(result.Content)
+	var raw interface{}
+	if err := json.Unmarshal([]byte(jsonText), &raw); err != nil {
+		return nil, fmt.Errorf("invalid translation JSON: %v", err)
+	}
+	if errs := validateSchema(translateSchema, raw, "$"); len(errs) > 0 {
+		return nil, fmt.Errorf("translation response failed schema validation: %s", strings.Join(errs, "; "))
+	}
+
+	var response struct {
+		Translations []translateResult `json:"translations"`
+	}
+	if err := json.Unmarshal([]byte(jsonText), &response); err != nil {
+		return nil, fmt.Errorf("invalid translation JSON: %v", err)
+	}
+	if len(response.Translations) == 0 {
+		return nil, fmt.Errorf("translation response has no translations")
+	}
+
+	return response.Translations, nil
+}
+
+/**
+ * フォーム・クエリのtranslateの値（"true" / "1" など）を読む。読めなければfalse
+ */
+func parseTranslateFlag(value string) bool {
+	translate, err := strconv.ParseBool(value)
+	return err == nil && translate
+}
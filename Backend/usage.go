@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+/**
+ * AI使用量の記録モジュール
+ * LLMの呼び出しごとにトークン数・モデル・応答時間・成否をtbl_aiusageに記録し、
+ * 管理者向けに日別・モデル別の集計と概算コストを返す
+ * 無料枠をどれだけ使ったかを、429が返ってくる前に把握するためのもの
+ */
+
+// 呼び出しの用途
+const (
+	AIPurposeAnalysis   = "analysis"   // 初回のスキル解析
+	AIPurposeRefine     = "refine"     // セッションでの絞り込み
+	AIPurposeRerank     = "rerank"     // 検索結果の並べ直し
+	AIPurposeSkillSheet = "skillsheet" // スキルシートの強み・提案
+	AIPurposeEmbedding  = "embedding"  // 案件・検索条件のベクトル化
+	AIPurposeTranslate  = "translate"  // 案件の英訳
+	AIPurposeOther      = "other"      // 用途の指定なし
+)
+
+// 呼び出しの成否
+const (
+	aiUsageStatusOK    = "ok"
+	aiUsageStatusError = "error"
+)
+
+// 記録するエラーメッセージの最大文字数
+const aiUsageErrorMaxRunes = 500
+
+// 集計期間の既定値と上限（日）
+const (
+	defaultAIUsageDays = 30
+	maxAIUsageDays     = 365
+)
+
+// モデルごとの単価（USD / 100万トークン）
+type ModelPrice struct {
+	Prompt     float64 `json:"prompt"`     // 入力トークン
+	Completion float64 `json:"completion"` // 出力トークン
+}
+
+// 既定の単価表（AI_PRICE_TABLEで上書き・追加できる）
+var defaultModelPrices = map[string]ModelPrice{
+	"openai/gpt-3.5-turbo":   {Prompt: 0.5, Completion: 1.5},
+	"openai/gpt-4o-mini":     {Prompt: 0.15, Completion: 0.6},
+	"gpt-3.5-turbo":          {Prompt: 0.5, Completion: 1.5},
+	"gpt-4o-mini":            {Prompt: 0.15, Completion: 0.6},
+	"gemini-1.5-flash":       {Prompt: 0.075, Completion: 0.3},
+	"gemini-1.5-pro":         {Prompt: 1.25, Completion: 5},
+	"text-embedding-3-small": {Prompt: 0.02},
+	"text-embedding-3-large": {Prompt: 0.13},
+	"mock":                   {},
+}
+
+// 使用量1件分
+type aiUsageRecord struct {
+	Provider  string
+	Model     string
+	Purpose   string
+	Prompt    string // プロンプトのバージョン
+	Usage     LLMUsage
+	LatencyMs int64
+	Status    string
+	Error     string
+}
+
+// 集計結果1行分（日別・モデル別・合計で共通）
+type AIUsageSummary struct {
+	Date             string  `json:"date,omitempty"`           // 日付（日別のみ）
+	Model            string  `json:"model,omitempty"`          // モデル名（モデル別のみ）
+	Purpose          string  `json:"purpose,omitempty"`        // 用途（プロンプト別のみ）
+	PromptVersion    string  `json:"prompt_version,omitempty"` // プロンプトのバージョン（プロンプト別のみ）
+	Calls            int     `json:"calls"`
+	Errors           int     `json:"errors"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+	Priced           *bool   `json:"priced,omitempty"` // 単価表にモデルがあったか（モデル別のみ）
+}
+
+type aiPurposeKey struct{}
+
+/**
+ * 呼び出しの用途をcontextに付ける
+ */
+func withAIPurpose(ctx context.Context, purpose string) context.Context {
+	return context.WithValue(ctx, aiPurposeKey{}, purpose)
+}
+
+/**
+ * contextから呼び出しの用途を取り出す（指定がなければother）
+ */
+func aiPurposeFromContext(ctx context.Context) string {
+	if purpose, ok := ctx.Value(aiPurposeKey{}).(string); ok && purpose != "" {
+		return purpose
+	}
+	return AIPurposeOther
+}
+
+// 使用量を記録するプロバイダーのラッパー
+type meteredProvider struct {
+	LLMProvider
+}
+
+// ストリーミング対応のプロバイダー用（StreamingLLMProviderの判定を保つため別の型にする）
+type meteredStreamingProvider struct {
+	meteredProvider
+	streaming StreamingLLMProvider
+}
+
+/**
+ * プロバイダーに使用量の記録を被せる
+ */
+func withUsageMetering(provider LLMProvider) LLMProvider {
+	switch p := provider.(type) {
+	case *meteredProvider, *meteredStreamingProvider:
+		return provider
+	case StreamingLLMProvider:
+		return &meteredStreamingProvider{meteredProvider: meteredProvider{p}, streaming: p}
+	default:
+		return &meteredProvider{provider}
+	}
+}
+
+func (m *meteredProvider) Chat(ctx context.Context, messages []AIChatMessage) (LLMResult, error) {
+	start := time.Now()
+	result, err := m.LLMProvider.Chat(ctx, messages)
+	m.record(ctx, result, err, time.Since(start))
+	return result, err
+}
+
+func (m *meteredStreamingProvider) ChatStream(ctx context.Context, messages []AIChatMessage, onDelta func(string)) (LLMResult, error) {
+	start := time.Now()
+	result, err := m.streaming.ChatStream(ctx, messages, onDelta)
+	m.record(ctx, result, err, time.Since(start))
+	return result, err
+}
+
+func (m *meteredProvider) record(ctx context.Context, result LLMResult, err error, latency time.Duration) {
+	record := aiUsageRecord{
+		Provider:  m.Name(),
+		Model:     result.Model,
+		Purpose:   aiPurposeFromContext(ctx),
+		Prompt:    promptVersionFromContext(ctx),
+		Usage:     result.Usage,
+		LatencyMs: latency.Milliseconds(),
+		Status:    aiUsageStatusOK,
+	}
+	if record.Model == "" {
+		record.Model = m.Model()
+	}
+	if record.Usage.TotalTokens == 0 {
+		record.Usage.TotalTokens = record.Usage.PromptTokens + record.Usage.CompletionTokens
+	}
+	if err != nil {
+		record.Status = aiUsageStatusError
+		record.Error = truncateRunes(err.Error(), aiUsageErrorMaxRunes)
+	}
+	recordAIUsage(record)
+}
+
+/**
+ * 使用量をtbl_aiusageに保存する
+ * 記録の失敗でAIの結果を捨てないよう、エラーはログに出すだけにする
+ */
+func recordAIUsage(record aiUsageRecord) {
+	if db == nil {
+		return
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO tbl_aiusage (aiuprv, aiumdl, aiupur, aiupvr, aiuptk, aiuctk, aiuttk, aiulat, aiusts, aiuerr)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, record.Provider, record.Model, record.Purpose, record.Prompt,
+		record.Usage.PromptTokens, record.Usage.CompletionTokens, record.Usage.TotalTokens,
+		record.LatencyMs, record.Status, record.Error)
+	if err != nil {
+		log.Printf("AI usage record error: %v", err)
+	}
+}
+
+/**
+ * 単価表を読み込む
+ * AI_PRICE_TABLEにJSON（{"モデル名": {"prompt": 0.5, "completion": 1.5}}）があれば既定の表に重ねる
+ */
+func loadModelPrices() map[string]ModelPrice {
+	prices := map[string]ModelPrice{}
+	for model, price := range defaultModelPrices {
+		prices[model] = price
+	}
+
+	raw := getEnvWithDefault("AI_PRICE_TABLE", "")
+	if raw == "" {
+		return prices
+	}
+
+	var overrides map[string]ModelPrice
+	if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+		log.Printf("Invalid AI_PRICE_TABLE, using default prices: %v", err)
+		return prices
+	}
+	for model, price := range overrides {
+		prices[model] = price
+	}
+	return prices
+}
+
+/**
+ * モデル名に対応する単価を探す
+ * 完全一致がなければ最長の前方一致を使う（"gemini-1.5-flash-002" → "gemini-1.5-flash"）
+ * @return bool 単価表にあったかどうか
+ */
+func lookupModelPrice(prices map[string]ModelPrice, model string) (ModelPrice, bool) {
+	if price, ok := prices[model]; ok {
+		return price, true
+	}
+
+	best := ""
+	for name := range prices {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return prices[best], true
+}
+
+/**
+ * トークン数から概算コスト（USD）を計算
+ */
+func estimateAICost(price ModelPrice, promptTokens, completionTokens int64) float64 {
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1000000
+}
+
+/**
+ * 直近days日分の使用量を日別・モデル別に集計する
+ */
+func loadAIUsageReport(days int) (daily, models []AIUsageSummary, total AIUsageSummary, err error) {
+	rows, err := db.Query(`
+		SELECT to_char(date_trunc('day', aiucrt), 'YYYY-MM-DD'), aiumdl,
+			COUNT(*), COUNT(*) FILTER (WHERE aiusts <> 'ok'),
+			COALESCE(SUM(aiuptk), 0), COALESCE(SUM(aiuctk), 0), COALESCE(SUM(aiuttk), 0),
+			COALESCE(AVG(aiulat), 0)
+		FROM tbl_aiusage
+		WHERE aiucrt >= NOW() - make_interval(days => $1)
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, days)
+	if err != nil {
+		return nil, nil, total, fmt.Errorf("failed to load AI usage: %v", err)
+	}
+	defer rows.Close()
+
+	prices := loadModelPrices()
+	dailyIndex := map[string]int{}
+	modelIndex := map[string]int{}
+	daily = []AIUsageSummary{}
+	models = []AIUsageSummary{}
+
+	for rows.Next() {
+		var row AIUsageSummary
+		if err := rows.Scan(&row.Date, &row.Model, &row.Calls, &row.Errors,
+			&row.PromptTokens, &row.CompletionTokens, &row.TotalTokens, &row.AvgLatencyMs); err != nil {
+			return nil, nil, total, fmt.Errorf("failed to scan AI usage row: %v", err)
+		}
+		price, priced := lookupModelPrice(prices, row.Model)
+		row.EstimatedCostUSD = estimateAICost(price, row.PromptTokens, row.CompletionTokens)
+
+		i, ok := dailyIndex[row.Date]
+		if !ok {
+			i = len(daily)
+			dailyIndex[row.Date] = i
+			daily = append(daily, AIUsageSummary{Date: row.Date})
+		}
+		daily[i] = addAIUsage(daily[i], row)
+
+		j, ok := modelIndex[row.Model]
+		if !ok {
+			j = len(models)
+			modelIndex[row.Model] = j
+			models = append(models, AIUsageSummary{Model: row.Model, Priced: &priced})
+		}
+		models[j] = addAIUsage(models[j], row)
+
+		total = addAIUsage(total, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, total, fmt.Errorf("row iteration error: %v", err)
+	}
+
+	// モデル別は概算コストの高い順
+	sort.SliceStable(models, func(i, j int) bool {
+		return models[i].EstimatedCostUSD > models[j].EstimatedCostUSD
+	})
+
+	return daily, models, total, nil
+}
+
+/**
+ * 直近days日分の使用量を用途・プロンプトのバージョン別に集計する
+ * バージョンを切り替えたときに、エラー率やトークン数を比べるためのもの
+ */
+func loadAIUsageByPrompt(days int) ([]AIUsageSummary, error) {
+	rows, err := db.Query(`
+		SELECT aiupur, aiupvr,
+			COUNT(*), COUNT(*) FILTER (WHERE aiusts <> 'ok'),
+			COALESCE(SUM(aiuptk), 0), COALESCE(SUM(aiuctk), 0), COALESCE(SUM(aiuttk), 0),
+			COALESCE(AVG(aiulat), 0)
+		FROM tbl_aiusage
+		WHERE aiucrt >= NOW() - make_interval(days => $1)
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AI usage by prompt: %v", err)
+	}
+	defer rows.Close()
+
+	prompts := []AIUsageSummary{}
+	for rows.Next() {
+		var row AIUsageSummary
+		if err := rows.Scan(&row.Purpose, &row.PromptVersion, &row.Calls, &row.Errors,
+			&row.PromptTokens, &row.CompletionTokens, &row.TotalTokens, &row.AvgLatencyMs); err != nil {
+			return nil, fmt.Errorf("failed to scan AI usage row: %v", err)
+		}
+		prompts = append(prompts, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %v", err)
+	}
+
+	return prompts, nil
+}
+
+/**
+ * 集計行を足し合わせる（平均応答時間は呼び出し回数で重み付け）
+ */
+func addAIUsage(sum, row AIUsageSummary) AIUsageSummary {
+	calls := sum.Calls + row.Calls
+	if calls > 0 {
+		sum.AvgLatencyMs = (sum.AvgLatencyMs*float64(sum.Calls) + row.AvgLatencyMs*float64(row.Calls)) / float64(calls)
+	}
+	sum.Calls = calls
+	sum.Errors += row.Errors
+	sum.PromptTokens += row.PromptTokens
+	sum.CompletionTokens += row.CompletionTokens
+	sum.TotalTokens += row.TotalTokens
+	sum.EstimatedCostUSD += row.EstimatedCostUSD
+	return sum
+}
+
+/**
+ * AI使用量の集計を返す管理者用ハンドラー
+ * ?days=N で集計期間を指定（デフォルト30日、最大365日）
+ */
+func handleGetAIUsage(c *gin.Context) {
+	days := defaultAIUsageDays
+	if raw := c.Query("days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxAIUsageDays {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxAIUsageDays)})
+			return
+		}
+		days = parsed
+	}
+
+	daily, models, total, err := loadAIUsageReport(days)
+	if err != nil {
+		log.Printf("AI usage report error: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to load AI usage"})
+		return
+	}
+
+	prompts, err := loadAIUsageByPrompt(days)
+	if err != nil {
+		log.Printf("AI usage report error: %v", err)
+		c.JSON(500, gin.H{"error": "Failed to load AI usage"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"days":    days,
+		"daily":   daily,
+		"models":  models,
+		"prompts": prompts,
+		"total":   total,
+	})
+}
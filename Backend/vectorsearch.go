@@ -0,0 +1,610 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+/**
+ * 意味検索モジュール
+ * ILIKE・正規表現の検索では「サーバーサイド保守」がバックエンドの仕事だとわからないため、
+ * 案件と検索条件をベクトルにして（embedding.go）、近さとキーワードのスコアを混ぜて並べる
+ *
+ * 案件のベクトルはtbl_projectvecに保存し、検索はpgvectorがあればDBで、なければGoでコサイン類似度を計算する
+ */
+
+// 検索方法
+const (
+	SearchModeKeyword  = "keyword"  // スキル名の一致だけで探す（従来の検索）
+	SearchModeSemantic = "semantic" // キーワードのスコアと意味の近さを混ぜる
+)
+
+// ベクトルの検索先
+const (
+	VectorStorePgvector = "pgvector" // DBでpgvectorの<=>を使う
+	VectorStoreMemory   = "memory"   // tbl_projectvecを読み込み、Goで計算する
+)
+
+const (
+	semanticCandidateLimit = 50   // 意味の近さで取り出す候補の件数
+	semanticQueryMaxRunes  = 1000 // 検索条件のベクトルに含めるメッセージの最大文字数
+	embeddingTextMaxRunes  = 1000 // 案件のベクトルに含める詳細の最大文字数
+	embeddingSyncChunkSize = 100  // 同期で1度にベクトル化・保存する件数
+)
+
+// 意味検索の設定
+type SemanticConfig struct {
+	Weight        float64 // 意味の近さの重み（0〜1、残りがキーワードのスコア）
+	MinSimilarity float64 // これより遠い案件は、キーワードで見つかっていなければ候補に加えない
+}
+
+// 意味の近さ1件分
+type vectorHit struct {
+	URL        string
+	Similarity float64
+}
+
+// 案件のベクトルの検索先
+type projectVectorStore interface {
+	Name() string
+	Nearest(ctx context.Context, model string, query []float32, limit int) ([]vectorHit, error)
+	Similarities(ctx context.Context, model string, query []float32, urls []string) (map[string]float64, error)
+}
+
+// 埋め込みの同期結果
+type EmbeddingSyncResult struct {
+	Model    string `json:"model"`    // 埋め込みのモデル名
+	Store    string `json:"store"`    // ベクトルの検索先（pgvector / memory）
+	Checked  int    `json:"checked"`  // 確認した案件数
+	Embedded int    `json:"embedded"` // 新しく（作り直して）ベクトルにした案件数
+}
+
+var (
+	// pgvectorが使えるかどうか（起動後に1度だけ確認する）
+	pgvectorMu        sync.Mutex
+	pgvectorAvailable *bool
+
+	// memoryの検索先が読み込んだベクトル
+	projectVectorsMu       sync.Mutex
+	projectVectors         map[string][]float32
+	projectVectorsModel    string
+	projectVectorsLoadedAt time.Time
+
+	// 同期を同時に走らせない
+	embeddingSyncMu         sync.Mutex
+	errEmbeddingSyncRunning = errors.New("embedding sync is already running")
+)
+
+/**
+ * 環境変数から意味検索の設定を読み込む
+ */
+func LoadSemanticConfig() SemanticConfig {
+	weight, err := strconv.ParseFloat(getEnvWithDefault("SEMANTIC_WEIGHT", "0.5"), 64)
+	if err != nil || weight < 0 || weight > 1 {
+		weight = 0.5
+	}
+	minSimilarity, err := strconv.ParseFloat(getEnvWithDefault("SEMANTIC_MIN_SIMILARITY", "0.2"), 64)
+	if err != nil {
+		minSimilarity = 0.2
+	}
+	return SemanticConfig{Weight: weight, MinSimilarity: minSimilarity}
+}
+
+/**
+ * 検索方法を決める
+ * リクエストで指定があればそれを、なければSEARCH_MODE（デフォルトkeyword）を使う
+ */
+func resolveSearchMode(requested string) (string, error) {
+	mode := requested
+	if mode == "" {
+		mode = getEnvWithDefault("SEARCH_MODE", SearchModeKeyword)
+	}
+	switch mode {
+	case SearchModeKeyword, SearchModeSemantic:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown search mode: %s", mode)
+	}
+}
+
+/**
+ * 意味検索に使う検索条件の文章
+ * 分析結果の検索用プロンプト・希望する役割・重点スキルに、メッセージ（長ければ先頭）を足す
+ */
+func semanticQueryText(message string, analysis AIAnalysis) string {
+	var parts []string
+	for _, part := range []string{analysis.SearchPrompt, analysis.PreferredRole, strings.Join(analysis.KeySkills, " ")} {
+		if strings.TrimSpace(part) != "" {
+			parts = append(parts, part)
+		}
+	}
+	if strings.TrimSpace(message) != "" {
+		parts = append(parts, truncateRunes(message, semanticQueryMaxRunes))
+	}
+	return strings.Join(parts, "\n")
+}
+
+/**
+ * キーワード検索の候補に意味の近さを混ぜて並べ直す
+ * 意味の近い案件はキーワードで見つかっていなくても候補に加える
+ * 並べる値は (1-w) × キーワードのスコア（候補内の最大で割る） + w × コサイン類似度
+ */
+func applySemanticSearch(ctx context.Context, params SearchParams, candidates []rankedProject) ([]rankedProject, error) {
+	query := params.Query
+	if strings.TrimSpace(query) == "" {
+		query = strings.Join(params.KeySkills, " ")
+	}
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("no text to embed for semantic search")
+	}
+
+	emb, err := getEmbedder()
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := emb.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %v", err)
+	}
+	queryVector := vectors[0]
+
+	store, err := getProjectVectorStore()
+	if err != nil {
+		return nil, err
+	}
+	hits, err := store.Nearest(ctx, emb.Model(), queryVector, semanticCandidateLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	config := LoadSemanticConfig()
+	similarities := map[string]float64{}
+	known := map[string]bool{}
+	for _, c := range candidates {
+		known[c.URL] = true
+	}
+	var extraURLs []string
+	for _, h := range hits {
+		similarities[h.URL] = h.Similarity
+		if !known[h.URL] && h.Similarity >= config.MinSimilarity {
+			extraURLs = append(extraURLs, h.URL)
+		}
+	}
+
+	// キーワードで見つかった候補のうち、近い順の上位に入らなかったものの近さ
+	var missing []string
+	for _, c := range candidates {
+		if _, ok := similarities[c.URL]; !ok {
+			missing = append(missing, c.URL)
+		}
+	}
+	if len(missing) > 0 {
+		extra, err := store.Similarities(ctx, emb.Model(), queryVector, missing)
+		if err != nil {
+			return nil, err
+		}
+		for url, sim := range extra {
+			similarities[url] = sim
+		}
+	}
+
+	if len(extraURLs) > 0 {
+		projects, err := loadProjectsByURL(extraURLs, params.Filter)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, scoreCandidates(projects, weightedSearchSkills(params), params)...)
+	}
+
+	blendSemanticScores(candidates, similarities, config.Weight)
+	return candidates, nil
+}
+
+/**
+ * キーワードのスコアと意味の近さを混ぜた値を付け、その高い順に並べる
+ */
+func blendSemanticScores(candidates []rankedProject, similarities map[string]float64, weight float64) {
+	maxKeyword := 0
+	for _, c := range candidates {
+		if score := c.MatchScore + c.PriceScore; score > maxKeyword {
+			maxKeyword = score
+		}
+	}
+
+	for i := range candidates {
+		c := &candidates[i]
+		keyword := 0.0
+		if maxKeyword > 0 {
+			keyword = float64(c.MatchScore+c.PriceScore) / float64(maxKeyword)
+		}
+		similarity := math.Max(similarities[c.URL], 0)
+		c.Similarity = similarity
+		c.BlendedScore = (1-weight)*keyword + weight*similarity
+
+		if c.Match != nil {
+			roundedSimilarity := math.Round(similarity*1000) / 1000
+			roundedBlended := math.Round(c.BlendedScore*1000) / 1000
+			c.Match.Similarity = &roundedSimilarity
+			c.Match.BlendedScore = &roundedBlended
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].BlendedScore > candidates[j].BlendedScore
+	})
+}
+
+/**
+ * URLを指定して案件を読み込む（絞り込み条件に合わない案件は読み込まない）
+ */
+func loadProjectsByURL(urls []string, filter *ProjectFilter) ([]Project, error) {
+	conditions, args := filter.sqlConditions([]interface{}{pq.Array(urls)})
+	rows, err := db.Query(`
+		SELECT prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt
+		FROM tbl_project
+		WHERE `+strings.Join(append([]string{"prourl = ANY($1)"}, conditions...), " AND "), args...)
+	if err != nil {
+		return nil, fmt.Errorf("database query failed: %v", err)
+	}
+	defer rows.Close()
+
+	return scanProjects(rows)
+}
+
+// ============================================================
+// ベクトルの検索先
+// ============================================================
+
+/**
+ * ベクトルの検索先を決める
+ * VECTOR_STORE=auto（デフォルト）のときは、DBにpgvector拡張が入っていればpgvector、なければmemory
+ */
+func getProjectVectorStore() (projectVectorStore, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	switch getEnvWithDefault("VECTOR_STORE", "auto") {
+	case VectorStorePgvector:
+		return pgvectorStore{}, nil
+	case VectorStoreMemory:
+		return memoryVectorStore{}, nil
+	case "auto":
+		if hasPgvector() {
+			return pgvectorStore{}, nil
+		}
+		return memoryVectorStore{}, nil
+	default:
+		return nil, fmt.Errorf("unknown vector store: %s", getEnvWithDefault("VECTOR_STORE", "auto"))
+	}
+}
+
+/**
+ * DBにpgvector拡張が入っているか（確認に失敗したら入っていないものとして扱う）
+ */
+func hasPgvector() bool {
+	pgvectorMu.Lock()
+	defer pgvectorMu.Unlock()
+
+	if pgvectorAvailable != nil {
+		return *pgvectorAvailable
+	}
+	available := false
+	if err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector')`).Scan(&available); err != nil {
+		log.Printf("pgvector check failed, using in-memory vector search: %v", err)
+		available = false
+	}
+	pgvectorAvailable = &available
+	return available
+}
+
+/**
+ * pgvectorに渡すベクトルの文字列（"[0.1,0.2,...]"）
+ */
+func formatPgvector(vector []float32) string {
+	parts := make([]string, len(vector))
+	for i, v := range vector {
+		parts[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// pgvectorの<=>（コサイン距離）で計算する検索先
+type pgvectorStore struct{}
+
+func (pgvectorStore) Name() string { return VectorStorePgvector }
+
+func (pgvectorStore) Nearest(ctx context.Context, model string, query []float32, limit int) ([]vectorHit, error) {
+	return queryVectorHits(ctx, `
+		SELECT pvcurl, 1 - (pvcvec::vector <=> $1::vector) AS similarity
+		FROM tbl_projectvec
+		WHERE pvcmdl = $2
+		ORDER BY pvcvec::vector <=> $1::vector
+		LIMIT $3
+	`, formatPgvector(query), model, limit)
+}
+
+func (pgvectorStore) Similarities(ctx context.Context, model string, query []float32, urls []string) (map[string]float64, error) {
+	hits, err := queryVectorHits(ctx, `
+		SELECT pvcurl, 1 - (pvcvec::vector <=> $1::vector) AS similarity
+		FROM tbl_projectvec
+		WHERE pvcmdl = $2 AND pvcurl = ANY($3)
+	`, formatPgvector(query), model, pq.Array(urls))
+	if err != nil {
+		return nil, err
+	}
+	similarities := map[string]float64{}
+	for _, h := range hits {
+		similarities[h.URL] = h.Similarity
+	}
+	return similarities, nil
+}
+
+func queryVectorHits(ctx context.Context, query string, args ...interface{}) ([]vectorHit, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("vector search failed: %v", err)
+	}
+	defer rows.Close()
+
+	var hits []vectorHit
+	for rows.Next() {
+		var h vectorHit
+		if err := rows.Scan(&h.URL, &h.Similarity); err != nil {
+			log.Printf("Row scan error: %v", err)
+			continue
+		}
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %v", err)
+	}
+	return hits, nil
+}
+
+// tbl_projectvecを読み込んでGoで計算する検索先
+type memoryVectorStore struct{}
+
+func (memoryVectorStore) Name() string { return VectorStoreMemory }
+
+func (memoryVectorStore) Nearest(ctx context.Context, model string, query []float32, limit int) ([]vectorHit, error) {
+	vectors, err := getProjectVectors(model)
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]vectorHit, 0, len(vectors))
+	for url, v := range vectors {
+		hits = append(hits, vectorHit{URL: url, Similarity: cosineSimilarity(query, v)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Similarity != hits[j].Similarity {
+			return hits[i].Similarity > hits[j].Similarity
+		}
+		return hits[i].URL < hits[j].URL
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+func (memoryVectorStore) Similarities(ctx context.Context, model string, query []float32, urls []string) (map[string]float64, error) {
+	vectors, err := getProjectVectors(model)
+	if err != nil {
+		return nil, err
+	}
+	similarities := map[string]float64{}
+	for _, url := range urls {
+		if v, ok := vectors[url]; ok {
+			similarities[url] = cosineSimilarity(query, v)
+		}
+	}
+	return similarities, nil
+}
+
+/**
+ * モデルの案件ベクトルをすべて返す
+ * EMBEDDING_CACHE_TTL（デフォルト10分）の間は前回読み込んだものを使う。同期したら読み直す
+ */
+func getProjectVectors(model string) (map[string][]float32, error) {
+	projectVectorsMu.Lock()
+	defer projectVectorsMu.Unlock()
+
+	ttl := envDuration("EMBEDDING_CACHE_TTL", 10*time.Minute)
+	if projectVectors != nil && projectVectorsModel == model && time.Since(projectVectorsLoadedAt) < ttl {
+		return projectVectors, nil
+	}
+
+	rows, err := db.Query(`SELECT pvcurl, pvcvec FROM tbl_projectvec WHERE pvcmdl = $1`, model)
+	if err != nil {
+		return nil, fmt.Errorf("database query failed: %v", err)
+	}
+	defer rows.Close()
+
+	vectors := map[string][]float32{}
+	for rows.Next() {
+		var url string
+		var vector pq.Float32Array
+		if err := rows.Scan(&url, &vector); err != nil {
+			log.Printf("Row scan error: %v", err)
+			continue
+		}
+		vectors[url] = vector
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %v", err)
+	}
+
+	projectVectors = vectors
+	projectVectorsModel = model
+	projectVectorsLoadedAt = time.Now()
+	return projectVectors, nil
+}
+
+/**
+ * 読み込んだ案件ベクトルを捨てる（次の検索で読み直す）
+ */
+func invalidateProjectVectors() {
+	projectVectorsMu.Lock()
+	projectVectors = nil
+	projectVectorsMu.Unlock()
+}
+
+// ============================================================
+// 案件ベクトルの同期
+// ============================================================
+
+/**
+ * 案件のベクトルに含める文章（タイトル・スキル欄・詳細の先頭）
+ */
+func projectEmbeddingText(title, skills, detail string) string {
+	return strings.TrimSpace(title + "\n" + skills + "\n" + truncateRunes(detail, embeddingTextMaxRunes))
+}
+
+/**
+ * 文章のハッシュ（ベクトルを作り直すかどうかの判定用）
+ */
+func embeddingTextHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+/**
+ * ベクトルがない・内容が変わった案件をベクトルにしてtbl_projectvecに保存する
+ * tbl_projectは日次バッチで入れ替わるので、新しい順にEMBEDDING_SYNC_MAX_PROJECTS（デフォルト5000）件まで確認する
+ */
+func syncProjectEmbeddings(ctx context.Context) (EmbeddingSyncResult, error) {
+	if !embeddingSyncMu.TryLock() {
+		return EmbeddingSyncResult{}, errEmbeddingSyncRunning
+	}
+	defer embeddingSyncMu.Unlock()
+
+	if db == nil {
+		return EmbeddingSyncResult{}, fmt.Errorf("database connection is nil")
+	}
+	emb, err := getEmbedder()
+	if err != nil {
+		return EmbeddingSyncResult{}, err
+	}
+	store, err := getProjectVectorStore()
+	if err != nil {
+		return EmbeddingSyncResult{}, err
+	}
+	result := EmbeddingSyncResult{Model: emb.Model(), Store: store.Name()}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.prourl, p.prottl, p.prodtl, p.proot1, v.pvchsh
+		FROM tbl_project p
+		LEFT JOIN tbl_projectvec v ON v.pvcurl = p.prourl AND v.pvcmdl = $1
+		ORDER BY p.procrt DESC
+		LIMIT $2
+	`, emb.Model(), envInt("EMBEDDING_SYNC_MAX_PROJECTS", 5000))
+	if err != nil {
+		return result, fmt.Errorf("database query failed: %v", err)
+	}
+
+	type pendingProject struct {
+		url, text, hash string
+	}
+	var pending []pendingProject
+	for rows.Next() {
+		var url string
+		var title, detail, skills, storedHash *string
+		if err := rows.Scan(&url, &title, &detail, &skills, &storedHash); err != nil {
+			log.Printf("Row scan error: %v", err)
+			continue
+		}
+		result.Checked++
+		text := projectEmbeddingText(stringValue(title), stringValue(skills), stringValue(detail))
+		hash := embeddingTextHash(text)
+		if text == "" || stringValue(storedHash) == hash {
+			continue
+		}
+		pending = append(pending, pendingProject{url: url, text: text, hash: hash})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, fmt.Errorf("row iteration error: %v", err)
+	}
+
+	// 途中で失敗しても、保存できた分は次の検索から使う
+	defer invalidateProjectVectors()
+	for start := 0; start < len(pending); start += embeddingSyncChunkSize {
+		end := start + embeddingSyncChunkSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		texts := make([]string, end-start)
+		for i, p := range pending[start:end] {
+			texts[i] = p.text
+		}
+
+		vectors, err := emb.Embed(ctx, texts)
+		if err != nil {
+			return result, fmt.Errorf("failed to embed projects: %v", err)
+		}
+		for i, p := range pending[start:end] {
+			if _, err := db.ExecContext(ctx, `
+				INSERT INTO tbl_projectvec (pvcurl, pvcmdl, pvchsh, pvcvec, pvcupd)
+				VALUES ($1, $2, $3, $4, now())
+				ON CONFLICT (pvcurl, pvcmdl) DO UPDATE
+				SET pvchsh = EXCLUDED.pvchsh, pvcvec = EXCLUDED.pvcvec, pvcupd = EXCLUDED.pvcupd
+			`, p.url, emb.Model(), p.hash, pq.Array(vectors[i])); err != nil {
+				return result, fmt.Errorf("failed to save project vector: %v", err)
+			}
+			result.Embedded++
+		}
+	}
+
+	return result, nil
+}
+
+/**
+ * 意味検索を使う設定なら、起動時とEMBEDDING_SYNC_INTERVAL（デフォルト6時間）ごとに案件ベクトルを同期する
+ */
+func startEmbeddingSync() {
+	if mode, err := resolveSearchMode(""); err != nil || mode != SearchModeSemantic {
+		return
+	}
+	interval := envDuration("EMBEDDING_SYNC_INTERVAL", 6*time.Hour)
+	go func() {
+		for {
+			result, err := syncProjectEmbeddings(context.Background())
+			if err != nil {
+				log.Printf("Embedding sync error: %v", err)
+			} else {
+				log.Printf("Embedding sync finished: model=%s store=%s checked=%d embedded=%d", result.Model, result.Store, result.Checked, result.Embedded)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+/**
+ * 管理者向け: 案件ベクトルをすぐに同期する
+ */
+func handleSyncEmbeddings(c *gin.Context) {
+	result, err := syncProjectEmbeddings(c.Request.Context())
+	if err == errEmbeddingSyncRunning {
+		c.JSON(409, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Embedding sync error: %v", err)
+		c.JSON(500, gin.H{"error": err.Error(), "result": result})
+		return
+	}
+	c.JSON(200, result)
+}
@@ -2,20 +2,30 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
 	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // =====================
@@ -23,23 +33,31 @@ import (
 // =====================
 
 type ChatRequest struct {
-	Message string `json:"message" binding:"required"`
+	Message string         `json:"message" binding:"required"`
+	Filters *ProjectFilter `json:"filters,omitempty"` // AIがメッセージから取り出した条件と合わせて使う
+	// Vercel版は会話セッションを持たず前回の条件がないので、受け取っても何もしない
+	ClearFilters bool `json:"clear_filters,omitempty"`
 }
 
 type ChatResponse struct {
-	AIAnalysis AIAnalysis `json:"ai_analysis"`
-	Projects   []Project  `json:"projects"`
+	AIAnalysis    AIAnalysis     `json:"ai_analysis"`
+	Projects      []Project      `json:"projects"`
+	PromptVersion string         `json:"prompt_version,omitempty"`
+	Filters       *ProjectFilter `json:"filters,omitempty"`
 }
 
 type AIAnalysis struct {
-	EstimatedSalary  string   `json:"estimated_salary"`
-	Strengths        string   `json:"strengths"`
-	Suggestions      string   `json:"suggestions"`
-	StructuredSkills []Skill  `json:"structured_skills"`
-	SearchPrompt     string   `json:"search_prompt"`
-	KeySkills        []string `json:"key_skills"`
-	PreferredRole    string   `json:"preferred_role"`
-	ExperienceLevel  string   `json:"experience_level"`
+	EstimatedSalary  string         `json:"estimated_salary"`
+	Strengths        string         `json:"strengths"`
+	Suggestions      string         `json:"suggestions"`
+	StructuredSkills []Skill        `json:"structured_skills"`
+	SearchPrompt     string         `json:"search_prompt"`
+	KeySkills        []string       `json:"key_skills"`
+	PreferredRole    string         `json:"preferred_role"`
+	ExperienceLevel  string         `json:"experience_level"`
+	Filters          *ProjectFilter `json:"filters,omitempty"`
+	ExcludedSkills   []string       `json:"excluded_skills,omitempty"`
+	ExcludedKeywords []string       `json:"excluded_keywords,omitempty"`
 }
 
 type Skill struct {
@@ -75,6 +93,7 @@ type AIChatMessage struct {
 }
 
 type AIChatResponse struct {
+	Model   string `json:"model"`
 	Choices []struct {
 		Message AIChatMessage `json:"message"`
 	} `json:"choices"`
@@ -143,6 +162,8 @@ func getRouter() *gin.Engine {
 		{
 			api.GET("/health", handleHealth)
 			api.POST("/chat", handleChat)
+			api.GET("/chat/stream", handleChatStream)
+			api.POST("/chat/stream", handleChatStream)
 			api.GET("/projects", getAllProjects)
 		}
 
@@ -173,20 +194,25 @@ func handleChat(c *gin.Context) {
 		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
 		return
 	}
+	if err := req.Filters.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: invalid filters: " + err.Error()})
+		return
+	}
 
-	aiAnalysis, err := analyzeSkills(req.Message)
+	aiAnalysis, promptVersion, err := analyzeSkills(req.Message)
 	if err != nil {
 		log.Printf("AI API error: %v", err)
-		errorMsg := err.Error()
-		if strings.Contains(errorMsg, "quota") || strings.Contains(errorMsg, "429") || strings.Contains(errorMsg, "insufficient_quota") {
-			c.JSON(500, gin.H{"error": "AI APIの利用上限に達しました。しばらく時間をおいてから再度お試しください。", "detail": errorMsg})
-		} else {
-			c.JSON(500, gin.H{"error": "AI分析に失敗しました。もう一度お試しください。", "detail": errorMsg})
+		body := aiErrorBody(err)
+		if retryAfter, ok := body["retry_after"].(int); ok {
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
 		}
+		c.JSON(aiErrorStatus(err), body)
 		return
 	}
 
-	projects, err := searchProjectsWithPriority(aiAnalysis.KeySkills, aiAnalysis.StructuredSkills)
+	// リクエストのfiltersを優先し、AIがメッセージから取り出した条件と合わせる
+	filter := mergeProjectFilters(req.Filters, analysisFilters(aiAnalysis, req.Message, time.Now()))
+	projects, err := searchProjectsWithPriority(aiAnalysis.KeySkills, aiAnalysis.StructuredSkills, filter)
 	if err != nil {
 		log.Printf("Database search error: %v", err)
 		c.JSON(500, gin.H{"error": "Database search failed: " + err.Error()})
@@ -194,24 +220,138 @@ func handleChat(c *gin.Context) {
 	}
 
 	c.JSON(200, ChatResponse{
-		AIAnalysis: aiAnalysis,
-		Projects:   projects,
+		AIAnalysis:    aiAnalysis,
+		Projects:      projects,
+		PromptVersion: promptVersion,
+		Filters:       filter,
 	})
 }
 
+func describeAIError(err error) string {
+	if errors.Is(err, errPromptNotFound) {
+		return "解析プロンプトが登録されていません。管理者にお問い合わせください。"
+	}
+	var aiErr *AIError
+	if !errors.As(err, &aiErr) {
+		return "AI分析に失敗しました。もう一度お試しください。"
+	}
+	switch aiErr.Kind {
+	case AIErrorQuota:
+		return "AI APIの利用上限に達しました。しばらく時間をおいてから再度お試しください。"
+	case AIErrorRateLimited:
+		return "AI APIへのリクエストが集中しています。少し待ってから再度お試しください。"
+	case AIErrorTimeout:
+		return "AI分析が時間内に終わりませんでした。もう一度お試しください。"
+	case AIErrorUnavailable:
+		return "AI APIに接続できません。しばらく時間をおいてから再度お試しください。"
+	default:
+		return "AI分析に失敗しました。もう一度お試しください。"
+	}
+}
+
+// SSE版。Vercel上ではAIの返答をまとめて受け取ってから、フィールドごとに送る
+// イベント: analyzing → analysis_field → project → done（失敗時は error）
+func handleChatStream(c *gin.Context) {
+	var req ChatRequest
+	if c.Request.Method == "GET" {
+		req.Message = c.Query("message")
+		if req.Message == "" {
+			c.JSON(400, gin.H{"error": "Invalid request: message is required"})
+			return
+		}
+		filters, err := parseFilterParam(c.Query("filters"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+			return
+		}
+		req.Filters = filters
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if err := req.Filters.Validate(); err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: invalid filters: " + err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	send := func(event string, data interface{}) {
+		c.SSEvent(event, data)
+		c.Writer.Flush()
+	}
+
+	send("analyzing", gin.H{"message": "AIがスキルを分析しています"})
+
+	aiAnalysis, promptVersion, err := analyzeSkills(req.Message)
+	if err != nil {
+		log.Printf("AI API error: %v", err)
+		// SSEはすでに200で始まっているので、本来のステータスはイベントの中で返す
+		event := aiErrorBody(err)
+		event["status"] = aiErrorStatus(err)
+		send("error", event)
+		return
+	}
+
+	fields := []struct {
+		name  string
+		value interface{}
+	}{
+		{"estimated_salary", aiAnalysis.EstimatedSalary},
+		{"strengths", aiAnalysis.Strengths},
+		{"suggestions", aiAnalysis.Suggestions},
+		{"structured_skills", aiAnalysis.StructuredSkills},
+		{"search_prompt", aiAnalysis.SearchPrompt},
+		{"key_skills", aiAnalysis.KeySkills},
+		{"preferred_role", aiAnalysis.PreferredRole},
+		{"experience_level", aiAnalysis.ExperienceLevel},
+	}
+	for _, f := range fields {
+		send("analysis_field", gin.H{"field": f.name, "value": f.value})
+	}
+
+	filter := mergeProjectFilters(req.Filters, analysisFilters(aiAnalysis, req.Message, time.Now()))
+	projects, err := searchProjectsWithPriority(aiAnalysis.KeySkills, aiAnalysis.StructuredSkills, filter)
+	if err != nil {
+		log.Printf("Database search error: %v", err)
+		send("error", gin.H{"error": "Database search failed: " + err.Error()})
+		return
+	}
+
+	for i, p := range projects {
+		send("project", gin.H{"rank": i + 1, "project": p})
+	}
+
+	send("done", ChatResponse{AIAnalysis: aiAnalysis, Projects: projects, PromptVersion: promptVersion, Filters: filter})
+}
+
 func getAllProjects(c *gin.Context) {
+	filter, err := filterFromQuery(c)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
 	database := getDB()
 	if database == nil {
 		c.JSON(500, gin.H{"error": "Database connection failed"})
 		return
 	}
 
+	where := ""
+	conditions, args := filter.sqlConditions(nil)
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
 	query := `
 		SELECT prourl, prottl, prodtl, proprc, proprd, proot1, proot2, prostn, procrt
 		FROM tbl_project
+		` + where + `
 		ORDER BY procrt DESC
 	`
-	rows, err := database.Query(query)
+	rows, err := database.Query(query, args...)
 	if err != nil {
 		log.Printf("Database query failed: %v", err)
 		c.JSON(500, gin.H{"error": "Database query failed"})
@@ -245,117 +385,1063 @@ func getAllProjects(c *gin.Context) {
 	c.JSON(200, AllProjectsResponse{Projects: projects, Total: len(projects)})
 }
 
+// =====================
+// 絞り込み条件
+// =====================
+
+// 案件の絞り込み条件（Backend/filter.goのProjectFilterと同じJSON）。指定のない項目では絞り込まない
+type ProjectFilter struct {
+	MinPrice       int      `json:"min_price,omitempty"`       // 単価の下限（円/月）
+	MaxPrice       int      `json:"max_price,omitempty"`       // 単価の上限（円/月）
+	WorkStyle      string   `json:"work_style,omitempty"`      // remote / onsite
+	Period         string   `json:"period,omitempty"`          // long / short
+	Sources        []string `json:"sources,omitempty"`         // このサイトの案件のみ（prostnの部分一致）
+	ExcludeSources []string `json:"exclude_sources,omitempty"` // このサイトの案件を除く
+	PostedSince    string   `json:"posted_since,omitempty"`    // この日以降に掲載された案件のみ（YYYY-MM-DD）
+
+	ExcludedSkills   []string `json:"excluded_skills,omitempty"`   // このスキルがある案件を除く
+	ExcludedKeywords []string `json:"excluded_keywords,omitempty"` // この語（部分一致）がある案件を除く
+}
+
+// 除外するスキル・キーワードの上限
+const maxExcludedTerms = 20
+
+// 案件の本文から働き方・契約期間を判定するパターン（Backendと同じ）
+const (
+	filterRemotePattern   = `リモート|在宅|テレワーク|remote`
+	filterNoRemotePattern = `リモート(不可|なし|無し|ng)|フル出社|常駐のみ`
+)
+
+var filterPeriodPatterns = map[string]string{
+	"long":  `長期|継続`,
+	"short": `短期|単発|スポット`,
+}
+
+// 掲載日（"直近3日" "3日以内" "ここ1週間"）。AIは日付を計算しないのでルールで読む
+var messagePostedPattern = regexp.MustCompile(`(?:直近|ここ)\s*(\d+)\s*(日|週間)|(\d+)\s*(日|週間)\s*以内`)
+
+func (f *ProjectFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	switch {
+	case f.MinPrice < 0 || f.MaxPrice < 0:
+		return errors.New("min_price and max_price must not be negative")
+	case f.MinPrice > 0 && f.MaxPrice > 0 && f.MinPrice > f.MaxPrice:
+		return errors.New("min_price must not be greater than max_price")
+	case f.WorkStyle != "" && f.WorkStyle != "remote" && f.WorkStyle != "onsite":
+		return fmt.Errorf("unknown work_style: %s (use remote or onsite)", f.WorkStyle)
+	case f.Period != "" && filterPeriodPatterns[f.Period] == "":
+		return fmt.Errorf("unknown period: %s (use long or short)", f.Period)
+	case len(f.ExcludedSkills) > maxExcludedTerms || len(f.ExcludedKeywords) > maxExcludedTerms:
+		return fmt.Errorf("excluded_skills and excluded_keywords must have at most %d items", maxExcludedTerms)
+	}
+	for _, keyword := range f.ExcludedKeywords {
+		if utf8.RuneCountInString(strings.TrimSpace(keyword)) < 2 {
+			return fmt.Errorf("excluded_keywords must be at least 2 characters: %q", keyword)
+		}
+	}
+	if f.PostedSince != "" {
+		if _, err := time.Parse("2006-01-02", f.PostedSince); err != nil {
+			return fmt.Errorf("posted_since must be YYYY-MM-DD: %s", f.PostedSince)
+		}
+	}
+	return nil
+}
+
+func (f *ProjectFilter) IsEmpty() bool {
+	return f == nil || (f.MinPrice == 0 && f.MaxPrice == 0 && f.WorkStyle == "" && f.Period == "" &&
+		len(f.Sources) == 0 && len(f.ExcludeSources) == 0 && f.PostedSince == "" &&
+		len(f.ExcludedSkills) == 0 && len(f.ExcludedKeywords) == 0)
+}
+
+// Backendと同じ合わせ方。単価・働き方・契約期間・掲載日・掲載サイトは前にある条件を優先し、除外の条件はすべて合わせる
+func mergeProjectFilters(filters ...*ProjectFilter) *ProjectFilter {
+	var merged ProjectFilter
+	for _, f := range filters {
+		if f == nil {
+			continue
+		}
+		if merged.MinPrice == 0 {
+			merged.MinPrice = f.MinPrice
+		}
+		if merged.MaxPrice == 0 {
+			merged.MaxPrice = f.MaxPrice
+		}
+		if merged.WorkStyle == "" {
+			merged.WorkStyle = f.WorkStyle
+		}
+		if merged.Period == "" {
+			merged.Period = f.Period
+		}
+		if merged.PostedSince == "" {
+			merged.PostedSince = f.PostedSince
+		}
+		if len(merged.Sources) == 0 {
+			merged.Sources = appendUniqueFold(nil, f.Sources...)
+		}
+		merged.ExcludeSources = appendUniqueFold(merged.ExcludeSources, f.ExcludeSources...)
+		merged.ExcludedSkills = appendUniqueFold(merged.ExcludedSkills, f.ExcludedSkills...)
+		merged.ExcludedKeywords = appendUniqueFold(merged.ExcludedKeywords, f.ExcludedKeywords...)
+	}
+	if len(merged.ExcludedSkills) > maxExcludedTerms {
+		merged.ExcludedSkills = merged.ExcludedSkills[:maxExcludedTerms]
+	}
+	if len(merged.ExcludedKeywords) > maxExcludedTerms {
+		merged.ExcludedKeywords = merged.ExcludedKeywords[:maxExcludedTerms]
+	}
+	if merged.IsEmpty() {
+		return nil
+	}
+	return &merged
+}
+
+func appendUniqueFold(values []string, additions ...string) []string {
+	for _, a := range additions {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		found := false
+		for _, v := range values {
+			if strings.EqualFold(v, a) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, a)
+		}
+	}
+	return values
+}
+
+// AIが返した条件（filters・excluded_skills・excluded_keywords）と、メッセージの掲載日の条件。AIの条件が不正なら使わない
+func analysisFilters(analysis AIAnalysis, message string, now time.Time) *ProjectFilter {
+	extracted := mergeProjectFilters(analysis.Filters, &ProjectFilter{
+		ExcludedSkills:   analysis.ExcludedSkills,
+		ExcludedKeywords: analysis.ExcludedKeywords,
+	})
+	if err := extracted.Validate(); err != nil {
+		log.Printf("Ignoring invalid filters from analysis: %v", err)
+		extracted = nil
+	}
+
+	var posted *ProjectFilter
+	if m := messagePostedPattern.FindStringSubmatch(message); m != nil {
+		number, unit := m[1], m[2]
+		if number == "" {
+			number, unit = m[3], m[4]
+		}
+		days, _ := strconv.Atoi(number)
+		if unit == "週間" {
+			days *= 7
+		}
+		posted = &ProjectFilter{PostedSince: now.AddDate(0, 0, -days).Format("2006-01-02")}
+	}
+	return mergeProjectFilters(extracted, posted)
+}
+
+// JSONの文字列で渡された絞り込み条件（GETのクエリ用。空ならnil）
+func parseFilterParam(raw string) (*ProjectFilter, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var f ProjectFilter
+	if err := json.Unmarshal([]byte(raw), &f); err != nil {
+		return nil, fmt.Errorf("invalid filters: %v", err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid filters: %v", err)
+	}
+	return &f, nil
+}
+
+// /api/projectsのクエリパラメーター（Backendと同じ名前。source・exclude_*はカンマ区切りか複数回指定）
+func filterFromQuery(c *gin.Context) (*ProjectFilter, error) {
+	var f ProjectFilter
+	for name, target := range map[string]*int{"min_price": &f.MinPrice, "max_price": &f.MaxPrice} {
+		if raw := c.Query(name); raw != "" {
+			value, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%s must be an integer (yen per month): %s", name, raw)
+			}
+			*target = value
+		}
+	}
+	f.WorkStyle = c.Query("work_style")
+	f.Period = c.Query("period")
+	f.PostedSince = c.Query("posted_since")
+	for name, target := range map[string]*[]string{
+		"source": &f.Sources, "exclude_source": &f.ExcludeSources,
+		"exclude_skill": &f.ExcludedSkills, "exclude_keyword": &f.ExcludedKeywords,
+	} {
+		for _, raw := range c.QueryArray(name) {
+			*target = appendUniqueFold(*target, strings.Split(raw, ",")...)
+		}
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if f.IsEmpty() {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+// SQLで絞り込む条件。プレースホルダーはargsの続きの番号を使う
+// 単価はBackendのマイグレーション9で作るproject_price_matches（単価の文字列を月額にして比べる）を使う
+func (f *ProjectFilter) sqlConditions(args []interface{}) ([]string, []interface{}) {
+	if f == nil {
+		return nil, args
+	}
+
+	var conditions []string
+	add := func(format string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, v := range values {
+			args = append(args, v)
+			placeholders[i] = len(args)
+		}
+		conditions = append(conditions, fmt.Sprintf(format, placeholders...))
+	}
+
+	const workText = "concat_ws(' ', prottl, prodtl, proprd, proot2)"
+	switch f.WorkStyle {
+	case "remote":
+		add("("+workText+" ~* $%d AND "+workText+" !~* $%d)", filterRemotePattern, filterNoRemotePattern)
+	case "onsite":
+		add("NOT ("+workText+" ~* $%d AND "+workText+" !~* $%d)", filterRemotePattern, filterNoRemotePattern)
+	}
+	if pattern := filterPeriodPatterns[f.Period]; pattern != "" {
+		add("concat_ws(' ', prottl, prodtl, proprd) ~* $%d", pattern)
+	}
+	if len(f.Sources) > 0 {
+		add("prostn ILIKE ANY($%d)", pq.Array(containsLikePatterns(f.Sources)))
+	}
+	if len(f.ExcludeSources) > 0 {
+		add("NOT (coalesce(prostn, '') ILIKE ANY($%d))", pq.Array(containsLikePatterns(f.ExcludeSources)))
+	}
+	if f.PostedSince != "" {
+		add("procrt >= $%d", f.PostedSince)
+	}
+	if f.MinPrice > 0 || f.MaxPrice > 0 {
+		add("project_price_matches(proprc, $%d, $%d)", f.MinPrice, f.MaxPrice)
+	}
+
+	const matchText = "concat_ws(' ', prottl, proot1, prodtl)"
+	if len(f.ExcludedSkills) > 0 {
+		var patterns []string
+		for _, skill := range f.ExcludedSkills {
+			patterns = append(patterns, skillPattern(skill))
+		}
+		add("NOT ("+matchText+" ~* $%d)", strings.Join(patterns, "|"))
+	}
+	if len(f.ExcludedKeywords) > 0 {
+		add("NOT ("+matchText+" ILIKE ANY($%d))", pq.Array(containsLikePatterns(f.ExcludedKeywords)))
+	}
+	return conditions, args
+}
+
+// スキル名の単語境界つきパターン（スキル辞書は持たないので、エイリアスには広げない）
+// 英数字で始まる/終わる名前は英数字に続いていないことを条件にする（"Go"が"Google"に当たらないように）
+func skillPattern(skill string) string {
+	name := strings.ToLower(strings.TrimSpace(skill))
+	pattern := regexp.QuoteMeta(name)
+	if first, _ := utf8.DecodeRuneInString(name); first < utf8.RuneSelf && (unicode.IsLetter(first) || unicode.IsDigit(first)) {
+		pattern = `(^|[^a-z0-9_])` + pattern
+	}
+	if last, _ := utf8.DecodeLastRuneInString(name); last < utf8.RuneSelf && (unicode.IsLetter(last) || unicode.IsDigit(last)) {
+		pattern += `([^a-z_]|$)`
+	}
+	return "(" + pattern + ")"
+}
+
+func containsLikePatterns(values []string) []string {
+	patterns := make([]string, len(values))
+	for i, s := range values {
+		patterns[i] = "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s) + "%"
+	}
+	return patterns
+}
+
 // =====================
 // AI分析
 // =====================
 
-func analyzeSkills(message string) (AIAnalysis, error) {
-	apiKey := os.Getenv("OPENROUTER_API_KEY")
-	if apiKey == "" {
-		return AIAnalysis{}, fmt.Errorf("OPENROUTER_API_KEY not set")
+func analyzeSkills(message string) (AIAnalysis, string, error) {
+	systemPrompt, promptVersion, err := loadAnalysisPrompt()
+	if err != nil {
+		return AIAnalysis{}, promptVersion, err
 	}
 
-	systemPrompt := `あなたはIT案件マッチングの専門家です。ユーザーのスキルシート情報を深く分析し、案件検索に最適なJSON形式で回答してください。
+	provider, err := newLLMProvider(loadLLMConfig())
+	if err != nil {
+		return AIAnalysis{}, promptVersion, err
+	}
 
-以下の形式でJSONを返してください（他の説明文は含めないでください）:
-{
-  "estimated_salary": "月額XX万円〜XX万円",
-  "strengths": "具体的な強みの説明",
-  "suggestions": "今後のキャリアアップの提案",
-  "structured_skills": [
-    {
-      "skill_name": "スキル名",
-      "experience_years": 年数
-    }
+	// スキーマ違反の返答は検証エラーを添えてAIに差し戻し、LLM_REPAIR_ATTEMPTS回まで修正させる（Backend/chat.goと同じ）
+	messages := []AIChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: message},
+	}
+	repairAttempts := envNonNegativeInt("LLM_REPAIR_ATTEMPTS", 2)
+	var validationErrors []string
+	for attempt := 0; attempt <= repairAttempts; attempt++ {
+		result, err := provider.Chat(context.Background(), messages)
+		if err != nil {
+			return AIAnalysis{}, promptVersion, err
+		}
+
+		analysis, errs := parseAIAnalysis(result.Content)
+		if len(errs) == 0 {
+			return analysis, promptVersion, nil
+		}
+
+		validationErrors = errs
+		log.Printf("AI response failed schema validation (attempt %d/%d): %v. Raw: %s", attempt+1, repairAttempts+1, errs, result.Content)
+		messages = append(messages,
+			AIChatMessage{Role: "assistant", Content: result.Content},
+			AIChatMessage{Role: "user", Content: buildRepairMessage(errs)},
+		)
+	}
+
+	return AIAnalysis{}, promptVersion, badAIResponse("failed to parse AI JSON after %d repair attempts: %s", repairAttempts, strings.Join(validationErrors, "; "))
+}
+
+// =====================
+// AI分析結果のスキーマ検証（Backend/schema.goと同じ契約）
+// =====================
+
+// AIAnalysisのJSON Schema。Backend/schema.goのaiAnalysisSchemaJSONと同じ内容にする（AIへの修正依頼にもそのまま渡す）
+const aiAnalysisSchemaJSON = `{
+  "type": "object",
+  "required": [
+    "estimated_salary", "strengths", "suggestions", "structured_skills",
+    "search_prompt", "key_skills", "preferred_role", "experience_level"
   ],
-  "search_prompt": "案件検索用の最適化されたプロンプト",
-  "key_skills": ["最も重要なスキル1", "最も重要なスキル2", "最も重要なスキル3"],
-  "preferred_role": "最適な役割（例：フロントエンドエンジニア、フルスタック開発者、など）",
-  "experience_level": "初級/中級/上級/エキスパート のいずれか"
-}
-
-重要:
-- 必ず有効なJSONのみを返してください。Markdownのコードブロック（` + "```" + `json など）は含めないでください。
-- すべてのフィールドを必ず含めてください。
-`
-
-	reqBody := AIChatRequest{
-		Model: "openai/gpt-3.5-turbo",
-		Messages: []AIChatMessage{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: message},
-		},
-		Temperature: 0.7,
+  "properties": {
+    "estimated_salary": {"type": "string"},
+    "strengths": {"type": "string"},
+    "suggestions": {"type": "string"},
+    "structured_skills": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["skill_name", "experience_years"],
+        "properties": {
+          "skill_name": {"type": "string"},
+          "experience_years": {"type": "number", "minimum": 0}
+        }
+      }
+    },
+    "search_prompt": {"type": "string"},
+    "key_skills": {"type": "array", "items": {"type": "string"}},
+    "preferred_role": {"type": "string"},
+    "experience_level": {"type": "string", "enum": ["初級", "中級", "上級", "エキスパート"]},
+    "excluded_skills": {"type": "array", "items": {"type": "string"}},
+    "excluded_keywords": {"type": "array", "items": {"type": "string"}},
+    "filters": {
+      "type": "object",
+      "properties": {
+        "min_price": {"type": "number", "minimum": 0},
+        "max_price": {"type": "number", "minimum": 0},
+        "work_style": {"type": "string", "enum": ["", "remote", "onsite"]},
+        "period": {"type": "string", "enum": ["", "long", "short"]},
+        "sources": {"type": "array", "items": {"type": "string"}},
+        "exclude_sources": {"type": "array", "items": {"type": "string"}},
+        "posted_since": {"type": "string"},
+        "excluded_skills": {"type": "array", "items": {"type": "string"}},
+        "excluded_keywords": {"type": "array", "items": {"type": "string"}}
+      }
+    }
+  }
+}`
+
+// JSON Schemaのうち、AIAnalysisの検証に必要なキーワードだけを扱う
+type jsonSchema struct {
+	Type       string                 `json:"type"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	Enum       []interface{}          `json:"enum"`
+	Minimum    *float64               `json:"minimum"`
+}
+
+var aiAnalysisSchema = mustParseSchema(aiAnalysisSchemaJSON)
+
+func mustParseSchema(raw string) *jsonSchema {
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		panic(fmt.Sprintf("invalid JSON schema: %v", err))
+	}
+	return &schema
+}
+
+// 値がスキーマを満たすか検証し、エラーの一覧を返す（pathはエラーメッセージ用のフィールドパス）
+func validateSchema(schema *jsonSchema, value interface{}, path string) []string {
+	var errs []string
+
+	if schema.Type != "" && !matchesSchemaType(schema.Type, value) {
+		return []string{fmt.Sprintf("%s: %s型である必要があります（実際: %s）", path, schema.Type, describeJSONType(value))}
+	}
+
+	if len(schema.Enum) > 0 && !isJSONContainer(value) {
+		found := false
+		for _, candidate := range schema.Enum {
+			if candidate == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			var options []string
+			for _, candidate := range schema.Enum {
+				options = append(options, fmt.Sprint(candidate))
+			}
+			errs = append(errs, fmt.Sprintf("%s: %s のいずれかである必要があります（実際: %v）", path, strings.Join(options, "/"), value))
+		}
+	}
+
+	if schema.Minimum != nil {
+		if number, ok := value.(float64); ok && number < *schema.Minimum {
+			errs = append(errs, fmt.Sprintf("%s: %v以上である必要があります（実際: %v）", path, *schema.Minimum, number))
+		}
 	}
 
-	jsonBody, err := json.Marshal(reqBody)
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for _, key := range schema.Required {
+			if _, ok := typed[key]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: 必須フィールド %s がありません", path, key))
+			}
+		}
+		keys := make([]string, 0, len(schema.Properties))
+		for key := range schema.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if child, ok := typed[key]; ok {
+				errs = append(errs, validateSchema(schema.Properties[key], child, path+"."+key)...)
+			}
+		}
+	case []interface{}:
+		if schema.Items != nil {
+			for i, item := range typed {
+				errs = append(errs, validateSchema(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+
+	return errs
+}
+
+func matchesSchemaType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == float64(int64(number))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	}
+	return true
+}
+
+func isJSONContainer(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+	return false
+}
+
+func describeJSONType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// AIの返答からJSON部分を取り出す（コードブロックや前後の説明文があっても最初の{から最後の}まで）
+func extractJSONText(responseText string) string {
+	cleanedText := strings.TrimSpace(responseText)
+	cleanedText = strings.TrimPrefix(cleanedText, "```json")
+	cleanedText = strings.TrimPrefix(cleanedText, "```")
+	cleanedText = strings.TrimSuffix(cleanedText, "```")
+	cleanedText = strings.TrimSpace(cleanedText)
+
+	start := strings.Index(cleanedText, "{")
+	end := strings.LastIndex(cleanedText, "}")
+	if start >= 0 && end > start {
+		return cleanedText[start : end+1]
+	}
+	return cleanedText
+}
+
+// AIの返答をパースしてスキーマ検証する。JSONとして不正な場合も検証エラーとして返す
+func parseAIAnalysis(responseText string) (AIAnalysis, []string) {
+	jsonText := extractJSONText(responseText)
+
+	var raw interface{}
+	if err := json.Unmarshal([]byte(jsonText), &raw); err != nil {
+		return AIAnalysis{}, []string{fmt.Sprintf("有効なJSONではありません: %v", err)}
+	}
+
+	if errs := validateSchema(aiAnalysisSchema, raw, "$"); len(errs) > 0 {
+		return AIAnalysis{}, errs
+	}
+
+	var analysis AIAnalysis
+	if err := json.Unmarshal([]byte(jsonText), &analysis); err != nil {
+		return AIAnalysis{}, []string{fmt.Sprintf("AIAnalysisに変換できません: %v", err)}
+	}
+
+	return analysis, nil
+}
+
+// スキーマ違反をAIに差し戻すための修正依頼メッセージ
+func buildRepairMessage(errs []string) string {
+	var sb strings.Builder
+	sb.WriteString("前回の回答は次の理由でスキーマに適合しませんでした。\n")
+	for _, e := range errs {
+		sb.WriteString("- " + e + "\n")
+	}
+	sb.WriteString("\n以下のJSON Schemaを満たすように修正し、JSONのみを返してください。\n")
+	sb.WriteString(aiAnalysisSchemaJSON)
+	return sb.String()
+}
+
+// =====================
+// プロンプトテンプレート
+// =====================
+
+// PROMPT_VERSIONSで指定がないときに使う解析プロンプトのバージョン（Backend/prompt.goと同じ）
+// 文言はBackend/prompts/analysis/<バージョン>.tmplだけに置き、Backendが起動時にtbl_promptへ登録したものを読む
+const defaultAnalysisPromptVersion = "v3"
+
+// tbl_promptに使用中のバージョンの行がない
+var errPromptNotFound = errors.New("prompt not found")
+
+// 使用するバージョン。PROMPT_VERSIONS（"analysis=v2,rerank=v1"）はBackendと同じ書式
+func activePromptVersion(name, defaultVersion string) string {
+	for _, pair := range strings.Split(os.Getenv("PROMPT_VERSIONS"), ",") {
+		key, version, ok := strings.Cut(pair, "=")
+		if ok && strings.TrimSpace(key) == name && strings.TrimSpace(version) != "" {
+			return strings.TrimSpace(version)
+		}
+	}
+	return defaultVersion
+}
+
+// 解析プロンプトをtbl_promptから読み込む。行がなければエラー（文言の写しは持たない）
+func loadAnalysisPrompt() (string, string, error) {
+	version := activePromptVersion("analysis", defaultAnalysisPromptVersion)
+	conn := getDB()
+	if conn == nil {
+		return "", version, fmt.Errorf("failed to load prompt analysis/%s: database connection failed", version)
+	}
+
+	var text string
+	err := conn.QueryRow("SELECT prmtxt FROM tbl_prompt WHERE prmnam = $1 AND prmver = $2", "analysis", version).Scan(&text)
+	if err == sql.ErrNoRows {
+		return "", version, fmt.Errorf("%w: analysis/%s is not in tbl_prompt", errPromptNotFound, version)
+	}
+	if err != nil {
+		return "", version, fmt.Errorf("failed to load prompt analysis/%s: %v", version, err)
+	}
+
+	tmpl, err := template.New("analysis/" + version).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", version, fmt.Errorf("invalid prompt analysis/%s: %v", version, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return "", version, fmt.Errorf("failed to render prompt analysis/%s: %v", version, err)
+	}
+	return buf.String(), version, nil
+}
+
+// =====================
+// LLMプロバイダー
+// =====================
+
+type LLMConfig struct {
+	Provider    string
+	Model       string
+	Temperature float64
+	BaseURL     string
+	APIKey      string
+}
+
+type LLMResult struct {
+	Content string
+	Model   string
+}
+
+type LLMProvider interface {
+	Name() string
+	Model() string
+	Chat(ctx context.Context, messages []AIChatMessage) (LLMResult, error)
+}
+
+func getEnvWithDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func loadLLMConfig() LLMConfig {
+	provider := strings.ToLower(getEnvWithDefault("LLM_PROVIDER", "openrouter"))
+	temperature, err := strconv.ParseFloat(getEnvWithDefault("LLM_TEMPERATURE", "0.7"), 64)
+	if err != nil {
+		temperature = 0.7
+	}
+
+	config := LLMConfig{
+		Provider:    provider,
+		Model:       os.Getenv("LLM_MODEL"),
+		Temperature: temperature,
+		BaseURL:     os.Getenv("LLM_BASE_URL"),
+		APIKey:      os.Getenv("LLM_API_KEY"),
+	}
+
+	switch provider {
+	case "openrouter":
+		config.Model = firstNonEmpty(config.Model, "openai/gpt-3.5-turbo")
+		config.BaseURL = firstNonEmpty(config.BaseURL, "https://openrouter.ai/api/v1")
+		config.APIKey = firstNonEmpty(config.APIKey, os.Getenv("OPENROUTER_API_KEY"))
+	case "gemini":
+		config.Model = firstNonEmpty(config.Model, "gemini-1.5-flash")
+		config.BaseURL = firstNonEmpty(config.BaseURL, "https://generativelanguage.googleapis.com/v1beta")
+		config.APIKey = firstNonEmpty(config.APIKey, os.Getenv("GEMINI_API_KEY"))
+	case "mock":
+		config.Model = firstNonEmpty(config.Model, "mock")
+	}
+	return config
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func newLLMProvider(config LLMConfig) (LLMProvider, error) {
+	switch config.Provider {
+	case "openrouter":
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("OPENROUTER_API_KEY not set")
+		}
+		return &openAICompatibleProvider{name: "openrouter", config: config}, nil
+	case "openai":
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("LLM_BASE_URL not set")
+		}
+		if config.Model == "" {
+			return nil, fmt.Errorf("LLM_MODEL not set")
+		}
+		return &openAICompatibleProvider{name: "openai", config: config}, nil
+	case "gemini":
+		if config.APIKey == "" {
+			return nil, fmt.Errorf("GEMINI_API_KEY not set")
+		}
+		return &geminiProvider{config: config}, nil
+	case "mock":
+		return &mockProvider{response: firstNonEmpty(os.Getenv("LLM_MOCK_RESPONSE"), defaultMockResponse)}, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", config.Provider)
+	}
+}
+
+// =====================
+// AI APIの呼び出し（Backend/aiclient.goと同じタイムアウト・再試行・エラーの種類）
+// Vercelでは呼び出しごとにプロセスが変わりうるので、サーキットブレーカーは持たない
+// =====================
+
+const (
+	AIErrorQuota       = "ai_quota_exceeded" // 利用上限・残高不足（待っても回復しない）
+	AIErrorRateLimited = "ai_rate_limited"   // 短時間に呼びすぎ（待てば回復する）
+	AIErrorTimeout     = "ai_timeout"        // 応答が時間内に返らなかった
+	AIErrorUnavailable = "ai_unavailable"    // 5xx・通信エラー
+	AIErrorBadResponse = "ai_bad_response"   // 応答を読み取れない・リクエストが受け付けられない
+)
+
+type AIError struct {
+	Kind       string
+	StatusCode int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *AIError) Error() string { return e.Err.Error() }
+func (e *AIError) Unwrap() error { return e.Err }
+
+// 全体のタイムアウトは呼び出しごとのcontextで決めるので、ここでは接続までのタイムアウトだけ設定する
+var aiHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
+
+func envDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnvWithDefault(key, defaultValue.String()))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func envNonNegativeInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(getEnvWithDefault(key, strconv.Itoa(defaultValue)))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// JSONをPOSTして200のボディを返す。一時的なエラー（429・5xx・通信エラー・タイムアウト）は再試行する
+// AI_TIMEOUT・AI_MAX_RETRIES・AI_RETRY_BASE_DELAY・AI_RETRY_MAX_DELAYはBackendと同じ
+func postJSON(ctx context.Context, url string, headers map[string]string, payload interface{}) ([]byte, error) {
+	jsonBody, err := json.Marshal(payload)
 	if err != nil {
-		return AIAnalysis{}, fmt.Errorf("failed to marshal request: %v", err)
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewBuffer(jsonBody))
+	timeout := envDuration("AI_TIMEOUT", 30*time.Second)
+	maxRetries := envNonNegativeInt("AI_MAX_RETRIES", 2)
+	baseDelay := envDuration("AI_RETRY_BASE_DELAY", 500*time.Millisecond)
+	maxDelay := envDuration("AI_RETRY_MAX_DELAY", 10*time.Second)
+
+	for attempt := 0; ; attempt++ {
+		body, aiErr := postJSONOnce(ctx, timeout, url, headers, jsonBody)
+		if aiErr == nil {
+			return body, nil
+		}
+
+		// 呼び出し元がキャンセルした・待っても回復しない・回数を使い切った・Retry-Afterが長すぎる場合は返す
+		retryable := aiErr.Kind == AIErrorRateLimited || aiErr.Kind == AIErrorTimeout || aiErr.Kind == AIErrorUnavailable
+		if ctx.Err() != nil || !retryable || attempt >= maxRetries || aiErr.RetryAfter > maxDelay {
+			return nil, aiErr
+		}
+		wait := baseDelay << attempt
+		wait += time.Duration((rand.Float64()*0.4 - 0.2) * float64(wait))
+		if wait > maxDelay {
+			wait = maxDelay
+		}
+		if wait < aiErr.RetryAfter {
+			wait = aiErr.RetryAfter
+		}
+		log.Printf("AI API call failed, retrying in %v (%d/%d): %v", wait, attempt+1, maxRetries, aiErr)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, aiErr
+		}
+	}
+}
+
+func postJSONOnce(ctx context.Context, timeout time.Duration, url string, headers map[string]string, jsonBody []byte) ([]byte, *AIError) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBody))
 	if err != nil {
-		return AIAnalysis{}, fmt.Errorf("failed to create request: %v", err)
+		return nil, &AIError{Kind: AIErrorBadResponse, Err: fmt.Errorf("failed to create request: %v", err)}
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := aiHTTPClient.Do(req)
 	if err != nil {
-		return AIAnalysis{}, fmt.Errorf("AI API call failed: %v", err)
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			return nil, &AIError{Kind: AIErrorTimeout, Err: fmt.Errorf("AI API call timed out: %v", err)}
+		}
+		return nil, &AIError{Kind: AIErrorUnavailable, Err: fmt.Errorf("AI API call failed: %v", err)}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return AIAnalysis{}, fmt.Errorf("failed to read response body: %v", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, &AIError{Kind: AIErrorTimeout, Err: fmt.Errorf("AI API call timed out: %v", err)}
+		}
+		return nil, &AIError{Kind: AIErrorUnavailable, Err: fmt.Errorf("failed to read response body: %v", err)}
 	}
-
 	if resp.StatusCode != 200 {
-		return AIAnalysis{}, fmt.Errorf("AI API error: status %d, body: %s", resp.StatusCode, string(body))
+		return nil, classifyAIStatus(resp.StatusCode, resp.Header.Get("Retry-After"), string(body))
+	}
+	return body, nil
+}
+
+// 200以外のステータスをエラーの種類に振り分ける
+func classifyAIStatus(status int, retryAfter, body string) *AIError {
+	aiErr := &AIError{
+		StatusCode: status,
+		RetryAfter: parseRetryAfter(retryAfter, time.Now()),
+		Err:        fmt.Errorf("AI API error: status %d, body: %s", status, body),
+	}
+	lower := strings.ToLower(body)
+	switch {
+	case status == 402 || (status == 429 && strings.Contains(lower, "quota") && !strings.Contains(lower, "per minute") && !strings.Contains(lower, "perminute")):
+		// OpenRouterの残高不足は402、OpenAIの枠切れは429のinsufficient_quota
+		// Geminiは1分あたりの上限でもquotaと返すので、それはレート制限として扱う
+		aiErr.Kind = AIErrorQuota
+	case status == 429:
+		aiErr.Kind = AIErrorRateLimited
+	case status == 408 || status == 504:
+		aiErr.Kind = AIErrorTimeout
+	case status >= 500:
+		aiErr.Kind = AIErrorUnavailable
+	default:
+		aiErr.Kind = AIErrorBadResponse
+	}
+	return aiErr
+}
+
+// Retry-Afterヘッダーを読む（秒数とHTTP日付の両方に対応、読めなければ0）
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// 応答を読み取れなかったときのエラー
+func badAIResponse(format string, args ...interface{}) error {
+	return &AIError{Kind: AIErrorBadResponse, Err: fmt.Errorf(format, args...)}
+}
+
+// AIのエラーをHTTPステータスにする（利用上限・レート制限は429、停止中は503、タイムアウトは504、不正な応答は502）
+func aiErrorStatus(err error) int {
+	var aiErr *AIError
+	if !errors.As(err, &aiErr) {
+		return 500
+	}
+	switch aiErr.Kind {
+	case AIErrorQuota, AIErrorRateLimited:
+		return 429
+	case AIErrorUnavailable:
+		return 503
+	case AIErrorTimeout:
+		return 504
+	case AIErrorBadResponse:
+		return 502
+	default:
+		return 500
+	}
+}
+
+// Retry-Afterに入れる秒数（切り上げ）
+func retryAfterSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// AIのエラーのレスポンス（SSEではイベントの中身）。待てば回復するエラーにはretry_afterを付ける
+func aiErrorBody(err error) gin.H {
+	body := gin.H{"error": describeAIError(err), "detail": err.Error()}
+	var aiErr *AIError
+	if errors.As(err, &aiErr) {
+		body["code"] = aiErr.Kind
+		if aiErr.RetryAfter > 0 {
+			body["retry_after"] = retryAfterSeconds(aiErr.RetryAfter)
+		}
+	}
+	return body
+}
+
+// OpenRouter / Ollama / vLLM
+type openAICompatibleProvider struct {
+	name   string
+	config LLMConfig
+}
+
+func (p *openAICompatibleProvider) Name() string  { return p.name }
+func (p *openAICompatibleProvider) Model() string { return p.config.Model }
+
+func (p *openAICompatibleProvider) Chat(ctx context.Context, messages []AIChatMessage) (LLMResult, error) {
+	headers := map[string]string{}
+	if p.config.APIKey != "" {
+		headers["Authorization"] = "Bearer " + p.config.APIKey
+	}
+
+	url := strings.TrimSuffix(p.config.BaseURL, "/") + "/chat/completions"
+	body, err := postJSON(ctx, url, headers, AIChatRequest{
+		Model:       p.config.Model,
+		Messages:    messages,
+		Temperature: p.config.Temperature,
+	})
+	if err != nil {
+		return LLMResult{}, err
 	}
 
 	var chatResp AIChatResponse
 	if err := json.Unmarshal(body, &chatResp); err != nil {
-		return AIAnalysis{}, fmt.Errorf("failed to parse AI response: %v", err)
+		return LLMResult{}, badAIResponse("failed to parse AI response: %v", err)
 	}
-
 	if len(chatResp.Choices) == 0 {
-		return AIAnalysis{}, fmt.Errorf("empty choices from AI")
+		return LLMResult{}, badAIResponse("empty choices from AI")
 	}
+	return LLMResult{Content: chatResp.Choices[0].Message.Content, Model: firstNonEmpty(chatResp.Model, p.config.Model)}, nil
+}
 
-	responseText := chatResp.Choices[0].Message.Content
-	cleanedText := strings.TrimSpace(responseText)
-	cleanedText = strings.TrimPrefix(cleanedText, "```json")
-	cleanedText = strings.TrimPrefix(cleanedText, "```")
-	cleanedText = strings.TrimSuffix(cleanedText, "```")
-	cleanedText = strings.TrimSpace(cleanedText)
+// Gemini
+type geminiPart struct {
+	Text string `json:"text"`
+}
 
-	var analysis AIAnalysis
-	if err := json.Unmarshal([]byte(cleanedText), &analysis); err != nil {
-		log.Printf("Failed to parse AI response. Raw: %s", responseText)
-		return AIAnalysis{}, fmt.Errorf("failed to parse AI JSON: %v", err)
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiProvider struct {
+	config LLMConfig
+}
+
+func (p *geminiProvider) Name() string  { return "gemini" }
+func (p *geminiProvider) Model() string { return p.config.Model }
+
+func (p *geminiProvider) Chat(ctx context.Context, messages []AIChatMessage) (LLMResult, error) {
+	reqBody := map[string]interface{}{
+		"generationConfig": map[string]interface{}{
+			"temperature":      p.config.Temperature,
+			"responseMimeType": "application/json",
+		},
+	}
+	var contents []geminiContent
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			reqBody["systemInstruction"] = geminiContent{Parts: []geminiPart{{Text: m.Content}}}
+		case "assistant":
+			contents = append(contents, geminiContent{Role: "model", Parts: []geminiPart{{Text: m.Content}}})
+		default:
+			contents = append(contents, geminiContent{Role: "user", Parts: []geminiPart{{Text: m.Content}}})
+		}
 	}
+	reqBody["contents"] = contents
 
-	return analysis, nil
+	url := fmt.Sprintf("%s/models/%s:generateContent", strings.TrimSuffix(p.config.BaseURL, "/"), p.config.Model)
+	body, err := postJSON(ctx, url, map[string]string{"x-goog-api-key": p.config.APIKey}, reqBody)
+	if err != nil {
+		return LLMResult{}, err
+	}
+
+	var geminiResp struct {
+		Candidates []struct {
+			Content geminiContent `json:"content"`
+		} `json:"candidates"`
+		ModelVersion string `json:"modelVersion"`
+	}
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return LLMResult{}, badAIResponse("failed to parse AI response: %v", err)
+	}
+	if len(geminiResp.Candidates) == 0 {
+		return LLMResult{}, badAIResponse("empty candidates from AI")
+	}
+
+	var text strings.Builder
+	for _, part := range geminiResp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+	}
+	return LLMResult{Content: text.String(), Model: firstNonEmpty(geminiResp.ModelVersion, p.config.Model)}, nil
+}
+
+// モック（APIキーなしの動作確認用）
+const defaultMockResponse = `{
+  "estimated_salary": "月額60万円〜80万円",
+  "strengths": "バックエンド開発の経験が豊富",
+  "suggestions": "クラウド関連の資格取得を推奨",
+  "structured_skills": [{"skill_name": "Java", "experience_years": 3}],
+  "search_prompt": "Java バックエンド開発",
+  "key_skills": ["Java"],
+  "preferred_role": "バックエンドエンジニア",
+  "experience_level": "中級"
+}`
+
+type mockProvider struct {
+	response string
+}
+
+func (p *mockProvider) Name() string  { return "mock" }
+func (p *mockProvider) Model() string { return "mock" }
+
+func (p *mockProvider) Chat(ctx context.Context, messages []AIChatMessage) (LLMResult, error) {
+	return LLMResult{Content: p.response, Model: "mock"}, nil
 }
 
 // =====================
 // 案件検索
 // =====================
 
-func searchProjectsWithPriority(keySkills []string, allSkills []Skill) ([]Project, error) {
+func searchProjectsWithPriority(keySkills []string, allSkills []Skill, filter *ProjectFilter) ([]Project, error) {
 	if len(keySkills) == 0 && len(allSkills) == 0 {
 		return []Project{}, nil
 	}
 
+	// 除外したスキルでは検索しない
+	excluded := map[string]bool{}
+	if filter != nil {
+		for _, skill := range filter.ExcludedSkills {
+			excluded[strings.ToLower(strings.TrimSpace(skill))] = true
+		}
+	}
 	var primarySkills []string
-	for i, skill := range keySkills {
-		if i >= 3 {
+	for _, skill := range keySkills {
+		if len(primarySkills) >= 3 {
 			break
 		}
-		primarySkills = append(primarySkills, skill)
+		if !excluded[strings.ToLower(strings.TrimSpace(skill))] {
+			primarySkills = append(primarySkills, skill)
+		}
 	}
 
 	if len(primarySkills) == 0 {
@@ -394,7 +1480,11 @@ func searchProjectsWithPriority(keySkills []string, allSkills []Skill) ([]Projec
 			baseIndex+1, baseIndex+2, baseIndex+3)
 		whereConditions = append(whereConditions, whereCondition)
 	}
-	whereClause := strings.Join(whereConditions, " OR ")
+	whereClause := "(" + strings.Join(whereConditions, " OR ") + ")"
+	filterConditions, args := filter.sqlConditions(args)
+	for _, condition := range filterConditions {
+		whereClause += " AND " + condition
+	}
 
 	query := fmt.Sprintf(`
 		WITH scored_projects AS (
@@ -1,6 +1,7 @@
 module github.com/ikdrn/anken_match
 
-go 1.22
+// golang.org/x/net v0.38.0・x/crypto v0.36.0などの依存がgo 1.23.0以上を要求するため（1.22のままだとgo.modの更新が必要になりビルドできない）
+go 1.23.0
 
 require (
 	github.com/gin-contrib/cors v1.7.3